@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/auth"
+	"net/http"
+	"time"
+)
+
+func (s *server) loginHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	user, err := s.store.Users().GetByUsername(r.Context(), req.Username)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	if !auth.CheckPasswordHash(req.Password, user.PasswordHash) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	tokens, err := s.createSession(r.Context(), user)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.LoginResponse{AuthTokens: tokens, User: user})
+}
+
+// refreshHandler exchanges a refresh token for a new access token. The
+// refresh token is rotated: the one presented stops working immediately.
+func (s *server) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+
+	sessionID, user, err := s.store.Sessions().Rotate(
+		r.Context(), auth.HashRefreshToken(req.RefreshToken), refreshHash, time.Now().Add(auth.RefreshTokenTTL),
+	)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to refresh session")
+		return
+	}
+
+	token, err := auth.GenerateJWT(user.ID, user.Role, sessionID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.AuthTokens{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+	})
+}
+
+// logoutHandler revokes the session of the access token used for the call.
+func (s *server) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	sessionID, _ := auth.SessionID(r)
+
+	if err := s.store.Sessions().Revoke(r.Context(), sessionID); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to log out")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) registerHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.RegisterUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+
+	// Default role is 'vendedor'
+	user := models.User{
+		Name:         req.Name,
+		Username:     req.Username,
+		PasswordHash: hashedPassword,
+		Role:         "vendedor",
+	}
+	err = s.store.Users().Create(r.Context(), &user)
+	if errors.Is(err, repository.ErrConflict) {
+		respondWithError(w, http.StatusConflict, "Username is already taken")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, user)
+}
+
+// --- Session Helpers ---
+
+// createSession stores a new session for the user and issues its tokens.
+func (s *server) createSession(ctx context.Context, user models.User) (models.AuthTokens, error) {
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		return models.AuthTokens{}, err
+	}
+
+	sessionID, err := s.store.Sessions().Create(ctx, user.ID, refreshHash, time.Now().Add(auth.RefreshTokenTTL))
+	if err != nil {
+		return models.AuthTokens{}, err
+	}
+
+	token, err := auth.GenerateJWT(user.ID, user.Role, sessionID)
+	if err != nil {
+		return models.AuthTokens{}, err
+	}
+
+	return models.AuthTokens{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+	}, nil
+}
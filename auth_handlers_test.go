@@ -0,0 +1,55 @@
+package main
+
+import (
+	"gestor-simples-ecs/internal/models"
+	"net/http"
+	"testing"
+)
+
+func TestLogin(t *testing.T) {
+	env := newTestEnv(t)
+	env.createUser("maria", "vendedor")
+
+	rec := env.do("POST", "/api/v1/auth/login", "", models.LoginRequest{Username: "maria", Password: "wrong"})
+	expectStatus(t, rec, http.StatusUnauthorized)
+
+	rec = env.do("POST", "/api/v1/auth/login", "", models.LoginRequest{Username: "maria", Password: testPassword})
+	expectStatus(t, rec, http.StatusOK)
+	login := decode[models.LoginResponse](t, rec)
+	if login.Token == "" || login.RefreshToken == "" || login.User.Username != "maria" {
+		t.Fatalf("unexpected login response: %+v", login)
+	}
+
+	rec = env.do("GET", "/api/v1/users/"+itoa(login.User.ID), login.Token, nil)
+	expectStatus(t, rec, http.StatusOK)
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	env := newTestEnv(t)
+	env.createUser("maria", "vendedor")
+
+	rec := env.do("POST", "/api/v1/auth/login", "", models.LoginRequest{Username: "maria", Password: testPassword})
+	login := decode[models.LoginResponse](t, rec)
+
+	rec = env.do("POST", "/api/v1/auth/refresh", "", models.RefreshRequest{RefreshToken: login.RefreshToken})
+	expectStatus(t, rec, http.StatusOK)
+	refreshed := decode[models.AuthTokens](t, rec)
+	if refreshed.RefreshToken == login.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	rec = env.do("POST", "/api/v1/auth/refresh", "", models.RefreshRequest{RefreshToken: login.RefreshToken})
+	expectStatus(t, rec, http.StatusUnauthorized)
+
+	rec = env.do("POST", "/api/v1/auth/refresh", "", models.RefreshRequest{RefreshToken: refreshed.RefreshToken})
+	expectStatus(t, rec, http.StatusOK)
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.createUser("maria", "vendedor")
+	token := env.token(user)
+
+	expectStatus(t, env.do("POST", "/api/v1/auth/logout", token, nil), http.StatusNoContent)
+	expectStatus(t, env.do("GET", "/api/v1/users/"+itoa(user.ID), token, nil), http.StatusUnauthorized)
+}
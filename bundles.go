@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/money"
+	"net/http"
+)
+
+// --- Bundle Handlers ---
+//
+// A bundle is a product sold as a kit of other products, its components. It
+// has no stock of its own: selling one takes its components out of stock in
+// the sale's transaction, and the bundles available are as many as the
+// components' available stock makes. Bundles don't nest, and neither
+// bundles nor their components have variants.
+
+// bundleAvailable returns how many bundles the components' available stock
+// makes.
+func bundleAvailable(components []models.BundleComponent) int {
+	available := -1
+	for _, c := range components {
+		if n := max(c.Available, 0) / c.Quantity; available < 0 || n < available {
+			available = n
+		}
+	}
+	return max(available, 0)
+}
+
+// withComponents fills in the components of the bundles among products and
+// sets their stock to what the components make.
+func withComponents(ctx context.Context, bundles repository.BundleRepository, products []models.Product) error {
+	ids := make([]int64, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+	components, err := bundles.List(ctx, ids...)
+	if err != nil {
+		return err
+	}
+	for i := range products {
+		p := &products[i]
+		for _, c := range components {
+			if c.BundleID == p.ID {
+				p.Components = append(p.Components, c)
+			}
+		}
+		if len(p.Components) > 0 {
+			p.Quantity = bundleAvailable(p.Components)
+			p.Reserved, p.Available = 0, p.Quantity
+		}
+	}
+	return nil
+}
+
+// isBundle reports whether the product has components.
+func isBundle(ctx context.Context, tx repository.Store, productID int64) (bool, error) {
+	components, err := tx.Bundles().List(ctx, productID)
+	return len(components) > 0, err
+}
+
+// rejectBundle returns a 400 naming what bundles don't support if product
+// is one.
+func rejectBundle(ctx context.Context, tx repository.Store, product models.Product, unsupported string) error {
+	bundle, err := isBundle(ctx, tx, product.ID)
+	if err != nil {
+		return newAPIError(http.StatusInternalServerError, "Failed to load bundle components")
+	}
+	if bundle {
+		return newAPIError(http.StatusBadRequest, fmt.Sprintf("%q is a bundle; %s", product.Name, unsupported))
+	}
+	return nil
+}
+
+// setProductComponentsHandler replaces the bill of materials of a product,
+// turning it into a bundle, or back into a plain product with an empty list.
+func (s *server) setProductComponentsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	var components []models.BundleComponent
+	if err := json.NewDecoder(r.Body).Decode(&components); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	seen := map[int64]bool{}
+	for _, c := range components {
+		if c.Quantity <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Component quantities must be positive")
+			return
+		}
+		if c.ProductID == id || seen[c.ProductID] {
+			respondWithError(w, http.StatusBadRequest, "A bundle cannot contain itself or the same product twice")
+			return
+		}
+		seen[c.ProductID] = true
+	}
+
+	var product models.Product
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		product, err = tx.Products().GetForUpdate(r.Context(), id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusNotFound, "Product not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product")
+		}
+
+		if len(components) > 0 {
+			if product.Quantity != 0 {
+				return newAPIError(http.StatusConflict, "Product has stock of its own; bring its quantity to zero before making it a bundle")
+			}
+			if err := checkBundleProduct(r.Context(), tx, product); err != nil {
+				return err
+			}
+			if err := rejectLots(r.Context(), tx, product, "bundles have no stock of their own"); err != nil {
+				return err
+			}
+			bundles, err := tx.Bundles().ContainedIn(r.Context(), id)
+			if err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to load bundles")
+			}
+			if len(bundles) > 0 {
+				return newAPIError(http.StatusBadRequest, "Product goes into another bundle; bundles cannot be nested")
+			}
+		}
+		for _, c := range components {
+			component, err := tx.Products().Get(r.Context(), c.ProductID)
+			if errors.Is(err, repository.ErrNotFound) {
+				return newAPIError(http.StatusBadRequest, fmt.Sprintf("Component product %d not found", c.ProductID))
+			}
+			if err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to load product")
+			}
+			if err := checkBundleProduct(r.Context(), tx, component); err != nil {
+				return err
+			}
+			if err := rejectBundle(r.Context(), tx, component, "bundles cannot be nested"); err != nil {
+				return err
+			}
+		}
+
+		err = tx.Bundles().Set(r.Context(), id, components)
+		if errors.Is(err, repository.ErrInUse) {
+			return newAPIError(http.StatusBadRequest, "Component product not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to save bundle components")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	products := []models.Product{product}
+	if err := withComponents(r.Context(), s.store.Bundles(), products); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load bundle components")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, products[0])
+}
+
+// checkBundleProduct rejects products with variants as bundles or
+// components.
+func checkBundleProduct(ctx context.Context, tx repository.Store, product models.Product) error {
+	found, err := hasVariants(ctx, tx, product.ID)
+	if err != nil {
+		return newAPIError(http.StatusInternalServerError, "Failed to load product variants")
+	}
+	if found {
+		return newAPIError(http.StatusBadRequest, fmt.Sprintf("%q has variants, which bundles and their components cannot have", product.Name))
+	}
+	return nil
+}
+
+// chargeBundle takes the components of item's bundles out of stock and
+// returns the bundle, costed at its components, with what each bundle
+// consumed. The components' Revenue holds their list price per bundle
+// until attributeRevenue splits the line's value by it.
+func chargeBundle(ctx context.Context, tx repository.Store, item models.CreateSaleItem, components []models.BundleComponent) (models.Product, []models.SaleItemComponent, error) {
+	bundle, err := tx.Products().Get(ctx, item.ProductID)
+	if err != nil {
+		return bundle, nil, newAPIError(http.StatusInternalServerError, "Failed to load product")
+	}
+	if item.VariantID != nil {
+		return bundle, nil, newAPIError(http.StatusBadRequest, fmt.Sprintf("Bundle %q has no variants", bundle.Name))
+	}
+
+	bundle.CostPrice = 0
+	consumed := make([]models.SaleItemComponent, len(components))
+	for i, c := range components {
+		p, err := tx.Products().DecrementStock(ctx, c.ProductID, c.Quantity*item.Quantity)
+		if errors.Is(err, repository.ErrInsufficientStock) {
+			return bundle, nil, newAPIError(http.StatusBadRequest, fmt.Sprintf("Insufficient available stock of %s for bundle %s", c.ProductName, bundle.Name))
+		}
+		if err != nil {
+			return bundle, nil, newAPIError(http.StatusInternalServerError, "Failed to update product stock")
+		}
+		if err := checkBundleProduct(ctx, tx, p); err != nil {
+			return bundle, nil, err
+		}
+		bundle.CostPrice += p.CostPrice.Mul(c.Quantity)
+		consumed[i] = models.SaleItemComponent{
+			ProductID:   p.ID,
+			ProductName: p.Name,
+			Quantity:    c.Quantity,
+			UnitCost:    p.CostPrice,
+			Revenue:     p.Price.Mul(c.Quantity),
+		}
+	}
+	return bundle, consumed, nil
+}
+
+// attributeRevenue splits the discounted value of a bundle line among its
+// components in proportion to their list prices, or their quantities if
+// none has a price.
+func attributeRevenue(line *models.SaleItem) {
+	weights := make([]money.Amount, len(line.Components))
+	var total money.Amount
+	for i, c := range line.Components {
+		weights[i] = c.Revenue
+		total += c.Revenue
+	}
+	if total == 0 {
+		for i, c := range line.Components {
+			weights[i] = money.FromCents(int64(c.Quantity))
+		}
+	}
+	net := line.UnitPrice.Mul(line.Quantity) - line.Discount
+	for i, share := range net.Split(weights) {
+		line.Components[i].Revenue = share
+	}
+}
+
+// getBundleRevenueHandler returns the revenue of every bundle sold and the
+// part of it attributed to each component, optionally limited to sales
+// between ?startDate= and ?endDate=.
+func (s *server) getBundleRevenueHandler(w http.ResponseWriter, r *http.Request) {
+	from, until, err := parseDateRange(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := s.store.Sales().BundleRevenue(r.Context(), from, until)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to build bundle report")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"gestor-simples-ecs/internal/models"
+	"net/http"
+	"testing"
+)
+
+func TestBundles(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+
+	coffee := env.createProduct(adminToken, "Café 250g", "10.00", 10)
+	mug := env.createProduct(adminToken, "Caneca", "30.00", 3)
+	kit := env.createProduct(adminToken, "Kit Café", "35.00", 0)
+	kitPath := "/api/v1/products/" + itoa(kit.ID)
+	components := []models.BundleComponent{{ProductID: coffee.ID, Quantity: 2}, {ProductID: mug.ID, Quantity: 1}}
+
+	expectStatus(t, env.do("PUT", kitPath+"/components", sellerToken, components), http.StatusForbidden)
+	expectStatus(t, env.do("PUT", kitPath+"/components", adminToken, []models.BundleComponent{{ProductID: kit.ID, Quantity: 1}}), http.StatusBadRequest)
+	stocked := env.createProduct(adminToken, "Kit Antigo", "20.00", 1)
+	expectStatus(t, env.do("PUT", "/api/v1/products/"+itoa(stocked.ID)+"/components", adminToken, components), http.StatusConflict)
+
+	rec := env.do("PUT", kitPath+"/components", adminToken, components)
+	expectStatus(t, rec, http.StatusOK)
+	if p := decode[models.Product](t, rec); len(p.Components) != 2 || p.Available != 3 {
+		t.Fatalf("bundle = %+v", p)
+	}
+	// A component cannot be a bundle itself
+	gift := env.createProduct(adminToken, "Kit Presente", "50.00", 0)
+	expectStatus(t, env.do("PUT", "/api/v1/products/"+itoa(gift.ID)+"/components", adminToken, []models.BundleComponent{{ProductID: kit.ID, Quantity: 1}}), http.StatusBadRequest)
+
+	// Bundles have no stock of their own to move
+	rec = env.do("POST", kitPath+"/movements", adminToken, models.CreateStockMovementRequest{
+		Type: models.StockMovementAdjustment, Quantity: 5, Reason: "Entrada",
+	})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	// Selling bundles takes their components out of stock
+	rec = env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+		Items:    []models.CreateSaleItem{{ProductID: kit.ID, Quantity: 2}},
+		Payments: pix(t, "70.00"),
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	salePath := "/api/v1/sales/" + itoa(decode[models.CreateSaleResponse](t, rec).SaleID)
+
+	rec = env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+		Items:    []models.CreateSaleItem{{ProductID: kit.ID, Quantity: 2}},
+		Payments: pix(t, "70.00"),
+	})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	quantities := func(wantCoffee, wantMug, wantKit int) {
+		t.Helper()
+		for id, want := range map[int64]int{coffee.ID: wantCoffee, mug.ID: wantMug, kit.ID: wantKit} {
+			if p := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(id), sellerToken, nil)); p.Available != want {
+				t.Fatalf("%s available = %d, want %d", p.Name, p.Available, want)
+			}
+		}
+	}
+	quantities(6, 1, 1)
+
+	sale := decode[models.Sale](t, env.do("GET", salePath, sellerToken, nil))
+	if c := sale.Items[0].Components; len(c) != 2 || c[0].Revenue != mustParse(t, "28.00") || c[1].Revenue != mustParse(t, "42.00") {
+		t.Fatalf("sale item components = %+v", c)
+	}
+
+	// Quotes of bundles sell them but cannot hold stock for them
+	rec = env.do("POST", "/api/v1/quotes", sellerToken, models.CreateQuoteRequest{Items: []models.CreateSaleItem{{ProductID: kit.ID, Quantity: 1}}})
+	expectStatus(t, rec, http.StatusCreated)
+	quotePath := "/api/v1/quotes/" + itoa(decode[models.Quote](t, rec).ID)
+	expectStatus(t, env.do("POST", quotePath+"/reserve", sellerToken, nil), http.StatusBadRequest)
+	expectStatus(t, env.do("POST", quotePath+"/convert", sellerToken, models.ConvertQuoteRequest{Payments: pix(t, "35.00")}), http.StatusCreated)
+	quantities(4, 0, 0)
+
+	// Returns put the components back
+	rec = env.do("POST", salePath+"/returns", adminToken, models.ReturnSaleItemsRequest{
+		Reason: "Desistiu", Items: []models.SaleItem{{ProductID: kit.ID, Quantity: 1}},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	quantities(6, 1, 1)
+
+	rec = env.do("GET", "/api/v1/products/reconciliation", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if discrepancies := decode[[]models.StockDiscrepancy](t, rec); len(discrepancies) != 0 {
+		t.Fatalf("unexpected discrepancies: %+v", discrepancies)
+	}
+
+	// Revenue goes to the bundle and, split by list price, to its components
+	expectStatus(t, env.do("GET", "/api/v1/dashboard/bundles", sellerToken, nil), http.StatusForbidden)
+	rec = env.do("GET", "/api/v1/dashboard/bundles", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	report := decode[[]models.BundleRevenue](t, rec)
+	if len(report) != 1 || report[0].UnitsSold != 2 || report[0].Revenue != mustParse(t, "70.00") {
+		t.Fatalf("bundle report = %+v", report)
+	}
+	if c := report[0].Components; len(c) != 2 ||
+		c[0].ProductID != mug.ID || c[0].UnitsSold != 2 || c[0].Revenue != mustParse(t, "42.00") ||
+		c[1].ProductID != coffee.ID || c[1].UnitsSold != 4 || c[1].Revenue != mustParse(t, "28.00") {
+		t.Fatalf("component revenue = %+v", c)
+	}
+
+	// Products that became bundles after being ordered cannot be received
+	basket := env.createProduct(adminToken, "Cesta", "60.00", 0)
+	rec = env.do("POST", "/api/v1/suppliers", adminToken, map[string]string{"name": "Torrefação Aurora"})
+	expectStatus(t, rec, http.StatusCreated)
+	rec = env.do("POST", "/api/v1/purchase-orders", adminToken, models.CreatePurchaseOrderRequest{
+		SupplierID: decode[models.Supplier](t, rec).ID,
+		Items:      []models.CreatePurchaseOrderItem{{ProductID: basket.ID, Quantity: 2, UnitCost: mustParse(t, "40.00")}},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	orderPath := "/api/v1/purchase-orders/" + itoa(decode[models.PurchaseOrder](t, rec).ID)
+	expectStatus(t, env.do("PUT", "/api/v1/products/"+itoa(basket.ID)+"/components", adminToken, components), http.StatusOK)
+	expectStatus(t, env.do("POST", orderPath+"/receive", adminToken, models.ReceivePurchaseOrderRequest{}), http.StatusBadRequest)
+
+	// Bundles are not low on stock themselves; their components and the
+	// other products short of ten units are
+	summary := decode[models.AdminDashboardSummary](t, env.do("GET", "/api/v1/dashboard/summary", adminToken, nil))
+	if summary.LowStockProducts != 4 {
+		t.Fatalf("low stock products = %d, want 4", summary.LowStockProducts)
+	}
+
+	// Components stay while a bundle uses them
+	expectStatus(t, env.do("DELETE", "/api/v1/products/"+itoa(stocked.ID), adminToken, nil), http.StatusNoContent)
+	expectStatus(t, env.do("DELETE", "/api/v1/products/"+itoa(mug.ID), adminToken, nil), http.StatusConflict)
+
+	// Nor can they gain variants
+	spoon := env.createProduct(adminToken, "Colher", "5.00", 0)
+	expectStatus(t, env.do("PUT", "/api/v1/products/"+itoa(gift.ID)+"/components", adminToken, []models.BundleComponent{{ProductID: spoon.ID, Quantity: 1}}), http.StatusOK)
+	expectStatus(t, env.do("POST", "/api/v1/products/"+itoa(spoon.ID)+"/variants", adminToken, models.ProductVariant{Name: "Inox"}), http.StatusConflict)
+
+	// Units sold before a product became a bundle have no stock to go back to
+	tea := env.createProduct(adminToken, "Kit Chá", "25.00", 1)
+	rec = env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+		Items:    []models.CreateSaleItem{{ProductID: tea.ID, Quantity: 1}},
+		Payments: pix(t, "25.00"),
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	teaSale := "/api/v1/sales/" + itoa(decode[models.CreateSaleResponse](t, rec).SaleID)
+	expectStatus(t, env.do("PUT", "/api/v1/products/"+itoa(tea.ID)+"/components", adminToken, []models.BundleComponent{{ProductID: spoon.ID, Quantity: 2}}), http.StatusOK)
+	expectStatus(t, env.do("POST", teaSale+"/cancel", adminToken, models.CancelSaleRequest{Reason: "Desistiu"}), http.StatusConflict)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// --- Category Handlers ---
+
+// categoryTree nests categories under their parents, keeping the order they
+// come in, and returns those whose parent is root (the top level for nil).
+func categoryTree(categories []models.Category, root *int64) []models.Category {
+	tree := []models.Category{}
+	for _, c := range categories {
+		if (c.ParentID == nil && root == nil) || (c.ParentID != nil && root != nil && *c.ParentID == *root) {
+			id := c.ID
+			c.Children = categoryTree(categories, &id)
+			tree = append(tree, c)
+		}
+	}
+	return tree
+}
+
+// getCategoriesHandler returns the whole catalog as a tree.
+func (s *server) getCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	categories, err := s.store.Categories().List(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query categories")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, categoryTree(categories, nil))
+}
+
+// getCategoryHandler returns the category with its subcategories.
+func (s *server) getCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	category, err := s.store.Categories().Get(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Category not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load category")
+		return
+	}
+	categories, err := s.store.Categories().List(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query categories")
+		return
+	}
+	category.Children = categoryTree(categories, &id)
+
+	respondWithJSON(w, http.StatusOK, category)
+}
+
+// decodeCategory reads and validates a category payload. It writes the
+// error response itself.
+func decodeCategory(w http.ResponseWriter, r *http.Request) (models.Category, bool) {
+	var c models.Category
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return c, false
+	}
+	c.Children = nil
+	if c.Name = strings.TrimSpace(c.Name); c.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "Category name is required")
+		return c, false
+	}
+	return c, true
+}
+
+func (s *server) createCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	c, ok := decodeCategory(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.store.Categories().Create(r.Context(), &c)
+	if errors.Is(err, repository.ErrConflict) {
+		respondWithError(w, http.StatusConflict, "A category with this name already exists at this level")
+		return
+	}
+	if errors.Is(err, repository.ErrInUse) {
+		respondWithError(w, http.StatusBadRequest, "Parent category not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create category")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, c)
+}
+
+// updateCategoryHandler renames the category or moves it, with everything
+// below it, under another parent.
+func (s *server) updateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	c, ok := decodeCategory(w, r)
+	if !ok {
+		return
+	}
+	c.ID = id
+
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		current, err := tx.Categories().Get(r.Context(), id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusNotFound, "Category not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load category")
+		}
+		c.CreatedAt = current.CreatedAt
+
+		// Moving a category below itself would detach the branch from the tree
+		if c.ParentID != nil {
+			ancestors, err := tx.Categories().Ancestors(r.Context(), *c.ParentID)
+			if errors.Is(err, repository.ErrNotFound) {
+				return newAPIError(http.StatusBadRequest, "Parent category not found")
+			}
+			if err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to load parent category")
+			}
+			if slices.Contains(ancestors, id) {
+				return newAPIError(http.StatusBadRequest, "A category cannot be moved under itself or its subcategories")
+			}
+		}
+
+		err = tx.Categories().Update(r.Context(), c)
+		if errors.Is(err, repository.ErrConflict) {
+			return newAPIError(http.StatusConflict, "A category with this name already exists at this level")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to update category")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, c)
+}
+
+func (s *server) deleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid category ID")
+		return
+	}
+
+	err = s.store.Categories().Delete(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Category not found")
+		return
+	}
+	if errors.Is(err, repository.ErrInUse) {
+		respondWithError(w, http.StatusConflict, "Category has subcategories, products, promotions or sales and cannot be deleted")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete category")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
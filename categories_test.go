@@ -0,0 +1,121 @@
+package main
+
+import (
+	"gestor-simples-ecs/internal/models"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestCategoryTree(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+
+	create := func(name string, parentID *int64) models.Category {
+		t.Helper()
+		rec := env.do("POST", "/api/v1/categories", adminToken, models.Category{Name: name, ParentID: parentID})
+		expectStatus(t, rec, http.StatusCreated)
+		return decode[models.Category](t, rec)
+	}
+	stationery := create("Papelaria", nil)
+	writing := create("Escrita", &stationery.ID)
+	pens := create("Canetas", &writing.ID)
+	drinks := create("Bebidas", nil)
+
+	expectStatus(t, env.do("POST", "/api/v1/categories", sellerToken, models.Category{Name: "Limpeza"}), http.StatusForbidden)
+	expectStatus(t, env.do("POST", "/api/v1/categories", adminToken, models.Category{Name: " escrita ", ParentID: &stationery.ID}), http.StatusConflict)
+	missing := drinks.ID + 100
+	expectStatus(t, env.do("POST", "/api/v1/categories", adminToken, models.Category{Name: "Sucos", ParentID: &missing}), http.StatusBadRequest)
+
+	rec := env.do("GET", "/api/v1/categories", sellerToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	tree := decode[[]models.Category](t, rec)
+	if len(tree) != 2 || tree[0].Name != "Bebidas" || tree[1].Name != "Papelaria" ||
+		len(tree[1].Children) != 1 || len(tree[1].Children[0].Children) != 1 || tree[1].Children[0].Children[0].ID != pens.ID {
+		t.Fatalf("unexpected category tree: %+v", tree)
+	}
+
+	// Moving a category below its own subcategory would make a cycle
+	rec = env.do("PUT", "/api/v1/categories/"+itoa(stationery.ID), adminToken, models.Category{Name: "Papelaria", ParentID: &pens.ID})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 10)
+	pen.CategoryID = &pens.ID
+	expectStatus(t, env.do("PUT", "/api/v1/products/"+itoa(pen.ID), adminToken, pen), http.StatusOK)
+	env.createProduct(adminToken, "Cerveja", "5.00", 10)
+	pen.CategoryID = &missing
+	expectStatus(t, env.do("PUT", "/api/v1/products/"+itoa(pen.ID), adminToken, pen), http.StatusBadRequest)
+	// Updates that leave categoryId out keep the product in its category
+	expectStatus(t, env.do("PUT", "/api/v1/products/"+itoa(pen.ID), adminToken, map[string]string{"price": "2.70"}), http.StatusOK)
+
+	// Filtering by a category includes its subcategories
+	products, total := pageOf[models.Product](t, env.do("GET", "/api/v1/products?category="+itoa(stationery.ID), sellerToken, nil))
+	if total != 1 || products[0].ID != pen.ID || products[0].Category != "Canetas" {
+		t.Fatalf("stationery products: %+v", products)
+	}
+	if _, total := pageOf[models.Product](t, env.do("GET", "/api/v1/products?category="+itoa(drinks.ID), sellerToken, nil)); total != 0 {
+		t.Fatalf("drinks products = %d, want 0", total)
+	}
+
+	expectStatus(t, env.do("DELETE", "/api/v1/categories/"+itoa(writing.ID), adminToken, nil), http.StatusConflict)
+	expectStatus(t, env.do("DELETE", "/api/v1/categories/"+itoa(pens.ID), adminToken, nil), http.StatusConflict)
+	expectStatus(t, env.do("DELETE", "/api/v1/categories/"+itoa(drinks.ID), adminToken, nil), http.StatusNoContent)
+}
+
+func TestCategoryRevenue(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+
+	stationery := decode[models.Category](t, env.do("POST", "/api/v1/categories", adminToken, models.Category{Name: "Papelaria"}))
+	pens := decode[models.Category](t, env.do("POST", "/api/v1/categories", adminToken, models.Category{Name: "Canetas", ParentID: &stationery.ID}))
+	paper := decode[models.Category](t, env.do("POST", "/api/v1/categories", adminToken, models.Category{Name: "Papel", ParentID: &stationery.ID}))
+
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 10)
+	pen.CategoryID = &pens.ID
+	expectStatus(t, env.do("PUT", "/api/v1/products/"+itoa(pen.ID), adminToken, pen), http.StatusOK)
+	notebook := env.createProduct(adminToken, "Caderno", "15.90", 10)
+	notebook.CategoryID = &stationery.ID
+	expectStatus(t, env.do("PUT", "/api/v1/products/"+itoa(notebook.ID), adminToken, notebook), http.StatusOK)
+	gum := env.createProduct(adminToken, "Chiclete", "1.00", 10)
+
+	rec := env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+		Items:    []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 4}, {ProductID: notebook.ID, Quantity: 1}, {ProductID: gum.ID, Quantity: 3}},
+		Payments: pix(t, "28.90"),
+	})
+	expectStatus(t, rec, http.StatusCreated)
+
+	rec = env.do("GET", "/api/v1/dashboard/categories", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	want := []models.CategoryRevenue{
+		{CategoryID: &stationery.ID, Name: "Papelaria", UnitsSold: 5, Revenue: mustParse(t, "25.90")},
+		{UnitsSold: 3, Revenue: mustParse(t, "3.00")},
+	}
+	if report := decode[[]models.CategoryRevenue](t, rec); !reflect.DeepEqual(report, want) {
+		t.Fatalf("top-level revenue = %+v, want %+v", report, want)
+	}
+	if summary := decode[models.AdminDashboardSummary](t, env.do("GET", "/api/v1/dashboard/summary", adminToken, nil)); !reflect.DeepEqual(summary.RevenueByCategory, want) {
+		t.Fatalf("dashboard revenue by category = %+v, want %+v", summary.RevenueByCategory, want)
+	}
+
+	// Drilling down lists the subcategories, even without sales
+	rec = env.do("GET", "/api/v1/dashboard/categories?parentId="+itoa(stationery.ID), adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	want = []models.CategoryRevenue{
+		{CategoryID: &pens.ID, Name: "Canetas", UnitsSold: 4, Revenue: mustParse(t, "10.00")},
+		{CategoryID: &paper.ID, Name: "Papel"},
+	}
+	if report := decode[[]models.CategoryRevenue](t, rec); !reflect.DeepEqual(report, want) {
+		t.Fatalf("stationery revenue = %+v, want %+v", report, want)
+	}
+
+	// Moving a product leaves its past sales in the category they were made in
+	expectStatus(t, env.do("PUT", "/api/v1/products/"+itoa(pen.ID), adminToken, map[string]interface{}{"categoryId": paper.ID}), http.StatusOK)
+	rec = env.do("GET", "/api/v1/dashboard/categories?parentId="+itoa(stationery.ID), adminToken, nil)
+	if report := decode[[]models.CategoryRevenue](t, rec); !reflect.DeepEqual(report, want) {
+		t.Fatalf("stationery revenue after moving the pen = %+v, want %+v", report, want)
+	}
+	expectStatus(t, env.do("DELETE", "/api/v1/categories/"+itoa(pens.ID), adminToken, nil), http.StatusConflict)
+	expectStatus(t, env.do("GET", "/api/v1/dashboard/categories", sellerToken, nil), http.StatusForbidden)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/document"
+	"net/http"
+	"net/mail"
+	"strings"
+)
+
+// --- Customer Handlers ---
+
+func (s *server) getCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	customers, total, err := s.store.Customers().List(r.Context(), repository.CustomerFilter{
+		Search: strings.TrimSpace(r.URL.Query().Get("search")),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query customers")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.Page{Data: customers, Total: total, Limit: limit, Offset: offset})
+}
+
+// decodeCustomer reads and validates a customer payload, normalizing the
+// document to bare digits. It writes the error response itself.
+func decodeCustomer(w http.ResponseWriter, r *http.Request) (models.Customer, bool) {
+	var c models.Customer
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return c, false
+	}
+
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "Customer name is required")
+		return c, false
+	}
+	if c.Document = strings.TrimSpace(c.Document); c.Document != "" {
+		digits, err := document.Normalize(c.Document)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid CPF or CNPJ")
+			return c, false
+		}
+		c.Document = digits
+	}
+	if c.Email = strings.TrimSpace(c.Email); c.Email != "" {
+		if _, err := mail.ParseAddress(c.Email); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid email address")
+			return c, false
+		}
+	}
+	c.Phone = strings.TrimSpace(c.Phone)
+	c.Address = strings.TrimSpace(c.Address)
+	return c, true
+}
+
+func (s *server) createCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	c, ok := decodeCustomer(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.store.Customers().Create(r.Context(), &c)
+	if errors.Is(err, repository.ErrConflict) {
+		respondWithError(w, http.StatusConflict, "A customer with this document already exists")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create customer")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, c)
+}
+
+func (s *server) getCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid customer ID")
+		return
+	}
+
+	c, err := s.store.Customers().Get(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Customer not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, c)
+}
+
+func (s *server) updateCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid customer ID")
+		return
+	}
+
+	c, ok := decodeCustomer(w, r)
+	if !ok {
+		return
+	}
+	c.ID = id
+
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		current, err := tx.Customers().Get(r.Context(), id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusNotFound, "Customer not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load customer")
+		}
+		c.CreatedAt = current.CreatedAt
+
+		err = tx.Customers().Update(r.Context(), c)
+		if errors.Is(err, repository.ErrConflict) {
+			return newAPIError(http.StatusConflict, "A customer with this document already exists")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to update customer")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, c)
+}
+
+func (s *server) deleteCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid customer ID")
+		return
+	}
+
+	err = s.store.Customers().Delete(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Customer not found")
+		return
+	}
+	if errors.Is(err, repository.ErrInUse) {
+		respondWithError(w, http.StatusConflict, "Customer has sales or quotes and cannot be deleted")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete customer")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getCustomerSalesHandler lists a customer's purchase history, newest first.
+// Sellers without sales:view_all only see the sales they made.
+func (s *server) getCustomerSalesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid customer ID")
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := s.store.Customers().Get(r.Context(), id); err != nil {
+		respondWithError(w, http.StatusNotFound, "Customer not found")
+		return
+	}
+
+	filter := repository.SaleFilter{CustomerID: &id, Limit: limit, Offset: offset}
+	var ok bool
+	if filter.UserID, ok = restrictToOwnSales(w, r, nil); !ok {
+		return
+	}
+
+	sales, total, err := s.store.Sales().List(r.Context(), filter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query sales")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.Page{Data: sales, Total: total, Limit: limit, Offset: offset})
+}
+
+func (s *server) getCustomerMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid customer ID")
+		return
+	}
+
+	if _, err := s.store.Customers().Get(r.Context(), id); err != nil {
+		respondWithError(w, http.StatusNotFound, "Customer not found")
+		return
+	}
+
+	metrics, err := s.store.Customers().Metrics(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to compute customer metrics")
+		return
+	}
+	if metrics.PurchaseCount > 0 {
+		metrics.AverageTicket = metrics.LifetimeValue.MulRate(1, int64(metrics.PurchaseCount))
+	}
+
+	respondWithJSON(w, http.StatusOK, metrics)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"gestor-simples-ecs/internal/models"
+	"net/http"
+	"testing"
+)
+
+func TestCustomerCRUD(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+
+	rec := env.do("POST", "/api/v1/customers", sellerToken, models.Customer{Name: "Ana", Document: "111.111.111-11"})
+	expectStatus(t, rec, http.StatusBadRequest)
+	rec = env.do("POST", "/api/v1/customers", sellerToken, models.Customer{Name: "Ana", Email: "not-an-email"})
+	expectStatus(t, rec, http.StatusBadRequest)
+	rec = env.do("POST", "/api/v1/customers", sellerToken, models.Customer{Document: "529.982.247-25"})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	rec = env.do("POST", "/api/v1/customers", sellerToken, models.Customer{Name: "Ana Souza", Document: "529.982.247-25", Email: "ana@example.com"})
+	expectStatus(t, rec, http.StatusCreated)
+	ana := decode[models.Customer](t, rec)
+	if ana.Document != "52998224725" {
+		t.Fatalf("document = %q, want digits only", ana.Document)
+	}
+
+	// The same document in another format is still a duplicate
+	rec = env.do("POST", "/api/v1/customers", sellerToken, models.Customer{Name: "Outra Ana", Document: "52998224725"})
+	expectStatus(t, rec, http.StatusConflict)
+
+	rec = env.do("POST", "/api/v1/customers", adminToken, models.Customer{Name: "Loja Exemplo", Document: "11.222.333/0001-81"})
+	expectStatus(t, rec, http.StatusCreated)
+	shop := decode[models.Customer](t, rec)
+
+	rec = env.do("PUT", "/api/v1/customers/"+itoa(shop.ID), adminToken, models.Customer{Name: "Loja Exemplo", Document: "529.982.247-25"})
+	expectStatus(t, rec, http.StatusConflict)
+	rec = env.do("PUT", "/api/v1/customers/"+itoa(shop.ID), adminToken, models.Customer{Name: "Loja Exemplo Ltda", Phone: "11 99999-0000"})
+	expectStatus(t, rec, http.StatusOK)
+	expectStatus(t, env.do("PUT", "/api/v1/customers/999", adminToken, models.Customer{Name: "X"}), http.StatusNotFound)
+
+	rec = env.do("GET", "/api/v1/customers/"+itoa(shop.ID), sellerToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[models.Customer](t, rec); got.Name != "Loja Exemplo Ltda" || got.Document != "" || got.Phone != "11 99999-0000" {
+		t.Fatalf("unexpected customer after update: %+v", got)
+	}
+
+	rec = env.do("GET", "/api/v1/customers?search=5299822", sellerToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if customers, total := pageOf[models.Customer](t, rec); total != 1 || customers[0].ID != ana.ID {
+		t.Fatalf("search by document returned %+v", customers)
+	}
+	rec = env.do("GET", "/api/v1/customers?search=loja", sellerToken, nil)
+	if customers, total := pageOf[models.Customer](t, rec); total != 1 || customers[0].ID != shop.ID {
+		t.Fatalf("search by name returned %+v", customers)
+	}
+
+	expectStatus(t, env.do("DELETE", "/api/v1/customers/"+itoa(shop.ID), adminToken, nil), http.StatusNoContent)
+	expectStatus(t, env.do("GET", "/api/v1/customers/"+itoa(shop.ID), adminToken, nil), http.StatusNotFound)
+}
+
+func TestCustomerSales(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	maria := env.createUser("maria", "vendedor")
+	mariaToken := env.token(maria)
+	joaoToken := env.token(env.createUser("joao", "vendedor"))
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 100)
+
+	rec := env.do("POST", "/api/v1/customers", mariaToken, models.Customer{Name: "Ana"})
+	expectStatus(t, rec, http.StatusCreated)
+	ana := decode[models.Customer](t, rec)
+
+	missing := int64(999)
+	rec = env.do("POST", "/api/v1/sales", mariaToken, models.CreateSaleRequest{CustomerID: &missing, Items: []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 1}}, Payments: pix(t, "2.50")})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	sell := func(token string, quantity int) int64 {
+		t.Helper()
+		rec := env.do("POST", "/api/v1/sales", token, models.CreateSaleRequest{
+			CustomerID: &ana.ID,
+			Items:      []models.CreateSaleItem{{ProductID: pen.ID, Quantity: quantity}},
+			Payments:   pix(t, pen.Price.Mul(quantity).String()),
+		})
+		expectStatus(t, rec, http.StatusCreated)
+		return decode[models.CreateSaleResponse](t, rec).SaleID
+	}
+	first := sell(mariaToken, 4)     // 10.00, 2 units returned later
+	sell(joaoToken, 2)               // 5.00
+	cancelled := sell(mariaToken, 8) // 20.00, cancelled
+
+	rec = env.do("POST", "/api/v1/sales/"+itoa(first)+"/returns", adminToken, models.ReturnSaleItemsRequest{
+		Reason: "Defeito", Items: []models.SaleItem{{ProductID: pen.ID, Quantity: 2}},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	rec = env.do("POST", "/api/v1/sales/"+itoa(cancelled)+"/cancel", adminToken, models.CancelSaleRequest{Reason: "Desistiu"})
+	expectStatus(t, rec, http.StatusCreated)
+
+	expectStatus(t, env.do("DELETE", "/api/v1/customers/"+itoa(ana.ID), adminToken, nil), http.StatusConflict)
+
+	rec = env.do("GET", "/api/v1/customers/"+itoa(ana.ID)+"/sales", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	sales, total := pageOf[models.Sale](t, rec)
+	if total != 3 || sales[0].CustomerName != "Ana" || sales[0].CustomerID == nil || *sales[0].CustomerID != ana.ID {
+		t.Fatalf("unexpected history: %d sales, %+v", total, sales)
+	}
+
+	// Sellers only see the customer's purchases they made
+	rec = env.do("GET", "/api/v1/customers/"+itoa(ana.ID)+"/sales", mariaToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if _, total := pageOf[models.Sale](t, rec); total != 2 {
+		t.Fatalf("seller sees %d sales, want 2", total)
+	}
+
+	expectStatus(t, env.do("GET", "/api/v1/customers/"+itoa(ana.ID)+"/metrics", mariaToken, nil), http.StatusForbidden)
+	expectStatus(t, env.do("GET", "/api/v1/customers/999/metrics", adminToken, nil), http.StatusNotFound)
+
+	rec = env.do("GET", "/api/v1/customers/"+itoa(ana.ID)+"/metrics", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	metrics := decode[models.CustomerMetrics](t, rec)
+	if metrics.PurchaseCount != 2 {
+		t.Fatalf("purchase count = %d, want 2", metrics.PurchaseCount)
+	}
+	if metrics.LifetimeValue != mustParse(t, "10.00") || metrics.AverageTicket != mustParse(t, "5.00") {
+		t.Fatalf("lifetime value = %s, average ticket = %s", metrics.LifetimeValue, metrics.AverageTicket)
+	}
+	if metrics.FirstPurchaseAt == nil || metrics.LastPurchaseAt == nil {
+		t.Fatalf("missing purchase dates: %+v", metrics)
+	}
+}
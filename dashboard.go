@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/pkg/auth"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// --- Dashboard Handlers ---
+
+// sellerCommissionRate is the share of net sales paid to sellers, in percent.
+const sellerCommissionRate = 10
+
+func (s *server) getDashboardSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from the context (set by AuthMiddleware)
+	userID, _ := auth.UserID(r)
+
+	switch {
+	case auth.Can(r, auth.PermReportsView):
+		s.getAdminDashboardSummary(w, r)
+	case auth.Can(r, auth.PermSalesCreate):
+		s.getVendedorDashboardSummary(w, r, userID)
+	default:
+		respondWithError(w, http.StatusForbidden, "No dashboard available for your role")
+	}
+}
+
+// startOfMonth returns midnight on the first day of t's month, in t's location.
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+func (s *server) getAdminDashboardSummary(w http.ResponseWriter, r *http.Request) {
+	since := startOfMonth(time.Now().In(storeLocation))
+	summary, err := s.store.Dashboard().AdminSummary(r.Context(), since, auth.PermSalesCreate)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to build dashboard summary")
+		return
+	}
+	summary.RevenueByCategory, err = s.store.Sales().CategoryRevenue(r.Context(), nil, &since, nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to build dashboard summary")
+		return
+	}
+	expiring, err := s.store.Lots().Expiring(r.Context(), today().AddDate(0, 0, expiringLotsDays))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to build dashboard summary")
+		return
+	}
+	summary.ExpiringLots = len(expiring)
+
+	respondWithJSON(w, http.StatusOK, summary)
+}
+
+func (s *server) getVendedorDashboardSummary(w http.ResponseWriter, r *http.Request, userID int64) {
+	summary, err := s.store.Dashboard().SellerTotals(r.Context(), userID, startOfMonth(time.Now().In(storeLocation)))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to get user's total sales for the month")
+		return
+	}
+
+	summary.Commissions = summary.MyTotalSalesMonth.MulRate(sellerCommissionRate, 100)
+
+	respondWithJSON(w, http.StatusOK, summary)
+}
+
+// getProductMarginsHandler returns the gross margin per product, optionally
+// limited to sales between ?startDate= and ?endDate=.
+func (s *server) getProductMarginsHandler(w http.ResponseWriter, r *http.Request) {
+	s.respondWithMargins(w, r, s.store.Sales().ProductMargins)
+}
+
+// getSellerMarginsHandler is getProductMarginsHandler per seller.
+func (s *server) getSellerMarginsHandler(w http.ResponseWriter, r *http.Request) {
+	s.respondWithMargins(w, r, s.store.Sales().SellerMargins)
+}
+
+func (s *server) respondWithMargins(w http.ResponseWriter, r *http.Request, margins func(ctx context.Context, from, until *time.Time) ([]models.MarginReport, error)) {
+	from, until, err := parseDateRange(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := margins(r.Context(), from, until)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to build margin report")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// getCategoryRevenueHandler returns the revenue of each subcategory of
+// ?parentId=, or of the top-level categories without it, counting the sales
+// of everything below them. ?startDate= and ?endDate= limit the sales.
+func (s *server) getCategoryRevenueHandler(w http.ResponseWriter, r *http.Request) {
+	var parentID *int64
+	if v := r.URL.Query().Get("parentId"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "parentId must be a number")
+			return
+		}
+		if _, err := s.store.Categories().Get(r.Context(), id); err != nil {
+			respondWithError(w, http.StatusNotFound, "Category not found")
+			return
+		}
+		parentID = &id
+	}
+	from, until, err := parseDateRange(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := s.store.Sales().CategoryRevenue(r.Context(), parentID, from, until)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to build category report")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
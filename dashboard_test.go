@@ -0,0 +1,160 @@
+package main
+
+import (
+	"gestor-simples-ecs/internal/models"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDashboardSummary(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	maria := env.createUser("maria", "vendedor")
+	joao := env.createUser("joao", "vendedor")
+	mariaToken, joaoToken := env.token(maria), env.token(joao)
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 100)
+	notebook := env.createProduct(adminToken, "Caderno", "15.90", 5)
+
+	sell := func(token string, payments []models.Payment, items ...models.CreateSaleItem) string {
+		rec := env.do("POST", "/api/v1/sales", token, models.CreateSaleRequest{Items: items, Payments: payments})
+		expectStatus(t, rec, http.StatusCreated)
+		return "/api/v1/sales/" + itoa(decode[models.CreateSaleResponse](t, rec).SaleID)
+	}
+	mariaSale := sell(mariaToken, []models.Payment{
+		{Method: models.PaymentMethodCash, Amount: mustParse(t, "10"), Tendered: mustParse(t, "20")},
+		{Method: models.PaymentMethodCreditCard, Amount: mustParse(t, "15"), Installments: 3},
+	}, models.CreateSaleItem{ProductID: pen.ID, Quantity: 10})
+	sell(joaoToken, pix(t, "31.80"), models.CreateSaleItem{ProductID: notebook.ID, Quantity: 2})
+	cancelled := sell(joaoToken, pix(t, "15.90"), models.CreateSaleItem{ProductID: notebook.ID, Quantity: 1})
+	expectStatus(t, env.do("POST", cancelled+"/cancel", adminToken, models.CancelSaleRequest{Reason: "Desistiu"}), http.StatusCreated)
+
+	rec := env.do("GET", "/api/v1/dashboard/summary", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	admin := decode[models.AdminDashboardSummary](t, rec)
+	want := models.AdminDashboardSummary{
+		TotalSalesMonth:   mustParse(t, "56.80"),
+		TotalSellers:      3, // Every role allowed to sell counts, admin included
+		LowStockProducts:  1,
+		TopSellingProduct: models.TopSellingProduct{ID: pen.ID, Name: "Caneta"},
+		PaymentMethods: []models.PaymentTotal{
+			{Method: models.PaymentMethodPix, Count: 1, Total: mustParse(t, "31.80")},
+			{Method: models.PaymentMethodCreditCard, Count: 1, Total: mustParse(t, "15")},
+			{Method: models.PaymentMethodCash, Count: 1, Total: mustParse(t, "10")},
+		},
+		GrossMarginMonth:  mustParse(t, "56.80"), // Neither product has a cost price
+		RevenueByCategory: []models.CategoryRevenue{{UnitsSold: 12, Revenue: mustParse(t, "56.80")}},
+	}
+	if !reflect.DeepEqual(admin, want) {
+		t.Fatalf("admin summary = %+v, want %+v", admin, want)
+	}
+
+	rec = env.do("GET", "/api/v1/dashboard/summary", mariaToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	seller := decode[models.VendedorDashboardSummary](t, rec)
+	if seller.MyTotalSalesMonth != mustParse(t, "25") || seller.MyRank != 2 || seller.Commissions != mustParse(t, "2.50") {
+		t.Fatalf("seller summary = %+v", seller)
+	}
+
+	rec = env.do("GET", "/api/v1/dashboard/summary", env.token(env.createUser("pedro", "vendedor")), nil)
+	if seller := decode[models.VendedorDashboardSummary](t, rec); seller.MyRank != 0 || seller.MyTotalSalesMonth != 0 {
+		t.Fatalf("summary without sales = %+v", seller)
+	}
+
+	// A return comes off the sale's payments in proportion to their amounts
+	rec = env.do("POST", mariaSale+"/returns", adminToken, models.ReturnSaleItemsRequest{
+		Reason: "Defeito", Items: []models.SaleItem{{ProductID: pen.ID, Quantity: 2}},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	rec = env.do("GET", "/api/v1/dashboard/summary", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	wantMethods := []models.PaymentTotal{
+		{Method: models.PaymentMethodPix, Count: 1, Total: mustParse(t, "31.80")},
+		{Method: models.PaymentMethodCreditCard, Count: 1, Total: mustParse(t, "12")},
+		{Method: models.PaymentMethodCash, Count: 1, Total: mustParse(t, "8")},
+	}
+	if got := decode[models.AdminDashboardSummary](t, rec).PaymentMethods; !reflect.DeepEqual(got, wantMethods) {
+		t.Fatalf("payment methods after return = %+v, want %+v", got, wantMethods)
+	}
+}
+
+func TestMargins(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	maria := env.createUser("maria", "vendedor")
+	joao := env.createUser("joao", "vendedor")
+	mariaToken, joaoToken := env.token(maria), env.token(joao)
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 0)
+	rec := env.do("POST", "/api/v1/products", adminToken, map[string]interface{}{"name": "Caderno", "price": "15.90", "costPrice": "10.00", "quantity": 5})
+	expectStatus(t, rec, http.StatusCreated)
+	notebook := decode[models.Product](t, rec)
+
+	// Receipts move the cost price to the weighted average of the stock
+	cost := mustParse(t, "1.00")
+	rec = env.do("POST", "/api/v1/products/"+itoa(pen.ID)+"/movements", adminToken, models.CreateStockMovementRequest{
+		Type: models.StockMovementPurchaseReceipt, Quantity: 10, Reason: "NF 123", UnitCost: &cost,
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	supplier := decode[models.Supplier](t, env.do("POST", "/api/v1/suppliers", adminToken, map[string]string{"name": "Distribuidora Central"}))
+	order := decode[models.PurchaseOrder](t, env.do("POST", "/api/v1/purchase-orders", adminToken, models.CreatePurchaseOrderRequest{
+		SupplierID: supplier.ID,
+		Items:      []models.CreatePurchaseOrderItem{{ProductID: pen.ID, Quantity: 30, UnitCost: mustParse(t, "1.60")}},
+	}))
+	rec = env.do("POST", "/api/v1/purchase-orders/"+itoa(order.ID)+"/receive", adminToken, models.ReceivePurchaseOrderRequest{
+		Items: []models.ReceivePurchaseOrderItem{{ProductID: pen.ID, Quantity: 10}},
+	})
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(pen.ID), adminToken, nil)); got.CostPrice != mustParse(t, "1.30") {
+		t.Fatalf("cost price = %s, want 1.30", got.CostPrice)
+	}
+	// Editing the product keeps its cost price and what the body leaves out
+	rec = env.do("PUT", "/api/v1/products/"+itoa(pen.ID), adminToken, map[string]interface{}{"price": "2.50", "costPrice": "0.00"})
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(pen.ID), adminToken, nil)); got.CostPrice != mustParse(t, "1.30") || got.Name != "Caneta" || got.Quantity != 20 {
+		t.Fatalf("product after update = %+v", got)
+	}
+
+	rec = env.do("POST", "/api/v1/sales", mariaToken, models.CreateSaleRequest{Items: []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 4}}, Payments: pix(t, "10.00")})
+	expectStatus(t, rec, http.StatusCreated)
+	salePath := "/api/v1/sales/" + itoa(decode[models.CreateSaleResponse](t, rec).SaleID)
+	rec = env.do("POST", salePath+"/returns", adminToken, models.ReturnSaleItemsRequest{
+		Reason: "Defeito", Items: []models.SaleItem{{ProductID: pen.ID, Quantity: 1}},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	sale := decode[models.SaleDetail](t, env.do("GET", salePath, adminToken, nil))
+	if sale.Items[0].UnitCost != mustParse(t, "1.30") || sale.CostTotal != mustParse(t, "3.90") || sale.GrossMargin != mustParse(t, "3.60") {
+		t.Fatalf("sale margin: unit cost %s, cost %s, margin %s", sale.Items[0].UnitCost, sale.CostTotal, sale.GrossMargin)
+	}
+
+	// Later purchases don't change the cost of past sales
+	rec = env.do("POST", "/api/v1/purchase-orders/"+itoa(order.ID)+"/receive", adminToken, models.ReceivePurchaseOrderRequest{})
+	expectStatus(t, rec, http.StatusOK)
+	rec = env.do("POST", "/api/v1/sales", joaoToken, models.CreateSaleRequest{Items: []models.CreateSaleItem{{ProductID: notebook.ID, Quantity: 2}}, Payments: pix(t, "31.80")})
+	expectStatus(t, rec, http.StatusCreated)
+
+	rec = env.do("GET", "/api/v1/dashboard/margins/products", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	wantProducts := []models.MarginReport{
+		{ID: notebook.ID, Name: "Caderno", UnitsSold: 2, Revenue: mustParse(t, "31.80"), Cost: mustParse(t, "20.00"), GrossMargin: mustParse(t, "11.80")},
+		{ID: pen.ID, Name: "Caneta", UnitsSold: 3, Revenue: mustParse(t, "7.50"), Cost: mustParse(t, "3.90"), GrossMargin: mustParse(t, "3.60")},
+	}
+	if products := decode[[]models.MarginReport](t, rec); !reflect.DeepEqual(products, wantProducts) {
+		t.Fatalf("product margins = %+v, want %+v", products, wantProducts)
+	}
+
+	rec = env.do("GET", "/api/v1/dashboard/margins/sellers", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if sellers := decode[[]models.MarginReport](t, rec); len(sellers) != 2 || sellers[0].ID != joao.ID || sellers[1].ID != maria.ID || sellers[1].GrossMargin != mustParse(t, "3.60") {
+		t.Fatalf("seller margins = %+v", sellers)
+	}
+	rec = env.do("GET", "/api/v1/dashboard/margins/sellers?startDate=2000-01-01&endDate=2000-01-31", adminToken, nil)
+	if sellers := decode[[]models.MarginReport](t, rec); len(sellers) != 0 {
+		t.Fatalf("seller margins outside the period = %+v", sellers)
+	}
+	expectStatus(t, env.do("GET", "/api/v1/dashboard/margins/products", mariaToken, nil), http.StatusForbidden)
+
+	admin := decode[models.AdminDashboardSummary](t, env.do("GET", "/api/v1/dashboard/summary", adminToken, nil))
+	if admin.GrossMarginMonth != mustParse(t, "15.40") {
+		t.Fatalf("gross margin this month = %s, want 15.40", admin.GrossMarginMonth)
+	}
+}
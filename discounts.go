@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/auth"
+	"gestor-simples-ecs/pkg/money"
+	"net/http"
+)
+
+// hundredPercent is 100% in the cents of a percentage money.Amount.
+const hundredPercent = 10000
+
+// validateDiscount checks a requested discount on its own, before the value
+// it applies to is known. A nil discount is valid.
+func validateDiscount(d *models.Discount) error {
+	if d == nil {
+		return nil
+	}
+	switch d.Type {
+	case models.DiscountTypePercentage:
+		if d.Value <= 0 || d.Value.Cents() > hundredPercent {
+			return errors.New("percentage discounts must be greater than 0 and at most 100")
+		}
+	case models.DiscountTypeAmount:
+		if d.Value <= 0 {
+			return errors.New("discount amounts must be positive")
+		}
+	default:
+		return fmt.Errorf("invalid discount type: %q", d.Type)
+	}
+	return nil
+}
+
+// discountOn returns how much a validated discount takes off base, or an
+// error if it is larger than base.
+func discountOn(d *models.Discount, base money.Amount) (money.Amount, error) {
+	if d == nil {
+		return 0, nil
+	}
+	if d.Type == models.DiscountTypePercentage {
+		return base.MulRate(d.Value.Cents(), hundredPercent), nil
+	}
+	if d.Value > base {
+		return 0, fmt.Errorf("discount of %s exceeds the value of %s it applies to", d.Value, base)
+	}
+	return d.Value, nil
+}
+
+// discountLimit returns the largest discount, as a percentage of the
+// subtotal, the caller may give without approval; nil means no limit.
+// Holders of discounts:approve have none.
+func (s *server) discountLimit(r *http.Request) (*money.Amount, error) {
+	if auth.Can(r, auth.PermDiscountsApprove) {
+		return nil, nil
+	}
+	roleName, _ := auth.Role(r)
+	role, err := s.store.Roles().Get(r.Context(), roleName)
+	if err != nil {
+		return nil, err
+	}
+	return role.MaxDiscount, nil
+}
+
+// approveDiscount enforces a discount limit. Discounts within it need nothing
+// else; larger ones must carry the credentials of a user holding
+// discounts:approve. It returns the approver's ID, if one was needed.
+func approveDiscount(ctx context.Context, tx repository.Store, limit *money.Amount, subtotal, discount money.Amount, approval *models.DiscountApproval) (*int64, error) {
+	// Compare discount/subtotal with the limit without rounding either side
+	if limit == nil || discount.Cents()*hundredPercent <= subtotal.Cents()*limit.Cents() {
+		return nil, nil
+	}
+
+	if approval == nil {
+		return nil, newAPIError(http.StatusForbidden, fmt.Sprintf("Discounts above %s%% of the subtotal require approval", *limit))
+	}
+	approver, err := tx.Users().GetByUsername(ctx, approval.Username)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, newAPIError(http.StatusForbidden, "Invalid discount approval credentials")
+	}
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "Failed to verify discount approval")
+	}
+	if !auth.CheckPasswordHash(approval.Password, approver.PasswordHash) {
+		return nil, newAPIError(http.StatusForbidden, "Invalid discount approval credentials")
+	}
+	allowed, err := tx.Roles().HasPermission(ctx, approver.Role, auth.PermDiscountsApprove)
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "Failed to verify discount approval")
+	}
+	if !allowed {
+		return nil, newAPIError(http.StatusForbidden, "Approver is not allowed to approve discounts")
+	}
+	return &approver.ID, nil
+}
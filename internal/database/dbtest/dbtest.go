@@ -0,0 +1,86 @@
+// Package dbtest gives tests a migrated Postgres database of their own, so
+// the same suites that run on the in-memory store can run on the real one.
+package dbtest
+
+import (
+	"database/sql"
+	"fmt"
+	"gestor-simples-ecs/internal/database"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// EnvVar names the variable holding the connection string of the Postgres
+// server tests may use. Tests needing Postgres are skipped without it.
+const EnvVar = "DATABASE_URL"
+
+var schemas atomic.Int64
+
+// Enabled reports whether EnvVar is set.
+func Enabled() bool {
+	return os.Getenv(EnvVar) != ""
+}
+
+// Open connects to the server at EnvVar inside a new schema migrated to the
+// latest version, dropped again when the test ends. It skips the test when
+// EnvVar is unset.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+	conn := os.Getenv(EnvVar)
+	if conn == "" {
+		t.Skipf("%s is not set", EnvVar)
+	}
+
+	admin, err := sql.Open("postgres", conn)
+	if err != nil {
+		t.Fatalf("opening %s: %v", EnvVar, err)
+	}
+	schema := fmt.Sprintf("test_%d_%d_%d", os.Getpid(), time.Now().Unix(), schemas.Add(1))
+	scoped, err := withSearchPath(conn, schema)
+	if err != nil {
+		admin.Close()
+		t.Fatalf("parsing %s: %v", EnvVar, err)
+	}
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatalf("creating schema %s: %v", schema, err)
+	}
+
+	db, err := sql.Open("postgres", scoped)
+	if err != nil {
+		t.Fatalf("opening schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("dropping schema %s: %v", schema, err)
+		}
+		admin.Close()
+	})
+
+	if _, err := database.MigrateUp(db); err != nil {
+		t.Fatalf("migrating schema %s: %v", schema, err)
+	}
+	return db
+}
+
+// withSearchPath sets the search_path run-time parameter on a connection
+// string in either URL or key=value form, so every pooled connection
+// works inside schema.
+func withSearchPath(conn, schema string) (string, error) {
+	if !strings.Contains(conn, "://") {
+		return conn + " search_path=" + schema, nil
+	}
+	u, err := url.Parse(conn)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
@@ -1,4 +1,4 @@
--- PostgreSQL Database Modeling Script for Gestor Simples
+-- Initial schema for Gestor Simples: users, products, sales and their items.
 
 -- Table: Users
 -- Stores information about users (administrators and sellers).
@@ -37,16 +37,8 @@ CREATE TABLE IF NOT EXISTS sales_items (
     quantity INTEGER NOT NULL
 );
 
--- Optional: Add indexes for performance
+-- Indexes
 CREATE INDEX IF NOT EXISTS idx_sales_user_id ON sales (user_id);
 CREATE INDEX IF NOT EXISTS idx_sales_items_sale_id ON sales_items (sale_id);
 CREATE INDEX IF NOT EXISTS idx_sales_items_product_id ON sales_items (product_id);
 CREATE INDEX IF NOT EXISTS idx_users_username ON users (username);
-
--- Optional: Add a few initial users and products for testing
--- You might want to hash the password for 'admin' user with your application's hashing logic
--- INSERT INTO users (name, username, password_hash, role) VALUES ('Admin User', 'admin', 'your_hashed_admin_password', 'admin');
--- INSERT INTO users (name, username, password_hash, role) VALUES ('Seller One', 'seller1', 'your_hashed_seller1_password', 'vendedor');
-
--- INSERT INTO products (name, description, quantity, price) VALUES ('Product A', 'Description for Product A', 100, 19.99);
--- INSERT INTO products (name, description, quantity, price) VALUES ('Product B', 'Description for Product B', 50, 49.99);
@@ -0,0 +1,2 @@
+ALTER TABLE sales_items DROP COLUMN IF EXISTS product_name;
+ALTER TABLE sales_items DROP COLUMN IF EXISTS unit_price;
@@ -0,0 +1,18 @@
+-- Snapshot product name and unit price into sales_items.
+--
+-- Until now sales_items only referenced products, so revenue was always
+-- recomputed from the current products.price. Existing rows are backfilled
+-- with the current name and price, which is the best information available.
+
+ALTER TABLE sales_items ADD COLUMN IF NOT EXISTS product_name TEXT;
+ALTER TABLE sales_items ADD COLUMN IF NOT EXISTS unit_price REAL;
+
+UPDATE sales_items si
+SET product_name = p.name,
+    unit_price = p.price
+FROM products p
+WHERE si.product_id = p.id
+  AND (si.product_name IS NULL OR si.unit_price IS NULL);
+
+ALTER TABLE sales_items ALTER COLUMN product_name SET NOT NULL;
+ALTER TABLE sales_items ALTER COLUMN unit_price SET NOT NULL;
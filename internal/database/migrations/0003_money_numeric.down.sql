@@ -0,0 +1,9 @@
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_price_check;
+
+ALTER TABLE products
+    ALTER COLUMN price DROP DEFAULT,
+    ALTER COLUMN price TYPE REAL USING price::real,
+    ALTER COLUMN price SET DEFAULT 0.0;
+
+ALTER TABLE sales_items
+    ALTER COLUMN unit_price TYPE REAL USING unit_price::real;
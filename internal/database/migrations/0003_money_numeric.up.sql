@@ -0,0 +1,21 @@
+-- Store money as exact NUMERIC(12, 2) instead of REAL.
+--
+-- REAL values are rounded to the nearest cent, which matches what the API
+-- has always displayed.
+
+ALTER TABLE products
+    ALTER COLUMN price DROP DEFAULT,
+    ALTER COLUMN price TYPE NUMERIC(12, 2) USING round(price::numeric, 2),
+    ALTER COLUMN price SET DEFAULT 0.00;
+
+ALTER TABLE sales_items
+    ALTER COLUMN unit_price TYPE NUMERIC(12, 2) USING round(unit_price::numeric, 2);
+
+-- Prices are never negative.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'products_price_check') THEN
+        ALTER TABLE products ADD CONSTRAINT products_price_check CHECK (price >= 0);
+    END IF;
+END
+$$;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS refund_items;
+DROP TABLE IF EXISTS refunds;
+
+ALTER TABLE sales_items DROP COLUMN IF EXISTS returned_quantity;
+ALTER TABLE sales DROP COLUMN IF EXISTS status;
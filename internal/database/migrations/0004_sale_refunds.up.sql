@@ -0,0 +1,31 @@
+-- Sale cancellations and partial returns.
+
+ALTER TABLE sales
+    ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'completed'
+    CHECK (status IN ('completed', 'cancelled'));
+
+ALTER TABLE sales_items
+    ADD COLUMN IF NOT EXISTS returned_quantity INTEGER NOT NULL DEFAULT 0
+    CHECK (returned_quantity BETWEEN 0 AND quantity);
+
+CREATE TABLE IF NOT EXISTS refunds (
+    id SERIAL PRIMARY KEY,
+    sale_id INTEGER NOT NULL REFERENCES sales(id),
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    type TEXT NOT NULL CHECK (type IN ('cancellation', 'return')),
+    reason TEXT NOT NULL,
+    total_amount NUMERIC(12, 2) NOT NULL DEFAULT 0.00,
+    date TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS refund_items (
+    id SERIAL PRIMARY KEY,
+    refund_id INTEGER NOT NULL REFERENCES refunds(id),
+    product_id INTEGER NOT NULL REFERENCES products(id),
+    product_name TEXT NOT NULL,
+    quantity INTEGER NOT NULL,
+    unit_price NUMERIC(12, 2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_sale_id ON refunds (sale_id);
+CREATE INDEX IF NOT EXISTS idx_refund_items_refund_id ON refund_items (refund_id);
@@ -0,0 +1,23 @@
+-- Stock movement ledger.
+--
+-- Every product gets an 'initial' entry for its current quantity so the
+-- ledger reconciles from the moment it is introduced.
+
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    type TEXT NOT NULL CHECK (type IN ('initial', 'sale', 'adjustment', 'purchase_receipt', 'return', 'inventory_count')),
+    quantity_delta INTEGER NOT NULL,
+    reason TEXT NOT NULL,
+    sale_id INTEGER REFERENCES sales(id),
+    date TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_movements_product_id ON stock_movements (product_id);
+
+INSERT INTO stock_movements (product_id, type, quantity_delta, reason)
+SELECT p.id, 'initial', p.quantity, 'Opening balance'
+FROM products p
+WHERE p.quantity <> 0
+  AND NOT EXISTS (SELECT 1 FROM stock_movements m WHERE m.product_id = p.id);
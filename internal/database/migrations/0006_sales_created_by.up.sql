@@ -0,0 +1,9 @@
+-- Record who keyed each sale separately from the seller.
+--
+-- Historical sales are assumed to have been keyed by their seller.
+
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS created_by INTEGER REFERENCES users(id);
+
+UPDATE sales SET created_by = user_id WHERE created_by IS NULL;
+
+ALTER TABLE sales ALTER COLUMN created_by SET NOT NULL;
@@ -0,0 +1,75 @@
+-- Roles and permissions.
+--
+-- users.role keeps storing the role name, now constrained to the roles table.
+-- Any role value in use that is not one of the built-in roles is created
+-- without permissions so the foreign key can be added; grant it access via
+-- the /roles endpoints.
+
+-- Table: Roles
+-- Named groups of permissions assigned to users.
+CREATE TABLE IF NOT EXISTS roles (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+-- Table: Permissions
+-- Catalog of the permissions checked by the API.
+CREATE TABLE IF NOT EXISTS permissions (
+    code TEXT PRIMARY KEY,
+    description TEXT NOT NULL
+);
+
+-- Table: Role_Permissions
+-- Grants of permissions to roles.
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role TEXT NOT NULL REFERENCES roles(name) ON UPDATE CASCADE ON DELETE CASCADE,
+    permission TEXT NOT NULL REFERENCES permissions(code) ON UPDATE CASCADE ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+-- Built-in permissions and roles. The API relies on these existing.
+INSERT INTO permissions (code, description) VALUES
+('users:read', 'View users'),
+('users:write', 'Create, edit and delete users, change roles and revoke sessions'),
+('roles:manage', 'Manage roles and their permissions'),
+('products:write', 'Create, edit and delete products'),
+('stock:manage', 'Record stock movements and reconcile stock'),
+('sales:create', 'Register sales'),
+('sales:create_on_behalf', 'Register sales on behalf of another seller'),
+('sales:cancel', 'Cancel sales and process returns'),
+('reports:view', 'View store-wide dashboards and reports')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO roles (name, description) VALUES
+('admin', 'Administrador'),
+('gerente', 'Gerente de loja'),
+('vendedor', 'Vendedor')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission)
+SELECT 'admin', code FROM permissions
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+('gerente', 'users:read'),
+('gerente', 'products:write'),
+('gerente', 'stock:manage'),
+('gerente', 'sales:create'),
+('gerente', 'sales:create_on_behalf'),
+('gerente', 'sales:cancel'),
+('gerente', 'reports:view'),
+('vendedor', 'sales:create')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO roles (name)
+SELECT DISTINCT role FROM users
+ON CONFLICT (name) DO NOTHING;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'users_role_fkey') THEN
+        ALTER TABLE users
+            ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE;
+    END IF;
+END
+$$;
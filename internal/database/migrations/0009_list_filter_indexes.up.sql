@@ -0,0 +1,4 @@
+-- Index the columns used by the list endpoints' filters and sorting.
+
+CREATE INDEX IF NOT EXISTS idx_sales_date ON sales (date);
+CREATE INDEX IF NOT EXISTS idx_users_role ON users (role);
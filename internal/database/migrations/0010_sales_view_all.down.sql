@@ -0,0 +1,2 @@
+DELETE FROM role_permissions WHERE permission = 'sales:view_all';
+DELETE FROM permissions WHERE code = 'sales:view_all';
@@ -0,0 +1,10 @@
+-- Sellers only see their own sales; viewing everyone's requires sales:view_all.
+
+INSERT INTO permissions (code, description) VALUES
+('sales:view_all', 'View sales of every seller')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+('admin', 'sales:view_all'),
+('gerente', 'sales:view_all')
+ON CONFLICT DO NOTHING;
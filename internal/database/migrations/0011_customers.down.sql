@@ -0,0 +1,7 @@
+DELETE FROM role_permissions WHERE permission = 'customers:write';
+DELETE FROM permissions WHERE code = 'customers:write';
+
+DROP INDEX IF EXISTS idx_sales_customer_id;
+ALTER TABLE sales DROP COLUMN IF EXISTS customer_id;
+
+DROP TABLE IF EXISTS customers;
@@ -0,0 +1,28 @@
+-- Customer registry, optionally linked to sales.
+--
+-- document holds the CPF or CNPJ digits without punctuation; customers may be
+-- registered without one.
+
+CREATE TABLE IF NOT EXISTS customers (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    document TEXT UNIQUE,
+    phone TEXT NOT NULL DEFAULT '',
+    email TEXT NOT NULL DEFAULT '',
+    address TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE sales ADD COLUMN IF NOT EXISTS customer_id INTEGER REFERENCES customers(id);
+
+CREATE INDEX IF NOT EXISTS idx_sales_customer_id ON sales (customer_id);
+
+INSERT INTO permissions (code, description) VALUES
+('customers:write', 'Register and edit customers')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+('admin', 'customers:write'),
+('gerente', 'customers:write'),
+('vendedor', 'customers:write')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,17 @@
+-- Payments of each sale. A sale may be split across several payments whose
+-- amounts add up to its total.
+--
+-- installments is only set (1 or more) for credit card payments, and
+-- tendered/change only for cash; both are 0 otherwise.
+
+CREATE TABLE IF NOT EXISTS sale_payments (
+    id SERIAL PRIMARY KEY,
+    sale_id INTEGER NOT NULL REFERENCES sales(id),
+    method TEXT NOT NULL CHECK (method IN ('cash', 'pix', 'debit_card', 'credit_card', 'boleto')),
+    amount NUMERIC(12,2) NOT NULL CHECK (amount > 0),
+    installments INTEGER NOT NULL DEFAULT 0,
+    tendered NUMERIC(12,2) NOT NULL DEFAULT 0,
+    change NUMERIC(12,2) NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_sale_payments_sale_id ON sale_payments (sale_id);
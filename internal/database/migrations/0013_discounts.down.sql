@@ -0,0 +1,14 @@
+DELETE FROM role_permissions WHERE permission = 'discounts:approve';
+DELETE FROM permissions WHERE code = 'discounts:approve';
+
+ALTER TABLE roles DROP COLUMN IF EXISTS max_discount;
+
+ALTER TABLE sales
+    DROP COLUMN IF EXISTS discount_approved_by,
+    DROP COLUMN IF EXISTS discount;
+
+ALTER TABLE refund_items DROP COLUMN IF EXISTS total;
+
+ALTER TABLE sales_items
+    DROP COLUMN IF EXISTS refunded_amount,
+    DROP COLUMN IF EXISTS discount;
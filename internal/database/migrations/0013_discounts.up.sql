@@ -0,0 +1,36 @@
+-- Item and sale level discounts, with a per-role limit.
+--
+-- sales_items.discount is everything taken off the line: its own discount
+-- plus its share of the sale-level discount, which is kept on sales.discount
+-- for reference. refunded_amount tracks how much of the discounted line value
+-- has been refunded, so returns give back what the customer actually paid.
+--
+-- roles.max_discount is the largest discount, as a percentage of the sale
+-- subtotal, the role may give without approval; NULL means no limit.
+
+ALTER TABLE sales_items
+    ADD COLUMN IF NOT EXISTS discount NUMERIC(12,2) NOT NULL DEFAULT 0 CHECK (discount >= 0),
+    ADD COLUMN IF NOT EXISTS refunded_amount NUMERIC(12,2) NOT NULL DEFAULT 0;
+
+UPDATE sales_items SET refunded_amount = unit_price * returned_quantity;
+
+ALTER TABLE refund_items ADD COLUMN IF NOT EXISTS total NUMERIC(12,2) NOT NULL DEFAULT 0;
+
+UPDATE refund_items SET total = unit_price * quantity;
+
+ALTER TABLE sales
+    ADD COLUMN IF NOT EXISTS discount NUMERIC(12,2) NOT NULL DEFAULT 0 CHECK (discount >= 0),
+    ADD COLUMN IF NOT EXISTS discount_approved_by INTEGER REFERENCES users(id);
+
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS max_discount NUMERIC(5,2) CHECK (max_discount BETWEEN 0 AND 100);
+
+UPDATE roles SET max_discount = 10 WHERE name = 'gerente';
+UPDATE roles SET max_discount = 5 WHERE name = 'vendedor';
+
+INSERT INTO permissions (code, description) VALUES
+('discounts:approve', 'Give and approve discounts above the role limit')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+('admin', 'discounts:approve')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,12 @@
+DELETE FROM role_permissions WHERE permission = 'promotions:manage';
+DELETE FROM permissions WHERE code = 'promotions:manage';
+
+DROP INDEX IF EXISTS idx_sales_items_promotion_id;
+
+ALTER TABLE sales_items
+    DROP COLUMN IF EXISTS promotion_discount,
+    DROP COLUMN IF EXISTS promotion_id;
+
+DROP TABLE IF EXISTS promotions;
+
+ALTER TABLE products DROP COLUMN IF EXISTS category;
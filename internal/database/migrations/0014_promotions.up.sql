@@ -0,0 +1,51 @@
+-- Promotions defined by admins and applied automatically at the register.
+--
+-- A promotion either takes a percentage off matching items or, for
+-- buy_x_get_y, gives free_quantity units free for every buy_quantity +
+-- free_quantity units on a line. It matches every product unless narrowed to
+-- one product or one category. Promotions with a code are coupons and only
+-- apply when the code is given; the others apply to every sale while active.
+--
+-- starts_at/ends_at bound when the promotion is valid at all, and
+-- happy_hour_start/happy_hour_end the time of day it runs, wrapping past
+-- midnight if the end comes first. usage_count counts the sales it was
+-- applied to, up to usage_limit if set.
+--
+-- Each sale line gets at most one promotion; sales_items.promotion_discount is
+-- the part of sales_items.discount it gave.
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS category TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS promotions (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('percentage', 'buy_x_get_y')),
+    code TEXT UNIQUE,
+    percentage NUMERIC(5,2) NOT NULL DEFAULT 0 CHECK (percentage BETWEEN 0 AND 100),
+    buy_quantity INTEGER NOT NULL DEFAULT 0,
+    free_quantity INTEGER NOT NULL DEFAULT 0,
+    product_id INTEGER REFERENCES products(id) ON DELETE CASCADE,
+    category TEXT NOT NULL DEFAULT '',
+    starts_at TIMESTAMP WITH TIME ZONE,
+    ends_at TIMESTAMP WITH TIME ZONE,
+    happy_hour_start TIME,
+    happy_hour_end TIME,
+    usage_limit INTEGER CHECK (usage_limit > 0),
+    usage_count INTEGER NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE sales_items
+    ADD COLUMN IF NOT EXISTS promotion_id INTEGER REFERENCES promotions(id),
+    ADD COLUMN IF NOT EXISTS promotion_discount NUMERIC(12,2) NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_sales_items_promotion_id ON sales_items (promotion_id);
+
+INSERT INTO permissions (code, description) VALUES
+('promotions:manage', 'Create, edit and delete promotions and coupons')
+ON CONFLICT (code) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+('admin', 'promotions:manage')
+ON CONFLICT DO NOTHING;
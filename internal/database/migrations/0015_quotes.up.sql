@@ -0,0 +1,32 @@
+-- Quotes (orçamentos): a priced cart handed to a customer who may buy later.
+--
+-- quote_items lock the unit price and item discount at the time of the quote;
+-- converting the quote within valid_until charges them instead of the current
+-- prices. Stock is only touched on conversion, which links the sale through
+-- sale_id. A quote past valid_until that was never converted is expired.
+
+CREATE TABLE IF NOT EXISTS quotes (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    created_by INTEGER NOT NULL REFERENCES users(id),
+    customer_id INTEGER REFERENCES customers(id),
+    notes TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'converted')),
+    valid_until TIMESTAMP WITH TIME ZONE NOT NULL,
+    sale_id INTEGER REFERENCES sales(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS quote_items (
+    id SERIAL PRIMARY KEY,
+    quote_id INTEGER NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products(id),
+    product_name TEXT NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    unit_price NUMERIC(12,2) NOT NULL,
+    discount NUMERIC(12,2) NOT NULL DEFAULT 0 CHECK (discount >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quotes_user_id ON quotes (user_id);
+CREATE INDEX IF NOT EXISTS idx_quotes_customer_id ON quotes (customer_id);
+CREATE INDEX IF NOT EXISTS idx_quote_items_quote_id ON quote_items (quote_id);
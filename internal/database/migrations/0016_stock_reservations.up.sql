@@ -0,0 +1,23 @@
+-- Stock reservations hold units of a product for a customer without selling
+-- them.
+--
+-- A product's on-hand stock is products.quantity; its available stock is that
+-- minus the quantity of its active reservations that have not expired yet.
+-- Sales only charge available stock, unless they consume the reservation.
+-- A background worker moves active reservations past expires_at to 'expired';
+-- until it runs they already stop counting against available stock.
+
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    quote_id INTEGER REFERENCES quotes(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    notes TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'consumed', 'released', 'expired')),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_active ON stock_reservations (product_id) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_quote_id ON stock_reservations (quote_id);
@@ -0,0 +1,2 @@
+ALTER TABLE sales_items DROP COLUMN IF EXISTS unit_cost;
+ALTER TABLE products DROP COLUMN IF EXISTS cost_price;
@@ -0,0 +1,13 @@
+-- Cost prices and gross margins.
+--
+-- products.cost_price is the weighted average cost of the units on hand. It
+-- moves whenever stock is received at a known unit cost. Each sale line
+-- keeps the cost price of its product at the time of the sale in unit_cost,
+-- so margins of past sales don't change with later purchases. Lines sold
+-- before this migration have no known cost and keep 0.
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS cost_price NUMERIC(12,2) NOT NULL DEFAULT 0 CHECK (cost_price >= 0);
+
+ALTER TABLE sales_items
+    ADD COLUMN IF NOT EXISTS unit_cost NUMERIC(12,2) NOT NULL DEFAULT 0 CHECK (unit_cost >= 0);
@@ -0,0 +1,12 @@
+-- Categories go back to free-text labels. Products and promotions keep the
+-- name of the category they were in, losing the hierarchy.
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS category TEXT NOT NULL DEFAULT '';
+UPDATE products p SET category = c.name FROM categories c WHERE c.id = p.category_id;
+ALTER TABLE products DROP COLUMN IF EXISTS category_id;
+
+ALTER TABLE promotions ADD COLUMN IF NOT EXISTS category TEXT NOT NULL DEFAULT '';
+UPDATE promotions p SET category = c.name FROM categories c WHERE c.id = p.category_id;
+ALTER TABLE promotions DROP COLUMN IF EXISTS category_id;
+
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,58 @@
+-- Hierarchical product categories.
+--
+-- Categories form a tree through parent_id; top-level categories (no
+-- parent) are the store's departments. Sibling names are unique regardless
+-- of case. A product belongs to at most one category, and listing or
+-- promoting a category covers every category below it.
+--
+-- The free-text products.category and promotions.category labels become
+-- top-level categories, matched case-insensitively, and are replaced by
+-- category_id.
+
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    parent_id INTEGER REFERENCES categories(id),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (parent_id <> id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS categories_sibling_name_key ON categories (COALESCE(parent_id, 0), LOWER(name));
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES categories(id);
+ALTER TABLE promotions ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES categories(id);
+
+-- The labels are only there to convert the first time through.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'products' AND column_name = 'category'
+    ) THEN
+        RETURN;
+    END IF;
+
+    INSERT INTO categories (name)
+    SELECT DISTINCT ON (LOWER(label)) label
+    FROM (
+        SELECT TRIM(category) AS label FROM products
+        UNION ALL
+        SELECT TRIM(category) FROM promotions
+    ) labels
+    WHERE label <> ''
+    ORDER BY LOWER(label), label;
+
+    UPDATE products p SET category_id = c.id
+    FROM categories c
+    WHERE c.parent_id IS NULL AND LOWER(c.name) = LOWER(TRIM(p.category));
+    UPDATE promotions p SET category_id = c.id
+    FROM categories c
+    WHERE c.parent_id IS NULL AND LOWER(c.name) = LOWER(TRIM(p.category));
+
+    ALTER TABLE products DROP COLUMN IF EXISTS category;
+    ALTER TABLE promotions DROP COLUMN IF EXISTS category;
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id);
+CREATE INDEX IF NOT EXISTS idx_products_category_id ON products(category_id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS products_barcode_key;
+DROP INDEX IF EXISTS products_sku_key;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS barcode,
+    DROP COLUMN IF EXISTS sku;
@@ -0,0 +1,12 @@
+-- SKUs and barcodes, so products can be found by the code on their label.
+--
+-- Both are optional and unique when set. sku is the store's own code, kept
+-- in upper case; barcode holds the digits of a GTIN (EAN-13, EAN-8, UPC-A or
+-- GTIN-14), whose check digit the application validates.
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS sku TEXT CHECK (sku <> ''),
+    ADD COLUMN IF NOT EXISTS barcode TEXT CHECK (barcode ~ '^[0-9]{8}$|^[0-9]{12,14}$');
+
+CREATE UNIQUE INDEX IF NOT EXISTS products_sku_key ON products (sku);
+CREATE UNIQUE INDEX IF NOT EXISTS products_barcode_key ON products (barcode);
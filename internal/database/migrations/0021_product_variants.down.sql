@@ -0,0 +1,41 @@
+-- Variant stock folds back into the product quantity, which already holds it.
+
+-- Purchase order lines of the variants of a product merge into one, at
+-- their average cost, so each product appears once per order again.
+DROP INDEX IF EXISTS purchase_order_items_line_key;
+WITH merged AS (
+    SELECT MIN(id) AS id, purchase_order_id, product_id,
+           SUM(quantity) AS quantity, SUM(received_quantity) AS received_quantity,
+           ROUND(SUM(unit_cost * quantity) / SUM(quantity), 2) AS unit_cost
+    FROM purchase_order_items
+    GROUP BY purchase_order_id, product_id
+    HAVING COUNT(*) > 1
+), kept AS (
+    UPDATE purchase_order_items poi
+    SET quantity = m.quantity, received_quantity = m.received_quantity, unit_cost = m.unit_cost
+    FROM merged m
+    WHERE poi.id = m.id
+)
+DELETE FROM purchase_order_items poi
+USING merged m
+WHERE poi.purchase_order_id = m.purchase_order_id AND poi.product_id = m.product_id AND poi.id <> m.id;
+ALTER TABLE purchase_order_items DROP COLUMN IF EXISTS variant_id;
+CREATE UNIQUE INDEX IF NOT EXISTS purchase_order_items_purchase_order_id_product_id_key ON purchase_order_items (purchase_order_id, product_id);
+
+ALTER TABLE stock_reservations DROP COLUMN IF EXISTS variant_id;
+
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS variant_id;
+
+ALTER TABLE refund_items
+    DROP COLUMN IF EXISTS variant_name,
+    DROP COLUMN IF EXISTS variant_id;
+
+ALTER TABLE quote_items
+    DROP COLUMN IF EXISTS variant_name,
+    DROP COLUMN IF EXISTS variant_id;
+
+ALTER TABLE sales_items
+    DROP COLUMN IF EXISTS variant_name,
+    DROP COLUMN IF EXISTS variant_id;
+
+DROP TABLE IF EXISTS product_variants;
@@ -0,0 +1,47 @@
+-- Product variants, such as the sizes and colors of a garment.
+--
+-- Each variant has its own stock and, optionally, its own price; without
+-- one it sells at the product price. A product with variants keeps in
+-- quantity the sum of their stock, so everything that looks at product
+-- stock keeps working. Sale, quote, refund and purchase order lines, stock
+-- movements and stock reservations record the variant they are about, and
+-- sale, quote and refund lines keep its name as sold. A purchase order has
+-- one line per product or variant.
+
+CREATE TABLE IF NOT EXISTS product_variants (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    attributes JSONB NOT NULL DEFAULT '{}',
+    price NUMERIC(12,2) CHECK (price >= 0),
+    quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS product_variants_name_key ON product_variants (product_id, LOWER(name));
+
+ALTER TABLE sales_items
+    ADD COLUMN IF NOT EXISTS variant_id INTEGER REFERENCES product_variants(id),
+    ADD COLUMN IF NOT EXISTS variant_name TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE quote_items
+    ADD COLUMN IF NOT EXISTS variant_id INTEGER REFERENCES product_variants(id),
+    ADD COLUMN IF NOT EXISTS variant_name TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE refund_items
+    ADD COLUMN IF NOT EXISTS variant_id INTEGER REFERENCES product_variants(id),
+    ADD COLUMN IF NOT EXISTS variant_name TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE stock_movements
+    ADD COLUMN IF NOT EXISTS variant_id INTEGER REFERENCES product_variants(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS stock_movements_variant_id_idx ON stock_movements (variant_id);
+
+ALTER TABLE stock_reservations
+    ADD COLUMN IF NOT EXISTS variant_id INTEGER REFERENCES product_variants(id) ON DELETE CASCADE;
+
+ALTER TABLE purchase_order_items
+    ADD COLUMN IF NOT EXISTS variant_id INTEGER REFERENCES product_variants(id),
+    DROP CONSTRAINT IF EXISTS purchase_order_items_purchase_order_id_product_id_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS purchase_order_items_line_key ON purchase_order_items (purchase_order_id, product_id, COALESCE(variant_id, 0));
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS sales_item_components;
+DROP TABLE IF EXISTS bundle_components;
@@ -0,0 +1,30 @@
+-- Bundles: products sold as a kit of other products.
+--
+-- A bundle has no stock of its own; selling one takes its components out of
+-- stock, and how many bundles are available follows from their stock.
+-- Every bundle sale line records the components consumed per bundle and
+-- the share of the line's revenue attributed to each, so returns restock
+-- what was actually sold and reports can credit the components.
+
+CREATE TABLE IF NOT EXISTS bundle_components (
+    bundle_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products(id),
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (bundle_id, product_id),
+    CHECK (bundle_id <> product_id)
+);
+
+CREATE INDEX IF NOT EXISTS bundle_components_product_id_idx ON bundle_components (product_id);
+
+CREATE TABLE IF NOT EXISTS sales_item_components (
+    id SERIAL PRIMARY KEY,
+    sale_item_id INTEGER NOT NULL REFERENCES sales_items(id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products(id),
+    product_name TEXT NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    unit_cost NUMERIC(12,2) NOT NULL DEFAULT 0,
+    revenue NUMERIC(12,2) NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS sales_item_components_sale_item_id_idx ON sales_item_components (sale_item_id);
+CREATE INDEX IF NOT EXISTS sales_item_components_product_id_idx ON sales_item_components (product_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS sales_item_lots;
+DROP TABLE IF EXISTS product_lots;
@@ -0,0 +1,32 @@
+-- Lots (batches) of a product with their expiry dates, for food, cosmetics
+-- and anything else that goes off.
+--
+-- A product with lots keeps in quantity the sum of their stock. Sales take
+-- stock from the unexpired lots that expire first (FEFO) and record on each
+-- line the lots they took from, with the lot code and expiry as sold, so
+-- returns go back to the same lots and batches can be traced to sales.
+
+CREATE TABLE IF NOT EXISTS product_lots (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    code TEXT NOT NULL,
+    expires_on DATE NOT NULL,
+    quantity INTEGER NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS product_lots_code_key ON product_lots (product_id, LOWER(code));
+CREATE INDEX IF NOT EXISTS product_lots_expires_on_idx ON product_lots (expires_on) WHERE quantity > 0;
+
+CREATE TABLE IF NOT EXISTS sales_item_lots (
+    id SERIAL PRIMARY KEY,
+    sale_item_id INTEGER NOT NULL REFERENCES sales_items(id) ON DELETE CASCADE,
+    lot_id INTEGER NOT NULL REFERENCES product_lots(id),
+    product_id INTEGER NOT NULL REFERENCES products(id),
+    code TEXT NOT NULL,
+    expires_on DATE NOT NULL,
+    quantity INTEGER NOT NULL CHECK (quantity > 0)
+);
+
+CREATE INDEX IF NOT EXISTS sales_item_lots_sale_item_id_idx ON sales_item_lots (sale_item_id);
+CREATE INDEX IF NOT EXISTS sales_item_lots_lot_id_idx ON sales_item_lots (lot_id);
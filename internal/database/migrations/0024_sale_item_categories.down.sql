@@ -0,0 +1 @@
+ALTER TABLE sales_items DROP COLUMN IF EXISTS category_id;
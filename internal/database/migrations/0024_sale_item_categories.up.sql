@@ -0,0 +1,14 @@
+-- Category of each sale line as sold.
+--
+-- Revenue per category follows the category a product was in when it was
+-- sold, so moving a product to another category doesn't rewrite past
+-- reports. Lines sold before this migration take the category their
+-- product is in now, the best guess left.
+
+ALTER TABLE sales_items ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES categories(id);
+
+UPDATE sales_items si SET category_id = p.category_id
+FROM products p
+WHERE p.id = si.product_id AND si.category_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_sales_items_category_id ON sales_items(category_id);
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"gestor-simples-ecs/pkg/money"
+	"time"
+)
 
 type User struct {
 	ID           int64  `json:"id"`
@@ -10,27 +13,512 @@ type User struct {
 	Role         string `json:"role"`
 }
 
+// Role groups permissions; users reference roles by name.
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	// MaxDiscount is the largest discount, as a percentage of the sale
+	// subtotal, the role may give without approval. Nil means no limit.
+	MaxDiscount *money.Amount `json:"maxDiscount"`
+}
+
+type Permission struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
 type Product struct {
-	ID          int64   `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Quantity    int     `json:"quantity"`
+	ID          int64        `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	SKU         string       `json:"sku"`     // Optional store code, unique
+	Barcode     string       `json:"barcode"` // Optional GTIN digits (EAN-13 and the like), unique
+	CategoryID  *int64       `json:"categoryId"`
+	Category    string       `json:"category"` // Name of the category; read-only
+	Price       money.Amount `json:"price"`
+	CostPrice   money.Amount `json:"costPrice"` // Weighted average cost of the units on hand
+	Quantity    int          `json:"quantity"`  // On hand
+	Reserved    int          `json:"reserved"`  // Held by active reservations; read-only
+	Available   int          `json:"available"` // Quantity minus Reserved; read-only
+
+	Variants   []ProductVariant  `json:"variants,omitempty"`   // Read-only; see ProductVariant
+	Components []BundleComponent `json:"components,omitempty"` // Read-only; set for bundles
+	Lots       []ProductLot      `json:"lots,omitempty"`       // Read-only; see ProductLot
+}
+
+// ProductLot is a batch of a product with its expiry date. The stock of a
+// product with lots is the sum of theirs, and sales take it from the
+// unexpired lots that expire first. A lot is still sold on ExpiresOn.
+type ProductLot struct {
+	ID          int64     `json:"id"`
+	ProductID   int64     `json:"productId"`
+	ProductName string    `json:"productName,omitempty"` // Read-only; set in the expiring lots listing
+	Code        string    `json:"code"`                  // Lot number as printed, unique within the product
+	ExpiresOn   time.Time `json:"expiresOn"`             // Date only
+	Quantity    int       `json:"quantity"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// BundleComponent is a product that goes into a bundle and how many units of
+// it each bundle takes. A bundle has no stock of its own: its Quantity and
+// Available are how many bundles the components' available stock makes.
+type BundleComponent struct {
+	BundleID    int64  `json:"-"`
+	ProductID   int64  `json:"productId"`
+	ProductName string `json:"productName"` // Read-only
+	Quantity    int    `json:"quantity"`    // Units per bundle
+	Available   int    `json:"available"`   // Available stock of the component; read-only
+}
+
+// ProductVariant is one version of a product, such as a size and color of a
+// garment, with its own stock. The stock of a product with variants is the
+// sum of theirs.
+type ProductVariant struct {
+	ID         int64             `json:"id"`
+	ProductID  int64             `json:"productId"`
+	Name       string            `json:"name"`       // Unique within the product, e.g. "M / Azul"
+	Attributes map[string]string `json:"attributes"` // Optional, e.g. {"size": "M", "color": "Azul"}
+	Price      *money.Amount     `json:"price"`      // Optional; nil sells at the product price
+	Quantity   int               `json:"quantity"`   // On hand
+	Reserved   int               `json:"reserved"`   // Held by active reservations of the variant; read-only
+	Available  int               `json:"available"`  // Quantity minus Reserved; read-only
+	CreatedAt  time.Time         `json:"createdAt"`
+}
+
+// Category groups products in a tree. Top-level categories (without a
+// parent) are departments.
+type Category struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	ParentID  *int64     `json:"parentId"`
+	CreatedAt time.Time  `json:"createdAt"`
+	Children  []Category `json:"children,omitempty"` // Filled in the category tree only
+}
+
+// BundleRevenue sums the sale lines of a bundle and splits their revenue
+// among its components.
+type BundleRevenue struct {
+	BundleID   int64              `json:"bundleId"`
+	Name       string             `json:"name"`
+	UnitsSold  int                `json:"unitsSold"`
+	Revenue    money.Amount       `json:"revenue"`
+	Components []ComponentRevenue `json:"components"`
+}
+
+// ComponentRevenue is the part of a bundle's sales attributed to one of its
+// components.
+type ComponentRevenue struct {
+	ProductID int64        `json:"productId"`
+	Name      string       `json:"name"`
+	UnitsSold int          `json:"unitsSold"`
+	Revenue   money.Amount `json:"revenue"`
+}
+
+// CategoryRevenue sums the sale lines of the products in a category and
+// every category below it. CategoryID is nil for products without one.
+type CategoryRevenue struct {
+	CategoryID *int64       `json:"categoryId"`
+	Name       string       `json:"name"`
+	UnitsSold  int          `json:"unitsSold"`
+	Revenue    money.Amount `json:"revenue"`
+}
+
+// Stock movement types.
+const (
+	StockMovementInitial         = "initial"
+	StockMovementSale            = "sale"
+	StockMovementAdjustment      = "adjustment"
+	StockMovementPurchaseReceipt = "purchase_receipt"
+	StockMovementReturn          = "return"
+	StockMovementInventoryCount  = "inventory_count"
+)
+
+// StockMovement is one entry of the stock ledger. The sum of QuantityDelta
+// for a product always equals its current quantity.
+type StockMovement struct {
+	ID              int64     `json:"id"`
+	ProductID       int64     `json:"productId"`
+	VariantID       *int64    `json:"variantId,omitempty"`
+	UserID          *int64    `json:"userId"`
+	Type            string    `json:"type"`
+	QuantityDelta   int       `json:"quantityDelta"`
+	Reason          string    `json:"reason"`
+	SaleID          *int64    `json:"saleId,omitempty"`
+	PurchaseOrderID *int64    `json:"purchaseOrderId,omitempty"` // Set on purchase order receipts
+	Date            time.Time `json:"date"`
+}
+
+// StockDiscrepancy reports a product, or a variant of it, whose quantity
+// disagrees with its ledger.
+type StockDiscrepancy struct {
+	ProductID      int64  `json:"productId"`
+	ProductName    string `json:"productName"`
+	VariantID      *int64 `json:"variantId,omitempty"`
+	VariantName    string `json:"variantName,omitempty"`
+	Quantity       int    `json:"quantity"`
+	LedgerQuantity int    `json:"ledgerQuantity"`
+}
+
+type Customer struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Document  string    `json:"document"` // CPF or CNPJ digits, or "" if not informed
+	Phone     string    `json:"phone"`
+	Email     string    `json:"email"`
+	Address   string    `json:"address"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type Supplier struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Document  string    `json:"document"` // CNPJ or CPF digits, or "" if not informed
+	Phone     string    `json:"phone"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Purchase order statuses.
+const (
+	PurchaseOrderStatusOpen              = "open"
+	PurchaseOrderStatusPartiallyReceived = "partially_received"
+	PurchaseOrderStatusReceived          = "received"
+	PurchaseOrderStatusCancelled         = "cancelled"
+)
+
+// PurchaseOrder is stock ordered from a supplier. Receiving its items adds
+// them to the stock of the products, and of the variants ordered.
+type PurchaseOrder struct {
+	ID           int64               `json:"id"`
+	SupplierID   int64               `json:"supplierId"`
+	SupplierName string              `json:"supplierName"`
+	CreatedBy    int64               `json:"createdBy"`
+	Status       string              `json:"status"`
+	ExpectedAt   *time.Time          `json:"expectedAt"`
+	Notes        string              `json:"notes"`
+	CreatedAt    time.Time           `json:"createdAt"`
+	Items        []PurchaseOrderItem `json:"items"`
+	TotalCost    money.Amount        `json:"totalCost"` // Of every unit ordered
+}
+
+type PurchaseOrderItem struct {
+	ProductID        int64        `json:"productId"`
+	ProductName      string       `json:"productName"`
+	VariantID        *int64       `json:"variantId"` // Set for products with variants
+	VariantName      string       `json:"variantName"`
+	Quantity         int          `json:"quantity"`
+	ReceivedQuantity int          `json:"receivedQuantity"`
+	UnitCost         money.Amount `json:"unitCost"`
+}
+
+// IncomingStock is a purchase order line still waiting for units.
+type IncomingStock struct {
+	PurchaseOrderID int64        `json:"purchaseOrderId"`
+	SupplierID      int64        `json:"supplierId"`
+	SupplierName    string       `json:"supplierName"`
+	ProductID       int64        `json:"productId"`
+	ProductName     string       `json:"productName"`
+	VariantID       *int64       `json:"variantId"`
+	VariantName     string       `json:"variantName"`
+	Outstanding     int          `json:"outstanding"`
+	UnitCost        money.Amount `json:"unitCost"`
+	ExpectedAt      *time.Time   `json:"expectedAt"`
+}
+
+// CustomerMetrics summarizes a customer's purchases. Cancelled sales are left
+// out and returned items are subtracted.
+type CustomerMetrics struct {
+	CustomerID      int64        `json:"customerId"`
+	PurchaseCount   int          `json:"purchaseCount"`
+	LifetimeValue   money.Amount `json:"lifetimeValue"`
+	AverageTicket   money.Amount `json:"averageTicket"`
+	FirstPurchaseAt *time.Time   `json:"firstPurchaseAt"`
+	LastPurchaseAt  *time.Time   `json:"lastPurchaseAt"`
 }
 
+// Sale statuses.
+const (
+	SaleStatusCompleted = "completed"
+	SaleStatusCancelled = "cancelled"
+)
+
+// Sale is a sale with its items and totals. Subtotal is the undiscounted
+// value of the items, DiscountTotal every discount given on them (including
+// the sale-level SaleDiscount) and TotalPrice what the customer paid.
 type Sale struct {
-	ID         int64     `json:"id"`
-	UserID     int64     `json:"userId"`
-	Date       time.Time `json:"date"`
-	Items      []SaleItem `json:"items"`
-	TotalPrice float64   `json:"totalPrice"`
+	ID                 int64        `json:"id"`
+	UserID             int64        `json:"userId"` // Seller credited with the sale
+	SellerName         string       `json:"sellerName"`
+	CreatedBy          int64        `json:"createdBy"` // User who keyed the sale
+	CustomerID         *int64       `json:"customerId"`
+	CustomerName       string       `json:"customerName,omitempty"`
+	Date               time.Time    `json:"date"`
+	Status             string       `json:"status"`
+	Items              []SaleItem   `json:"items"`
+	Subtotal           money.Amount `json:"subtotal"`
+	SaleDiscount       money.Amount `json:"saleDiscount"`
+	DiscountTotal      money.Amount `json:"discountTotal"`
+	DiscountApprovedBy *int64       `json:"discountApprovedBy,omitempty"`
+	TotalPrice         money.Amount `json:"totalPrice"`
+	RefundedTotal      money.Amount `json:"refundedTotal"`
+	CostTotal          money.Amount `json:"costTotal"`   // Cost of the units not returned
+	GrossMargin        money.Amount `json:"grossMargin"` // TotalPrice minus RefundedTotal and CostTotal
+}
+
+// SaleDetail is a single sale with the name of who keyed it, how it was
+// paid and its refunds.
+type SaleDetail struct {
+	Sale
+	CreatedByName string    `json:"createdByName"`
+	Payments      []Payment `json:"payments"`
+	Refunds       []Refund  `json:"refunds"`
+}
+
+// Payment methods.
+const (
+	PaymentMethodCash       = "cash"
+	PaymentMethodPix        = "pix"
+	PaymentMethodDebitCard  = "debit_card"
+	PaymentMethodCreditCard = "credit_card"
+	PaymentMethodBoleto     = "boleto"
+)
+
+// PaymentMethods lists every accepted payment method.
+var PaymentMethods = []string{
+	PaymentMethodCash,
+	PaymentMethodPix,
+	PaymentMethodDebitCard,
+	PaymentMethodCreditCard,
+	PaymentMethodBoleto,
+}
+
+// Payment is one part of how a sale was paid. Amount is what it covers of the
+// sale total; for cash, Tendered is what the customer handed over and Change
+// what was given back.
+type Payment struct {
+	Method       string       `json:"method"`
+	Amount       money.Amount `json:"amount"`
+	Installments int          `json:"installments,omitempty"` // Credit card only
+	Tendered     money.Amount `json:"tendered,omitempty"`     // Cash only
+	Change       money.Amount `json:"change,omitempty"`       // Cash only
 }
 
+// SaleItem is a line of a sale or refund. On a sale, Discount is everything
+// taken off the line (the promotion applied to it, its own discount and its
+// share of the sale-level one) and Total the line value after it; on a
+// refund, Total is the amount refunded.
 type SaleItem struct {
-	ProductID   int64   `json:"productId"`
-	ProductName string  `json:"productName,omitempty"`
-	Quantity    int     `json:"quantity"`
-	UnitPrice   float64 `json:"unitPrice,omitempty"`
+	ProductID         int64        `json:"productId"`
+	ProductName       string       `json:"productName,omitempty"`
+	VariantID         *int64       `json:"variantId,omitempty"`
+	VariantName       string       `json:"variantName,omitempty"`
+	CategoryID        *int64       `json:"categoryId,omitempty"` // Product category when sold
+	Quantity          int          `json:"quantity"`
+	ReturnedQuantity  int          `json:"returnedQuantity,omitempty"`
+	UnitPrice         money.Amount `json:"unitPrice,omitempty"`
+	UnitCost          money.Amount `json:"unitCost,omitempty"` // Product cost price when sold
+	Discount          money.Amount `json:"discount,omitempty"`
+	PromotionID       *int64       `json:"promotionId,omitempty"`
+	PromotionDiscount money.Amount `json:"promotionDiscount,omitempty"` // Part of Discount
+	Total             money.Amount `json:"total,omitempty"`
+	RefundedAmount    money.Amount `json:"refundedAmount,omitempty"`
+
+	Components []SaleItemComponent `json:"components,omitempty"` // Set on bundle lines
+	Lots       []SaleItemLot       `json:"lots,omitempty"`       // Lots the line took stock from
+}
+
+// SaleItemLot is how many units of a product a sale line took from one lot,
+// with the lot's code and expiry as sold. On bundle lines ProductID is the
+// component's.
+type SaleItemLot struct {
+	LotID     int64     `json:"lotId"`
+	ProductID int64     `json:"productId"`
+	Code      string    `json:"code"`
+	ExpiresOn time.Time `json:"expiresOn"`
+	Quantity  int       `json:"quantity"`
+}
+
+// SaleItemComponent is a product a bundle sale line took out of stock, per
+// bundle, with the share of the line's discounted value attributed to it
+// in proportion to the component's price.
+type SaleItemComponent struct {
+	ProductID   int64        `json:"productId"`
+	ProductName string       `json:"productName"`
+	Quantity    int          `json:"quantity"` // Units per bundle
+	UnitCost    money.Amount `json:"unitCost"`
+	Revenue     money.Amount `json:"revenue"`
+}
+
+// Discount types.
+const (
+	DiscountTypePercentage = "percentage"
+	DiscountTypeAmount     = "amount"
+)
+
+// Discount is a requested discount. Value is a percentage ("12.5" is 12.5%)
+// or a fixed amount, depending on Type.
+type Discount struct {
+	Type  string       `json:"type"`
+	Value money.Amount `json:"value"`
+}
+
+// Promotion types.
+const (
+	PromotionTypePercentage = "percentage"  // Percentage off matching items
+	PromotionTypeBuyXGetY   = "buy_x_get_y" // FreeQuantity of every BuyQuantity+FreeQuantity units free
+)
+
+// Promotion is a discount applied automatically to matching sale lines.
+// It matches every product unless narrowed to ProductID or to CategoryID and
+// the categories below it. With a Code it is a coupon and only applies when
+// the code is given at checkout. StartsAt and EndsAt bound when it is valid;
+// HappyHourStart and HappyHourEnd ("HH:MM", server time) the time of day it
+// runs, wrapping past midnight if the end comes first.
+type Promotion struct {
+	ID             int64        `json:"id"`
+	Name           string       `json:"name"`
+	Type           string       `json:"type"`
+	Code           string       `json:"code,omitempty"`
+	Percentage     money.Amount `json:"percentage,omitempty"` // "12.5" is 12.5%
+	BuyQuantity    int          `json:"buyQuantity,omitempty"`
+	FreeQuantity   int          `json:"freeQuantity,omitempty"`
+	ProductID      *int64       `json:"productId"`
+	CategoryID     *int64       `json:"categoryId"`
+	StartsAt       *time.Time   `json:"startsAt"`
+	EndsAt         *time.Time   `json:"endsAt"`
+	HappyHourStart string       `json:"happyHourStart,omitempty"`
+	HappyHourEnd   string       `json:"happyHourEnd,omitempty"`
+	UsageLimit     *int         `json:"usageLimit"` // Sales it may apply to; nil is unlimited
+	UsageCount     int          `json:"usageCount"`
+	Active         bool         `json:"active"`
+	CreatedAt      time.Time    `json:"createdAt"`
+}
+
+// PromotionReport sums the sale lines a promotion was applied to. Cancelled
+// sales are left out; Revenue is net of discounts and refunds.
+type PromotionReport struct {
+	PromotionID   int64        `json:"promotionId"`
+	Name          string       `json:"name"`
+	Code          string       `json:"code,omitempty"`
+	SalesCount    int          `json:"salesCount"`
+	UnitsSold     int          `json:"unitsSold"`
+	Revenue       money.Amount `json:"revenue"`
+	DiscountGiven money.Amount `json:"discountGiven"`
+}
+
+// MarginReport is the gross margin of the sale lines of one product or
+// seller. Cancelled sales are left out; Revenue is net of discounts and
+// refunds and Cost counts only the units not returned.
+type MarginReport struct {
+	ID          int64        `json:"id"`
+	Name        string       `json:"name"`
+	UnitsSold   int          `json:"unitsSold"`
+	Revenue     money.Amount `json:"revenue"`
+	Cost        money.Amount `json:"cost"`
+	GrossMargin money.Amount `json:"grossMargin"`
+}
+
+// Quote statuses. Expired is never stored: it is reported for open quotes
+// past their validity.
+const (
+	QuoteStatusOpen      = "open"
+	QuoteStatusConverted = "converted"
+	QuoteStatusExpired   = "expired"
+)
+
+// Quote is a priced cart for a customer who may buy later. Its items lock
+// the unit price and item discount until ValidUntil; converting it places a
+// sale charged at those prices.
+type Quote struct {
+	ID            int64        `json:"id"`
+	UserID        int64        `json:"userId"` // Seller credited with the quote and its sale
+	SellerName    string       `json:"sellerName"`
+	CreatedBy     int64        `json:"createdBy"`
+	CustomerID    *int64       `json:"customerId"`
+	CustomerName  string       `json:"customerName,omitempty"`
+	Notes         string       `json:"notes"`
+	Status        string       `json:"status"`
+	ValidUntil    time.Time    `json:"validUntil"`
+	SaleID        *int64       `json:"saleId"` // Set once converted
+	CreatedAt     time.Time    `json:"createdAt"`
+	Items         []SaleItem   `json:"items"`
+	Subtotal      money.Amount `json:"subtotal"`
+	DiscountTotal money.Amount `json:"discountTotal"`
+	TotalPrice    money.Amount `json:"totalPrice"`
+}
+
+// OutOfStockItem is a quoted product without enough available stock to
+// convert or reserve the quote.
+type OutOfStockItem struct {
+	ProductID   int64  `json:"productId"`
+	ProductName string `json:"productName"`
+	VariantID   *int64 `json:"variantId"` // Set when the variant is short
+	VariantName string `json:"variantName"`
+	Requested   int    `json:"requested"`
+	Available   int    `json:"available"`
+}
+
+// Stock reservation statuses. Active reservations past ExpiresAt are
+// reported as expired even before the expiry worker marks them.
+const (
+	ReservationStatusActive   = "active"
+	ReservationStatusConsumed = "consumed"
+	ReservationStatusReleased = "released"
+	ReservationStatusExpired  = "expired"
+)
+
+// StockReservation holds units of a product out of its available stock
+// until it expires, is released or is consumed by a sale. Reservations of
+// products with variants hold units of one variant, which count against
+// both the variant and the product.
+type StockReservation struct {
+	ID          int64     `json:"id"`
+	ProductID   int64     `json:"productId"`
+	ProductName string    `json:"productName"`
+	VariantID   *int64    `json:"variantId"` // Set for products with variants
+	VariantName string    `json:"variantName"`
+	Quantity    int       `json:"quantity"`
+	QuoteID     *int64    `json:"quoteId"` // Set when reserved for a quote
+	UserID      int64     `json:"userId"`  // User who reserved
+	Notes       string    `json:"notes"`
+	Status      string    `json:"status"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type CreateReservationRequest struct {
+	ProductID int64  `json:"productId"`
+	VariantID *int64 `json:"variantId"` // Required for products with variants
+	Quantity  int    `json:"quantity"`
+	Notes     string `json:"notes"` // Optional
+}
+
+// Refund types.
+const (
+	RefundTypeCancellation = "cancellation"
+	RefundTypeReturn       = "return"
+)
+
+// Refund documents a full cancellation or a partial return of a sale.
+type Refund struct {
+	ID          int64        `json:"id"`
+	SaleID      int64        `json:"saleId"`
+	UserID      int64        `json:"userId"` // Operator who processed the refund
+	Type        string       `json:"type"`
+	Reason      string       `json:"reason"`
+	Date        time.Time    `json:"date"`
+	Items       []SaleItem   `json:"items"`
+	TotalAmount money.Amount `json:"totalAmount"`
+}
+
+// Page wraps one page of a list endpoint. Total counts every row matching
+// the filters, not just the ones returned.
+type Page struct {
+	Data   interface{} `json:"data"`
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
 }
 
 // Payloads for requests
@@ -47,22 +535,168 @@ type CreateUserRequest struct {
 	Role     string `json:"role"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// AuthTokens is returned on login and refresh. ExpiresIn is the access token
+// lifetime in seconds.
+type AuthTokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int    `json:"expiresIn"`
+}
+
+type LoginResponse struct {
+	AuthTokens
+	User User `json:"user"`
+}
+
+// UpdateUserRequest holds a partial user update; nil fields are left unchanged.
+type UpdateUserRequest struct {
+	Name     *string `json:"name"`
+	Username *string `json:"username"`
+	Role     *string `json:"role"`
+}
+
+type UpdateRolePermissionsRequest struct {
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateRoleMaxDiscountRequest struct {
+	MaxDiscount *money.Amount `json:"maxDiscount"` // Nil removes the limit
+}
+
 type RegisterUserRequest struct {
 	Name     string `json:"name"`
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
-type CreateSaleRequest struct {
-	UserID int64      `json:"userId"`
+// CreateStockMovementRequest records a manual stock change. Quantity is the
+// signed change for adjustments and receipts, and the counted stock for
+// inventory counts.
+type CreateStockMovementRequest struct {
+	VariantID *int64        `json:"variantId,omitempty"` // Required for products with variants
+	Type      string        `json:"type"`
+	Quantity  int           `json:"quantity"`
+	Reason    string        `json:"reason"`
+	UnitCost  *money.Amount `json:"unitCost,omitempty"` // Optional, receipts only; updates the cost price
+}
+
+type CreatePurchaseOrderRequest struct {
+	SupplierID int64                     `json:"supplierId"`
+	ExpectedAt *time.Time                `json:"expectedAt"` // Optional
+	Notes      string                    `json:"notes"`      // Optional
+	Items      []CreatePurchaseOrderItem `json:"items"`
+}
+
+type CreatePurchaseOrderItem struct {
+	ProductID int64        `json:"productId"`
+	VariantID *int64       `json:"variantId"` // Required for products with variants
+	Quantity  int          `json:"quantity"`
+	UnitCost  money.Amount `json:"unitCost"`
+}
+
+// ReceivePurchaseOrderRequest lists the units that arrived. Without items,
+// everything still outstanding is received.
+type ReceivePurchaseOrderRequest struct {
+	Items []ReceivePurchaseOrderItem `json:"items"`
+}
+
+// ReceivePurchaseOrderItem is a receipt of a product, or of the variant of
+// the order line. Products tracked by lot need the lot the units belong to:
+// an existing one by code, or a new one with ExpiresOn.
+type ReceivePurchaseOrderItem struct {
+	ProductID int64      `json:"productId"`
+	VariantID *int64     `json:"variantId,omitempty"`
+	Quantity  int        `json:"quantity"`
+	LotCode   string     `json:"lotCode,omitempty"`
+	ExpiresOn *time.Time `json:"expiresOn,omitempty"` // Date only; for new lots
+}
+
+type CancelSaleRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ReturnSaleItemsRequest struct {
+	Reason string     `json:"reason"`
 	Items  []SaleItem `json:"items"`
 }
 
+type CreateSaleRequest struct {
+	UserID           int64             `json:"userId"`     // Optional; only admins may set another seller
+	CustomerID       *int64            `json:"customerId"` // Optional
+	Items            []CreateSaleItem  `json:"items"`
+	Discount         *Discount         `json:"discount"` // Optional, on the whole sale
+	DiscountApproval *DiscountApproval `json:"discountApproval"`
+	CouponCode       string            `json:"couponCode"`     // Optional
+	Payments         []Payment         `json:"payments"`       // Must add up to the sale total
+	ReservationIDs   []int64           `json:"reservationIds"` // Optional; reservations the sale consumes
+}
+
+// CreateSaleItem names its product by exactly one of ProductID, Barcode
+// or SKU. Products with variants also need VariantID.
+type CreateSaleItem struct {
+	ProductID int64     `json:"productId"`
+	Barcode   string    `json:"barcode,omitempty"`
+	SKU       string    `json:"sku,omitempty"`
+	VariantID *int64    `json:"variantId,omitempty"`
+	Quantity  int       `json:"quantity"`
+	Discount  *Discount `json:"discount"` // Optional
+}
+
+type CreateQuoteRequest struct {
+	UserID     int64            `json:"userId"`     // Optional; only users allowed to sell on behalf of others may set another seller
+	CustomerID *int64           `json:"customerId"` // Optional
+	Items      []CreateSaleItem `json:"items"`
+	ValidUntil *time.Time       `json:"validUntil"` // Optional; defaults to a week from now
+	Notes      string           `json:"notes"`
+}
+
+// ConvertQuoteRequest carries what a sale needs beyond the quote itself.
+type ConvertQuoteRequest struct {
+	Discount         *Discount         `json:"discount"` // Optional, on the whole sale
+	DiscountApproval *DiscountApproval `json:"discountApproval"`
+	CouponCode       string            `json:"couponCode"`
+	Payments         []Payment         `json:"payments"`
+}
+
+// OutOfStockResponse is the 409 answer to converting a quote whose items are
+// no longer in stock.
+type OutOfStockResponse struct {
+	Error      string           `json:"error"`
+	OutOfStock []OutOfStockItem `json:"outOfStock"`
+}
+
+// DiscountApproval carries the credentials of a user allowed to approve a
+// discount above the seller's limit.
+type DiscountApproval struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type CreateSaleResponse struct {
+	SaleID int64        `json:"saleId"`
+	Change money.Amount `json:"change"` // Total change due on cash payments
+}
+
 type AdminDashboardSummary struct {
-	TotalSalesMonth  float64         `json:"totalSalesMonth"`
-	TotalSellers     int             `json:"totalSellers"`
-	LowStockProducts int             `json:"lowStockProducts"`
+	TotalSalesMonth   money.Amount      `json:"totalSalesMonth"`
+	TotalSellers      int               `json:"totalSellers"`
+	LowStockProducts  int               `json:"lowStockProducts"`
 	TopSellingProduct TopSellingProduct `json:"topSellingProduct"`
+	PaymentMethods    []PaymentTotal    `json:"paymentMethods"`
+	GrossMarginMonth  money.Amount      `json:"grossMarginMonth"`
+	RevenueByCategory []CategoryRevenue `json:"revenueByCategory"` // Per department
+	ExpiringLots      int               `json:"expiringLots"`      // Lots with stock expiring soon or expired
+}
+
+// PaymentTotal is how much was received through one payment method.
+type PaymentTotal struct {
+	Method string       `json:"method"`
+	Count  int          `json:"count"`
+	Total  money.Amount `json:"total"`
 }
 
 type TopSellingProduct struct {
@@ -71,7 +705,7 @@ type TopSellingProduct struct {
 }
 
 type VendedorDashboardSummary struct {
-	MyTotalSalesMonth float64 `json:"myTotalSalesMonth"`
-	MyRank            int     `json:"myRank"`
-	Commissions       float64 `json:"commissions"`
+	MyTotalSalesMonth money.Amount `json:"myTotalSalesMonth"`
+	MyRank            int          `json:"myRank"`
+	Commissions       money.Amount `json:"commissions"`
 }
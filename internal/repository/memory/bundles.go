@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"slices"
+	"sort"
+)
+
+type bundleRepository struct{ s *Store }
+
+func (r bundleRepository) List(ctx context.Context, bundleIDs ...int64) ([]models.BundleComponent, error) {
+	defer r.s.lock()()
+
+	ids := slices.Clone(bundleIDs)
+	slices.Sort(ids)
+	components := []models.BundleComponent{}
+	for _, id := range slices.Compact(ids) {
+		for _, c := range r.s.data.bundles[id] {
+			p := r.s.withStock(r.s.data.products[c.ProductID])
+			c.ProductName, c.Available = p.Name, p.Available
+			components = append(components, c)
+		}
+	}
+	return components, nil
+}
+
+func (r bundleRepository) Set(ctx context.Context, bundleID int64, components []models.BundleComponent) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.products[bundleID]; !ok {
+		return repository.ErrInUse
+	}
+	stored := make([]models.BundleComponent, len(components))
+	for i, c := range components {
+		if _, ok := r.s.data.products[c.ProductID]; !ok || c.ProductID == bundleID {
+			return repository.ErrInUse
+		}
+		stored[i] = models.BundleComponent{BundleID: bundleID, ProductID: c.ProductID, Quantity: c.Quantity}
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].ProductID < stored[j].ProductID })
+
+	if len(stored) == 0 {
+		delete(r.s.data.bundles, bundleID)
+	} else {
+		r.s.data.bundles[bundleID] = stored
+	}
+	return nil
+}
+
+func (r bundleRepository) ContainedIn(ctx context.Context, productID int64) ([]int64, error) {
+	defer r.s.lock()()
+
+	return r.s.containedIn(productID), nil
+}
+
+// containedIn returns the IDs of the bundles productID goes into.
+func (s *Store) containedIn(productID int64) []int64 {
+	ids := []int64{}
+	for id, components := range s.data.bundles {
+		if slices.ContainsFunc(components, func(c models.BundleComponent) bool { return c.ProductID == productID }) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
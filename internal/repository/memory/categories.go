@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"sort"
+	"strings"
+	"time"
+)
+
+type categoryRepository struct{ s *Store }
+
+// inCategory reports whether categoryID is root or a category below it.
+func (s *Store) inCategory(categoryID *int64, root int64) bool {
+	for id := categoryID; id != nil; id = s.data.categories[*id].ParentID {
+		if *id == root {
+			return true
+		}
+	}
+	return false
+}
+
+func (r categoryRepository) List(ctx context.Context) ([]models.Category, error) {
+	defer r.s.lock()()
+
+	categories := []models.Category{}
+	for _, c := range r.s.data.categories {
+		categories = append(categories, c)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if categories[i].Name != categories[j].Name {
+			return categories[i].Name < categories[j].Name
+		}
+		return categories[i].ID < categories[j].ID
+	})
+	return categories, nil
+}
+
+func (r categoryRepository) Get(ctx context.Context, id int64) (models.Category, error) {
+	defer r.s.lock()()
+
+	c, ok := r.s.data.categories[id]
+	if !ok {
+		return models.Category{}, repository.ErrNotFound
+	}
+	return c, nil
+}
+
+// check enforces the parent foreign key and unique sibling names.
+func (r categoryRepository) check(c models.Category) error {
+	if c.ParentID != nil {
+		if _, ok := r.s.data.categories[*c.ParentID]; !ok {
+			return repository.ErrInUse
+		}
+	}
+	for _, other := range r.s.data.categories {
+		if other.ID != c.ID && sameParent(other.ParentID, c.ParentID) && strings.EqualFold(other.Name, c.Name) {
+			return repository.ErrConflict
+		}
+	}
+	return nil
+}
+
+func sameParent(a, b *int64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func (r categoryRepository) Create(ctx context.Context, c *models.Category) error {
+	defer r.s.lock()()
+
+	if err := r.check(*c); err != nil {
+		return err
+	}
+	r.s.data.lastCategoryID++
+	c.ID = r.s.data.lastCategoryID
+	c.CreatedAt = time.Now()
+	c.Children = nil
+	r.s.data.categories[c.ID] = *c
+	return nil
+}
+
+func (r categoryRepository) Update(ctx context.Context, c models.Category) error {
+	defer r.s.lock()()
+
+	current, ok := r.s.data.categories[c.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if err := r.check(c); err != nil {
+		return err
+	}
+	c.CreatedAt, c.Children = current.CreatedAt, nil
+	r.s.data.categories[c.ID] = c
+	return nil
+}
+
+func (r categoryRepository) Delete(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.categories[id]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, c := range r.s.data.categories {
+		if c.ParentID != nil && *c.ParentID == id {
+			return repository.ErrInUse
+		}
+	}
+	for _, p := range r.s.data.products {
+		if p.CategoryID != nil && *p.CategoryID == id {
+			return repository.ErrInUse
+		}
+	}
+	for _, p := range r.s.data.promotions {
+		if p.CategoryID != nil && *p.CategoryID == id {
+			return repository.ErrInUse
+		}
+	}
+	for _, sale := range r.s.data.sales {
+		for _, item := range sale.Items {
+			if item.CategoryID != nil && *item.CategoryID == id {
+				return repository.ErrInUse
+			}
+		}
+	}
+	delete(r.s.data.categories, id)
+	return nil
+}
+
+func (r categoryRepository) Ancestors(ctx context.Context, id int64) ([]int64, error) {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.categories[id]; !ok {
+		return nil, repository.ErrNotFound
+	}
+	ids := []int64{}
+	for current := &id; current != nil; current = r.s.data.categories[*current].ParentID {
+		ids = append(ids, *current)
+	}
+	return ids, nil
+}
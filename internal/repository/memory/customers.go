@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"sort"
+	"strings"
+	"time"
+)
+
+type customerRepository struct{ s *Store }
+
+func (r customerRepository) List(ctx context.Context, filter repository.CustomerFilter) ([]models.Customer, int, error) {
+	defer r.s.lock()()
+
+	search := strings.ToLower(filter.Search)
+	customers := []models.Customer{}
+	for _, c := range r.s.data.customers {
+		if search != "" && !strings.Contains(strings.ToLower(c.Name), search) &&
+			(c.Document == "" || !strings.Contains(c.Document, filter.Search)) {
+			continue
+		}
+		customers = append(customers, c)
+	}
+	sort.Slice(customers, func(i, j int) bool {
+		if customers[i].Name != customers[j].Name {
+			return customers[i].Name < customers[j].Name
+		}
+		return customers[i].ID < customers[j].ID
+	})
+	return page(customers, filter.Limit, filter.Offset), len(customers), nil
+}
+
+func (r customerRepository) Get(ctx context.Context, id int64) (models.Customer, error) {
+	defer r.s.lock()()
+
+	c, ok := r.s.data.customers[id]
+	if !ok {
+		return models.Customer{}, repository.ErrNotFound
+	}
+	return c, nil
+}
+
+func (r customerRepository) Create(ctx context.Context, c *models.Customer) error {
+	defer r.s.lock()()
+
+	if r.documentTaken(c.Document, 0) {
+		return repository.ErrConflict
+	}
+	r.s.data.lastCustomerID++
+	c.ID = r.s.data.lastCustomerID
+	c.CreatedAt = time.Now()
+	r.s.data.customers[c.ID] = *c
+	return nil
+}
+
+func (r customerRepository) Update(ctx context.Context, c models.Customer) error {
+	defer r.s.lock()()
+
+	current, ok := r.s.data.customers[c.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if r.documentTaken(c.Document, c.ID) {
+		return repository.ErrConflict
+	}
+	c.CreatedAt = current.CreatedAt
+	r.s.data.customers[c.ID] = c
+	return nil
+}
+
+// documentTaken reports whether another customer already has the document.
+func (r customerRepository) documentTaken(document string, exceptID int64) bool {
+	if document == "" {
+		return false
+	}
+	for _, c := range r.s.data.customers {
+		if c.Document == document && c.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r customerRepository) Delete(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.customers[id]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, sale := range r.s.data.sales {
+		if sale.CustomerID != nil && *sale.CustomerID == id {
+			return repository.ErrInUse
+		}
+	}
+	for _, quote := range r.s.data.quotes {
+		if quote.CustomerID != nil && *quote.CustomerID == id {
+			return repository.ErrInUse
+		}
+	}
+	delete(r.s.data.customers, id)
+	return nil
+}
+
+func (r customerRepository) Metrics(ctx context.Context, id int64) (models.CustomerMetrics, error) {
+	defer r.s.lock()()
+
+	metrics := models.CustomerMetrics{CustomerID: id}
+	for _, sale := range r.s.data.sales {
+		if sale.CustomerID == nil || *sale.CustomerID != id || sale.Status == models.SaleStatusCancelled {
+			continue
+		}
+		metrics.PurchaseCount++
+		metrics.LifetimeValue += netTotal(sale)
+
+		date := sale.Date
+		if metrics.FirstPurchaseAt == nil || date.Before(*metrics.FirstPurchaseAt) {
+			metrics.FirstPurchaseAt = &date
+		}
+		if metrics.LastPurchaseAt == nil || date.After(*metrics.LastPurchaseAt) {
+			metrics.LastPurchaseAt = &date
+		}
+	}
+	return metrics, nil
+}
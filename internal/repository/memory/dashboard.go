@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/money"
+	"slices"
+	"time"
+)
+
+type dashboardRepository struct{ s *Store }
+
+func (r dashboardRepository) AdminSummary(ctx context.Context, since time.Time, sellerPermission string) (models.AdminDashboardSummary, error) {
+	defer r.s.lock()()
+
+	var summary models.AdminDashboardSummary
+	for _, sale := range r.s.data.sales {
+		if !sale.Date.Before(since) && sale.Status != models.SaleStatusCancelled {
+			summary.TotalSalesMonth += netTotal(sale)
+			summary.GrossMarginMonth += netTotal(sale) - costTotal(sale)
+		}
+	}
+	for _, u := range r.s.data.users {
+		if slices.Contains(r.s.data.roles[u.Role].Permissions, sellerPermission) {
+			summary.TotalSellers++
+		}
+	}
+	// Bundles have no stock of their own; their components are counted instead
+	for _, p := range r.s.data.products {
+		if p.Quantity < 10 && len(r.s.data.bundles[p.ID]) == 0 {
+			summary.LowStockProducts++
+		}
+	}
+
+	// Top product across every sale not cancelled, ties going to the lowest ID
+	sold := map[int64]int{}
+	for _, sale := range r.s.data.sales {
+		if sale.Status == models.SaleStatusCancelled {
+			continue
+		}
+		for _, item := range sale.Items {
+			if _, ok := r.s.data.products[item.ProductID]; ok {
+				sold[item.ProductID] += item.Quantity - item.ReturnedQuantity
+			}
+		}
+	}
+	var topID int64
+	for id, qty := range sold {
+		if topID == 0 || qty > sold[topID] || (qty == sold[topID] && id < topID) {
+			topID = id
+		}
+	}
+	if topID != 0 {
+		summary.TopSellingProduct = models.TopSellingProduct{ID: topID, Name: r.s.data.products[topID].Name}
+	}
+
+	var sales []repository.SalePayments
+	index := map[int64]int{}
+	for _, p := range r.s.data.payments {
+		sale := r.s.data.sales[p.saleID]
+		if sale.Date.Before(since) || sale.Status == models.SaleStatusCancelled {
+			continue
+		}
+		i, ok := index[p.saleID]
+		if !ok {
+			i = len(sales)
+			index[p.saleID] = i
+			sales = append(sales, repository.SalePayments{})
+			for _, item := range sale.Items {
+				sales[i].Refunded += item.RefundedAmount
+			}
+		}
+		sales[i].Payments = append(sales[i].Payments, p.Payment)
+	}
+	summary.PaymentMethods = repository.SumPaymentMethods(sales)
+	return summary, nil
+}
+
+func (r dashboardRepository) SellerTotals(ctx context.Context, userID int64, since time.Time) (models.VendedorDashboardSummary, error) {
+	defer r.s.lock()()
+
+	// Only sellers with at least one sold line in the period are ranked;
+	// cancelled sales count for nobody
+	totals := map[int64]money.Amount{}
+	for _, sale := range r.s.data.sales {
+		if !sale.Date.Before(since) && len(sale.Items) > 0 && sale.Status != models.SaleStatusCancelled {
+			totals[sale.UserID] += netTotal(sale)
+		}
+	}
+
+	var summary models.VendedorDashboardSummary
+	mine, ranked := totals[userID]
+	summary.MyTotalSalesMonth = mine
+	if ranked {
+		summary.MyRank = 1
+		for _, total := range totals {
+			if total > mine {
+				summary.MyRank++
+			}
+		}
+	}
+	return summary, nil
+}
+
+// netTotal is the sale value left after discounts and returns.
+func netTotal(sale models.Sale) money.Amount {
+	var total money.Amount
+	for _, item := range sale.Items {
+		total += item.UnitPrice.Mul(item.Quantity) - item.Discount - item.RefundedAmount
+	}
+	return total
+}
+
+// costTotal is what the units of the sale not returned cost.
+func costTotal(sale models.Sale) money.Amount {
+	var total money.Amount
+	for _, item := range sale.Items {
+		total += item.UnitCost.Mul(item.Quantity - item.ReturnedQuantity)
+	}
+	return total
+}
@@ -0,0 +1,169 @@
+package memory
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+type lotRepository struct{ s *Store }
+
+// sortLots orders lots by expiry, then ID, the order sales consume them in.
+func sortLots(lots []models.ProductLot) {
+	sort.Slice(lots, func(i, j int) bool {
+		if !lots[i].ExpiresOn.Equal(lots[j].ExpiresOn) {
+			return lots[i].ExpiresOn.Before(lots[j].ExpiresOn)
+		}
+		return lots[i].ID < lots[j].ID
+	})
+}
+
+func (r lotRepository) List(ctx context.Context, productIDs ...int64) ([]models.ProductLot, error) {
+	defer r.s.lock()()
+
+	lots := []models.ProductLot{}
+	for _, l := range r.s.data.lots {
+		if slices.Contains(productIDs, l.ProductID) {
+			lots = append(lots, l)
+		}
+	}
+	sortLots(lots)
+	return lots, nil
+}
+
+func (r lotRepository) Get(ctx context.Context, id int64) (models.ProductLot, error) {
+	defer r.s.lock()()
+
+	l, ok := r.s.data.lots[id]
+	if !ok {
+		return models.ProductLot{}, repository.ErrNotFound
+	}
+	return l, nil
+}
+
+// check enforces the product foreign key and unique codes per product.
+func (r lotRepository) check(l models.ProductLot) error {
+	if _, ok := r.s.data.products[l.ProductID]; !ok {
+		return repository.ErrInUse
+	}
+	for _, other := range r.s.data.lots {
+		if other.ID != l.ID && other.ProductID == l.ProductID && strings.EqualFold(other.Code, l.Code) {
+			return repository.ErrConflict
+		}
+	}
+	return nil
+}
+
+func (r lotRepository) Create(ctx context.Context, l *models.ProductLot) error {
+	defer r.s.lock()()
+
+	if err := r.check(*l); err != nil {
+		return err
+	}
+	r.s.data.lastLotID++
+	l.ID = r.s.data.lastLotID
+	l.CreatedAt = time.Now()
+	l.ProductName = ""
+	r.s.data.lots[l.ID] = *l
+	return nil
+}
+
+func (r lotRepository) Update(ctx context.Context, l models.ProductLot) error {
+	defer r.s.lock()()
+
+	current, ok := r.s.data.lots[l.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	l.ProductID, l.ProductName, l.CreatedAt = current.ProductID, "", current.CreatedAt
+	if err := r.check(l); err != nil {
+		return err
+	}
+	r.s.data.lots[l.ID] = l
+	return nil
+}
+
+func (r lotRepository) Delete(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.lots[id]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, sale := range r.s.data.sales {
+		for _, item := range sale.Items {
+			if slices.ContainsFunc(item.Lots, func(l models.SaleItemLot) bool { return l.LotID == id }) {
+				return repository.ErrInUse
+			}
+		}
+	}
+	delete(r.s.data.lots, id)
+	return nil
+}
+
+func (r lotRepository) AdjustStock(ctx context.Context, id int64, delta int) error {
+	defer r.s.lock()()
+
+	l, ok := r.s.data.lots[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	l.Quantity += delta
+	r.s.data.lots[id] = l
+	return nil
+}
+
+func (r lotRepository) Consume(ctx context.Context, productID int64, quantity int, on time.Time) ([]models.SaleItemLot, error) {
+	defer r.s.lock()()
+
+	var lots []models.ProductLot
+	for _, l := range r.s.data.lots {
+		if l.ProductID == productID {
+			lots = append(lots, l)
+		}
+	}
+	if len(lots) == 0 {
+		return nil, nil
+	}
+	sortLots(lots)
+
+	var taken []models.SaleItemLot
+	left := quantity
+	for _, l := range lots {
+		if left == 0 {
+			break
+		}
+		if l.Quantity == 0 || l.ExpiresOn.Before(on) {
+			continue
+		}
+		n := min(l.Quantity, left)
+		taken = append(taken, models.SaleItemLot{LotID: l.ID, ProductID: productID, Code: l.Code, ExpiresOn: l.ExpiresOn, Quantity: n})
+		left -= n
+	}
+	if left > 0 {
+		return nil, repository.ErrInsufficientStock
+	}
+	for _, t := range taken {
+		l := r.s.data.lots[t.LotID]
+		l.Quantity -= t.Quantity
+		r.s.data.lots[t.LotID] = l
+	}
+	return taken, nil
+}
+
+func (r lotRepository) Expiring(ctx context.Context, until time.Time) ([]models.ProductLot, error) {
+	defer r.s.lock()()
+
+	lots := []models.ProductLot{}
+	for _, l := range r.s.data.lots {
+		if l.Quantity > 0 && !l.ExpiresOn.After(until) {
+			l.ProductName = r.s.data.products[l.ProductID].Name
+			lots = append(lots, l)
+		}
+	}
+	sortLots(lots)
+	return lots, nil
+}
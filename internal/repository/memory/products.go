@@ -0,0 +1,331 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/money"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+type productRepository struct{ s *Store }
+
+func (r productRepository) List(ctx context.Context, filter repository.ProductFilter) ([]models.Product, int, error) {
+	if !repository.ValidProductSort(filter.Sort) {
+		return nil, 0, fmt.Errorf("invalid product sort %q", filter.Sort)
+	}
+	defer r.s.lock()()
+
+	search := strings.ToLower(filter.Search)
+	products := []models.Product{}
+	for _, p := range r.s.data.products {
+		if search != "" && !strings.Contains(strings.ToLower(p.Name), search) {
+			continue
+		}
+		if filter.CategoryID != nil && !r.s.inCategory(p.CategoryID, *filter.CategoryID) {
+			continue
+		}
+		products = append(products, r.detail(p))
+	}
+
+	column := strings.TrimPrefix(filter.Sort, "-")
+	desc := column != filter.Sort
+	sort.Slice(products, func(i, j int) bool {
+		a, b := products[i], products[j]
+		var cmp int
+		switch column {
+		case "name":
+			cmp = strings.Compare(a.Name, b.Name)
+		case "price":
+			cmp = compare(a.Price, b.Price)
+		case "quantity":
+			cmp = compare(a.Quantity, b.Quantity)
+		}
+		if desc {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp < 0
+		}
+		return a.ID < b.ID
+	})
+	return page(products, filter.Limit, filter.Offset), len(products), nil
+}
+
+func compare[T ~int | ~int64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// detail fills in the product's stock figures and category name.
+func (r productRepository) detail(p models.Product) models.Product {
+	p.Category = ""
+	if p.CategoryID != nil {
+		p.Category = r.s.data.categories[*p.CategoryID].Name
+	}
+	return r.s.withStock(p)
+}
+
+// check enforces the category foreign key and the uniqueness of SKUs and
+// barcodes.
+func (r productRepository) check(p models.Product) error {
+	if p.CategoryID != nil {
+		if _, ok := r.s.data.categories[*p.CategoryID]; !ok {
+			return repository.ErrInUse
+		}
+	}
+	for _, other := range r.s.data.products {
+		if other.ID != p.ID && ((p.SKU != "" && other.SKU == p.SKU) || (p.Barcode != "" && other.Barcode == p.Barcode)) {
+			return repository.ErrConflict
+		}
+	}
+	return nil
+}
+
+func (r productRepository) Get(ctx context.Context, id int64) (models.Product, error) {
+	defer r.s.lock()()
+
+	p, ok := r.s.data.products[id]
+	if !ok {
+		return models.Product{}, repository.ErrNotFound
+	}
+	return r.detail(p), nil
+}
+
+func (r productRepository) GetByBarcode(ctx context.Context, barcode string) (models.Product, error) {
+	return r.find(func(p models.Product) bool { return barcode != "" && p.Barcode == barcode })
+}
+
+func (r productRepository) GetBySKU(ctx context.Context, sku string) (models.Product, error) {
+	return r.find(func(p models.Product) bool { return sku != "" && p.SKU == sku })
+}
+
+func (r productRepository) find(match func(models.Product) bool) (models.Product, error) {
+	defer r.s.lock()()
+
+	for _, p := range r.s.data.products {
+		if match(p) {
+			return r.detail(p), nil
+		}
+	}
+	return models.Product{}, repository.ErrNotFound
+}
+
+// GetForUpdate needs no row lock: transactions already hold the store lock.
+func (r productRepository) GetForUpdate(ctx context.Context, id int64) (models.Product, error) {
+	return r.Get(ctx, id)
+}
+
+func (r productRepository) Create(ctx context.Context, p *models.Product) error {
+	defer r.s.lock()()
+
+	if err := r.check(*p); err != nil {
+		return err
+	}
+	r.s.data.lastProductID++
+	p.ID = r.s.data.lastProductID
+	r.s.data.products[p.ID] = *p
+	*p = r.detail(*p)
+	return nil
+}
+
+func (r productRepository) Update(ctx context.Context, p models.Product) error {
+	defer r.s.lock()()
+
+	current, ok := r.s.data.products[p.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if err := r.check(p); err != nil {
+		return err
+	}
+	p.CostPrice = current.CostPrice
+	r.s.data.products[p.ID] = p
+	return nil
+}
+
+func (r productRepository) Delete(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.products[id]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, sale := range r.s.data.sales {
+		for _, item := range sale.Items {
+			if item.ProductID == id || slices.ContainsFunc(item.Components, func(c models.SaleItemComponent) bool { return c.ProductID == id }) {
+				return repository.ErrInUse
+			}
+		}
+	}
+	for _, quote := range r.s.data.quotes {
+		for _, item := range quote.Items {
+			if item.ProductID == id {
+				return repository.ErrInUse
+			}
+		}
+	}
+	for _, order := range r.s.data.orders {
+		for _, item := range order.Items {
+			if item.ProductID == id {
+				return repository.ErrInUse
+			}
+		}
+	}
+	if len(r.s.containedIn(id)) > 0 {
+		return repository.ErrInUse
+	}
+
+	delete(r.s.data.products, id)
+	delete(r.s.data.bundles, id)
+	movements := r.s.data.movements[:0]
+	for _, m := range r.s.data.movements {
+		if m.ProductID != id {
+			movements = append(movements, m)
+		}
+	}
+	r.s.data.movements = movements
+	for variantID, v := range r.s.data.variants {
+		if v.ProductID == id {
+			delete(r.s.data.variants, variantID)
+		}
+	}
+	for lotID, l := range r.s.data.lots {
+		if l.ProductID == id {
+			delete(r.s.data.lots, lotID)
+		}
+	}
+	for promotionID, p := range r.s.data.promotions {
+		if p.ProductID != nil && *p.ProductID == id {
+			delete(r.s.data.promotions, promotionID)
+		}
+	}
+	for reservationID, res := range r.s.data.reservations {
+		if res.ProductID == id {
+			delete(r.s.data.reservations, reservationID)
+		}
+	}
+	return nil
+}
+
+func (r productRepository) AdjustStock(ctx context.Context, id int64, delta int) error {
+	defer r.s.lock()()
+
+	p, ok := r.s.data.products[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	p.Quantity += delta
+	r.s.data.products[id] = p
+	return nil
+}
+
+func (r productRepository) ReceiveStock(ctx context.Context, id int64, quantity int, unitCost money.Amount) error {
+	defer r.s.lock()()
+
+	p, ok := r.s.data.products[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	onHand := p.Quantity
+	if onHand < 0 {
+		onHand = 0
+	}
+	p.CostPrice = (p.CostPrice.Mul(onHand) + unitCost.Mul(quantity)).MulRate(1, int64(onHand+quantity))
+	p.Quantity += quantity
+	r.s.data.products[id] = p
+	return nil
+}
+
+func (r productRepository) DecrementStock(ctx context.Context, id int64, quantity int) (models.Product, error) {
+	defer r.s.lock()()
+
+	p, ok := r.s.data.products[id]
+	if !ok || p.Quantity-r.s.reserved(id) < quantity {
+		return models.Product{}, repository.ErrInsufficientStock
+	}
+	p.Quantity -= quantity
+	r.s.data.products[id] = p
+	return r.detail(p), nil
+}
+
+func (r productRepository) RecordMovement(ctx context.Context, m *models.StockMovement) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.products[m.ProductID]; !ok {
+		return repository.ErrInUse
+	}
+	r.s.data.lastMovementID++
+	m.ID = r.s.data.lastMovementID
+	m.Date = time.Now()
+	r.s.data.movements = append(r.s.data.movements, *m)
+	return nil
+}
+
+func (r productRepository) ListMovements(ctx context.Context, productID int64) ([]models.StockMovement, error) {
+	defer r.s.lock()()
+
+	movements := []models.StockMovement{}
+	for i := len(r.s.data.movements) - 1; i >= 0; i-- {
+		if m := r.s.data.movements[i]; m.ProductID == productID {
+			movements = append(movements, m)
+		}
+	}
+	return movements, nil
+}
+
+func (r productRepository) Reconcile(ctx context.Context) ([]models.StockDiscrepancy, error) {
+	defer r.s.lock()()
+
+	ledger, variantLedger := map[int64]int{}, map[int64]int{}
+	for _, m := range r.s.data.movements {
+		ledger[m.ProductID] += m.QuantityDelta
+		if m.VariantID != nil {
+			variantLedger[*m.VariantID] += m.QuantityDelta
+		}
+	}
+
+	discrepancies := []models.StockDiscrepancy{}
+	for _, p := range r.s.data.products {
+		if p.Quantity != ledger[p.ID] {
+			discrepancies = append(discrepancies, models.StockDiscrepancy{
+				ProductID:      p.ID,
+				ProductName:    p.Name,
+				Quantity:       p.Quantity,
+				LedgerQuantity: ledger[p.ID],
+			})
+		}
+	}
+	for _, v := range r.s.data.variants {
+		if v.Quantity != variantLedger[v.ID] {
+			id := v.ID
+			discrepancies = append(discrepancies, models.StockDiscrepancy{
+				ProductID:      v.ProductID,
+				ProductName:    r.s.data.products[v.ProductID].Name,
+				VariantID:      &id,
+				VariantName:    v.Name,
+				Quantity:       v.Quantity,
+				LedgerQuantity: variantLedger[v.ID],
+			})
+		}
+	}
+	sort.Slice(discrepancies, func(i, j int) bool {
+		a, b := discrepancies[i], discrepancies[j]
+		if a.ProductID != b.ProductID {
+			return a.ProductID < b.ProductID
+		}
+		if a.VariantID == nil || b.VariantID == nil {
+			return a.VariantID == nil && b.VariantID != nil
+		}
+		return *a.VariantID < *b.VariantID
+	})
+	return discrepancies, nil
+}
@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"sort"
+	"time"
+)
+
+type promotionRepository struct{ s *Store }
+
+func (r promotionRepository) List(ctx context.Context) ([]models.Promotion, error) {
+	defer r.s.lock()()
+
+	return r.sorted(func(models.Promotion) bool { return true }), nil
+}
+
+// sorted returns the promotions matching keep, ordered by ID.
+func (r promotionRepository) sorted(keep func(models.Promotion) bool) []models.Promotion {
+	promotions := []models.Promotion{}
+	for _, p := range r.s.data.promotions {
+		if keep(p) {
+			promotions = append(promotions, p)
+		}
+	}
+	sort.Slice(promotions, func(i, j int) bool { return promotions[i].ID < promotions[j].ID })
+	return promotions
+}
+
+func (r promotionRepository) Get(ctx context.Context, id int64) (models.Promotion, error) {
+	defer r.s.lock()()
+
+	p, ok := r.s.data.promotions[id]
+	if !ok {
+		return models.Promotion{}, repository.ErrNotFound
+	}
+	return p, nil
+}
+
+// check plays the role of the code uniqueness constraint and the product
+// foreign key.
+func (r promotionRepository) check(p models.Promotion) error {
+	if p.Code != "" {
+		for _, other := range r.s.data.promotions {
+			if other.ID != p.ID && other.Code == p.Code {
+				return repository.ErrConflict
+			}
+		}
+	}
+	if p.ProductID != nil {
+		if _, ok := r.s.data.products[*p.ProductID]; !ok {
+			return repository.ErrInUse
+		}
+	}
+	if p.CategoryID != nil {
+		if _, ok := r.s.data.categories[*p.CategoryID]; !ok {
+			return repository.ErrInUse
+		}
+	}
+	return nil
+}
+
+func (r promotionRepository) Create(ctx context.Context, p *models.Promotion) error {
+	defer r.s.lock()()
+
+	if err := r.check(*p); err != nil {
+		return err
+	}
+	r.s.data.lastPromotionID++
+	p.ID = r.s.data.lastPromotionID
+	p.UsageCount = 0
+	p.CreatedAt = time.Now()
+	r.s.data.promotions[p.ID] = *p
+	return nil
+}
+
+func (r promotionRepository) Update(ctx context.Context, p models.Promotion) error {
+	defer r.s.lock()()
+
+	current, ok := r.s.data.promotions[p.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if err := r.check(p); err != nil {
+		return err
+	}
+	p.UsageCount, p.CreatedAt = current.UsageCount, current.CreatedAt
+	r.s.data.promotions[p.ID] = p
+	return nil
+}
+
+func (r promotionRepository) Delete(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.promotions[id]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, sale := range r.s.data.sales {
+		for _, item := range sale.Items {
+			if item.PromotionID != nil && *item.PromotionID == id {
+				return repository.ErrInUse
+			}
+		}
+	}
+	delete(r.s.data.promotions, id)
+	return nil
+}
+
+func (r promotionRepository) ListValid(ctx context.Context, at time.Time) ([]models.Promotion, error) {
+	defer r.s.lock()()
+
+	return r.sorted(func(p models.Promotion) bool {
+		return p.Active &&
+			(p.StartsAt == nil || !p.StartsAt.After(at)) &&
+			(p.EndsAt == nil || p.EndsAt.After(at)) &&
+			(p.UsageLimit == nil || p.UsageCount < *p.UsageLimit)
+	}), nil
+}
+
+func (r promotionRepository) Use(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+
+	p, ok := r.s.data.promotions[id]
+	if !ok || (p.UsageLimit != nil && p.UsageCount >= *p.UsageLimit) {
+		return repository.ErrConflict
+	}
+	p.UsageCount++
+	r.s.data.promotions[id] = p
+	return nil
+}
+
+func (r promotionRepository) Report(ctx context.Context, from, until *time.Time) ([]models.PromotionReport, error) {
+	defer r.s.lock()()
+
+	report := []models.PromotionReport{}
+	for _, p := range r.sorted(func(models.Promotion) bool { return true }) {
+		line := models.PromotionReport{PromotionID: p.ID, Name: p.Name, Code: p.Code}
+		for _, sale := range r.s.data.sales {
+			if sale.Status == models.SaleStatusCancelled ||
+				(from != nil && sale.Date.Before(*from)) ||
+				(until != nil && !sale.Date.Before(*until)) {
+				continue
+			}
+			applied := false
+			for _, item := range sale.Items {
+				if item.PromotionID == nil || *item.PromotionID != p.ID {
+					continue
+				}
+				applied = true
+				line.UnitsSold += item.Quantity - item.ReturnedQuantity
+				line.Revenue += item.UnitPrice.Mul(item.Quantity) - item.Discount - item.RefundedAmount
+				line.DiscountGiven += item.PromotionDiscount
+			}
+			if applied {
+				line.SalesCount++
+			}
+		}
+		report = append(report, line)
+	}
+	return report, nil
+}
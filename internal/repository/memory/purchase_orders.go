@@ -0,0 +1,180 @@
+package memory
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"sort"
+	"time"
+)
+
+type purchaseOrderRepository struct{ s *Store }
+
+func (r purchaseOrderRepository) List(ctx context.Context, filter repository.PurchaseOrderFilter) ([]models.PurchaseOrder, int, error) {
+	defer r.s.lock()()
+
+	orders := []models.PurchaseOrder{}
+	for _, order := range r.s.data.orders {
+		if filter.SupplierID != nil && order.SupplierID != *filter.SupplierID {
+			continue
+		}
+		if filter.Status != "" && order.Status != filter.Status {
+			continue
+		}
+		orders = append(orders, r.detail(order))
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].CreatedAt.After(orders[j].CreatedAt)
+		}
+		return orders[i].ID > orders[j].ID
+	})
+	return page(orders, filter.Limit, filter.Offset), len(orders), nil
+}
+
+// detail returns a copy of the order with its own items, names and total.
+func (r purchaseOrderRepository) detail(order models.PurchaseOrder) models.PurchaseOrder {
+	order.SupplierName = r.s.data.suppliers[order.SupplierID].Name
+	order.Items = append([]models.PurchaseOrderItem{}, order.Items...)
+	order.TotalCost = 0
+	for i, item := range order.Items {
+		order.Items[i].ProductName = r.s.data.products[item.ProductID].Name
+		if item.VariantID != nil {
+			order.Items[i].VariantName = r.s.data.variants[*item.VariantID].Name
+		}
+		order.TotalCost += item.UnitCost.Mul(item.Quantity)
+	}
+	return order
+}
+
+func (r purchaseOrderRepository) Get(ctx context.Context, id int64) (models.PurchaseOrder, error) {
+	defer r.s.lock()()
+
+	order, ok := r.s.data.orders[id]
+	if !ok {
+		return models.PurchaseOrder{}, repository.ErrNotFound
+	}
+	return r.detail(order), nil
+}
+
+// GetForUpdate needs no row lock: transactions already hold the store lock.
+func (r purchaseOrderRepository) GetForUpdate(ctx context.Context, id int64) (models.PurchaseOrder, error) {
+	return r.Get(ctx, id)
+}
+
+func (r purchaseOrderRepository) Create(ctx context.Context, order *models.PurchaseOrder) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.suppliers[order.SupplierID]; !ok {
+		return repository.ErrInUse
+	}
+	if _, ok := r.s.data.users[order.CreatedBy]; !ok {
+		return repository.ErrInUse
+	}
+	type line struct{ productID, variantID int64 }
+	seen := map[line]bool{}
+	for _, item := range order.Items {
+		if _, ok := r.s.data.products[item.ProductID]; !ok {
+			return repository.ErrInUse
+		}
+		key := line{productID: item.ProductID}
+		if item.VariantID != nil {
+			if _, ok := r.s.data.variants[*item.VariantID]; !ok {
+				return repository.ErrInUse
+			}
+			key.variantID = *item.VariantID
+		}
+		if seen[key] {
+			return repository.ErrConflict
+		}
+		seen[key] = true
+	}
+	r.s.data.lastOrderID++
+	order.ID = r.s.data.lastOrderID
+	order.Status = models.PurchaseOrderStatusOpen
+	order.CreatedAt = time.Now()
+
+	stored := *order
+	stored.Items = append([]models.PurchaseOrderItem{}, order.Items...)
+	r.s.data.orders[order.ID] = stored
+	return nil
+}
+
+func (r purchaseOrderRepository) ReceiveItem(ctx context.Context, id, productID int64, variantID *int64, quantity int) error {
+	defer r.s.lock()()
+
+	order, ok := r.s.data.orders[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	for i, item := range order.Items {
+		if item.ProductID != productID || !sameParent(item.VariantID, variantID) {
+			continue
+		}
+		if item.ReceivedQuantity+quantity > item.Quantity {
+			return repository.ErrConflict
+		}
+		order.Items[i].ReceivedQuantity += quantity
+		r.s.data.orders[id] = order
+		return nil
+	}
+	return repository.ErrNotFound
+}
+
+func (r purchaseOrderRepository) SetStatus(ctx context.Context, id int64, status string) error {
+	defer r.s.lock()()
+
+	order, ok := r.s.data.orders[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	order.Status = status
+	r.s.data.orders[id] = order
+	return nil
+}
+
+func (r purchaseOrderRepository) Incoming(ctx context.Context, productID *int64) ([]models.IncomingStock, error) {
+	defer r.s.lock()()
+
+	incoming := []models.IncomingStock{}
+	for _, order := range r.s.data.orders {
+		if order.Status != models.PurchaseOrderStatusOpen && order.Status != models.PurchaseOrderStatusPartiallyReceived {
+			continue
+		}
+		for _, item := range r.detail(order).Items {
+			if item.ReceivedQuantity == item.Quantity || (productID != nil && item.ProductID != *productID) {
+				continue
+			}
+			incoming = append(incoming, models.IncomingStock{
+				PurchaseOrderID: order.ID,
+				SupplierID:      order.SupplierID,
+				SupplierName:    r.s.data.suppliers[order.SupplierID].Name,
+				ProductID:       item.ProductID,
+				ProductName:     item.ProductName,
+				VariantID:       item.VariantID,
+				VariantName:     item.VariantName,
+				Outstanding:     item.Quantity - item.ReceivedQuantity,
+				UnitCost:        item.UnitCost,
+				ExpectedAt:      order.ExpectedAt,
+			})
+		}
+	}
+	// Orders without an expected date come last
+	sort.Slice(incoming, func(i, j int) bool {
+		a, b := incoming[i], incoming[j]
+		switch {
+		case a.ExpectedAt == nil && b.ExpectedAt != nil:
+			return false
+		case a.ExpectedAt != nil && b.ExpectedAt == nil:
+			return true
+		case a.ExpectedAt != nil && !a.ExpectedAt.Equal(*b.ExpectedAt):
+			return a.ExpectedAt.Before(*b.ExpectedAt)
+		case a.PurchaseOrderID != b.PurchaseOrderID:
+			return a.PurchaseOrderID < b.PurchaseOrderID
+		case a.ProductID != b.ProductID:
+			return a.ProductID < b.ProductID
+		}
+		return b.VariantID != nil && (a.VariantID == nil || *a.VariantID < *b.VariantID)
+	})
+	return incoming, nil
+}
@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"sort"
+	"time"
+)
+
+type quoteRepository struct{ s *Store }
+
+func (r quoteRepository) List(ctx context.Context, filter repository.QuoteFilter) ([]models.Quote, int, error) {
+	defer r.s.lock()()
+
+	quotes := []models.Quote{}
+	for _, quote := range r.s.data.quotes {
+		if filter.UserID != nil && quote.UserID != *filter.UserID {
+			continue
+		}
+		if filter.CustomerID != nil && (quote.CustomerID == nil || *quote.CustomerID != *filter.CustomerID) {
+			continue
+		}
+		quotes = append(quotes, r.detail(quote))
+	}
+	sort.Slice(quotes, func(i, j int) bool {
+		if !quotes[i].CreatedAt.Equal(quotes[j].CreatedAt) {
+			return quotes[i].CreatedAt.After(quotes[j].CreatedAt)
+		}
+		return quotes[i].ID > quotes[j].ID
+	})
+	return page(quotes, filter.Limit, filter.Offset), len(quotes), nil
+}
+
+// detail returns a copy of the quote with its own items, the seller and
+// customer names, computed totals and expiry.
+func (r quoteRepository) detail(quote models.Quote) models.Quote {
+	quote.SellerName = r.s.data.users[quote.UserID].Name
+	if quote.CustomerID != nil {
+		quote.CustomerName = r.s.data.customers[*quote.CustomerID].Name
+	}
+	if quote.Status == models.QuoteStatusOpen && !quote.ValidUntil.After(time.Now()) {
+		quote.Status = models.QuoteStatusExpired
+	}
+	quote.Items = append([]models.SaleItem{}, quote.Items...)
+	quote.Subtotal, quote.DiscountTotal, quote.TotalPrice = 0, 0, 0
+	for i, item := range quote.Items {
+		quote.Items[i].Total = item.UnitPrice.Mul(item.Quantity) - item.Discount
+		quote.Subtotal += item.UnitPrice.Mul(item.Quantity)
+		quote.DiscountTotal += item.Discount
+		quote.TotalPrice += quote.Items[i].Total
+	}
+	return quote
+}
+
+func (r quoteRepository) Get(ctx context.Context, id int64) (models.Quote, error) {
+	defer r.s.lock()()
+
+	quote, ok := r.s.data.quotes[id]
+	if !ok {
+		return models.Quote{}, repository.ErrNotFound
+	}
+	return r.detail(quote), nil
+}
+
+func (r quoteRepository) Create(ctx context.Context, quote *models.Quote) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.users[quote.UserID]; !ok {
+		return repository.ErrInUse
+	}
+	if quote.CustomerID != nil {
+		if _, ok := r.s.data.customers[*quote.CustomerID]; !ok {
+			return repository.ErrInUse
+		}
+	}
+	for _, item := range quote.Items {
+		if _, ok := r.s.data.products[item.ProductID]; !ok {
+			return repository.ErrInUse
+		}
+	}
+	r.s.data.lastQuoteID++
+	quote.ID = r.s.data.lastQuoteID
+	quote.Status = models.QuoteStatusOpen
+	quote.SaleID = nil
+	quote.CreatedAt = time.Now()
+
+	stored := *quote
+	stored.Items = append([]models.SaleItem{}, quote.Items...)
+	r.s.data.quotes[quote.ID] = stored
+	return nil
+}
+
+func (r quoteRepository) MarkConverted(ctx context.Context, id, saleID int64) error {
+	defer r.s.lock()()
+
+	quote, ok := r.s.data.quotes[id]
+	if !ok || quote.Status != models.QuoteStatusOpen {
+		return repository.ErrConflict
+	}
+	quote.Status = models.QuoteStatusConverted
+	quote.SaleID = &saleID
+	r.s.data.quotes[id] = quote
+	return nil
+}
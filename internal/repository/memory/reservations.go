@@ -0,0 +1,168 @@
+package memory
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"sort"
+	"time"
+)
+
+type reservationRepository struct{ s *Store }
+
+// holds reports whether the reservation still holds stock at now.
+func holds(res models.StockReservation, now time.Time) bool {
+	return res.Status == models.ReservationStatusActive && res.ExpiresAt.After(now)
+}
+
+// reserved returns the units of the product held by reservations.
+func (s *Store) reserved(productID int64) int {
+	now := time.Now()
+	var quantity int
+	for _, res := range s.data.reservations {
+		if res.ProductID == productID && holds(res, now) {
+			quantity += res.Quantity
+		}
+	}
+	return quantity
+}
+
+// variantReserved returns the units of the variant held by reservations.
+func (s *Store) variantReserved(variantID int64) int {
+	now := time.Now()
+	var quantity int
+	for _, res := range s.data.reservations {
+		if res.VariantID != nil && *res.VariantID == variantID && holds(res, now) {
+			quantity += res.Quantity
+		}
+	}
+	return quantity
+}
+
+// withStock fills in the product's reserved and available stock.
+func (s *Store) withStock(p models.Product) models.Product {
+	p.Reserved = s.reserved(p.ID)
+	p.Available = p.Quantity - p.Reserved
+	return p
+}
+
+func (r reservationRepository) List(ctx context.Context, filter repository.ReservationFilter) ([]models.StockReservation, int, error) {
+	defer r.s.lock()()
+
+	reservations := []models.StockReservation{}
+	for _, res := range r.s.data.reservations {
+		res = r.detail(res)
+		if filter.ProductID != nil && res.ProductID != *filter.ProductID {
+			continue
+		}
+		if filter.QuoteID != nil && (res.QuoteID == nil || *res.QuoteID != *filter.QuoteID) {
+			continue
+		}
+		if filter.Status != "" && res.Status != filter.Status {
+			continue
+		}
+		reservations = append(reservations, res)
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		if !reservations[i].CreatedAt.Equal(reservations[j].CreatedAt) {
+			return reservations[i].CreatedAt.After(reservations[j].CreatedAt)
+		}
+		return reservations[i].ID > reservations[j].ID
+	})
+	return page(reservations, filter.Limit, filter.Offset), len(reservations), nil
+}
+
+// detail adds the product and variant names and reports lapsed
+// reservations as expired.
+func (r reservationRepository) detail(res models.StockReservation) models.StockReservation {
+	res.ProductName = r.s.data.products[res.ProductID].Name
+	if res.VariantID != nil {
+		res.VariantName = r.s.data.variants[*res.VariantID].Name
+	}
+	if res.Status == models.ReservationStatusActive && !holds(res, time.Now()) {
+		res.Status = models.ReservationStatusExpired
+	}
+	return res
+}
+
+func (r reservationRepository) Get(ctx context.Context, id int64) (models.StockReservation, error) {
+	defer r.s.lock()()
+
+	res, ok := r.s.data.reservations[id]
+	if !ok {
+		return models.StockReservation{}, repository.ErrNotFound
+	}
+	return r.detail(res), nil
+}
+
+func (r reservationRepository) Create(ctx context.Context, res *models.StockReservation) error {
+	defer r.s.lock()()
+
+	p, ok := r.s.data.products[res.ProductID]
+	if !ok || p.Quantity-r.s.reserved(p.ID) < res.Quantity {
+		return repository.ErrInsufficientStock
+	}
+	if res.VariantID != nil {
+		v, ok := r.s.data.variants[*res.VariantID]
+		if !ok || v.ProductID != res.ProductID || v.Quantity-r.s.variantReserved(v.ID) < res.Quantity {
+			return repository.ErrInsufficientStock
+		}
+	}
+	if _, ok := r.s.data.users[res.UserID]; !ok {
+		return repository.ErrInUse
+	}
+	if res.QuoteID != nil {
+		if _, ok := r.s.data.quotes[*res.QuoteID]; !ok {
+			return repository.ErrInUse
+		}
+	}
+	r.s.data.lastReservationID++
+	res.ID = r.s.data.lastReservationID
+	res.Status = models.ReservationStatusActive
+	res.CreatedAt = time.Now()
+	r.s.data.reservations[res.ID] = *res
+	return nil
+}
+
+func (r reservationRepository) Close(ctx context.Context, id int64, status string) error {
+	defer r.s.lock()()
+
+	res, ok := r.s.data.reservations[id]
+	if !ok || !holds(res, time.Now()) {
+		return repository.ErrConflict
+	}
+	res.Status = status
+	r.s.data.reservations[id] = res
+	return nil
+}
+
+func (r reservationRepository) Consume(ctx context.Context, id int64, quantity int) error {
+	defer r.s.lock()()
+
+	res, ok := r.s.data.reservations[id]
+	if !ok || !holds(res, time.Now()) || res.Quantity < quantity {
+		return repository.ErrConflict
+	}
+	if res.Quantity == quantity {
+		res.Status = models.ReservationStatusConsumed
+	} else {
+		res.Quantity -= quantity
+	}
+	r.s.data.reservations[id] = res
+	return nil
+}
+
+func (r reservationRepository) ExpireDue(ctx context.Context) (int64, error) {
+	defer r.s.lock()()
+
+	now := time.Now()
+	var expired int64
+	for id, res := range r.s.data.reservations {
+		if res.Status == models.ReservationStatusActive && !holds(res, now) {
+			res.Status = models.ReservationStatusExpired
+			r.s.data.reservations[id] = res
+			expired++
+		}
+	}
+	return expired, nil
+}
@@ -0,0 +1,138 @@
+package memory
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/money"
+	"sort"
+)
+
+type roleRepository struct{ s *Store }
+
+func (r roleRepository) List(ctx context.Context) ([]models.Role, error) {
+	defer r.s.lock()()
+
+	roles := make([]models.Role, 0, len(r.s.data.roles))
+	for _, role := range r.s.data.roles {
+		roles = append(roles, copyRole(role))
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (r roleRepository) Get(ctx context.Context, name string) (models.Role, error) {
+	defer r.s.lock()()
+
+	role, ok := r.s.data.roles[name]
+	if !ok {
+		return models.Role{}, repository.ErrNotFound
+	}
+	return copyRole(role), nil
+}
+
+// copyRole returns the role with its own, sorted permission slice and its
+// own discount limit.
+func copyRole(role models.Role) models.Role {
+	role.Permissions = append([]string{}, role.Permissions...)
+	sort.Strings(role.Permissions)
+	if role.MaxDiscount != nil {
+		limit := *role.MaxDiscount
+		role.MaxDiscount = &limit
+	}
+	return role
+}
+
+func (r roleRepository) Create(ctx context.Context, role models.Role) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.roles[role.Name]; ok {
+		return repository.ErrConflict
+	}
+	if err := r.checkPermissions(role.Permissions); err != nil {
+		return err
+	}
+	r.s.data.roles[role.Name] = copyRole(role)
+	return nil
+}
+
+func (r roleRepository) SetPermissions(ctx context.Context, name string, permissions []string) error {
+	defer r.s.lock()()
+
+	role, ok := r.s.data.roles[name]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if err := r.checkPermissions(permissions); err != nil {
+		return err
+	}
+	role.Permissions = permissions
+	r.s.data.roles[name] = copyRole(role)
+	return nil
+}
+
+func (r roleRepository) SetMaxDiscount(ctx context.Context, name string, maxDiscount *money.Amount) error {
+	defer r.s.lock()()
+
+	role, ok := r.s.data.roles[name]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	role.MaxDiscount = maxDiscount
+	r.s.data.roles[name] = copyRole(role)
+	return nil
+}
+
+// checkPermissions plays the role of the role_permissions foreign key.
+func (r roleRepository) checkPermissions(permissions []string) error {
+	for _, p := range permissions {
+		if _, ok := r.s.data.permissions[p]; !ok {
+			return repository.ErrInUse
+		}
+	}
+	return nil
+}
+
+func (r roleRepository) Delete(ctx context.Context, name string) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.roles[name]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, u := range r.s.data.users {
+		if u.Role == name {
+			return repository.ErrInUse
+		}
+	}
+	delete(r.s.data.roles, name)
+	return nil
+}
+
+func (r roleRepository) HasPermission(ctx context.Context, role, permission string) (bool, error) {
+	defer r.s.lock()()
+
+	for _, p := range r.s.data.roles[role].Permissions {
+		if p == permission {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r roleRepository) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	defer r.s.lock()()
+
+	permissions := make([]models.Permission, 0, len(r.s.data.permissions))
+	for _, p := range r.s.data.permissions {
+		permissions = append(permissions, p)
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].Code < permissions[j].Code })
+	return permissions, nil
+}
+
+func (r roleRepository) PermissionExists(ctx context.Context, code string) (bool, error) {
+	defer r.s.lock()()
+
+	_, ok := r.s.data.permissions[code]
+	return ok, nil
+}
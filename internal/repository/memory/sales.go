@@ -0,0 +1,334 @@
+package memory
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"slices"
+	"sort"
+	"time"
+)
+
+type saleRepository struct{ s *Store }
+
+func (r saleRepository) List(ctx context.Context, filter repository.SaleFilter) ([]models.Sale, int, error) {
+	defer r.s.lock()()
+
+	sales := []models.Sale{}
+	for _, sale := range r.s.data.sales {
+		if filter.UserID != nil && sale.UserID != *filter.UserID {
+			continue
+		}
+		if filter.CustomerID != nil && (sale.CustomerID == nil || *sale.CustomerID != *filter.CustomerID) {
+			continue
+		}
+		if filter.ProductID != nil && !hasProduct(sale, *filter.ProductID) {
+			continue
+		}
+		if filter.From != nil && sale.Date.Before(*filter.From) {
+			continue
+		}
+		if filter.Until != nil && !sale.Date.Before(*filter.Until) {
+			continue
+		}
+		sales = append(sales, r.detail(sale))
+	}
+	sort.Slice(sales, func(i, j int) bool {
+		if !sales[i].Date.Equal(sales[j].Date) {
+			return sales[i].Date.After(sales[j].Date)
+		}
+		return sales[i].ID > sales[j].ID
+	})
+	return page(sales, filter.Limit, filter.Offset), len(sales), nil
+}
+
+func hasProduct(sale models.Sale, productID int64) bool {
+	for _, item := range sale.Items {
+		if item.ProductID == productID {
+			return true
+		}
+	}
+	return false
+}
+
+// detail returns a copy of the sale with its own items, the seller and
+// customer names and computed totals.
+func (r saleRepository) detail(sale models.Sale) models.Sale {
+	sale.SellerName = r.s.data.users[sale.UserID].Name
+	if sale.CustomerID != nil {
+		sale.CustomerName = r.s.data.customers[*sale.CustomerID].Name
+	}
+	sale.Items = append([]models.SaleItem{}, sale.Items...)
+	sale.Subtotal, sale.DiscountTotal, sale.TotalPrice, sale.RefundedTotal, sale.CostTotal = 0, 0, 0, 0, 0
+	for i, item := range sale.Items {
+		sale.Items[i].Total = item.UnitPrice.Mul(item.Quantity) - item.Discount
+		sale.Subtotal += item.UnitPrice.Mul(item.Quantity)
+		sale.DiscountTotal += item.Discount
+		sale.TotalPrice += sale.Items[i].Total
+		sale.RefundedTotal += item.RefundedAmount
+		sale.CostTotal += item.UnitCost.Mul(item.Quantity - item.ReturnedQuantity)
+	}
+	sale.GrossMargin = sale.TotalPrice - sale.RefundedTotal - sale.CostTotal
+	return sale
+}
+
+func (r saleRepository) Create(ctx context.Context, sale *models.Sale) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.users[sale.UserID]; !ok {
+		return repository.ErrInUse
+	}
+	if sale.CustomerID != nil {
+		if _, ok := r.s.data.customers[*sale.CustomerID]; !ok {
+			return repository.ErrInUse
+		}
+	}
+	r.s.data.lastSaleID++
+	sale.ID = r.s.data.lastSaleID
+	sale.Date = time.Now()
+	sale.Status = models.SaleStatusCompleted
+	sale.Items = []models.SaleItem{}
+	r.s.data.sales[sale.ID] = *sale
+	return nil
+}
+
+func (r saleRepository) AddItem(ctx context.Context, saleID int64, item models.SaleItem) error {
+	defer r.s.lock()()
+
+	sale, ok := r.s.data.sales[saleID]
+	if !ok {
+		return repository.ErrInUse
+	}
+	item.ReturnedQuantity, item.RefundedAmount = 0, 0
+	item.Components = slices.Clone(item.Components)
+	item.Lots = slices.Clone(item.Lots)
+	sale.Items = append(sale.Items, item)
+	r.s.data.sales[saleID] = sale
+	return nil
+}
+
+func (r saleRepository) AddPayment(ctx context.Context, saleID int64, p models.Payment) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.sales[saleID]; !ok {
+		return repository.ErrInUse
+	}
+	r.s.data.payments = append(r.s.data.payments, payment{saleID: saleID, Payment: p})
+	return nil
+}
+
+func (r saleRepository) ListPayments(ctx context.Context, saleID int64) ([]models.Payment, error) {
+	defer r.s.lock()()
+
+	payments := []models.Payment{}
+	for _, p := range r.s.data.payments {
+		if p.saleID == saleID {
+			payments = append(payments, p.Payment)
+		}
+	}
+	return payments, nil
+}
+
+func (r saleRepository) Get(ctx context.Context, id int64) (models.Sale, error) {
+	defer r.s.lock()()
+
+	sale, ok := r.s.data.sales[id]
+	if !ok {
+		return models.Sale{}, repository.ErrNotFound
+	}
+	return r.detail(sale), nil
+}
+
+// GetForUpdate needs no row lock: transactions already hold the store lock.
+func (r saleRepository) GetForUpdate(ctx context.Context, id int64) (models.Sale, error) {
+	return r.Get(ctx, id)
+}
+
+func (r saleRepository) SetStatus(ctx context.Context, id int64, status string) error {
+	defer r.s.lock()()
+
+	sale, ok := r.s.data.sales[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	sale.Status = status
+	r.s.data.sales[id] = sale
+	return nil
+}
+
+func (r saleRepository) ReturnItem(ctx context.Context, saleID, productID int64, variantID *int64, quantity int) (models.SaleItem, error) {
+	defer r.s.lock()()
+
+	sale := r.s.data.sales[saleID]
+	for i, item := range sale.Items {
+		if item.ProductID == productID && sameParent(item.VariantID, variantID) && item.Quantity-item.ReturnedQuantity >= quantity {
+			// Prorate the discounted line value over every unit returned so far,
+			// so the last return takes whatever rounding left
+			line := item.UnitPrice.Mul(item.Quantity) - item.Discount
+			refunded := line.MulRate(int64(item.ReturnedQuantity+quantity), int64(item.Quantity))
+			sale.Items[i].ReturnedQuantity += quantity
+			sale.Items[i].RefundedAmount = refunded
+			return models.SaleItem{
+				ProductID:   productID,
+				ProductName: item.ProductName,
+				VariantID:   item.VariantID,
+				VariantName: item.VariantName,
+				Quantity:    quantity,
+				UnitPrice:   item.UnitPrice,
+				Total:       refunded - item.RefundedAmount,
+			}, nil
+		}
+	}
+	return models.SaleItem{}, repository.ErrReturnExceedsSold
+}
+
+func (r saleRepository) CreateRefund(ctx context.Context, refund *models.Refund) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.sales[refund.SaleID]; !ok {
+		return repository.ErrInUse
+	}
+	r.s.data.lastRefundID++
+	refund.ID = r.s.data.lastRefundID
+	refund.Date = time.Now()
+
+	stored := *refund
+	stored.Items = append([]models.SaleItem{}, refund.Items...)
+	r.s.data.refunds = append(r.s.data.refunds, stored)
+	return nil
+}
+
+func (r saleRepository) ListRefunds(ctx context.Context, saleID int64) ([]models.Refund, error) {
+	defer r.s.lock()()
+
+	refunds := []models.Refund{}
+	for _, refund := range r.s.data.refunds {
+		if refund.SaleID == saleID {
+			refund.Items = append([]models.SaleItem{}, refund.Items...)
+			refunds = append(refunds, refund)
+		}
+	}
+	return refunds, nil
+}
+
+func (r saleRepository) ProductMargins(ctx context.Context, from, until *time.Time) ([]models.MarginReport, error) {
+	defer r.s.lock()()
+
+	return r.margins(from, until, func(sale models.Sale, item models.SaleItem) (int64, string) {
+		return item.ProductID, r.s.data.products[item.ProductID].Name
+	}), nil
+}
+
+func (r saleRepository) SellerMargins(ctx context.Context, from, until *time.Time) ([]models.MarginReport, error) {
+	defer r.s.lock()()
+
+	return r.margins(from, until, func(sale models.Sale, item models.SaleItem) (int64, string) {
+		return sale.UserID, r.s.data.users[sale.UserID].Name
+	}), nil
+}
+
+// margins sums sale lines per the ID and name group returns for them.
+func (r saleRepository) margins(from, until *time.Time, group func(models.Sale, models.SaleItem) (int64, string)) []models.MarginReport {
+	lines := map[int64]*models.MarginReport{}
+	for _, sale := range r.s.data.sales {
+		if sale.Status == models.SaleStatusCancelled ||
+			(from != nil && sale.Date.Before(*from)) ||
+			(until != nil && !sale.Date.Before(*until)) {
+			continue
+		}
+		for _, item := range sale.Items {
+			id, name := group(sale, item)
+			if lines[id] == nil {
+				lines[id] = &models.MarginReport{ID: id, Name: name}
+			}
+			line := lines[id]
+			line.UnitsSold += item.Quantity - item.ReturnedQuantity
+			line.Revenue += item.UnitPrice.Mul(item.Quantity) - item.Discount - item.RefundedAmount
+			line.Cost += item.UnitCost.Mul(item.Quantity - item.ReturnedQuantity)
+			line.GrossMargin = line.Revenue - line.Cost
+		}
+	}
+
+	report := []models.MarginReport{}
+	for _, line := range lines {
+		report = append(report, *line)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].GrossMargin != report[j].GrossMargin {
+			return report[i].GrossMargin > report[j].GrossMargin
+		}
+		return report[i].ID < report[j].ID
+	})
+	return report
+}
+
+func (r saleRepository) BundleRevenue(ctx context.Context, from, until *time.Time) ([]models.BundleRevenue, error) {
+	defer r.s.lock()()
+
+	var lines []models.SaleItem
+	for _, sale := range r.s.data.sales {
+		if sale.Status == models.SaleStatusCancelled ||
+			(from != nil && sale.Date.Before(*from)) ||
+			(until != nil && !sale.Date.Before(*until)) {
+			continue
+		}
+		for _, item := range sale.Items {
+			if len(item.Components) == 0 {
+				continue
+			}
+			item.ProductName = r.s.data.products[item.ProductID].Name
+			item.Components = slices.Clone(item.Components)
+			for i, c := range item.Components {
+				item.Components[i].ProductName = r.s.data.products[c.ProductID].Name
+			}
+			lines = append(lines, item)
+		}
+	}
+	return repository.SumBundleRevenue(lines), nil
+}
+
+func (r saleRepository) CategoryRevenue(ctx context.Context, parentID *int64, from, until *time.Time) ([]models.CategoryRevenue, error) {
+	defer r.s.lock()()
+
+	report := []models.CategoryRevenue{}
+	for _, c := range r.s.data.categories {
+		if sameParent(c.ParentID, parentID) {
+			id := c.ID
+			report = append(report, models.CategoryRevenue{CategoryID: &id, Name: c.Name})
+		}
+	}
+	var uncategorized models.CategoryRevenue
+	for _, sale := range r.s.data.sales {
+		if sale.Status == models.SaleStatusCancelled ||
+			(from != nil && sale.Date.Before(*from)) ||
+			(until != nil && !sale.Date.Before(*until)) {
+			continue
+		}
+		for _, item := range sale.Items {
+			var line *models.CategoryRevenue
+			if item.CategoryID == nil && parentID == nil {
+				line = &uncategorized
+			}
+			for i := range report {
+				if r.s.inCategory(item.CategoryID, *report[i].CategoryID) {
+					line = &report[i]
+				}
+			}
+			if line != nil {
+				line.UnitsSold += item.Quantity - item.ReturnedQuantity
+				line.Revenue += item.UnitPrice.Mul(item.Quantity) - item.Discount - item.RefundedAmount
+			}
+		}
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Revenue != report[j].Revenue {
+			return report[i].Revenue > report[j].Revenue
+		}
+		return *report[i].CategoryID < *report[j].CategoryID
+	})
+	if uncategorized.UnitsSold != 0 || uncategorized.Revenue != 0 {
+		report = append(report, uncategorized)
+	}
+	return report, nil
+}
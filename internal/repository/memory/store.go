@@ -0,0 +1,257 @@
+// Package memory implements the repository interfaces in process memory.
+// It follows the semantics of the postgres implementation closely enough to
+// exercise the HTTP handlers in tests without a database.
+package memory
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/money"
+	"sync"
+	"time"
+)
+
+type session struct {
+	id               int64
+	userID           int64
+	refreshTokenHash string
+	expiresAt        time.Time
+	revoked          bool
+}
+
+type payment struct {
+	saleID int64
+	models.Payment
+}
+
+// data is everything the store holds. Transactions work on a deep copy and
+// swap it in on commit.
+type data struct {
+	users        map[int64]models.User
+	sessions     map[int64]session
+	roles        map[string]models.Role
+	permissions  map[string]models.Permission
+	products     map[int64]models.Product
+	categories   map[int64]models.Category
+	variants     map[int64]models.ProductVariant
+	bundles      map[int64][]models.BundleComponent // Components by bundle ID
+	lots         map[int64]models.ProductLot
+	customers    map[int64]models.Customer
+	movements    []models.StockMovement
+	sales        map[int64]models.Sale
+	payments     []payment
+	refunds      []models.Refund
+	promotions   map[int64]models.Promotion
+	quotes       map[int64]models.Quote
+	reservations map[int64]models.StockReservation
+	suppliers    map[int64]models.Supplier
+	orders       map[int64]models.PurchaseOrder
+
+	lastUserID, lastSessionID, lastProductID, lastCustomerID, lastMovementID, lastSaleID, lastRefundID, lastPromotionID, lastQuoteID, lastReservationID, lastSupplierID, lastOrderID, lastCategoryID, lastVariantID, lastLotID int64
+}
+
+func (d *data) clone() *data {
+	c := *d
+	c.users = make(map[int64]models.User, len(d.users))
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+	c.sessions = make(map[int64]session, len(d.sessions))
+	for k, v := range d.sessions {
+		c.sessions[k] = v
+	}
+	c.roles = make(map[string]models.Role, len(d.roles))
+	for k, v := range d.roles {
+		v.Permissions = append([]string{}, v.Permissions...)
+		c.roles[k] = v
+	}
+	c.permissions = make(map[string]models.Permission, len(d.permissions))
+	for k, v := range d.permissions {
+		c.permissions[k] = v
+	}
+	c.products = make(map[int64]models.Product, len(d.products))
+	for k, v := range d.products {
+		c.products[k] = v
+	}
+	c.categories = make(map[int64]models.Category, len(d.categories))
+	for k, v := range d.categories {
+		c.categories[k] = v
+	}
+	c.variants = make(map[int64]models.ProductVariant, len(d.variants))
+	for k, v := range d.variants {
+		c.variants[k] = v
+	}
+	c.bundles = make(map[int64][]models.BundleComponent, len(d.bundles))
+	for k, v := range d.bundles {
+		c.bundles[k] = v
+	}
+	c.lots = make(map[int64]models.ProductLot, len(d.lots))
+	for k, v := range d.lots {
+		c.lots[k] = v
+	}
+	c.customers = make(map[int64]models.Customer, len(d.customers))
+	for k, v := range d.customers {
+		c.customers[k] = v
+	}
+	c.movements = append([]models.StockMovement{}, d.movements...)
+	c.sales = make(map[int64]models.Sale, len(d.sales))
+	for k, v := range d.sales {
+		v.Items = append([]models.SaleItem{}, v.Items...)
+		c.sales[k] = v
+	}
+	c.payments = append([]payment{}, d.payments...)
+	c.refunds = make([]models.Refund, len(d.refunds))
+	for i, v := range d.refunds {
+		v.Items = append([]models.SaleItem{}, v.Items...)
+		c.refunds[i] = v
+	}
+	c.promotions = make(map[int64]models.Promotion, len(d.promotions))
+	for k, v := range d.promotions {
+		c.promotions[k] = v
+	}
+	c.quotes = make(map[int64]models.Quote, len(d.quotes))
+	for k, v := range d.quotes {
+		v.Items = append([]models.SaleItem{}, v.Items...)
+		c.quotes[k] = v
+	}
+	c.reservations = make(map[int64]models.StockReservation, len(d.reservations))
+	for k, v := range d.reservations {
+		c.reservations[k] = v
+	}
+	c.suppliers = make(map[int64]models.Supplier, len(d.suppliers))
+	for k, v := range d.suppliers {
+		c.suppliers[k] = v
+	}
+	c.orders = make(map[int64]models.PurchaseOrder, len(d.orders))
+	for k, v := range d.orders {
+		v.Items = append([]models.PurchaseOrderItem{}, v.Items...)
+		c.orders[k] = v
+	}
+	return &c
+}
+
+// Store is a repository.Store kept in memory. A transaction holds the store
+// lock until it ends, so transactions are fully serialized.
+type Store struct {
+	mu   *sync.Mutex
+	data *data
+	inTx bool
+}
+
+// New returns an empty store seeded with the built-in permissions and the
+// admin, gerente and vendedor roles with the grants the migrations give them.
+func New() *Store {
+	d := &data{
+		users:        map[int64]models.User{},
+		sessions:     map[int64]session{},
+		roles:        map[string]models.Role{},
+		permissions:  map[string]models.Permission{},
+		products:     map[int64]models.Product{},
+		categories:   map[int64]models.Category{},
+		variants:     map[int64]models.ProductVariant{},
+		bundles:      map[int64][]models.BundleComponent{},
+		lots:         map[int64]models.ProductLot{},
+		customers:    map[int64]models.Customer{},
+		sales:        map[int64]models.Sale{},
+		promotions:   map[int64]models.Promotion{},
+		quotes:       map[int64]models.Quote{},
+		reservations: map[int64]models.StockReservation{},
+		suppliers:    map[int64]models.Supplier{},
+		orders:       map[int64]models.PurchaseOrder{},
+	}
+
+	all := []string{}
+	for _, p := range builtinPermissions {
+		d.permissions[p.Code] = p
+		all = append(all, p.Code)
+	}
+	gerente := []string{}
+	for _, code := range all {
+		if code != "users:write" && code != "roles:manage" && code != "discounts:approve" && code != "promotions:manage" {
+			gerente = append(gerente, code)
+		}
+	}
+	gerenteLimit, vendedorLimit := money.FromCents(1000), money.FromCents(500)
+	d.roles["admin"] = models.Role{Name: "admin", Description: "Administrador", Permissions: all}
+	d.roles["gerente"] = models.Role{Name: "gerente", Description: "Gerente de loja", Permissions: gerente, MaxDiscount: &gerenteLimit}
+	d.roles["vendedor"] = models.Role{Name: "vendedor", Description: "Vendedor", Permissions: []string{"customers:write", "sales:create"}, MaxDiscount: &vendedorLimit}
+
+	return &Store{mu: &sync.Mutex{}, data: d}
+}
+
+// builtinPermissions mirrors the permissions seeded by the migrations.
+var builtinPermissions = []models.Permission{
+	{Code: "customers:write", Description: "Register and edit customers"},
+	{Code: "discounts:approve", Description: "Give and approve discounts above the role limit"},
+	{Code: "products:write", Description: "Create, edit and delete products"},
+	{Code: "promotions:manage", Description: "Create, edit and delete promotions and coupons"},
+	{Code: "reports:view", Description: "View store-wide dashboards and reports"},
+	{Code: "roles:manage", Description: "Manage roles and their permissions"},
+	{Code: "sales:cancel", Description: "Cancel sales and process returns"},
+	{Code: "sales:create", Description: "Register sales"},
+	{Code: "sales:create_on_behalf", Description: "Register sales on behalf of another seller"},
+	{Code: "sales:view_all", Description: "View sales of every seller"},
+	{Code: "stock:manage", Description: "Record stock movements and reconcile stock"},
+	{Code: "users:read", Description: "View users"},
+	{Code: "users:write", Description: "Create, edit and delete users, change roles and revoke sessions"},
+}
+
+// lock takes the store lock, unless this store is a transaction already holding it.
+func (s *Store) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (s *Store) Users() repository.UserRepository               { return userRepository{s} }
+func (s *Store) Sessions() repository.SessionRepository         { return sessionRepository{s} }
+func (s *Store) Roles() repository.RoleRepository               { return roleRepository{s} }
+func (s *Store) Products() repository.ProductRepository         { return productRepository{s} }
+func (s *Store) Categories() repository.CategoryRepository      { return categoryRepository{s} }
+func (s *Store) Variants() repository.VariantRepository         { return variantRepository{s} }
+func (s *Store) Bundles() repository.BundleRepository           { return bundleRepository{s} }
+func (s *Store) Lots() repository.LotRepository                 { return lotRepository{s} }
+func (s *Store) Customers() repository.CustomerRepository       { return customerRepository{s} }
+func (s *Store) Sales() repository.SaleRepository               { return saleRepository{s} }
+func (s *Store) Promotions() repository.PromotionRepository     { return promotionRepository{s} }
+func (s *Store) Quotes() repository.QuoteRepository             { return quoteRepository{s} }
+func (s *Store) Reservations() repository.ReservationRepository { return reservationRepository{s} }
+func (s *Store) Suppliers() repository.SupplierRepository       { return supplierRepository{s} }
+func (s *Store) PurchaseOrders() repository.PurchaseOrderRepository {
+	return purchaseOrderRepository{s}
+}
+func (s *Store) Dashboard() repository.DashboardRepository { return dashboardRepository{s} }
+
+// WithTx runs fn against a copy of the data and keeps the copy only if fn
+// succeeds. fn must use the Store it is given; calling back into s would
+// deadlock.
+func (s *Store) WithTx(ctx context.Context, fn func(tx repository.Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &Store{mu: s.mu, data: s.data.clone(), inTx: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.data = tx.data
+	return nil
+}
+
+// page slices items for a limit/offset page.
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"sort"
+	"strings"
+	"time"
+)
+
+type supplierRepository struct{ s *Store }
+
+func (r supplierRepository) List(ctx context.Context, filter repository.SupplierFilter) ([]models.Supplier, int, error) {
+	defer r.s.lock()()
+
+	search := strings.ToLower(filter.Search)
+	suppliers := []models.Supplier{}
+	for _, sup := range r.s.data.suppliers {
+		if search != "" && !strings.Contains(strings.ToLower(sup.Name), search) &&
+			(sup.Document == "" || !strings.Contains(sup.Document, filter.Search)) {
+			continue
+		}
+		suppliers = append(suppliers, sup)
+	}
+	sort.Slice(suppliers, func(i, j int) bool {
+		if suppliers[i].Name != suppliers[j].Name {
+			return suppliers[i].Name < suppliers[j].Name
+		}
+		return suppliers[i].ID < suppliers[j].ID
+	})
+	return page(suppliers, filter.Limit, filter.Offset), len(suppliers), nil
+}
+
+func (r supplierRepository) Get(ctx context.Context, id int64) (models.Supplier, error) {
+	defer r.s.lock()()
+
+	sup, ok := r.s.data.suppliers[id]
+	if !ok {
+		return models.Supplier{}, repository.ErrNotFound
+	}
+	return sup, nil
+}
+
+func (r supplierRepository) Create(ctx context.Context, sup *models.Supplier) error {
+	defer r.s.lock()()
+
+	if r.documentTaken(sup.Document, 0) {
+		return repository.ErrConflict
+	}
+	r.s.data.lastSupplierID++
+	sup.ID = r.s.data.lastSupplierID
+	sup.CreatedAt = time.Now()
+	r.s.data.suppliers[sup.ID] = *sup
+	return nil
+}
+
+func (r supplierRepository) Update(ctx context.Context, sup models.Supplier) error {
+	defer r.s.lock()()
+
+	current, ok := r.s.data.suppliers[sup.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if r.documentTaken(sup.Document, sup.ID) {
+		return repository.ErrConflict
+	}
+	sup.CreatedAt = current.CreatedAt
+	r.s.data.suppliers[sup.ID] = sup
+	return nil
+}
+
+// documentTaken reports whether another supplier already has the document.
+func (r supplierRepository) documentTaken(document string, exceptID int64) bool {
+	if document == "" {
+		return false
+	}
+	for _, sup := range r.s.data.suppliers {
+		if sup.Document == document && sup.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r supplierRepository) Delete(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.suppliers[id]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, order := range r.s.data.orders {
+		if order.SupplierID == id {
+			return repository.ErrInUse
+		}
+	}
+	delete(r.s.data.suppliers, id)
+	return nil
+}
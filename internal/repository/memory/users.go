@@ -0,0 +1,188 @@
+package memory
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"sort"
+	"time"
+)
+
+type userRepository struct{ s *Store }
+
+func (r userRepository) List(ctx context.Context, filter repository.UserFilter) ([]models.User, int, error) {
+	defer r.s.lock()()
+
+	users := []models.User{}
+	for _, u := range r.s.data.users {
+		if filter.Role != "" && u.Role != filter.Role {
+			continue
+		}
+		u.PasswordHash = ""
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return page(users, filter.Limit, filter.Offset), len(users), nil
+}
+
+func (r userRepository) Get(ctx context.Context, id int64) (models.User, error) {
+	defer r.s.lock()()
+
+	u, ok := r.s.data.users[id]
+	if !ok {
+		return models.User{}, repository.ErrNotFound
+	}
+	u.PasswordHash = ""
+	return u, nil
+}
+
+func (r userRepository) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	defer r.s.lock()()
+
+	for _, u := range r.s.data.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return models.User{}, repository.ErrNotFound
+}
+
+func (r userRepository) Create(ctx context.Context, user *models.User) error {
+	defer r.s.lock()()
+
+	if r.usernameTaken(user.Username, 0) {
+		return repository.ErrConflict
+	}
+	r.s.data.lastUserID++
+	user.ID = r.s.data.lastUserID
+	r.s.data.users[user.ID] = *user
+	return nil
+}
+
+func (r userRepository) Update(ctx context.Context, user models.User) error {
+	defer r.s.lock()()
+
+	current, ok := r.s.data.users[user.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	if r.usernameTaken(user.Username, user.ID) {
+		return repository.ErrConflict
+	}
+	current.Name, current.Username, current.Role = user.Name, user.Username, user.Role
+	r.s.data.users[user.ID] = current
+	return nil
+}
+
+func (r userRepository) usernameTaken(username string, exceptID int64) bool {
+	for _, u := range r.s.data.users {
+		if u.Username == username && u.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r userRepository) Delete(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.users[id]; !ok {
+		return repository.ErrNotFound
+	}
+	for _, sale := range r.s.data.sales {
+		if sale.UserID == id || sale.CreatedBy == id {
+			return repository.ErrInUse
+		}
+	}
+	for _, refund := range r.s.data.refunds {
+		if refund.UserID == id {
+			return repository.ErrInUse
+		}
+	}
+	for _, quote := range r.s.data.quotes {
+		if quote.UserID == id || quote.CreatedBy == id {
+			return repository.ErrInUse
+		}
+	}
+	for _, order := range r.s.data.orders {
+		if order.CreatedBy == id {
+			return repository.ErrInUse
+		}
+	}
+
+	delete(r.s.data.users, id)
+	for sid, sess := range r.s.data.sessions {
+		if sess.userID == id {
+			delete(r.s.data.sessions, sid)
+		}
+	}
+	for i, m := range r.s.data.movements {
+		if m.UserID != nil && *m.UserID == id {
+			r.s.data.movements[i].UserID = nil
+		}
+	}
+	return nil
+}
+
+type sessionRepository struct{ s *Store }
+
+func (r sessionRepository) Create(ctx context.Context, userID int64, refreshTokenHash string, expiresAt time.Time) (int64, error) {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.users[userID]; !ok {
+		return 0, repository.ErrInUse
+	}
+	r.s.data.lastSessionID++
+	id := r.s.data.lastSessionID
+	r.s.data.sessions[id] = session{id: id, userID: userID, refreshTokenHash: refreshTokenHash, expiresAt: expiresAt}
+	return id, nil
+}
+
+func (r sessionRepository) Rotate(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (int64, models.User, error) {
+	defer r.s.lock()()
+
+	now := time.Now()
+	for id, sess := range r.s.data.sessions {
+		if sess.refreshTokenHash != oldHash || sess.revoked || !sess.expiresAt.After(now) {
+			continue
+		}
+		sess.refreshTokenHash, sess.expiresAt = newHash, expiresAt
+		r.s.data.sessions[id] = sess
+
+		user := r.s.data.users[sess.userID]
+		user.PasswordHash = ""
+		return id, user, nil
+	}
+	return 0, models.User{}, repository.ErrNotFound
+}
+
+func (r sessionRepository) IsActive(ctx context.Context, sessionID int64) (bool, error) {
+	defer r.s.lock()()
+
+	sess, ok := r.s.data.sessions[sessionID]
+	return ok && !sess.revoked && sess.expiresAt.After(time.Now()), nil
+}
+
+func (r sessionRepository) Revoke(ctx context.Context, sessionID int64) error {
+	defer r.s.lock()()
+
+	if sess, ok := r.s.data.sessions[sessionID]; ok {
+		sess.revoked = true
+		r.s.data.sessions[sessionID] = sess
+	}
+	return nil
+}
+
+func (r sessionRepository) RevokeAllForUser(ctx context.Context, userID int64) (int64, error) {
+	defer r.s.lock()()
+
+	var revoked int64
+	for id, sess := range r.s.data.sessions {
+		if sess.userID == userID && !sess.revoked {
+			sess.revoked = true
+			r.s.data.sessions[id] = sess
+			revoked++
+		}
+	}
+	return revoked, nil
+}
@@ -0,0 +1,170 @@
+package memory
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+type variantRepository struct{ s *Store }
+
+// copyVariant keeps callers from sharing the stored attributes map.
+func copyVariant(v models.ProductVariant) models.ProductVariant {
+	v.Attributes = maps.Clone(v.Attributes)
+	if v.Attributes == nil {
+		v.Attributes = map[string]string{}
+	}
+	return v
+}
+
+// withVariantStock copies the variant and fills in its reserved and
+// available stock.
+func (s *Store) withVariantStock(v models.ProductVariant) models.ProductVariant {
+	v = copyVariant(v)
+	v.Reserved = s.variantReserved(v.ID)
+	v.Available = v.Quantity - v.Reserved
+	return v
+}
+
+func (r variantRepository) List(ctx context.Context, productIDs ...int64) ([]models.ProductVariant, error) {
+	defer r.s.lock()()
+
+	variants := []models.ProductVariant{}
+	for _, v := range r.s.data.variants {
+		if slices.Contains(productIDs, v.ProductID) {
+			variants = append(variants, r.s.withVariantStock(v))
+		}
+	}
+	sort.Slice(variants, func(i, j int) bool { return variants[i].ID < variants[j].ID })
+	return variants, nil
+}
+
+func (r variantRepository) Get(ctx context.Context, id int64) (models.ProductVariant, error) {
+	defer r.s.lock()()
+
+	v, ok := r.s.data.variants[id]
+	if !ok {
+		return models.ProductVariant{}, repository.ErrNotFound
+	}
+	return r.s.withVariantStock(v), nil
+}
+
+// check enforces the product foreign key and unique names per product.
+func (r variantRepository) check(v models.ProductVariant) error {
+	if _, ok := r.s.data.products[v.ProductID]; !ok {
+		return repository.ErrInUse
+	}
+	for _, other := range r.s.data.variants {
+		if other.ID != v.ID && other.ProductID == v.ProductID && strings.EqualFold(other.Name, v.Name) {
+			return repository.ErrConflict
+		}
+	}
+	return nil
+}
+
+func (r variantRepository) Create(ctx context.Context, v *models.ProductVariant) error {
+	defer r.s.lock()()
+
+	if err := r.check(*v); err != nil {
+		return err
+	}
+	r.s.data.lastVariantID++
+	v.ID = r.s.data.lastVariantID
+	v.CreatedAt = time.Now()
+	r.s.data.variants[v.ID] = copyVariant(*v)
+	return nil
+}
+
+func (r variantRepository) Update(ctx context.Context, v models.ProductVariant) error {
+	defer r.s.lock()()
+
+	current, ok := r.s.data.variants[v.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	v.ProductID, v.CreatedAt = current.ProductID, current.CreatedAt
+	if err := r.check(v); err != nil {
+		return err
+	}
+	r.s.data.variants[v.ID] = copyVariant(v)
+	return nil
+}
+
+func (r variantRepository) Delete(ctx context.Context, id int64) error {
+	defer r.s.lock()()
+
+	if _, ok := r.s.data.variants[id]; !ok {
+		return repository.ErrNotFound
+	}
+	refers := func(items []models.SaleItem) bool {
+		return slices.ContainsFunc(items, func(item models.SaleItem) bool {
+			return item.VariantID != nil && *item.VariantID == id
+		})
+	}
+	for _, sale := range r.s.data.sales {
+		if refers(sale.Items) {
+			return repository.ErrInUse
+		}
+	}
+	for _, quote := range r.s.data.quotes {
+		if refers(quote.Items) {
+			return repository.ErrInUse
+		}
+	}
+	for _, refund := range r.s.data.refunds {
+		if refers(refund.Items) {
+			return repository.ErrInUse
+		}
+	}
+	for _, order := range r.s.data.orders {
+		for _, item := range order.Items {
+			if item.VariantID != nil && *item.VariantID == id {
+				return repository.ErrInUse
+			}
+		}
+	}
+
+	delete(r.s.data.variants, id)
+	movements := r.s.data.movements[:0]
+	for _, m := range r.s.data.movements {
+		if m.VariantID == nil || *m.VariantID != id {
+			movements = append(movements, m)
+		}
+	}
+	r.s.data.movements = movements
+	for resID, res := range r.s.data.reservations {
+		if res.VariantID != nil && *res.VariantID == id {
+			delete(r.s.data.reservations, resID)
+		}
+	}
+	return nil
+}
+
+func (r variantRepository) AdjustStock(ctx context.Context, id int64, delta int) error {
+	defer r.s.lock()()
+
+	v, ok := r.s.data.variants[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	v.Quantity += delta
+	r.s.data.variants[id] = v
+	return nil
+}
+
+func (r variantRepository) DecrementStock(ctx context.Context, id int64, quantity int) (models.ProductVariant, error) {
+	defer r.s.lock()()
+
+	v, ok := r.s.data.variants[id]
+	if !ok || v.Quantity-r.s.variantReserved(id) < quantity {
+		return models.ProductVariant{}, repository.ErrInsufficientStock
+	}
+	v.Quantity -= quantity
+	r.s.data.variants[id] = v
+	return r.s.withVariantStock(v), nil
+}
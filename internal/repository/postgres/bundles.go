@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+
+	"github.com/lib/pq"
+)
+
+type bundleRepository struct{ q querier }
+
+func (r bundleRepository) List(ctx context.Context, bundleIDs ...int64) ([]models.BundleComponent, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT bc.bundle_id, bc.product_id, products.name, bc.quantity, products.quantity - `+reservedQuantity+`
+		FROM bundle_components bc
+		JOIN products ON products.id = bc.product_id
+		WHERE bc.bundle_id = ANY($1)
+		ORDER BY bc.bundle_id, bc.product_id`,
+		pq.Array(bundleIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	components := []models.BundleComponent{}
+	for rows.Next() {
+		var c models.BundleComponent
+		if err := rows.Scan(&c.BundleID, &c.ProductID, &c.ProductName, &c.Quantity, &c.Available); err != nil {
+			return nil, err
+		}
+		components = append(components, c)
+	}
+	return components, rows.Err()
+}
+
+func (r bundleRepository) Set(ctx context.Context, bundleID int64, components []models.BundleComponent) error {
+	if _, err := r.q.ExecContext(ctx, "DELETE FROM bundle_components WHERE bundle_id = $1", bundleID); err != nil {
+		return err
+	}
+	for _, c := range components {
+		_, err := r.q.ExecContext(ctx,
+			"INSERT INTO bundle_components (bundle_id, product_id, quantity) VALUES ($1, $2, $3)",
+			bundleID, c.ProductID, c.Quantity,
+		)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+func (r bundleRepository) ContainedIn(ctx context.Context, productID int64) ([]int64, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT bundle_id FROM bundle_components WHERE product_id = $1 ORDER BY bundle_id", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+)
+
+type categoryRepository struct{ q querier }
+
+// categorySubtree selects the IDs of the category given as ? and of every
+// category below it.
+const categorySubtree = `WITH RECURSIVE subtree AS (
+	SELECT id FROM categories WHERE id = ?
+	UNION ALL
+	SELECT c.id FROM categories c JOIN subtree st ON c.parent_id = st.id
+) SELECT id FROM subtree`
+
+func (r categoryRepository) List(ctx context.Context) ([]models.Category, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT id, name, parent_id, created_at FROM categories ORDER BY name, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		var c models.Category
+		if err := rows.Scan(&c.ID, &c.Name, &c.ParentID, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+func (r categoryRepository) Get(ctx context.Context, id int64) (models.Category, error) {
+	var c models.Category
+	err := r.q.QueryRowContext(ctx, "SELECT id, name, parent_id, created_at FROM categories WHERE id = $1", id).
+		Scan(&c.ID, &c.Name, &c.ParentID, &c.CreatedAt)
+	return c, mapError(err)
+}
+
+func (r categoryRepository) Create(ctx context.Context, c *models.Category) error {
+	err := r.q.QueryRowContext(ctx,
+		"INSERT INTO categories (name, parent_id) VALUES ($1, $2) RETURNING id, created_at",
+		c.Name, c.ParentID,
+	).Scan(&c.ID, &c.CreatedAt)
+	return mapError(err)
+}
+
+func (r categoryRepository) Update(ctx context.Context, c models.Category) error {
+	return expectOne(r.q.ExecContext(ctx,
+		"UPDATE categories SET name = $1, parent_id = $2 WHERE id = $3",
+		c.Name, c.ParentID, c.ID,
+	))
+}
+
+func (r categoryRepository) Delete(ctx context.Context, id int64) error {
+	return expectOne(r.q.ExecContext(ctx, "DELETE FROM categories WHERE id = $1", id))
+}
+
+func (r categoryRepository) Ancestors(ctx context.Context, id int64) ([]int64, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		WITH RECURSIVE up AS (
+			SELECT id, parent_id, 0 AS depth FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.parent_id, up.depth + 1 FROM categories c JOIN up ON c.id = up.parent_id
+		)
+		SELECT id FROM up ORDER BY depth`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var ancestor int64
+		if err := rows.Scan(&ancestor); err != nil {
+			return nil, err
+		}
+		ids = append(ids, ancestor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return ids, nil
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+)
+
+type customerRepository struct{ q querier }
+
+const customerColumns = "id, name, COALESCE(document, ''), phone, email, address, created_at"
+
+func scanCustomer(row interface{ Scan(...interface{}) error }, c *models.Customer) error {
+	return row.Scan(&c.ID, &c.Name, &c.Document, &c.Phone, &c.Email, &c.Address, &c.CreatedAt)
+}
+
+func (r customerRepository) List(ctx context.Context, filter repository.CustomerFilter) ([]models.Customer, int, error) {
+	var filters filterBuilder
+	if filter.Search != "" {
+		filters.add("(name ILIKE '%' || ? || '%' OR document LIKE '%' || ? || '%')", filter.Search)
+	}
+
+	var total int
+	if err := r.q.QueryRowContext(ctx, "SELECT COUNT(*) FROM customers"+filters.where(), filters.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	pageSQL, args := filters.page(filter.Limit, filter.Offset)
+	rows, err := r.q.QueryContext(ctx, "SELECT "+customerColumns+" FROM customers"+filters.where()+" ORDER BY name, id"+pageSQL, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	customers := []models.Customer{}
+	for rows.Next() {
+		var c models.Customer
+		if err := scanCustomer(rows, &c); err != nil {
+			return nil, 0, err
+		}
+		customers = append(customers, c)
+	}
+	return customers, total, rows.Err()
+}
+
+func (r customerRepository) Get(ctx context.Context, id int64) (models.Customer, error) {
+	var c models.Customer
+	err := scanCustomer(r.q.QueryRowContext(ctx, "SELECT "+customerColumns+" FROM customers WHERE id = $1", id), &c)
+	return c, mapError(err)
+}
+
+func (r customerRepository) Create(ctx context.Context, c *models.Customer) error {
+	err := r.q.QueryRowContext(ctx,
+		"INSERT INTO customers (name, document, phone, email, address) VALUES ($1, NULLIF($2, ''), $3, $4, $5) RETURNING id, created_at",
+		c.Name, c.Document, c.Phone, c.Email, c.Address,
+	).Scan(&c.ID, &c.CreatedAt)
+	return mapError(err)
+}
+
+func (r customerRepository) Update(ctx context.Context, c models.Customer) error {
+	return expectOne(r.q.ExecContext(ctx,
+		"UPDATE customers SET name = $1, document = NULLIF($2, ''), phone = $3, email = $4, address = $5 WHERE id = $6",
+		c.Name, c.Document, c.Phone, c.Email, c.Address, c.ID,
+	))
+}
+
+func (r customerRepository) Delete(ctx context.Context, id int64) error {
+	return expectOne(r.q.ExecContext(ctx, "DELETE FROM customers WHERE id = $1", id))
+}
+
+func (r customerRepository) Metrics(ctx context.Context, id int64) (models.CustomerMetrics, error) {
+	metrics := models.CustomerMetrics{CustomerID: id}
+	var first, last sql.NullTime
+	err := r.q.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(t.net), 0), MIN(t.date), MAX(t.date)
+		FROM (
+			SELECT s.id, s.date, COALESCE(SUM(si.unit_price * si.quantity - si.discount - si.refunded_amount), 0) AS net
+			FROM sales s
+			LEFT JOIN sales_items si ON si.sale_id = s.id
+			WHERE s.customer_id = $1 AND s.status <> 'cancelled'
+			GROUP BY s.id, s.date
+		) t
+	`, id).Scan(&metrics.PurchaseCount, &metrics.LifetimeValue, &first, &last)
+	if err != nil {
+		return metrics, err
+	}
+	if first.Valid {
+		metrics.FirstPurchaseAt = &first.Time
+	}
+	if last.Valid {
+		metrics.LastPurchaseAt = &last.Time
+	}
+	return metrics, nil
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/money"
+	"time"
+)
+
+type dashboardRepository struct{ q querier }
+
+func (r dashboardRepository) AdminSummary(ctx context.Context, since time.Time, sellerPermission string) (models.AdminDashboardSummary, error) {
+	var summary models.AdminDashboardSummary
+	err := r.q.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(si.unit_price * si.quantity - si.discount - si.refunded_amount), 0),
+			COALESCE(SUM(si.unit_price * si.quantity - si.discount - si.refunded_amount - si.unit_cost * (si.quantity - si.returned_quantity)), 0)
+		FROM sales s
+		JOIN sales_items si ON s.id = si.sale_id
+		WHERE s.date >= $1 AND s.status <> 'cancelled'
+	`, since).Scan(&summary.TotalSalesMonth, &summary.GrossMarginMonth)
+	if err != nil {
+		return summary, err
+	}
+
+	err = r.q.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM users u
+		JOIN role_permissions rp ON rp.role = u.role
+		WHERE rp.permission = $1
+	`, sellerPermission).Scan(&summary.TotalSellers)
+	if err != nil {
+		return summary, err
+	}
+
+	// Bundles have no stock of their own; their components are counted instead
+	err = r.q.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM products p
+		WHERE p.quantity < 10
+		  AND NOT EXISTS (SELECT 1 FROM bundle_components bc WHERE bc.bundle_id = p.id)
+	`).Scan(&summary.LowStockProducts)
+	if err != nil {
+		return summary, err
+	}
+
+	err = r.q.QueryRowContext(ctx, `
+		SELECT p.id, p.name
+		FROM sales s
+		JOIN sales_items si ON s.id = si.sale_id
+		JOIN products p ON si.product_id = p.id
+		WHERE s.status <> 'cancelled'
+		GROUP BY p.id, p.name
+		ORDER BY SUM(si.quantity - si.returned_quantity) DESC, p.id
+		LIMIT 1
+	`).Scan(&summary.TopSellingProduct.ID, &summary.TopSellingProduct.Name)
+	if err != nil && err != sql.ErrNoRows {
+		return summary, err
+	}
+
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT sp.sale_id, sp.method, sp.amount,
+			(SELECT COALESCE(SUM(si.refunded_amount), 0) FROM sales_items si WHERE si.sale_id = sp.sale_id)
+		FROM sale_payments sp
+		JOIN sales s ON s.id = sp.sale_id
+		WHERE s.date >= $1 AND s.status <> 'cancelled'
+		ORDER BY sp.sale_id, sp.id
+	`, since)
+	if err != nil {
+		return summary, err
+	}
+	defer rows.Close()
+
+	var sales []repository.SalePayments
+	var lastSaleID int64
+	for rows.Next() {
+		var saleID int64
+		var p models.Payment
+		var refunded money.Amount
+		if err := rows.Scan(&saleID, &p.Method, &p.Amount, &refunded); err != nil {
+			return summary, err
+		}
+		if len(sales) == 0 || saleID != lastSaleID {
+			sales = append(sales, repository.SalePayments{Refunded: refunded})
+			lastSaleID = saleID
+		}
+		sales[len(sales)-1].Payments = append(sales[len(sales)-1].Payments, p)
+	}
+	if err := rows.Err(); err != nil {
+		return summary, err
+	}
+	summary.PaymentMethods = repository.SumPaymentMethods(sales)
+	return summary, nil
+}
+
+func (r dashboardRepository) SellerTotals(ctx context.Context, userID int64, since time.Time) (models.VendedorDashboardSummary, error) {
+	var summary models.VendedorDashboardSummary
+	err := r.q.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(si.unit_price * si.quantity - si.discount - si.refunded_amount), 0)
+		FROM sales s
+		JOIN sales_items si ON s.id = si.sale_id
+		WHERE s.user_id = $1 AND s.date >= $2 AND s.status <> 'cancelled'
+	`, userID, since).Scan(&summary.MyTotalSalesMonth)
+	if err != nil {
+		return summary, err
+	}
+
+	err = r.q.QueryRowContext(ctx, `
+		WITH ranked_sellers AS (
+			SELECT
+				s.user_id,
+				RANK() OVER (ORDER BY SUM(si.unit_price * si.quantity - si.discount - si.refunded_amount) DESC) as rank
+			FROM sales s
+			JOIN sales_items si ON s.id = si.sale_id
+			WHERE s.date >= $2 AND s.status <> 'cancelled'
+			GROUP BY s.user_id
+		)
+		SELECT rank FROM ranked_sellers WHERE user_id = $1
+	`, userID, since).Scan(&summary.MyRank)
+	if err != nil && err != sql.ErrNoRows {
+		return summary, err
+	}
+	// Sellers without sales this period are unranked (0)
+	return summary, nil
+}
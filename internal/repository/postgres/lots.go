@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type lotRepository struct{ q querier }
+
+const lotColumns = "id, product_id, code, expires_on, quantity, created_at"
+
+func scanLot(row interface{ Scan(...interface{}) error }) (models.ProductLot, error) {
+	var l models.ProductLot
+	err := row.Scan(&l.ID, &l.ProductID, &l.Code, &l.ExpiresOn, &l.Quantity, &l.CreatedAt)
+	return l, err
+}
+
+func (r lotRepository) List(ctx context.Context, productIDs ...int64) ([]models.ProductLot, error) {
+	rows, err := r.q.QueryContext(ctx,
+		"SELECT "+lotColumns+" FROM product_lots WHERE product_id = ANY($1) ORDER BY expires_on, id",
+		pq.Array(productIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lots := []models.ProductLot{}
+	for rows.Next() {
+		l, err := scanLot(rows)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, l)
+	}
+	return lots, rows.Err()
+}
+
+func (r lotRepository) Get(ctx context.Context, id int64) (models.ProductLot, error) {
+	l, err := scanLot(r.q.QueryRowContext(ctx, "SELECT "+lotColumns+" FROM product_lots WHERE id = $1", id))
+	return l, mapError(err)
+}
+
+func (r lotRepository) Create(ctx context.Context, l *models.ProductLot) error {
+	err := r.q.QueryRowContext(ctx,
+		"INSERT INTO product_lots (product_id, code, expires_on, quantity) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		l.ProductID, l.Code, l.ExpiresOn, l.Quantity,
+	).Scan(&l.ID, &l.CreatedAt)
+	return mapError(err)
+}
+
+func (r lotRepository) Update(ctx context.Context, l models.ProductLot) error {
+	return expectOne(r.q.ExecContext(ctx,
+		"UPDATE product_lots SET code = $1, expires_on = $2, quantity = $3 WHERE id = $4",
+		l.Code, l.ExpiresOn, l.Quantity, l.ID,
+	))
+}
+
+func (r lotRepository) Delete(ctx context.Context, id int64) error {
+	return expectOne(r.q.ExecContext(ctx, "DELETE FROM product_lots WHERE id = $1", id))
+}
+
+func (r lotRepository) AdjustStock(ctx context.Context, id int64, delta int) error {
+	return expectOne(r.q.ExecContext(ctx, "UPDATE product_lots SET quantity = quantity + $1 WHERE id = $2", delta, id))
+}
+
+func (r lotRepository) Consume(ctx context.Context, productID int64, quantity int, on time.Time) ([]models.SaleItemLot, error) {
+	// Lock every lot of the product, so concurrent sales queue up behind this one
+	rows, err := r.q.QueryContext(ctx,
+		"SELECT "+lotColumns+" FROM product_lots WHERE product_id = $1 ORDER BY expires_on, id FOR UPDATE",
+		productID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var lots []models.ProductLot
+	for rows.Next() {
+		l, err := scanLot(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		lots = append(lots, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(lots) == 0 {
+		return nil, err
+	}
+
+	var taken []models.SaleItemLot
+	left := quantity
+	for _, l := range lots {
+		if left == 0 {
+			break
+		}
+		if l.Quantity == 0 || l.ExpiresOn.Before(on) {
+			continue
+		}
+		n := min(l.Quantity, left)
+		taken = append(taken, models.SaleItemLot{LotID: l.ID, ProductID: productID, Code: l.Code, ExpiresOn: l.ExpiresOn, Quantity: n})
+		left -= n
+	}
+	if left > 0 {
+		return nil, repository.ErrInsufficientStock
+	}
+	for _, t := range taken {
+		if err := r.AdjustStock(ctx, t.LotID, -t.Quantity); err != nil {
+			return nil, err
+		}
+	}
+	return taken, nil
+}
+
+func (r lotRepository) Expiring(ctx context.Context, until time.Time) ([]models.ProductLot, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT l.id, l.product_id, p.name, l.code, l.expires_on, l.quantity, l.created_at
+		FROM product_lots l
+		JOIN products p ON p.id = l.product_id
+		WHERE l.quantity > 0 AND l.expires_on <= $1
+		ORDER BY l.expires_on, l.id`,
+		until,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lots := []models.ProductLot{}
+	for rows.Next() {
+		var l models.ProductLot
+		if err := rows.Scan(&l.ID, &l.ProductID, &l.ProductName, &l.Code, &l.ExpiresOn, &l.Quantity, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		lots = append(lots, l)
+	}
+	return lots, rows.Err()
+}
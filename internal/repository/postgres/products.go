@@ -0,0 +1,223 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/money"
+	"strings"
+)
+
+type productRepository struct{ q querier }
+
+// reservedQuantity sums the units of a products row held by reservations.
+const reservedQuantity = `COALESCE((
+	SELECT SUM(sr.quantity) FROM stock_reservations sr
+	WHERE sr.product_id = products.id AND sr.status = 'active' AND sr.expires_at > NOW()
+), 0)`
+
+// variantReserved sums the units of a product_variants row held by
+// reservations.
+const variantReserved = `COALESCE((
+	SELECT SUM(sr.quantity) FROM stock_reservations sr
+	WHERE sr.variant_id = product_variants.id AND sr.status = 'active' AND sr.expires_at > NOW()
+), 0)`
+
+// categoryName is the name of the category of a products row.
+const categoryName = "COALESCE((SELECT c.name FROM categories c WHERE c.id = products.category_id), '')"
+
+const productColumns = "id, name, description, COALESCE(sku, ''), COALESCE(barcode, ''), category_id, " + categoryName + ", price, cost_price, quantity, " + reservedQuantity
+
+func scanProduct(row interface{ Scan(...interface{}) error }) (models.Product, error) {
+	var p models.Product
+	err := row.Scan(&p.ID, &p.Name, &p.Description, &p.SKU, &p.Barcode, &p.CategoryID, &p.Category, &p.Price, &p.CostPrice, &p.Quantity, &p.Reserved)
+	p.Available = p.Quantity - p.Reserved
+	return p, err
+}
+
+func (r productRepository) List(ctx context.Context, filter repository.ProductFilter) ([]models.Product, int, error) {
+	if !repository.ValidProductSort(filter.Sort) {
+		return nil, 0, fmt.Errorf("invalid product sort %q", filter.Sort)
+	}
+	orderBy := "id"
+	if filter.Sort != "" {
+		column := strings.TrimPrefix(filter.Sort, "-")
+		orderBy = column + ", id"
+		if column != filter.Sort {
+			orderBy = column + " DESC, id"
+		}
+	}
+
+	var filters filterBuilder
+	if filter.Search != "" {
+		filters.add("name ILIKE '%' || ? || '%'", filter.Search)
+	}
+	if filter.CategoryID != nil {
+		filters.add("category_id IN ("+categorySubtree+")", *filter.CategoryID)
+	}
+
+	var total int
+	if err := r.q.QueryRowContext(ctx, "SELECT COUNT(*) FROM products"+filters.where(), filters.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	pageSQL, args := filters.page(filter.Limit, filter.Offset)
+	rows, err := r.q.QueryContext(ctx, "SELECT "+productColumns+" FROM products"+filters.where()+" ORDER BY "+orderBy+pageSQL, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	products := []models.Product{}
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		products = append(products, p)
+	}
+	return products, total, rows.Err()
+}
+
+func (r productRepository) Get(ctx context.Context, id int64) (models.Product, error) {
+	return r.get(ctx, "SELECT "+productColumns+" FROM products WHERE id = $1", id)
+}
+
+func (r productRepository) GetForUpdate(ctx context.Context, id int64) (models.Product, error) {
+	return r.get(ctx, "SELECT "+productColumns+" FROM products WHERE id = $1 FOR UPDATE", id)
+}
+
+func (r productRepository) GetByBarcode(ctx context.Context, barcode string) (models.Product, error) {
+	return r.get(ctx, "SELECT "+productColumns+" FROM products WHERE barcode = $1", barcode)
+}
+
+func (r productRepository) GetBySKU(ctx context.Context, sku string) (models.Product, error) {
+	return r.get(ctx, "SELECT "+productColumns+" FROM products WHERE sku = $1", sku)
+}
+
+func (r productRepository) get(ctx context.Context, query string, arg interface{}) (models.Product, error) {
+	p, err := scanProduct(r.q.QueryRowContext(ctx, query, arg))
+	return p, mapError(err)
+}
+
+func (r productRepository) Create(ctx context.Context, p *models.Product) error {
+	err := r.q.QueryRowContext(ctx,
+		"INSERT INTO products (name, description, sku, barcode, category_id, price, cost_price, quantity) VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6, $7, $8) RETURNING id, "+categoryName,
+		p.Name, p.Description, p.SKU, p.Barcode, p.CategoryID, p.Price, p.CostPrice, p.Quantity,
+	).Scan(&p.ID, &p.Category)
+	p.Reserved, p.Available = 0, p.Quantity
+	return mapError(err)
+}
+
+func (r productRepository) Update(ctx context.Context, p models.Product) error {
+	return expectOne(r.q.ExecContext(ctx,
+		"UPDATE products SET name = $1, description = $2, sku = NULLIF($3, ''), barcode = NULLIF($4, ''), category_id = $5, price = $6, quantity = $7 WHERE id = $8",
+		p.Name, p.Description, p.SKU, p.Barcode, p.CategoryID, p.Price, p.Quantity, p.ID,
+	))
+}
+
+func (r productRepository) Delete(ctx context.Context, id int64) error {
+	return expectOne(r.q.ExecContext(ctx, "DELETE FROM products WHERE id = $1", id))
+}
+
+func (r productRepository) AdjustStock(ctx context.Context, id int64, delta int) error {
+	return expectOne(r.q.ExecContext(ctx, "UPDATE products SET quantity = quantity + $1 WHERE id = $2", delta, id))
+}
+
+func (r productRepository) ReceiveStock(ctx context.Context, id int64, quantity int, unitCost money.Amount) error {
+	return expectOne(r.q.ExecContext(ctx, `
+		UPDATE products SET
+			cost_price = ROUND((cost_price * GREATEST(quantity, 0) + $2::numeric * $1::integer) / (GREATEST(quantity, 0) + $1::integer), 2),
+			quantity = quantity + $1::integer
+		WHERE id = $3`,
+		quantity, unitCost, id,
+	))
+}
+
+func (r productRepository) DecrementStock(ctx context.Context, id int64, quantity int) (models.Product, error) {
+	p, err := scanProduct(r.q.QueryRowContext(ctx,
+		"UPDATE products SET quantity = quantity - $1 WHERE id = $2 AND quantity - "+reservedQuantity+" >= $1 RETURNING "+productColumns,
+		quantity, id,
+	))
+	if err == sql.ErrNoRows {
+		return p, repository.ErrInsufficientStock
+	}
+	return p, err
+}
+
+func (r productRepository) RecordMovement(ctx context.Context, m *models.StockMovement) error {
+	err := r.q.QueryRowContext(ctx,
+		"INSERT INTO stock_movements (product_id, variant_id, user_id, type, quantity_delta, reason, sale_id, purchase_order_id, date) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW()) RETURNING id, date",
+		m.ProductID, m.VariantID, m.UserID, m.Type, m.QuantityDelta, m.Reason, m.SaleID, m.PurchaseOrderID,
+	).Scan(&m.ID, &m.Date)
+	return mapError(err)
+}
+
+func (r productRepository) ListMovements(ctx context.Context, productID int64) ([]models.StockMovement, error) {
+	rows, err := r.q.QueryContext(ctx,
+		"SELECT id, product_id, variant_id, user_id, type, quantity_delta, reason, sale_id, purchase_order_id, date FROM stock_movements WHERE product_id = $1 ORDER BY date DESC, id DESC",
+		productID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := []models.StockMovement{}
+	for rows.Next() {
+		var (
+			m       models.StockMovement
+			userID  sql.NullInt64
+			saleID  sql.NullInt64
+			orderID sql.NullInt64
+		)
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.VariantID, &userID, &m.Type, &m.QuantityDelta, &m.Reason, &saleID, &orderID, &m.Date); err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			m.UserID = &userID.Int64
+		}
+		if saleID.Valid {
+			m.SaleID = &saleID.Int64
+		}
+		if orderID.Valid {
+			m.PurchaseOrderID = &orderID.Int64
+		}
+		movements = append(movements, m)
+	}
+	return movements, rows.Err()
+}
+
+func (r productRepository) Reconcile(ctx context.Context) ([]models.StockDiscrepancy, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT p.id, p.name, NULL::integer, '', p.quantity, COALESCE(SUM(m.quantity_delta), 0)
+		FROM products p
+		LEFT JOIN stock_movements m ON m.product_id = p.id
+		GROUP BY p.id, p.name, p.quantity
+		HAVING p.quantity <> COALESCE(SUM(m.quantity_delta), 0)
+		UNION ALL
+		SELECT p.id, p.name, v.id, v.name, v.quantity, COALESCE(SUM(m.quantity_delta), 0)
+		FROM product_variants v
+		JOIN products p ON p.id = v.product_id
+		LEFT JOIN stock_movements m ON m.variant_id = v.id
+		GROUP BY p.id, p.name, v.id, v.name, v.quantity
+		HAVING v.quantity <> COALESCE(SUM(m.quantity_delta), 0)
+		ORDER BY 1, 3 NULLS FIRST
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	discrepancies := []models.StockDiscrepancy{}
+	for rows.Next() {
+		var d models.StockDiscrepancy
+		if err := rows.Scan(&d.ProductID, &d.ProductName, &d.VariantID, &d.VariantName, &d.Quantity, &d.LedgerQuantity); err != nil {
+			return nil, err
+		}
+		discrepancies = append(discrepancies, d)
+	}
+	return discrepancies, rows.Err()
+}
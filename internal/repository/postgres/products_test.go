@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/database/dbtest"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/pkg/money"
+	"testing"
+)
+
+func TestReceiveStock(t *testing.T) {
+	products := New(dbtest.Open(t)).Products()
+	ctx := context.Background()
+
+	p := models.Product{Name: "Caneta", Price: money.FromCents(250), CostPrice: money.FromCents(100), Quantity: 10}
+	if err := products.Create(ctx, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(quantity int, costPrice money.Amount) {
+		t.Helper()
+		got, err := products.Get(ctx, p.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Quantity != quantity || got.CostPrice != costPrice {
+			t.Fatalf("quantity, cost price = %d, %s; want %d, %s", got.Quantity, got.CostPrice, quantity, costPrice)
+		}
+	}
+
+	// 10 units at 1.00 and 5 at 2.99 average 1.6633
+	if err := products.ReceiveStock(ctx, p.ID, 5, money.FromCents(299)); err != nil {
+		t.Fatal(err)
+	}
+	check(15, money.FromCents(166))
+
+	// Units owed by negative stock don't weigh in the average
+	if err := products.AdjustStock(ctx, p.ID, -20); err != nil {
+		t.Fatal(err)
+	}
+	if err := products.ReceiveStock(ctx, p.ID, 4, money.FromCents(500)); err != nil {
+		t.Fatal(err)
+	}
+	check(-1, money.FromCents(500))
+
+	if err := products.ReceiveStock(ctx, 0, 1, money.FromCents(100)); err == nil {
+		t.Fatal("receiving stock of a missing product succeeded")
+	}
+}
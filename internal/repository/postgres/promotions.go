@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"time"
+)
+
+type promotionRepository struct{ q querier }
+
+const promotionColumns = `id, name, type, COALESCE(code, ''), percentage, buy_quantity, free_quantity,
+	product_id, category_id, starts_at, ends_at,
+	COALESCE(to_char(happy_hour_start, 'HH24:MI'), ''), COALESCE(to_char(happy_hour_end, 'HH24:MI'), ''),
+	usage_limit, usage_count, active, created_at`
+
+// scanPromotion reads a row selected with promotionColumns.
+func scanPromotion(row interface{ Scan(...interface{}) error }) (models.Promotion, error) {
+	var (
+		p          models.Promotion
+		productID  sql.NullInt64
+		startsAt   sql.NullTime
+		endsAt     sql.NullTime
+		usageLimit sql.NullInt32
+	)
+	err := row.Scan(
+		&p.ID, &p.Name, &p.Type, &p.Code, &p.Percentage, &p.BuyQuantity, &p.FreeQuantity,
+		&productID, &p.CategoryID, &startsAt, &endsAt,
+		&p.HappyHourStart, &p.HappyHourEnd,
+		&usageLimit, &p.UsageCount, &p.Active, &p.CreatedAt,
+	)
+	if productID.Valid {
+		p.ProductID = &productID.Int64
+	}
+	if startsAt.Valid {
+		p.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		p.EndsAt = &endsAt.Time
+	}
+	if usageLimit.Valid {
+		limit := int(usageLimit.Int32)
+		p.UsageLimit = &limit
+	}
+	return p, err
+}
+
+func (r promotionRepository) list(ctx context.Context, query string, args ...interface{}) ([]models.Promotion, error) {
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promotions := []models.Promotion{}
+	for rows.Next() {
+		p, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, p)
+	}
+	return promotions, rows.Err()
+}
+
+func (r promotionRepository) List(ctx context.Context) ([]models.Promotion, error) {
+	return r.list(ctx, "SELECT "+promotionColumns+" FROM promotions ORDER BY id")
+}
+
+func (r promotionRepository) Get(ctx context.Context, id int64) (models.Promotion, error) {
+	p, err := scanPromotion(r.q.QueryRowContext(ctx, "SELECT "+promotionColumns+" FROM promotions WHERE id = $1", id))
+	return p, mapError(err)
+}
+
+func (r promotionRepository) Create(ctx context.Context, p *models.Promotion) error {
+	err := r.q.QueryRowContext(ctx, `
+		INSERT INTO promotions (
+			name, type, code, percentage, buy_quantity, free_quantity, product_id, category_id,
+			starts_at, ends_at, happy_hour_start, happy_hour_end, usage_limit, active
+		) VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, $8, $9, $10, NULLIF($11, '')::time, NULLIF($12, '')::time, $13, $14)
+		RETURNING id, created_at`,
+		p.Name, p.Type, p.Code, p.Percentage, p.BuyQuantity, p.FreeQuantity, p.ProductID, p.CategoryID,
+		p.StartsAt, p.EndsAt, p.HappyHourStart, p.HappyHourEnd, p.UsageLimit, p.Active,
+	).Scan(&p.ID, &p.CreatedAt)
+	return mapError(err)
+}
+
+func (r promotionRepository) Update(ctx context.Context, p models.Promotion) error {
+	return expectOne(r.q.ExecContext(ctx, `
+		UPDATE promotions SET
+			name = $1, type = $2, code = NULLIF($3, ''), percentage = $4, buy_quantity = $5, free_quantity = $6,
+			product_id = $7, category_id = $8, starts_at = $9, ends_at = $10,
+			happy_hour_start = NULLIF($11, '')::time, happy_hour_end = NULLIF($12, '')::time,
+			usage_limit = $13, active = $14
+		WHERE id = $15`,
+		p.Name, p.Type, p.Code, p.Percentage, p.BuyQuantity, p.FreeQuantity, p.ProductID, p.CategoryID,
+		p.StartsAt, p.EndsAt, p.HappyHourStart, p.HappyHourEnd, p.UsageLimit, p.Active, p.ID,
+	))
+}
+
+func (r promotionRepository) Delete(ctx context.Context, id int64) error {
+	return expectOne(r.q.ExecContext(ctx, "DELETE FROM promotions WHERE id = $1", id))
+}
+
+func (r promotionRepository) ListValid(ctx context.Context, at time.Time) ([]models.Promotion, error) {
+	return r.list(ctx, `
+		SELECT `+promotionColumns+` FROM promotions
+		WHERE active
+			AND (starts_at IS NULL OR starts_at <= $1)
+			AND (ends_at IS NULL OR ends_at > $1)
+			AND (usage_limit IS NULL OR usage_count < usage_limit)
+		ORDER BY id`,
+		at,
+	)
+}
+
+func (r promotionRepository) Use(ctx context.Context, id int64) error {
+	err := expectOne(r.q.ExecContext(ctx,
+		"UPDATE promotions SET usage_count = usage_count + 1 WHERE id = $1 AND (usage_limit IS NULL OR usage_count < usage_limit)",
+		id,
+	))
+	if errors.Is(err, repository.ErrNotFound) {
+		return repository.ErrConflict
+	}
+	return err
+}
+
+func (r promotionRepository) Report(ctx context.Context, from, until *time.Time) ([]models.PromotionReport, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT
+			p.id, p.name, COALESCE(p.code, ''),
+			COUNT(DISTINCT s.id),
+			COALESCE(SUM(si.quantity - si.returned_quantity), 0),
+			COALESCE(SUM(si.unit_price * si.quantity - si.discount - si.refunded_amount), 0),
+			COALESCE(SUM(si.promotion_discount), 0)
+		FROM promotions p
+		LEFT JOIN (sales_items si JOIN sales s ON s.id = si.sale_id
+			AND s.status <> 'cancelled'
+			AND ($1::timestamptz IS NULL OR s.date >= $1)
+			AND ($2::timestamptz IS NULL OR s.date < $2)
+		) ON si.promotion_id = p.id
+		GROUP BY p.id, p.name, p.code
+		ORDER BY p.id`,
+		from, until,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := []models.PromotionReport{}
+	for rows.Next() {
+		var line models.PromotionReport
+		if err := rows.Scan(&line.PromotionID, &line.Name, &line.Code, &line.SalesCount, &line.UnitsSold, &line.Revenue, &line.DiscountGiven); err != nil {
+			return nil, err
+		}
+		report = append(report, line)
+	}
+	return report, rows.Err()
+}
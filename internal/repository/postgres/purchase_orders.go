@@ -0,0 +1,230 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/money"
+)
+
+type purchaseOrderRepository struct{ q querier }
+
+const purchaseOrderSelect = `
+	SELECT
+		po.id, po.supplier_id, s.name, po.created_by, po.status, po.expected_at, po.notes, po.created_at,
+		poi.product_id, p.name, poi.variant_id, COALESCE(v.name, ''), poi.quantity, poi.received_quantity, poi.unit_cost
+`
+
+func (r purchaseOrderRepository) List(ctx context.Context, filter repository.PurchaseOrderFilter) ([]models.PurchaseOrder, int, error) {
+	var filters filterBuilder
+	if filter.SupplierID != nil {
+		filters.add("po.supplier_id = ?", *filter.SupplierID)
+	}
+	if filter.Status != "" {
+		filters.add("po.status = ?", filter.Status)
+	}
+
+	var total int
+	if err := r.q.QueryRowContext(ctx, "SELECT COUNT(*) FROM purchase_orders po"+filters.where(), filters.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// Page over orders first, then join their items, so LIMIT counts orders rather than item rows
+	pageSQL, args := filters.page(filter.Limit, filter.Offset)
+	rows, err := r.q.QueryContext(ctx, `
+		WITH page AS (
+			SELECT po.id FROM purchase_orders po`+filters.where()+`
+			ORDER BY po.created_at DESC, po.id DESC`+pageSQL+`
+		)`+purchaseOrderSelect+`
+		FROM page pg
+		JOIN purchase_orders po ON po.id = pg.id
+		JOIN suppliers s ON s.id = po.supplier_id
+		LEFT JOIN purchase_order_items poi ON poi.purchase_order_id = po.id
+		LEFT JOIN products p ON p.id = poi.product_id
+		LEFT JOIN product_variants v ON v.id = poi.variant_id
+		ORDER BY po.created_at DESC, po.id DESC, poi.id
+	`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	orders, err := scanPurchaseOrders(rows)
+	return orders, total, err
+}
+
+// scanPurchaseOrders groups order rows LEFT JOINed with their items, ordered by order.
+func scanPurchaseOrders(rows *sql.Rows) ([]models.PurchaseOrder, error) {
+	orders := []models.PurchaseOrder{}
+	for rows.Next() {
+		var (
+			order       models.PurchaseOrder
+			expectedAt  sql.NullTime
+			productID   sql.NullInt64
+			productName sql.NullString
+			variantID   *int64
+			variantName string
+			quantity    sql.NullInt32
+			received    sql.NullInt32
+			unitCost    money.Amount
+		)
+		if err := rows.Scan(
+			&order.ID, &order.SupplierID, &order.SupplierName, &order.CreatedBy, &order.Status, &expectedAt, &order.Notes, &order.CreatedAt,
+			&productID, &productName, &variantID, &variantName, &quantity, &received, &unitCost,
+		); err != nil {
+			return nil, err
+		}
+		if expectedAt.Valid {
+			order.ExpectedAt = &expectedAt.Time
+		}
+
+		// Rows come ordered by order, so a new ID starts a new order
+		if len(orders) == 0 || orders[len(orders)-1].ID != order.ID {
+			order.Items = []models.PurchaseOrderItem{}
+			orders = append(orders, order)
+		}
+		if productID.Valid {
+			current := &orders[len(orders)-1]
+			item := models.PurchaseOrderItem{
+				ProductID:        productID.Int64,
+				ProductName:      productName.String,
+				VariantID:        variantID,
+				VariantName:      variantName,
+				Quantity:         int(quantity.Int32),
+				ReceivedQuantity: int(received.Int32),
+				UnitCost:         unitCost,
+			}
+			current.Items = append(current.Items, item)
+			current.TotalCost += item.UnitCost.Mul(item.Quantity)
+		}
+	}
+	return orders, rows.Err()
+}
+
+func (r purchaseOrderRepository) Get(ctx context.Context, id int64) (models.PurchaseOrder, error) {
+	return r.get(ctx, id)
+}
+
+func (r purchaseOrderRepository) GetForUpdate(ctx context.Context, id int64) (models.PurchaseOrder, error) {
+	// Lock the order row first; FOR UPDATE cannot apply to the outer joins below
+	var locked int64
+	err := r.q.QueryRowContext(ctx, "SELECT id FROM purchase_orders WHERE id = $1 FOR UPDATE", id).Scan(&locked)
+	if err != nil {
+		return models.PurchaseOrder{}, mapError(err)
+	}
+	return r.get(ctx, id)
+}
+
+func (r purchaseOrderRepository) get(ctx context.Context, id int64) (models.PurchaseOrder, error) {
+	rows, err := r.q.QueryContext(ctx, purchaseOrderSelect+`
+		FROM purchase_orders po
+		JOIN suppliers s ON s.id = po.supplier_id
+		LEFT JOIN purchase_order_items poi ON poi.purchase_order_id = po.id
+		LEFT JOIN products p ON p.id = poi.product_id
+		LEFT JOIN product_variants v ON v.id = poi.variant_id
+		WHERE po.id = $1
+		ORDER BY poi.id
+	`, id)
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	defer rows.Close()
+
+	orders, err := scanPurchaseOrders(rows)
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	if len(orders) == 0 {
+		return models.PurchaseOrder{}, repository.ErrNotFound
+	}
+	return orders[0], nil
+}
+
+func (r purchaseOrderRepository) Create(ctx context.Context, order *models.PurchaseOrder) error {
+	err := r.q.QueryRowContext(ctx,
+		"INSERT INTO purchase_orders (supplier_id, created_by, expected_at, notes) VALUES ($1, $2, $3, $4) RETURNING id, status, created_at",
+		order.SupplierID, order.CreatedBy, order.ExpectedAt, order.Notes,
+	).Scan(&order.ID, &order.Status, &order.CreatedAt)
+	if err != nil {
+		return mapError(err)
+	}
+
+	for _, item := range order.Items {
+		_, err := r.q.ExecContext(ctx,
+			"INSERT INTO purchase_order_items (purchase_order_id, product_id, variant_id, quantity, unit_cost) VALUES ($1, $2, $3, $4, $5)",
+			order.ID, item.ProductID, item.VariantID, item.Quantity, item.UnitCost,
+		)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+func (r purchaseOrderRepository) ReceiveItem(ctx context.Context, id, productID int64, variantID *int64, quantity int) error {
+	err := expectOne(r.q.ExecContext(ctx,
+		"UPDATE purchase_order_items SET received_quantity = received_quantity + $1 WHERE purchase_order_id = $2 AND product_id = $3 AND variant_id IS NOT DISTINCT FROM $4 AND received_quantity + $1 <= quantity",
+		quantity, id, productID, variantID,
+	))
+	if !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+
+	// Tell a missing line from one that would be over-received
+	var exists bool
+	if err := r.q.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM purchase_order_items WHERE purchase_order_id = $1 AND product_id = $2 AND variant_id IS NOT DISTINCT FROM $3)",
+		id, productID, variantID,
+	).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return repository.ErrConflict
+	}
+	return repository.ErrNotFound
+}
+
+func (r purchaseOrderRepository) SetStatus(ctx context.Context, id int64, status string) error {
+	return expectOne(r.q.ExecContext(ctx, "UPDATE purchase_orders SET status = $1 WHERE id = $2", status, id))
+}
+
+func (r purchaseOrderRepository) Incoming(ctx context.Context, productID *int64) ([]models.IncomingStock, error) {
+	where := " WHERE po.status IN ('open', 'partially_received') AND poi.received_quantity < poi.quantity"
+	var args []interface{}
+	if productID != nil {
+		where += " AND poi.product_id = $1"
+		args = append(args, *productID)
+	}
+
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT po.id, po.supplier_id, s.name, poi.product_id, p.name, poi.variant_id, COALESCE(v.name, ''), poi.quantity - poi.received_quantity, poi.unit_cost, po.expected_at
+		FROM purchase_order_items poi
+		JOIN purchase_orders po ON po.id = poi.purchase_order_id
+		JOIN suppliers s ON s.id = po.supplier_id
+		JOIN products p ON p.id = poi.product_id
+		LEFT JOIN product_variants v ON v.id = poi.variant_id`+where+`
+		ORDER BY po.expected_at NULLS LAST, po.id, poi.product_id, poi.variant_id NULLS FIRST
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	incoming := []models.IncomingStock{}
+	for rows.Next() {
+		var (
+			line       models.IncomingStock
+			expectedAt sql.NullTime
+		)
+		if err := rows.Scan(&line.PurchaseOrderID, &line.SupplierID, &line.SupplierName, &line.ProductID, &line.ProductName, &line.VariantID, &line.VariantName, &line.Outstanding, &line.UnitCost, &expectedAt); err != nil {
+			return nil, err
+		}
+		if expectedAt.Valid {
+			line.ExpectedAt = &expectedAt.Time
+		}
+		incoming = append(incoming, line)
+	}
+	return incoming, rows.Err()
+}
@@ -0,0 +1,174 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/money"
+)
+
+type quoteRepository struct{ q querier }
+
+// quoteStatus reports open quotes past their validity as expired.
+const quoteStatus = "CASE WHEN q.status = 'open' AND q.valid_until <= NOW() THEN 'expired' ELSE q.status END"
+
+func (r quoteRepository) List(ctx context.Context, filter repository.QuoteFilter) ([]models.Quote, int, error) {
+	var filters filterBuilder
+	if filter.UserID != nil {
+		filters.add("q.user_id = ?", *filter.UserID)
+	}
+	if filter.CustomerID != nil {
+		filters.add("q.customer_id = ?", *filter.CustomerID)
+	}
+
+	var total int
+	if err := r.q.QueryRowContext(ctx, "SELECT COUNT(*) FROM quotes q"+filters.where(), filters.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// Page over quotes first, then join their items, so LIMIT counts quotes rather than item rows
+	pageSQL, args := filters.page(filter.Limit, filter.Offset)
+	rows, err := r.q.QueryContext(ctx, `
+		WITH page AS (
+			SELECT q.id FROM quotes q`+filters.where()+`
+			ORDER BY q.created_at DESC, q.id DESC`+pageSQL+`
+		)
+		SELECT
+			q.id, q.user_id, u.name, q.created_by, q.customer_id, c.name, q.notes, `+quoteStatus+`,
+			q.valid_until, q.sale_id, q.created_at,
+			qi.product_id, qi.product_name, qi.variant_id, qi.variant_name, qi.quantity, qi.unit_price, qi.discount
+		FROM page p
+		JOIN quotes q ON q.id = p.id
+		JOIN users u ON u.id = q.user_id
+		LEFT JOIN customers c ON c.id = q.customer_id
+		LEFT JOIN quote_items qi ON qi.quote_id = q.id
+		ORDER BY q.created_at DESC, q.id DESC, qi.id
+	`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	quotes, err := scanQuotes(rows)
+	return quotes, total, err
+}
+
+// scanQuotes groups quote rows LEFT JOINed with their items, ordered by quote.
+func scanQuotes(rows *sql.Rows) ([]models.Quote, error) {
+	quotes := []models.Quote{}
+	for rows.Next() {
+		var (
+			quote        models.Quote
+			customerID   sql.NullInt64
+			customerName sql.NullString
+			saleID       sql.NullInt64
+			productID    sql.NullInt64
+			productName  sql.NullString
+			variantID    *int64
+			variantName  sql.NullString
+			quantity     sql.NullInt32
+			unitPrice    money.Amount
+			discount     money.Amount
+		)
+		if err := rows.Scan(
+			&quote.ID, &quote.UserID, &quote.SellerName, &quote.CreatedBy, &customerID, &customerName, &quote.Notes, &quote.Status,
+			&quote.ValidUntil, &saleID, &quote.CreatedAt,
+			&productID, &productName, &variantID, &variantName, &quantity, &unitPrice, &discount,
+		); err != nil {
+			return nil, err
+		}
+		if customerID.Valid {
+			quote.CustomerID = &customerID.Int64
+			quote.CustomerName = customerName.String
+		}
+		if saleID.Valid {
+			quote.SaleID = &saleID.Int64
+		}
+
+		// Rows come ordered by quote, so a new ID starts a new quote
+		if len(quotes) == 0 || quotes[len(quotes)-1].ID != quote.ID {
+			quote.Items = []models.SaleItem{}
+			quotes = append(quotes, quote)
+		}
+		if productID.Valid {
+			current := &quotes[len(quotes)-1]
+			item := models.SaleItem{
+				ProductID:   productID.Int64,
+				ProductName: productName.String,
+				VariantID:   variantID,
+				VariantName: variantName.String,
+				Quantity:    int(quantity.Int32),
+				UnitPrice:   unitPrice,
+				Discount:    discount,
+				Total:       unitPrice.Mul(int(quantity.Int32)) - discount,
+			}
+			current.Items = append(current.Items, item)
+			current.Subtotal += item.UnitPrice.Mul(item.Quantity)
+			current.DiscountTotal += item.Discount
+			current.TotalPrice += item.Total
+		}
+	}
+	return quotes, rows.Err()
+}
+
+func (r quoteRepository) Get(ctx context.Context, id int64) (models.Quote, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT
+			q.id, q.user_id, u.name, q.created_by, q.customer_id, c.name, q.notes, `+quoteStatus+`,
+			q.valid_until, q.sale_id, q.created_at,
+			qi.product_id, qi.product_name, qi.variant_id, qi.variant_name, qi.quantity, qi.unit_price, qi.discount
+		FROM quotes q
+		JOIN users u ON u.id = q.user_id
+		LEFT JOIN customers c ON c.id = q.customer_id
+		LEFT JOIN quote_items qi ON qi.quote_id = q.id
+		WHERE q.id = $1
+		ORDER BY qi.id
+	`, id)
+	if err != nil {
+		return models.Quote{}, err
+	}
+	defer rows.Close()
+
+	quotes, err := scanQuotes(rows)
+	if err != nil {
+		return models.Quote{}, err
+	}
+	if len(quotes) == 0 {
+		return models.Quote{}, repository.ErrNotFound
+	}
+	return quotes[0], nil
+}
+
+func (r quoteRepository) Create(ctx context.Context, quote *models.Quote) error {
+	err := r.q.QueryRowContext(ctx,
+		"INSERT INTO quotes (user_id, created_by, customer_id, notes, valid_until) VALUES ($1, $2, $3, $4, $5) RETURNING id, status, created_at",
+		quote.UserID, quote.CreatedBy, quote.CustomerID, quote.Notes, quote.ValidUntil,
+	).Scan(&quote.ID, &quote.Status, &quote.CreatedAt)
+	if err != nil {
+		return mapError(err)
+	}
+
+	for _, item := range quote.Items {
+		_, err := r.q.ExecContext(ctx,
+			"INSERT INTO quote_items (quote_id, product_id, product_name, variant_id, variant_name, quantity, unit_price, discount) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+			quote.ID, item.ProductID, item.ProductName, item.VariantID, item.VariantName, item.Quantity, item.UnitPrice, item.Discount,
+		)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+func (r quoteRepository) MarkConverted(ctx context.Context, id, saleID int64) error {
+	err := expectOne(r.q.ExecContext(ctx,
+		"UPDATE quotes SET status = 'converted', sale_id = $1 WHERE id = $2 AND status = 'open'",
+		saleID, id,
+	))
+	if errors.Is(err, repository.ErrNotFound) {
+		return repository.ErrConflict
+	}
+	return err
+}
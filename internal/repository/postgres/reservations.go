@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+)
+
+type reservationRepository struct{ q querier }
+
+// reservationStatus reports active reservations past their expiry as expired.
+const reservationStatus = "CASE WHEN sr.status = 'active' AND sr.expires_at <= NOW() THEN 'expired' ELSE sr.status END"
+
+const reservationTables = "stock_reservations sr JOIN products p ON p.id = sr.product_id LEFT JOIN product_variants v ON v.id = sr.variant_id"
+
+const reservationColumns = "sr.id, sr.product_id, p.name, sr.variant_id, COALESCE(v.name, ''), sr.quantity, sr.quote_id, sr.user_id, sr.notes, " + reservationStatus + ", sr.expires_at, sr.created_at"
+
+func scanReservation(row interface{ Scan(...interface{}) error }) (models.StockReservation, error) {
+	var (
+		res     models.StockReservation
+		quoteID sql.NullInt64
+	)
+	err := row.Scan(&res.ID, &res.ProductID, &res.ProductName, &res.VariantID, &res.VariantName, &res.Quantity, &quoteID, &res.UserID, &res.Notes, &res.Status, &res.ExpiresAt, &res.CreatedAt)
+	if quoteID.Valid {
+		res.QuoteID = &quoteID.Int64
+	}
+	return res, err
+}
+
+func (r reservationRepository) List(ctx context.Context, filter repository.ReservationFilter) ([]models.StockReservation, int, error) {
+	var filters filterBuilder
+	if filter.ProductID != nil {
+		filters.add("sr.product_id = ?", *filter.ProductID)
+	}
+	if filter.QuoteID != nil {
+		filters.add("sr.quote_id = ?", *filter.QuoteID)
+	}
+	if filter.Status != "" {
+		filters.add(reservationStatus+" = ?", filter.Status)
+	}
+
+	var total int
+	if err := r.q.QueryRowContext(ctx, "SELECT COUNT(*) FROM stock_reservations sr"+filters.where(), filters.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	pageSQL, args := filters.page(filter.Limit, filter.Offset)
+	rows, err := r.q.QueryContext(ctx,
+		"SELECT "+reservationColumns+" FROM "+reservationTables+filters.where()+
+			" ORDER BY sr.created_at DESC, sr.id DESC"+pageSQL,
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	reservations := []models.StockReservation{}
+	for rows.Next() {
+		res, err := scanReservation(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		reservations = append(reservations, res)
+	}
+	return reservations, total, rows.Err()
+}
+
+func (r reservationRepository) Get(ctx context.Context, id int64) (models.StockReservation, error) {
+	res, err := scanReservation(r.q.QueryRowContext(ctx,
+		"SELECT "+reservationColumns+" FROM "+reservationTables+" WHERE sr.id = $1",
+		id,
+	))
+	return res, mapError(err)
+}
+
+func (r reservationRepository) Create(ctx context.Context, res *models.StockReservation) error {
+	// Lock the product so concurrent sales and reservations see this one
+	var available int
+	err := r.q.QueryRowContext(ctx,
+		"SELECT quantity - "+reservedQuantity+" FROM products WHERE id = $1 FOR UPDATE",
+		res.ProductID,
+	).Scan(&available)
+	if err == sql.ErrNoRows || (err == nil && available < res.Quantity) {
+		return repository.ErrInsufficientStock
+	}
+	if err != nil {
+		return err
+	}
+	if res.VariantID != nil {
+		err := r.q.QueryRowContext(ctx,
+			"SELECT quantity - "+variantReserved+" FROM product_variants WHERE id = $1 AND product_id = $2",
+			*res.VariantID, res.ProductID,
+		).Scan(&available)
+		if err == sql.ErrNoRows || (err == nil && available < res.Quantity) {
+			return repository.ErrInsufficientStock
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	err = r.q.QueryRowContext(ctx,
+		"INSERT INTO stock_reservations (product_id, variant_id, quantity, quote_id, user_id, notes, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, status, created_at",
+		res.ProductID, res.VariantID, res.Quantity, res.QuoteID, res.UserID, res.Notes, res.ExpiresAt,
+	).Scan(&res.ID, &res.Status, &res.CreatedAt)
+	return mapError(err)
+}
+
+func (r reservationRepository) Close(ctx context.Context, id int64, status string) error {
+	err := expectOne(r.q.ExecContext(ctx,
+		"UPDATE stock_reservations SET status = $1 WHERE id = $2 AND status = 'active' AND expires_at > NOW()",
+		status, id,
+	))
+	if errors.Is(err, repository.ErrNotFound) {
+		return repository.ErrConflict
+	}
+	return err
+}
+
+func (r reservationRepository) Consume(ctx context.Context, id int64, quantity int) error {
+	err := expectOne(r.q.ExecContext(ctx, `
+		UPDATE stock_reservations
+		SET status = CASE WHEN quantity = $2 THEN 'consumed' ELSE status END,
+		    quantity = CASE WHEN quantity = $2 THEN quantity ELSE quantity - $2 END
+		WHERE id = $1 AND status = 'active' AND expires_at > NOW() AND quantity >= $2
+	`, id, quantity))
+	if errors.Is(err, repository.ErrNotFound) {
+		return repository.ErrConflict
+	}
+	return err
+}
+
+func (r reservationRepository) ExpireDue(ctx context.Context) (int64, error) {
+	result, err := r.q.ExecContext(ctx, "UPDATE stock_reservations SET status = 'expired' WHERE status = 'active' AND expires_at <= NOW()")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
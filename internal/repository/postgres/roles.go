@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/money"
+)
+
+type roleRepository struct{ q querier }
+
+func (r roleRepository) List(ctx context.Context) ([]models.Role, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT ro.name, ro.description, ro.max_discount, rp.permission
+		FROM roles ro
+		LEFT JOIN role_permissions rp ON rp.role = ro.name
+		ORDER BY ro.name, rp.permission
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []models.Role{}
+	for rows.Next() {
+		var (
+			role       models.Role
+			permission sql.NullString
+		)
+		if err := rows.Scan(&role.Name, &role.Description, &role.MaxDiscount, &permission); err != nil {
+			return nil, err
+		}
+
+		// Rows come ordered by role, so a new name starts a new role
+		if len(roles) == 0 || roles[len(roles)-1].Name != role.Name {
+			role.Permissions = []string{}
+			roles = append(roles, role)
+		}
+		if permission.Valid {
+			current := &roles[len(roles)-1]
+			current.Permissions = append(current.Permissions, permission.String)
+		}
+	}
+	return roles, rows.Err()
+}
+
+func (r roleRepository) Get(ctx context.Context, name string) (models.Role, error) {
+	role := models.Role{Name: name, Permissions: []string{}}
+	err := r.q.QueryRowContext(ctx, "SELECT description, max_discount FROM roles WHERE name = $1 FOR UPDATE", name).Scan(&role.Description, &role.MaxDiscount)
+	if err != nil {
+		return role, mapError(err)
+	}
+
+	rows, err := r.q.QueryContext(ctx, "SELECT permission FROM role_permissions WHERE role = $1 ORDER BY permission", name)
+	if err != nil {
+		return role, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return role, err
+		}
+		role.Permissions = append(role.Permissions, permission)
+	}
+	return role, rows.Err()
+}
+
+func (r roleRepository) Create(ctx context.Context, role models.Role) error {
+	_, err := r.q.ExecContext(ctx,
+		"INSERT INTO roles (name, description, max_discount) VALUES ($1, $2, $3)",
+		role.Name, role.Description, role.MaxDiscount,
+	)
+	if err != nil {
+		return mapError(err)
+	}
+	return r.grant(ctx, role.Name, role.Permissions)
+}
+
+func (r roleRepository) SetPermissions(ctx context.Context, name string, permissions []string) error {
+	if _, err := r.q.ExecContext(ctx, "DELETE FROM role_permissions WHERE role = $1", name); err != nil {
+		return err
+	}
+	return r.grant(ctx, name, permissions)
+}
+
+func (r roleRepository) SetMaxDiscount(ctx context.Context, name string, maxDiscount *money.Amount) error {
+	return expectOne(r.q.ExecContext(ctx, "UPDATE roles SET max_discount = $1 WHERE name = $2", maxDiscount, name))
+}
+
+func (r roleRepository) grant(ctx context.Context, role string, permissions []string) error {
+	for _, p := range permissions {
+		if _, err := r.q.ExecContext(ctx, "INSERT INTO role_permissions (role, permission) VALUES ($1, $2)", role, p); err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+func (r roleRepository) Delete(ctx context.Context, name string) error {
+	var inUse bool
+	if err := r.q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE role = $1)", name).Scan(&inUse); err != nil {
+		return err
+	}
+	if inUse {
+		return repository.ErrInUse
+	}
+	return expectOne(r.q.ExecContext(ctx, "DELETE FROM roles WHERE name = $1", name))
+}
+
+func (r roleRepository) HasPermission(ctx context.Context, role, permission string) (bool, error) {
+	var granted bool
+	err := r.q.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM role_permissions WHERE role = $1 AND permission = $2)",
+		role, permission,
+	).Scan(&granted)
+	return granted, err
+}
+
+func (r roleRepository) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	rows, err := r.q.QueryContext(ctx, "SELECT code, description FROM permissions ORDER BY code")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []models.Permission{}
+	for rows.Next() {
+		var p models.Permission
+		if err := rows.Scan(&p.Code, &p.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, p)
+	}
+	return permissions, rows.Err()
+}
+
+func (r roleRepository) PermissionExists(ctx context.Context, code string) (bool, error) {
+	var exists bool
+	err := r.q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM permissions WHERE code = $1)", code).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,540 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/money"
+	"time"
+)
+
+type saleRepository struct{ q querier }
+
+func (r saleRepository) List(ctx context.Context, filter repository.SaleFilter) ([]models.Sale, int, error) {
+	var filters filterBuilder
+	if filter.UserID != nil {
+		filters.add("s.user_id = ?", *filter.UserID)
+	}
+	if filter.CustomerID != nil {
+		filters.add("s.customer_id = ?", *filter.CustomerID)
+	}
+	if filter.ProductID != nil {
+		filters.add("EXISTS (SELECT 1 FROM sales_items fi WHERE fi.sale_id = s.id AND fi.product_id = ?)", *filter.ProductID)
+	}
+	if filter.From != nil {
+		filters.add("s.date >= ?", *filter.From)
+	}
+	if filter.Until != nil {
+		filters.add("s.date < ?", *filter.Until)
+	}
+
+	var total int
+	if err := r.q.QueryRowContext(ctx, "SELECT COUNT(*) FROM sales s"+filters.where(), filters.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	// Page over sales first, then join their items, so LIMIT counts sales rather than item rows
+	pageSQL, args := filters.page(filter.Limit, filter.Offset)
+	rows, err := r.q.QueryContext(ctx, `
+		WITH page AS (
+			SELECT s.id, s.user_id, s.created_by, s.customer_id, s.date, s.status, s.discount, s.discount_approved_by
+			FROM sales s`+filters.where()+`
+			ORDER BY s.date DESC, s.id DESC`+pageSQL+`
+		)
+		SELECT
+			p.id, p.user_id, u.name, p.created_by, p.customer_id, c.name, p.date, p.status, p.discount, p.discount_approved_by,
+			si.product_id, si.quantity, si.returned_quantity,
+			si.product_name, si.variant_id, si.variant_name, si.category_id, si.unit_price, si.unit_cost, si.discount, si.promotion_id, si.promotion_discount, si.refunded_amount,
+			`+itemComponents+`, `+itemLots+`
+		FROM page p
+		JOIN users u ON u.id = p.user_id
+		LEFT JOIN customers c ON c.id = p.customer_id
+		LEFT JOIN sales_items si ON p.id = si.sale_id
+		ORDER BY p.date DESC, p.id DESC, si.id
+	`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	sales, err := scanSales(rows)
+	return sales, total, err
+}
+
+// itemComponents is the JSON array of the components of sale line si.
+const itemComponents = `COALESCE((
+	SELECT json_agg(json_build_object(
+		'productId', sic.product_id, 'productName', sic.product_name, 'quantity', sic.quantity,
+		'unitCost', sic.unit_cost, 'revenue', sic.revenue
+	) ORDER BY sic.id)
+	FROM sales_item_components sic WHERE sic.sale_item_id = si.id
+), '[]')`
+
+// itemLots is the JSON array of the lots sale line si took stock from.
+const itemLots = `COALESCE((
+	SELECT json_agg(json_build_object(
+		'lotId', sil.lot_id, 'productId', sil.product_id, 'code', sil.code,
+		'expiresOn', to_char(sil.expires_on, 'YYYY-MM-DD"T00:00:00Z"'), 'quantity', sil.quantity
+	) ORDER BY sil.id)
+	FROM sales_item_lots sil WHERE sil.sale_item_id = si.id
+), '[]')`
+
+// scanSales groups sale rows LEFT JOINed with their items, ordered by sale.
+func scanSales(rows *sql.Rows) ([]models.Sale, error) {
+	sales := []models.Sale{}
+	for rows.Next() {
+		var (
+			sale         models.Sale
+			customerID   sql.NullInt64
+			customerName sql.NullString
+			productID    sql.NullInt64 // Use sql.Null types for LEFT JOIN
+			quantity     sql.NullInt32
+			returnedQty  sql.NullInt32
+			productName  sql.NullString
+			variantID    *int64
+			variantName  sql.NullString
+			categoryID   *int64
+			unitPrice    money.Amount
+			unitCost     money.Amount
+			discount     money.Amount
+			promotionID  sql.NullInt64
+			promoted     money.Amount
+			refunded     money.Amount
+			components   []byte
+			lots         []byte
+		)
+		if err := rows.Scan(
+			&sale.ID, &sale.UserID, &sale.SellerName, &sale.CreatedBy, &customerID, &customerName,
+			&sale.Date, &sale.Status, &sale.SaleDiscount, &sale.DiscountApprovedBy,
+			&productID, &quantity, &returnedQty, &productName, &variantID, &variantName, &categoryID, &unitPrice, &unitCost, &discount, &promotionID, &promoted, &refunded, &components, &lots,
+		); err != nil {
+			return nil, err
+		}
+		if customerID.Valid {
+			sale.CustomerID = &customerID.Int64
+			sale.CustomerName = customerName.String
+		}
+
+		// Rows come ordered by sale, so a new ID starts a new sale
+		if len(sales) == 0 || sales[len(sales)-1].ID != sale.ID {
+			sale.Items = []models.SaleItem{}
+			sales = append(sales, sale)
+		}
+		if productID.Valid {
+			current := &sales[len(sales)-1]
+			item := models.SaleItem{
+				ProductID:         productID.Int64,
+				ProductName:       productName.String,
+				VariantID:         variantID,
+				VariantName:       variantName.String,
+				CategoryID:        categoryID,
+				Quantity:          int(quantity.Int32),
+				ReturnedQuantity:  int(returnedQty.Int32),
+				UnitPrice:         unitPrice,
+				UnitCost:          unitCost,
+				Discount:          discount,
+				PromotionDiscount: promoted,
+				Total:             unitPrice.Mul(int(quantity.Int32)) - discount,
+				RefundedAmount:    refunded,
+			}
+			if promotionID.Valid {
+				item.PromotionID = &promotionID.Int64
+			}
+			if err := json.Unmarshal(components, &item.Components); err != nil {
+				return nil, err
+			}
+			if len(item.Components) == 0 {
+				item.Components = nil
+			}
+			if err := json.Unmarshal(lots, &item.Lots); err != nil {
+				return nil, err
+			}
+			if len(item.Lots) == 0 {
+				item.Lots = nil
+			}
+			current.Items = append(current.Items, item)
+			current.Subtotal += item.UnitPrice.Mul(item.Quantity)
+			current.DiscountTotal += item.Discount
+			current.TotalPrice += item.Total
+			current.RefundedTotal += item.RefundedAmount
+			current.CostTotal += item.UnitCost.Mul(item.Quantity - item.ReturnedQuantity)
+			current.GrossMargin = current.TotalPrice - current.RefundedTotal - current.CostTotal
+		}
+	}
+	return sales, rows.Err()
+}
+
+func (r saleRepository) Create(ctx context.Context, sale *models.Sale) error {
+	err := r.q.QueryRowContext(ctx,
+		"INSERT INTO sales (user_id, created_by, customer_id, discount, discount_approved_by, date) VALUES ($1, $2, $3, $4, $5, NOW()) RETURNING id, date, status",
+		sale.UserID, sale.CreatedBy, sale.CustomerID, sale.SaleDiscount, sale.DiscountApprovedBy,
+	).Scan(&sale.ID, &sale.Date, &sale.Status)
+	return mapError(err)
+}
+
+func (r saleRepository) AddItem(ctx context.Context, saleID int64, item models.SaleItem) error {
+	var itemID int64
+	err := r.q.QueryRowContext(ctx,
+		"INSERT INTO sales_items (sale_id, product_id, product_name, variant_id, variant_name, category_id, quantity, unit_price, unit_cost, discount, promotion_id, promotion_discount) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id",
+		saleID, item.ProductID, item.ProductName, item.VariantID, item.VariantName, item.CategoryID, item.Quantity, item.UnitPrice, item.UnitCost, item.Discount, item.PromotionID, item.PromotionDiscount,
+	).Scan(&itemID)
+	if err != nil {
+		return mapError(err)
+	}
+
+	for _, c := range item.Components {
+		_, err := r.q.ExecContext(ctx,
+			"INSERT INTO sales_item_components (sale_item_id, product_id, product_name, quantity, unit_cost, revenue) VALUES ($1, $2, $3, $4, $5, $6)",
+			itemID, c.ProductID, c.ProductName, c.Quantity, c.UnitCost, c.Revenue,
+		)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	for _, l := range item.Lots {
+		_, err := r.q.ExecContext(ctx,
+			"INSERT INTO sales_item_lots (sale_item_id, lot_id, product_id, code, expires_on, quantity) VALUES ($1, $2, $3, $4, $5, $6)",
+			itemID, l.LotID, l.ProductID, l.Code, l.ExpiresOn, l.Quantity,
+		)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+func (r saleRepository) AddPayment(ctx context.Context, saleID int64, payment models.Payment) error {
+	_, err := r.q.ExecContext(ctx,
+		"INSERT INTO sale_payments (sale_id, method, amount, installments, tendered, change) VALUES ($1, $2, $3, $4, $5, $6)",
+		saleID, payment.Method, payment.Amount, payment.Installments, payment.Tendered, payment.Change,
+	)
+	return mapError(err)
+}
+
+func (r saleRepository) ListPayments(ctx context.Context, saleID int64) ([]models.Payment, error) {
+	rows, err := r.q.QueryContext(ctx,
+		"SELECT method, amount, installments, tendered, change FROM sale_payments WHERE sale_id = $1 ORDER BY id",
+		saleID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := []models.Payment{}
+	for rows.Next() {
+		var p models.Payment
+		if err := rows.Scan(&p.Method, &p.Amount, &p.Installments, &p.Tendered, &p.Change); err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+func (r saleRepository) Get(ctx context.Context, id int64) (models.Sale, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT
+			s.id, s.user_id, u.name, s.created_by, s.customer_id, c.name, s.date, s.status, s.discount, s.discount_approved_by,
+			si.product_id, si.quantity, si.returned_quantity,
+			si.product_name, si.variant_id, si.variant_name, si.category_id, si.unit_price, si.unit_cost, si.discount, si.promotion_id, si.promotion_discount, si.refunded_amount,
+			`+itemComponents+`, `+itemLots+`
+		FROM sales s
+		JOIN users u ON u.id = s.user_id
+		LEFT JOIN customers c ON c.id = s.customer_id
+		LEFT JOIN sales_items si ON s.id = si.sale_id
+		WHERE s.id = $1
+		ORDER BY si.id
+	`, id)
+	if err != nil {
+		return models.Sale{}, err
+	}
+	defer rows.Close()
+
+	sales, err := scanSales(rows)
+	if err != nil {
+		return models.Sale{}, err
+	}
+	if len(sales) == 0 {
+		return models.Sale{}, repository.ErrNotFound
+	}
+	return sales[0], nil
+}
+
+func (r saleRepository) GetForUpdate(ctx context.Context, id int64) (models.Sale, error) {
+	// Lock only the header; every change to a sale goes through it
+	var locked int64
+	if err := r.q.QueryRowContext(ctx, "SELECT id FROM sales WHERE id = $1 FOR UPDATE", id).Scan(&locked); err != nil {
+		return models.Sale{}, mapError(err)
+	}
+	return r.Get(ctx, id)
+}
+
+func (r saleRepository) SetStatus(ctx context.Context, id int64, status string) error {
+	return expectOne(r.q.ExecContext(ctx, "UPDATE sales SET status = $1 WHERE id = $2", status, id))
+}
+
+func (r saleRepository) ReturnItem(ctx context.Context, saleID, productID int64, variantID *int64, quantity int) (models.SaleItem, error) {
+	item := models.SaleItem{ProductID: productID, VariantID: variantID, Quantity: quantity}
+	// refunded_amount is always the discounted line value prorated over the
+	// units returned so far, so the last return takes whatever rounding left
+	err := r.q.QueryRowContext(ctx, `
+		UPDATE sales_items SET
+			returned_quantity = returned_quantity + $1,
+			refunded_amount = ROUND((unit_price * quantity - discount) * (returned_quantity + $1) / quantity, 2)
+		WHERE id = (
+			SELECT id FROM sales_items
+			WHERE sale_id = $2 AND product_id = $3 AND variant_id IS NOT DISTINCT FROM $4::integer
+				AND quantity - returned_quantity >= $1
+			ORDER BY id LIMIT 1
+		) AND quantity - returned_quantity >= $1
+		RETURNING product_name, variant_name, unit_price,
+			refunded_amount - ROUND((unit_price * quantity - discount) * (returned_quantity - $1) / quantity, 2)`,
+		quantity, saleID, productID, variantID,
+	).Scan(&item.ProductName, &item.VariantName, &item.UnitPrice, &item.Total)
+	if err == sql.ErrNoRows {
+		return item, repository.ErrReturnExceedsSold
+	}
+	return item, err
+}
+
+func (r saleRepository) CreateRefund(ctx context.Context, refund *models.Refund) error {
+	err := r.q.QueryRowContext(ctx,
+		"INSERT INTO refunds (sale_id, user_id, type, reason, total_amount, date) VALUES ($1, $2, $3, $4, $5, NOW()) RETURNING id, date",
+		refund.SaleID, refund.UserID, refund.Type, refund.Reason, refund.TotalAmount,
+	).Scan(&refund.ID, &refund.Date)
+	if err != nil {
+		return mapError(err)
+	}
+
+	for _, item := range refund.Items {
+		_, err := r.q.ExecContext(ctx,
+			"INSERT INTO refund_items (refund_id, product_id, product_name, variant_id, variant_name, quantity, unit_price, total) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+			refund.ID, item.ProductID, item.ProductName, item.VariantID, item.VariantName, item.Quantity, item.UnitPrice, item.Total,
+		)
+		if err != nil {
+			return mapError(err)
+		}
+	}
+	return nil
+}
+
+func (r saleRepository) ListRefunds(ctx context.Context, saleID int64) ([]models.Refund, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT
+			rf.id, rf.sale_id, rf.user_id, rf.type, rf.reason, rf.date, rf.total_amount,
+			ri.product_id, ri.product_name, ri.variant_id, ri.variant_name, ri.quantity, ri.unit_price, ri.total
+		FROM refunds rf
+		LEFT JOIN refund_items ri ON rf.id = ri.refund_id
+		WHERE rf.sale_id = $1
+		ORDER BY rf.date, rf.id, ri.id
+	`, saleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refunds := []models.Refund{}
+	for rows.Next() {
+		var (
+			refund      models.Refund
+			productID   sql.NullInt64
+			productName sql.NullString
+			variantID   *int64
+			variantName sql.NullString
+			quantity    sql.NullInt32
+			unitPrice   money.Amount
+			total       money.Amount
+		)
+		if err := rows.Scan(
+			&refund.ID, &refund.SaleID, &refund.UserID, &refund.Type, &refund.Reason, &refund.Date, &refund.TotalAmount,
+			&productID, &productName, &variantID, &variantName, &quantity, &unitPrice, &total,
+		); err != nil {
+			return nil, err
+		}
+
+		// Rows come ordered by refund, so a new ID starts a new document
+		if len(refunds) == 0 || refunds[len(refunds)-1].ID != refund.ID {
+			refund.Items = []models.SaleItem{}
+			refunds = append(refunds, refund)
+		}
+		if productID.Valid {
+			current := &refunds[len(refunds)-1]
+			current.Items = append(current.Items, models.SaleItem{
+				ProductID:   productID.Int64,
+				ProductName: productName.String,
+				VariantID:   variantID,
+				VariantName: variantName.String,
+				Quantity:    int(quantity.Int32),
+				UnitPrice:   unitPrice,
+				Total:       total,
+			})
+		}
+	}
+	return refunds, rows.Err()
+}
+
+func (r saleRepository) ProductMargins(ctx context.Context, from, until *time.Time) ([]models.MarginReport, error) {
+	return r.margins(ctx, "JOIN products g ON g.id = si.product_id", from, until)
+}
+
+func (r saleRepository) SellerMargins(ctx context.Context, from, until *time.Time) ([]models.MarginReport, error) {
+	return r.margins(ctx, "JOIN users g ON g.id = s.user_id", from, until)
+}
+
+// margins sums sale lines per row g of the joined table, which must have an
+// id and a name.
+func (r saleRepository) margins(ctx context.Context, join string, from, until *time.Time) ([]models.MarginReport, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT
+			g.id, g.name,
+			SUM(si.quantity - si.returned_quantity),
+			SUM(si.unit_price * si.quantity - si.discount - si.refunded_amount),
+			SUM(si.unit_cost * (si.quantity - si.returned_quantity))
+		FROM sales_items si
+		JOIN sales s ON s.id = si.sale_id
+		`+join+`
+		WHERE s.status <> 'cancelled'
+			AND ($1::timestamptz IS NULL OR s.date >= $1)
+			AND ($2::timestamptz IS NULL OR s.date < $2)
+		GROUP BY g.id, g.name
+		ORDER BY SUM(si.unit_price * si.quantity - si.discount - si.refunded_amount - si.unit_cost * (si.quantity - si.returned_quantity)) DESC, g.id`,
+		from, until,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := []models.MarginReport{}
+	for rows.Next() {
+		var line models.MarginReport
+		if err := rows.Scan(&line.ID, &line.Name, &line.UnitsSold, &line.Revenue, &line.Cost); err != nil {
+			return nil, err
+		}
+		line.GrossMargin = line.Revenue - line.Cost
+		report = append(report, line)
+	}
+	return report, rows.Err()
+}
+
+func (r saleRepository) BundleRevenue(ctx context.Context, from, until *time.Time) ([]models.BundleRevenue, error) {
+	rows, err := r.q.QueryContext(ctx, `
+		SELECT
+			si.id, si.product_id, p.name, si.quantity, si.returned_quantity,
+			si.unit_price, si.discount, si.refunded_amount,
+			sic.product_id, cp.name, sic.quantity, sic.revenue
+		FROM sales_items si
+		JOIN sales s ON s.id = si.sale_id
+		JOIN products p ON p.id = si.product_id
+		JOIN sales_item_components sic ON sic.sale_item_id = si.id
+		JOIN products cp ON cp.id = sic.product_id
+		WHERE s.status <> 'cancelled'
+			AND ($1::timestamptz IS NULL OR s.date >= $1)
+			AND ($2::timestamptz IS NULL OR s.date < $2)
+		ORDER BY si.id, sic.id`,
+		from, until,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Rows come ordered by line, one per component
+	var (
+		lines  []models.SaleItem
+		lastID int64
+	)
+	for rows.Next() {
+		var (
+			itemID    int64
+			line      models.SaleItem
+			component models.SaleItemComponent
+		)
+		if err := rows.Scan(
+			&itemID, &line.ProductID, &line.ProductName, &line.Quantity, &line.ReturnedQuantity,
+			&line.UnitPrice, &line.Discount, &line.RefundedAmount,
+			&component.ProductID, &component.ProductName, &component.Quantity, &component.Revenue,
+		); err != nil {
+			return nil, err
+		}
+		if len(lines) == 0 || itemID != lastID {
+			lines = append(lines, line)
+			lastID = itemID
+		}
+		current := &lines[len(lines)-1]
+		current.Components = append(current.Components, component)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return repository.SumBundleRevenue(lines), nil
+}
+
+// soldCategories sums, as CTE sold, the units and revenue per category the
+// lines were sold in, of the sales between $1 and $2, leaving cancelled
+// sales out.
+const soldCategories = `sold AS (
+	SELECT
+		si.category_id,
+		SUM(si.quantity - si.returned_quantity) AS units,
+		SUM(si.unit_price * si.quantity - si.discount - si.refunded_amount) AS revenue
+	FROM sales_items si
+	JOIN sales s ON s.id = si.sale_id
+	WHERE s.status <> 'cancelled'
+		AND ($1::timestamptz IS NULL OR s.date >= $1)
+		AND ($2::timestamptz IS NULL OR s.date < $2)
+	GROUP BY si.category_id
+)`
+
+func (r saleRepository) CategoryRevenue(ctx context.Context, parentID *int64, from, until *time.Time) ([]models.CategoryRevenue, error) {
+	// tree pairs each child of the parent with itself and every category below it
+	rows, err := r.q.QueryContext(ctx, `
+		WITH RECURSIVE tree AS (
+			SELECT id AS top_id, id FROM categories WHERE parent_id IS NOT DISTINCT FROM $3::integer
+			UNION ALL
+			SELECT t.top_id, c.id FROM categories c JOIN tree t ON c.parent_id = t.id
+		), `+soldCategories+`
+		SELECT c.id, c.name, COALESCE(SUM(sold.units), 0), COALESCE(SUM(sold.revenue), 0)
+		FROM categories c
+		JOIN tree t ON t.top_id = c.id
+		LEFT JOIN sold ON sold.category_id = t.id
+		GROUP BY c.id, c.name
+		ORDER BY COALESCE(SUM(sold.revenue), 0) DESC, c.id`,
+		from, until, parentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := []models.CategoryRevenue{}
+	for rows.Next() {
+		var line models.CategoryRevenue
+		if err := rows.Scan(&line.CategoryID, &line.Name, &line.UnitsSold, &line.Revenue); err != nil {
+			return nil, err
+		}
+		report = append(report, line)
+	}
+	if err := rows.Err(); err != nil || parentID != nil {
+		return report, err
+	}
+
+	var uncategorized models.CategoryRevenue
+	err = r.q.QueryRowContext(ctx, `
+		WITH `+soldCategories+`
+		SELECT COALESCE(SUM(sold.units), 0), COALESCE(SUM(sold.revenue), 0)
+		FROM sold
+		WHERE sold.category_id IS NULL`,
+		from, until,
+	).Scan(&uncategorized.UnitsSold, &uncategorized.Revenue)
+	if err != nil {
+		return nil, err
+	}
+	if uncategorized.UnitsSold != 0 || uncategorized.Revenue != 0 {
+		report = append(report, uncategorized)
+	}
+	return report, nil
+}
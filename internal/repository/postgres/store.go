@@ -0,0 +1,129 @@
+// Package postgres implements the repository interfaces on top of the
+// PostgreSQL schema in internal/database/migrations.
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gestor-simples-ecs/internal/repository"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Store is a repository.Store backed by a database/sql connection pool.
+type Store struct {
+	db *sql.DB // nil inside a transaction
+	q  querier
+}
+
+// New returns a Store that runs its queries on db.
+func New(db *sql.DB) *Store {
+	return &Store{db: db, q: db}
+}
+
+func (s *Store) Users() repository.UserRepository               { return userRepository{s.q} }
+func (s *Store) Sessions() repository.SessionRepository         { return sessionRepository{s.q} }
+func (s *Store) Roles() repository.RoleRepository               { return roleRepository{s.q} }
+func (s *Store) Products() repository.ProductRepository         { return productRepository{s.q} }
+func (s *Store) Categories() repository.CategoryRepository      { return categoryRepository{s.q} }
+func (s *Store) Variants() repository.VariantRepository         { return variantRepository{s.q} }
+func (s *Store) Bundles() repository.BundleRepository           { return bundleRepository{s.q} }
+func (s *Store) Lots() repository.LotRepository                 { return lotRepository{s.q} }
+func (s *Store) Customers() repository.CustomerRepository       { return customerRepository{s.q} }
+func (s *Store) Sales() repository.SaleRepository               { return saleRepository{s.q} }
+func (s *Store) Promotions() repository.PromotionRepository     { return promotionRepository{s.q} }
+func (s *Store) Quotes() repository.QuoteRepository             { return quoteRepository{s.q} }
+func (s *Store) Reservations() repository.ReservationRepository { return reservationRepository{s.q} }
+func (s *Store) Suppliers() repository.SupplierRepository       { return supplierRepository{s.q} }
+func (s *Store) PurchaseOrders() repository.PurchaseOrderRepository {
+	return purchaseOrderRepository{s.q}
+}
+func (s *Store) Dashboard() repository.DashboardRepository { return dashboardRepository{s.q} }
+
+// WithTx runs fn in a database transaction. Calls nested inside fn reuse the
+// outer transaction.
+func (s *Store) WithTx(ctx context.Context, fn func(tx repository.Store) error) error {
+	if s.db == nil {
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(&Store{q: tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// mapError translates driver errors into the repository sentinel errors.
+func mapError(err error) error {
+	if err == sql.ErrNoRows {
+		return repository.ErrNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "23505": // unique_violation
+			return repository.ErrConflict
+		case "23503": // foreign_key_violation
+			return repository.ErrInUse
+		}
+	}
+	return err
+}
+
+// expectOne maps an UPDATE or DELETE that touched no rows to ErrNotFound.
+func expectOne(res sql.Result, err error) error {
+	if err != nil {
+		return mapError(err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// filterBuilder accumulates SQL conditions and their positional arguments.
+type filterBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+// add appends a condition whose "?" placeholders all refer to arg.
+func (f *filterBuilder) add(condition string, arg interface{}) {
+	f.args = append(f.args, arg)
+	f.conditions = append(f.conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(f.args))))
+}
+
+// where renders the accumulated conditions as a WHERE clause, or "" if none.
+func (f *filterBuilder) where() string {
+	if len(f.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(f.conditions, " AND ")
+}
+
+// page renders LIMIT/OFFSET placeholders and returns the arguments to use with them.
+func (f *filterBuilder) page(limit, offset int) (string, []interface{}) {
+	n := len(f.args)
+	args := append(append([]interface{}{}, f.args...), limit, offset)
+	return fmt.Sprintf(" LIMIT $%d OFFSET $%d", n+1, n+2), args
+}
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+)
+
+type supplierRepository struct{ q querier }
+
+const supplierColumns = "id, name, COALESCE(document, ''), phone, email, created_at"
+
+func scanSupplier(row interface{ Scan(...interface{}) error }, s *models.Supplier) error {
+	return row.Scan(&s.ID, &s.Name, &s.Document, &s.Phone, &s.Email, &s.CreatedAt)
+}
+
+func (r supplierRepository) List(ctx context.Context, filter repository.SupplierFilter) ([]models.Supplier, int, error) {
+	var filters filterBuilder
+	if filter.Search != "" {
+		filters.add("(name ILIKE '%' || ? || '%' OR document LIKE '%' || ? || '%')", filter.Search)
+	}
+
+	var total int
+	if err := r.q.QueryRowContext(ctx, "SELECT COUNT(*) FROM suppliers"+filters.where(), filters.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	pageSQL, args := filters.page(filter.Limit, filter.Offset)
+	rows, err := r.q.QueryContext(ctx, "SELECT "+supplierColumns+" FROM suppliers"+filters.where()+" ORDER BY name, id"+pageSQL, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	suppliers := []models.Supplier{}
+	for rows.Next() {
+		var s models.Supplier
+		if err := scanSupplier(rows, &s); err != nil {
+			return nil, 0, err
+		}
+		suppliers = append(suppliers, s)
+	}
+	return suppliers, total, rows.Err()
+}
+
+func (r supplierRepository) Get(ctx context.Context, id int64) (models.Supplier, error) {
+	var s models.Supplier
+	err := scanSupplier(r.q.QueryRowContext(ctx, "SELECT "+supplierColumns+" FROM suppliers WHERE id = $1", id), &s)
+	return s, mapError(err)
+}
+
+func (r supplierRepository) Create(ctx context.Context, s *models.Supplier) error {
+	err := r.q.QueryRowContext(ctx,
+		"INSERT INTO suppliers (name, document, phone, email) VALUES ($1, NULLIF($2, ''), $3, $4) RETURNING id, created_at",
+		s.Name, s.Document, s.Phone, s.Email,
+	).Scan(&s.ID, &s.CreatedAt)
+	return mapError(err)
+}
+
+func (r supplierRepository) Update(ctx context.Context, s models.Supplier) error {
+	return expectOne(r.q.ExecContext(ctx,
+		"UPDATE suppliers SET name = $1, document = NULLIF($2, ''), phone = $3, email = $4 WHERE id = $5",
+		s.Name, s.Document, s.Phone, s.Email, s.ID,
+	))
+}
+
+func (r supplierRepository) Delete(ctx context.Context, id int64) error {
+	return expectOne(r.q.ExecContext(ctx, "DELETE FROM suppliers WHERE id = $1", id))
+}
@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"time"
+)
+
+type userRepository struct{ q querier }
+
+func (r userRepository) List(ctx context.Context, filter repository.UserFilter) ([]models.User, int, error) {
+	var filters filterBuilder
+	if filter.Role != "" {
+		filters.add("role = ?", filter.Role)
+	}
+
+	var total int
+	if err := r.q.QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+filters.where(), filters.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	pageSQL, args := filters.page(filter.Limit, filter.Offset)
+	rows, err := r.q.QueryContext(ctx, "SELECT id, name, username, role FROM users"+filters.where()+" ORDER BY id"+pageSQL, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Name, &user.Username, &user.Role); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+	return users, total, rows.Err()
+}
+
+func (r userRepository) Get(ctx context.Context, id int64) (models.User, error) {
+	var user models.User
+	err := r.q.QueryRowContext(ctx, "SELECT id, name, username, role FROM users WHERE id = $1", id).
+		Scan(&user.ID, &user.Name, &user.Username, &user.Role)
+	return user, mapError(err)
+}
+
+func (r userRepository) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	var user models.User
+	err := r.q.QueryRowContext(ctx, "SELECT id, name, username, password_hash, role FROM users WHERE username = $1", username).
+		Scan(&user.ID, &user.Name, &user.Username, &user.PasswordHash, &user.Role)
+	return user, mapError(err)
+}
+
+func (r userRepository) Create(ctx context.Context, user *models.User) error {
+	err := r.q.QueryRowContext(ctx,
+		"INSERT INTO users (name, username, password_hash, role) VALUES ($1, $2, $3, $4) RETURNING id",
+		user.Name, user.Username, user.PasswordHash, user.Role,
+	).Scan(&user.ID)
+	return mapError(err)
+}
+
+func (r userRepository) Update(ctx context.Context, user models.User) error {
+	return expectOne(r.q.ExecContext(ctx,
+		"UPDATE users SET name = $1, username = $2, role = $3 WHERE id = $4",
+		user.Name, user.Username, user.Role, user.ID,
+	))
+}
+
+func (r userRepository) Delete(ctx context.Context, id int64) error {
+	return expectOne(r.q.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id))
+}
+
+type sessionRepository struct{ q querier }
+
+func (r sessionRepository) Create(ctx context.Context, userID int64, refreshTokenHash string, expiresAt time.Time) (int64, error) {
+	var id int64
+	err := r.q.QueryRowContext(ctx,
+		"INSERT INTO sessions (user_id, refresh_token_hash, expires_at) VALUES ($1, $2, $3) RETURNING id",
+		userID, refreshTokenHash, expiresAt,
+	).Scan(&id)
+	return id, mapError(err)
+}
+
+func (r sessionRepository) Rotate(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (int64, models.User, error) {
+	var sessionID int64
+	var user models.User
+	err := r.q.QueryRowContext(ctx, `
+		UPDATE sessions s
+		SET refresh_token_hash = $1, expires_at = $2, last_used_at = NOW()
+		FROM users u
+		WHERE s.user_id = u.id
+			AND s.refresh_token_hash = $3
+			AND s.revoked_at IS NULL
+			AND s.expires_at > NOW()
+		RETURNING s.id, u.id, u.name, u.username, u.role`,
+		newHash, expiresAt, oldHash,
+	).Scan(&sessionID, &user.ID, &user.Name, &user.Username, &user.Role)
+	return sessionID, user, mapError(err)
+}
+
+func (r sessionRepository) IsActive(ctx context.Context, sessionID int64) (bool, error) {
+	var active bool
+	err := r.q.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM sessions WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW())",
+		sessionID,
+	).Scan(&active)
+	return active, err
+}
+
+func (r sessionRepository) Revoke(ctx context.Context, sessionID int64) error {
+	_, err := r.q.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL", sessionID)
+	return err
+}
+
+func (r sessionRepository) RevokeAllForUser(ctx context.Context, userID int64) (int64, error) {
+	res, err := r.q.ExecContext(ctx, "UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+
+	"github.com/lib/pq"
+)
+
+type variantRepository struct{ q querier }
+
+const variantColumns = "id, product_id, name, attributes, price, quantity, " + variantReserved + ", created_at"
+
+func scanVariant(row interface{ Scan(...interface{}) error }) (models.ProductVariant, error) {
+	var (
+		v          models.ProductVariant
+		attributes []byte
+	)
+	if err := row.Scan(&v.ID, &v.ProductID, &v.Name, &attributes, &v.Price, &v.Quantity, &v.Reserved, &v.CreatedAt); err != nil {
+		return v, err
+	}
+	v.Available = v.Quantity - v.Reserved
+	return v, json.Unmarshal(attributes, &v.Attributes)
+}
+
+// attributesJSON encodes the attributes for the JSONB column; nil becomes {}.
+func attributesJSON(attributes map[string]string) (string, error) {
+	if attributes == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(attributes)
+	return string(b), err
+}
+
+func (r variantRepository) List(ctx context.Context, productIDs ...int64) ([]models.ProductVariant, error) {
+	rows, err := r.q.QueryContext(ctx,
+		"SELECT "+variantColumns+" FROM product_variants WHERE product_id = ANY($1) ORDER BY id",
+		pq.Array(productIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := []models.ProductVariant{}
+	for rows.Next() {
+		v, err := scanVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, v)
+	}
+	return variants, rows.Err()
+}
+
+func (r variantRepository) Get(ctx context.Context, id int64) (models.ProductVariant, error) {
+	v, err := scanVariant(r.q.QueryRowContext(ctx, "SELECT "+variantColumns+" FROM product_variants WHERE id = $1", id))
+	return v, mapError(err)
+}
+
+func (r variantRepository) Create(ctx context.Context, v *models.ProductVariant) error {
+	attributes, err := attributesJSON(v.Attributes)
+	if err != nil {
+		return err
+	}
+	err = r.q.QueryRowContext(ctx,
+		"INSERT INTO product_variants (product_id, name, attributes, price, quantity) VALUES ($1, $2, $3::jsonb, $4, $5) RETURNING id, created_at",
+		v.ProductID, v.Name, attributes, v.Price, v.Quantity,
+	).Scan(&v.ID, &v.CreatedAt)
+	return mapError(err)
+}
+
+func (r variantRepository) Update(ctx context.Context, v models.ProductVariant) error {
+	attributes, err := attributesJSON(v.Attributes)
+	if err != nil {
+		return err
+	}
+	return expectOne(r.q.ExecContext(ctx,
+		"UPDATE product_variants SET name = $1, attributes = $2::jsonb, price = $3, quantity = $4 WHERE id = $5",
+		v.Name, attributes, v.Price, v.Quantity, v.ID,
+	))
+}
+
+func (r variantRepository) Delete(ctx context.Context, id int64) error {
+	return expectOne(r.q.ExecContext(ctx, "DELETE FROM product_variants WHERE id = $1", id))
+}
+
+func (r variantRepository) AdjustStock(ctx context.Context, id int64, delta int) error {
+	return expectOne(r.q.ExecContext(ctx, "UPDATE product_variants SET quantity = quantity + $1 WHERE id = $2", delta, id))
+}
+
+func (r variantRepository) DecrementStock(ctx context.Context, id int64, quantity int) (models.ProductVariant, error) {
+	v, err := scanVariant(r.q.QueryRowContext(ctx,
+		"UPDATE product_variants SET quantity = quantity - $1 WHERE id = $2 AND quantity - "+variantReserved+" >= $1 RETURNING "+variantColumns,
+		quantity, id,
+	))
+	if err == sql.ErrNoRows {
+		return v, repository.ErrInsufficientStock
+	}
+	return v, err
+}
@@ -0,0 +1,567 @@
+// Package repository defines the storage interfaces used by the HTTP
+// handlers. The postgres subpackage implements them on top of database/sql
+// and the memory subpackage keeps everything in process for tests.
+package repository
+
+import (
+	"context"
+	"errors"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/pkg/money"
+	"slices"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when the requested record does not exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned when a record would violate a uniqueness rule.
+	ErrConflict = errors.New("record already exists")
+	// ErrInUse is returned when a record cannot be deleted because others reference it.
+	ErrInUse = errors.New("record is still referenced")
+	// ErrInsufficientStock is returned when a product does not exist or has
+	// less stock than requested.
+	ErrInsufficientStock = errors.New("insufficient stock or product not found")
+	// ErrReturnExceedsSold is returned when a return asks for more units than
+	// are still outstanding on the sale.
+	ErrReturnExceedsSold = errors.New("returned quantity exceeds what was sold")
+)
+
+// Store gives access to every repository.
+type Store interface {
+	Users() UserRepository
+	Sessions() SessionRepository
+	Roles() RoleRepository
+	Products() ProductRepository
+	Categories() CategoryRepository
+	Variants() VariantRepository
+	Bundles() BundleRepository
+	Lots() LotRepository
+	Customers() CustomerRepository
+	Sales() SaleRepository
+	Promotions() PromotionRepository
+	Quotes() QuoteRepository
+	Reservations() ReservationRepository
+	Suppliers() SupplierRepository
+	PurchaseOrders() PurchaseOrderRepository
+	Dashboard() DashboardRepository
+
+	// WithTx runs fn inside a transaction. Repositories reached through the
+	// Store passed to fn share it; if fn returns an error, every change is
+	// rolled back and that error is returned.
+	WithTx(ctx context.Context, fn func(tx Store) error) error
+}
+
+// UserFilter narrows and pages UserRepository.List.
+type UserFilter struct {
+	Role   string
+	Limit  int
+	Offset int
+}
+
+type UserRepository interface {
+	// List returns one page of users ordered by ID and the total matching the filter.
+	List(ctx context.Context, filter UserFilter) ([]models.User, int, error)
+	Get(ctx context.Context, id int64) (models.User, error)
+	// GetByUsername also loads the password hash, for authentication.
+	GetByUsername(ctx context.Context, username string) (models.User, error)
+	// Create stores the user, including PasswordHash, and sets its ID.
+	Create(ctx context.Context, user *models.User) error
+	// Update saves the name, username and role.
+	Update(ctx context.Context, user models.User) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type SessionRepository interface {
+	// Create opens a session and returns its ID.
+	Create(ctx context.Context, userID int64, refreshTokenHash string, expiresAt time.Time) (int64, error)
+	// Rotate swaps an active session's refresh token hash for a new one and
+	// returns the session ID and its user. ErrNotFound means the old token is
+	// unknown, expired, already rotated or revoked.
+	Rotate(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (int64, models.User, error)
+	IsActive(ctx context.Context, sessionID int64) (bool, error)
+	Revoke(ctx context.Context, sessionID int64) error
+	// RevokeAllForUser revokes every open session and returns how many there were.
+	RevokeAllForUser(ctx context.Context, userID int64) (int64, error)
+}
+
+type RoleRepository interface {
+	List(ctx context.Context) ([]models.Role, error)
+	Get(ctx context.Context, name string) (models.Role, error)
+	// Create stores the role with its permissions and discount limit;
+	// ErrConflict if the name is taken.
+	Create(ctx context.Context, role models.Role) error
+	// SetPermissions replaces every grant of the role.
+	SetPermissions(ctx context.Context, name string, permissions []string) error
+	// SetMaxDiscount sets the role's discount limit; nil removes it.
+	SetMaxDiscount(ctx context.Context, name string, maxDiscount *money.Amount) error
+	// Delete removes the role; ErrInUse if users still have it.
+	Delete(ctx context.Context, name string) error
+	HasPermission(ctx context.Context, role, permission string) (bool, error)
+	ListPermissions(ctx context.Context) ([]models.Permission, error)
+	PermissionExists(ctx context.Context, code string) (bool, error)
+}
+
+// ProductSorts lists the accepted ProductFilter.Sort values. Each may be
+// prefixed with "-" for descending order.
+var ProductSorts = []string{"name", "price", "quantity"}
+
+// ValidProductSort reports whether sort is empty or an accepted sort value.
+func ValidProductSort(sort string) bool {
+	if sort == "" {
+		return true
+	}
+	if sort[0] == '-' {
+		sort = sort[1:]
+	}
+	for _, s := range ProductSorts {
+		if s == sort {
+			return true
+		}
+	}
+	return false
+}
+
+// ProductFilter narrows, sorts and pages ProductRepository.List.
+type ProductFilter struct {
+	Search     string // Case-insensitive substring of the name
+	CategoryID *int64 // The category or any category below it
+	Sort       string // One of ProductSorts, optionally prefixed with "-"; default is by ID
+	Limit      int
+	Offset     int
+}
+
+type ProductRepository interface {
+	List(ctx context.Context, filter ProductFilter) ([]models.Product, int, error)
+	Get(ctx context.Context, id int64) (models.Product, error)
+	// GetForUpdate loads the product and, inside a transaction, locks it
+	// until the transaction ends.
+	GetForUpdate(ctx context.Context, id int64) (models.Product, error)
+	// GetByBarcode and GetBySKU find a product by one of its codes, given
+	// normalized; ErrNotFound if no product has it.
+	GetByBarcode(ctx context.Context, barcode string) (models.Product, error)
+	GetBySKU(ctx context.Context, sku string) (models.Product, error)
+	// Create stores the product and sets its ID; ErrConflict if the SKU or
+	// barcode belongs to another product and ErrInUse if the category does
+	// not exist.
+	Create(ctx context.Context, product *models.Product) error
+	// Update has the errors of Create. It leaves the cost price alone,
+	// which only receipts change.
+	Update(ctx context.Context, product models.Product) error
+	// Delete removes the product; ErrInUse if sales, quotes or purchase
+	// orders refer to it or it goes into a bundle.
+	Delete(ctx context.Context, id int64) error
+	// AdjustStock adds delta (which may be negative) to the product quantity.
+	AdjustStock(ctx context.Context, id int64, delta int) error
+	// ReceiveStock adds quantity units bought at unitCost and moves the cost
+	// price to the weighted average of the units on hand and those received.
+	ReceiveStock(ctx context.Context, id int64, quantity int, unitCost money.Amount) error
+	// DecrementStock removes quantity units if that much is available, that
+	// is in stock and not reserved, and returns the product as it was
+	// charged; ErrInsufficientStock otherwise.
+	DecrementStock(ctx context.Context, id int64, quantity int) (models.Product, error)
+	// RecordMovement appends to the stock ledger and sets the movement's ID and Date.
+	RecordMovement(ctx context.Context, movement *models.StockMovement) error
+	// ListMovements returns the product's ledger, newest first.
+	ListMovements(ctx context.Context, productID int64) ([]models.StockMovement, error)
+	// Reconcile lists products, and variants, whose quantity differs from
+	// their ledger sum.
+	Reconcile(ctx context.Context) ([]models.StockDiscrepancy, error)
+}
+
+// CustomerFilter narrows and pages CustomerRepository.List.
+type CustomerFilter struct {
+	Search string // Case-insensitive substring of the name, or part of the document
+	Limit  int
+	Offset int
+}
+
+type CustomerRepository interface {
+	// List returns one page of customers ordered by name.
+	List(ctx context.Context, filter CustomerFilter) ([]models.Customer, int, error)
+	Get(ctx context.Context, id int64) (models.Customer, error)
+	// Create stores the customer and sets its ID and CreatedAt; ErrConflict if
+	// the document is already registered.
+	Create(ctx context.Context, customer *models.Customer) error
+	Update(ctx context.Context, customer models.Customer) error
+	// Delete removes the customer; ErrInUse if they have sales or quotes.
+	Delete(ctx context.Context, id int64) error
+	// Metrics aggregates the customer's sales. The average ticket is left to the caller.
+	Metrics(ctx context.Context, id int64) (models.CustomerMetrics, error)
+}
+
+// SaleFilter narrows and pages SaleRepository.List. Nil fields are ignored.
+type SaleFilter struct {
+	UserID     *int64
+	CustomerID *int64
+	ProductID  *int64
+	From       *time.Time // Inclusive
+	Until      *time.Time // Exclusive
+	Limit      int
+	Offset     int
+}
+
+type SaleRepository interface {
+	// List returns one page of sales with their items, seller and customer
+	// names, newest first.
+	List(ctx context.Context, filter SaleFilter) ([]models.Sale, int, error)
+	// Create stores the sale header (seller, creator, customer, sale-level
+	// discount and its approver) dated now and sets its ID, Date and Status.
+	// Items are added with AddItem.
+	Create(ctx context.Context, sale *models.Sale) error
+	// AddItem stores a line with its name, unit price, unit cost, discount,
+	// the lots it took stock from and, for bundles, components.
+	AddItem(ctx context.Context, saleID int64, item models.SaleItem) error
+	AddPayment(ctx context.Context, saleID int64, payment models.Payment) error
+	// ListPayments returns the sale's payments in the order they were added.
+	ListPayments(ctx context.Context, saleID int64) ([]models.Payment, error)
+	// Get loads the sale with its items, totals, seller and customer names.
+	Get(ctx context.Context, id int64) (models.Sale, error)
+	// GetForUpdate is Get that, inside a transaction, also locks the sale
+	// until the transaction ends.
+	GetForUpdate(ctx context.Context, id int64) (models.Sale, error)
+	SetStatus(ctx context.Context, id int64, status string) error
+	// ReturnItem marks quantity units of the product, and variant if not nil,
+	// as returned on the first sale line that still has that many
+	// outstanding, and returns those units with Total set to their share of
+	// the discounted line value.
+	ReturnItem(ctx context.Context, saleID, productID int64, variantID *int64, quantity int) (models.SaleItem, error)
+	// CreateRefund stores the refund document with its items and sets its ID and Date.
+	CreateRefund(ctx context.Context, refund *models.Refund) error
+	ListRefunds(ctx context.Context, saleID int64) ([]models.Refund, error)
+	// ProductMargins sums the gross margin per product of sales dated between
+	// from (inclusive) and until (exclusive), highest margin first. Nil bounds
+	// are ignored.
+	ProductMargins(ctx context.Context, from, until *time.Time) ([]models.MarginReport, error)
+	// SellerMargins is ProductMargins per seller.
+	SellerMargins(ctx context.Context, from, until *time.Time) ([]models.MarginReport, error)
+	// CategoryRevenue sums, for every child of the parent category (or every
+	// top-level category if parentID is nil), the lines of sales dated
+	// between from (inclusive) and until (exclusive) sold in it or below it,
+	// highest revenue first. Lines count for the category their product was
+	// in when sold, so moving a product doesn't rewrite past revenue. At the
+	// top level, lines sold without a category are summed last under a nil
+	// CategoryID if there are any.
+	// Cancelled sales are left out and nil bounds are ignored.
+	CategoryRevenue(ctx context.Context, parentID *int64, from, until *time.Time) ([]models.CategoryRevenue, error)
+	// BundleRevenue sums, for every bundle sold, the lines of sales dated
+	// between from (inclusive) and until (exclusive), highest revenue first,
+	// and splits each line's revenue net of refunds among its components in
+	// proportion to the revenue recorded for them. Cancelled sales are left
+	// out and nil bounds are ignored.
+	BundleRevenue(ctx context.Context, from, until *time.Time) ([]models.BundleRevenue, error)
+}
+
+type PromotionRepository interface {
+	// List returns every promotion ordered by ID.
+	List(ctx context.Context) ([]models.Promotion, error)
+	Get(ctx context.Context, id int64) (models.Promotion, error)
+	// Create stores the promotion and sets its ID and CreatedAt; ErrConflict
+	// if the code is taken and ErrInUse if the product does not exist.
+	Create(ctx context.Context, promotion *models.Promotion) error
+	// Update saves everything but the usage count and creation date.
+	Update(ctx context.Context, promotion models.Promotion) error
+	// Delete removes the promotion; ErrInUse if it was applied to a sale.
+	Delete(ctx context.Context, id int64) error
+	// ListValid returns the active promotions within their validity window at
+	// the given time and below their usage limit, ordered by ID. Happy-hour
+	// windows are left to the caller.
+	ListValid(ctx context.Context, at time.Time) ([]models.Promotion, error)
+	// Use counts one more sale for the promotion; ErrConflict if that would
+	// exceed its usage limit.
+	Use(ctx context.Context, id int64) error
+	// Report sums, for every promotion, the lines of sales dated between from
+	// (inclusive) and until (exclusive) it was applied to. Nil bounds are ignored.
+	Report(ctx context.Context, from, until *time.Time) ([]models.PromotionReport, error)
+}
+
+// QuoteFilter narrows and pages QuoteRepository.List. Nil fields are ignored.
+type QuoteFilter struct {
+	UserID     *int64
+	CustomerID *int64
+	Limit      int
+	Offset     int
+}
+
+type QuoteRepository interface {
+	// List returns one page of quotes with their items, newest first.
+	List(ctx context.Context, filter QuoteFilter) ([]models.Quote, int, error)
+	// Get loads the quote with its items, totals, seller and customer names.
+	// Open quotes past their validity are reported as expired.
+	Get(ctx context.Context, id int64) (models.Quote, error)
+	// Create stores the quote with its items and sets its ID, CreatedAt and Status.
+	Create(ctx context.Context, quote *models.Quote) error
+	// MarkConverted links an open quote to the sale it became; ErrConflict if
+	// it is no longer open.
+	MarkConverted(ctx context.Context, id, saleID int64) error
+}
+
+// ReservationFilter narrows and pages ReservationRepository.List. Zero
+// fields are ignored.
+type ReservationFilter struct {
+	ProductID *int64
+	QuoteID   *int64
+	Status    string // Matches the reported status, so "expired" includes unswept ones
+	Limit     int
+	Offset    int
+}
+
+type ReservationRepository interface {
+	// List returns one page of reservations, newest first.
+	List(ctx context.Context, filter ReservationFilter) ([]models.StockReservation, int, error)
+	// Get loads the reservation with its product name. Active reservations
+	// past their expiry are reported as expired.
+	Get(ctx context.Context, id int64) (models.StockReservation, error)
+	// Create holds the units and sets the reservation ID, Status and
+	// CreatedAt; ErrInsufficientStock if the product, or the variant
+	// reserved, has less available or the variant is not the product's.
+	Create(ctx context.Context, reservation *models.StockReservation) error
+	// Close moves an active, unexpired reservation to status (consumed or
+	// released); ErrConflict if it no longer holds stock.
+	Close(ctx context.Context, id int64, status string) error
+	// Consume takes quantity units of an active, unexpired reservation for
+	// a sale. Taking them all closes it as consumed; otherwise it keeps
+	// holding the rest. ErrConflict if it no longer holds that many.
+	Consume(ctx context.Context, id int64, quantity int) error
+	// ExpireDue marks active reservations past their expiry as expired and
+	// returns how many it marked.
+	ExpireDue(ctx context.Context) (int64, error)
+}
+
+type CategoryRepository interface {
+	// List returns every category ordered by name.
+	List(ctx context.Context) ([]models.Category, error)
+	Get(ctx context.Context, id int64) (models.Category, error)
+	// Create stores the category and sets its ID and CreatedAt; ErrConflict
+	// if a sibling has the same name and ErrInUse if the parent does not exist.
+	Create(ctx context.Context, category *models.Category) error
+	// Update renames or moves the category, with the errors of Create. The
+	// caller keeps the tree free of cycles.
+	Update(ctx context.Context, category models.Category) error
+	// Delete removes the category; ErrInUse if it has subcategories,
+	// products or promotions or sale lines were sold in it.
+	Delete(ctx context.Context, id int64) error
+	// Ancestors returns the ID of the category followed by those of its
+	// parents up to the top; ErrNotFound if it does not exist.
+	Ancestors(ctx context.Context, id int64) ([]int64, error)
+}
+
+// VariantRepository keeps the variants of products. Their stock moves
+// together with the product quantity, which the caller updates in the same
+// transaction.
+type VariantRepository interface {
+	// List returns the variants of the given products in the order they were
+	// created.
+	List(ctx context.Context, productIDs ...int64) ([]models.ProductVariant, error)
+	Get(ctx context.Context, id int64) (models.ProductVariant, error)
+	// Create stores the variant and sets its ID and CreatedAt; ErrConflict if
+	// the product has a variant with the same name and ErrInUse if the
+	// product does not exist.
+	Create(ctx context.Context, variant *models.ProductVariant) error
+	// Update changes the name, attributes, price and quantity of the variant,
+	// with the errors of Create.
+	Update(ctx context.Context, variant models.ProductVariant) error
+	// Delete removes the variant with its stock movements and reservations;
+	// ErrInUse if sales, quotes or refunds refer to it.
+	Delete(ctx context.Context, id int64) error
+	// AdjustStock adds delta (which may be negative) to the variant quantity.
+	AdjustStock(ctx context.Context, id int64, delta int) error
+	// DecrementStock removes quantity units if the variant has that many
+	// available and returns it; ErrInsufficientStock otherwise, also if it
+	// does not exist.
+	DecrementStock(ctx context.Context, id int64, quantity int) (models.ProductVariant, error)
+}
+
+type LotRepository interface {
+	// List returns the lots of the given products, those expiring first
+	// first.
+	List(ctx context.Context, productIDs ...int64) ([]models.ProductLot, error)
+	Get(ctx context.Context, id int64) (models.ProductLot, error)
+	// Create stores the lot and sets its ID and CreatedAt; ErrConflict if
+	// the product has a lot with the same code and ErrInUse if the product
+	// does not exist.
+	Create(ctx context.Context, lot *models.ProductLot) error
+	// Update changes the code, expiry and quantity of the lot, with the
+	// errors of Create.
+	Update(ctx context.Context, lot models.ProductLot) error
+	// Delete removes the lot; ErrInUse if sales took stock from it.
+	Delete(ctx context.Context, id int64) error
+	// AdjustStock adds delta (which may be negative) to the lot quantity.
+	AdjustStock(ctx context.Context, id int64, delta int) error
+	// Consume takes quantity units of the product from its lots not expired
+	// on the given date, those expiring first first, locking them until the
+	// transaction ends, and returns what it took from each. Products without
+	// lots are not tracked by lot and get nil; ErrInsufficientStock if the
+	// unexpired lots don't have enough.
+	Consume(ctx context.Context, productID int64, quantity int, on time.Time) ([]models.SaleItemLot, error)
+	// Expiring returns the lots with stock expiring on or before until,
+	// expired ones included, with their product names, those expiring first
+	// first.
+	Expiring(ctx context.Context, until time.Time) ([]models.ProductLot, error)
+}
+
+// SumBundleRevenue builds the BundleRevenue report from bundle sale lines,
+// named as the report should show them. Each line's revenue net of refunds
+// is split among its components in proportion to the revenue recorded for
+// them when it was sold.
+func SumBundleRevenue(lines []models.SaleItem) []models.BundleRevenue {
+	index := map[int64]int{}
+	report := []models.BundleRevenue{}
+	for _, line := range lines {
+		i, ok := index[line.ProductID]
+		if !ok {
+			i = len(report)
+			index[line.ProductID] = i
+			report = append(report, models.BundleRevenue{BundleID: line.ProductID, Name: line.ProductName, Components: []models.ComponentRevenue{}})
+		}
+		bundle := &report[i]
+		units := line.Quantity - line.ReturnedQuantity
+		revenue := line.UnitPrice.Mul(line.Quantity) - line.Discount - line.RefundedAmount
+		bundle.UnitsSold += units
+		bundle.Revenue += revenue
+
+		weights := make([]money.Amount, len(line.Components))
+		for j, c := range line.Components {
+			weights[j] = c.Revenue
+		}
+		shares := revenue.Split(weights)
+		for j, c := range line.Components {
+			k := slices.IndexFunc(bundle.Components, func(r models.ComponentRevenue) bool { return r.ProductID == c.ProductID })
+			if k < 0 {
+				k = len(bundle.Components)
+				bundle.Components = append(bundle.Components, models.ComponentRevenue{ProductID: c.ProductID, Name: c.ProductName})
+			}
+			bundle.Components[k].UnitsSold += units * c.Quantity
+			bundle.Components[k].Revenue += shares[j]
+		}
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Revenue != report[j].Revenue {
+			return report[i].Revenue > report[j].Revenue
+		}
+		return report[i].BundleID < report[j].BundleID
+	})
+	for _, bundle := range report {
+		sort.Slice(bundle.Components, func(i, j int) bool {
+			a, b := bundle.Components[i], bundle.Components[j]
+			if a.Revenue != b.Revenue {
+				return a.Revenue > b.Revenue
+			}
+			return a.ProductID < b.ProductID
+		})
+	}
+	return report
+}
+
+type BundleRepository interface {
+	// List returns the components of the given bundles with their names and
+	// available stock, ordered by bundle and component ID.
+	List(ctx context.Context, bundleIDs ...int64) ([]models.BundleComponent, error)
+	// Set replaces the components of the bundle; an empty list makes it a
+	// plain product again. ErrInUse if the bundle or a component does not
+	// exist.
+	Set(ctx context.Context, bundleID int64, components []models.BundleComponent) error
+	// ContainedIn returns the IDs of the bundles the product goes into.
+	ContainedIn(ctx context.Context, productID int64) ([]int64, error)
+}
+
+// SupplierFilter narrows and pages SupplierRepository.List.
+type SupplierFilter struct {
+	Search string // Case-insensitive substring of the name, or part of the document
+	Limit  int
+	Offset int
+}
+
+type SupplierRepository interface {
+	// List returns one page of suppliers ordered by name.
+	List(ctx context.Context, filter SupplierFilter) ([]models.Supplier, int, error)
+	Get(ctx context.Context, id int64) (models.Supplier, error)
+	// Create stores the supplier and sets its ID and CreatedAt; ErrConflict if
+	// the document is already registered.
+	Create(ctx context.Context, supplier *models.Supplier) error
+	Update(ctx context.Context, supplier models.Supplier) error
+	// Delete removes the supplier; ErrInUse if it has purchase orders.
+	Delete(ctx context.Context, id int64) error
+}
+
+// PurchaseOrderFilter narrows and pages PurchaseOrderRepository.List. Zero
+// fields are ignored.
+type PurchaseOrderFilter struct {
+	SupplierID *int64
+	Status     string
+	Limit      int
+	Offset     int
+}
+
+type PurchaseOrderRepository interface {
+	// List returns one page of purchase orders with their items, newest first.
+	List(ctx context.Context, filter PurchaseOrderFilter) ([]models.PurchaseOrder, int, error)
+	// Get loads the purchase order with its items and supplier name.
+	Get(ctx context.Context, id int64) (models.PurchaseOrder, error)
+	// GetForUpdate is Get, locking the order until the transaction ends.
+	GetForUpdate(ctx context.Context, id int64) (models.PurchaseOrder, error)
+	// Create stores the order with its items and sets its ID, Status and CreatedAt.
+	Create(ctx context.Context, order *models.PurchaseOrder) error
+	// ReceiveItem adds quantity to the received units of the order's line
+	// for the product and variant (nil for lines without one); ErrConflict
+	// if that exceeds what was ordered.
+	ReceiveItem(ctx context.Context, id, productID int64, variantID *int64, quantity int) error
+	SetStatus(ctx context.Context, id int64, status string) error
+	// Incoming lists the outstanding lines of open and partially received
+	// orders, earliest expected first, optionally for one product.
+	Incoming(ctx context.Context, productID *int64) ([]models.IncomingStock, error)
+}
+
+// SalePayments are the payments of one sale and how much of the sale was
+// refunded by returns.
+type SalePayments struct {
+	Payments []models.Payment
+	Refunded money.Amount
+}
+
+// SumPaymentMethods builds the per-method breakdown of the dashboard, the
+// largest total first. Each sale's refunds come off its payments in
+// proportion to their amounts, so the totals add up to the sales net of
+// refunds.
+func SumPaymentMethods(sales []SalePayments) []models.PaymentTotal {
+	index := map[string]int{}
+	report := []models.PaymentTotal{}
+	for _, sale := range sales {
+		weights := make([]money.Amount, len(sale.Payments))
+		for i, p := range sale.Payments {
+			weights[i] = p.Amount
+		}
+		refunded := sale.Refunded.Split(weights)
+		for i, p := range sale.Payments {
+			j, ok := index[p.Method]
+			if !ok {
+				j = len(report)
+				index[p.Method] = j
+				report = append(report, models.PaymentTotal{Method: p.Method})
+			}
+			report[j].Count++
+			report[j].Total += p.Amount - refunded[i]
+		}
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Total != report[j].Total {
+			return report[i].Total > report[j].Total
+		}
+		return report[i].Method < report[j].Method
+	})
+	return report
+}
+
+type DashboardRepository interface {
+	// AdminSummary aggregates store-wide figures for sales not cancelled
+	// since the given time, including the amount kept per payment method
+	// (see SumPaymentMethods). Sellers are the users whose role grants
+	// sellerPermission.
+	AdminSummary(ctx context.Context, since time.Time, sellerPermission string) (models.AdminDashboardSummary, error)
+	// SellerTotals returns a seller's net sales since the given time and their
+	// rank among sellers (0 if they sold nothing).
+	SellerTotals(ctx context.Context, userID int64, since time.Time) (models.VendedorDashboardSummary, error)
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/auth"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --- Product Lot Handlers ---
+//
+// A product with lots keeps its own quantity as the sum of theirs, as with
+// variants. Sales take stock from the unexpired lots that expire first
+// (FEFO) in the sale's transaction and record on each line the lots they
+// took from; returns put the units back in those lots.
+
+// expiringLotsDays is how far ahead the dashboard and, by default, the
+// expiring lots listing look for lots about to expire.
+const expiringLotsDays = 30
+
+// today returns the current date on the store's clock as the midnight UTC
+// lot expiry dates are kept in.
+func today() time.Time {
+	y, m, d := time.Now().In(storeLocation).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// rejectLots returns a 400 naming what products tracked by lot don't
+// support if product is one.
+func rejectLots(ctx context.Context, tx repository.Store, product models.Product, unsupported string) error {
+	lots, err := tx.Lots().List(ctx, product.ID)
+	if err != nil {
+		return newAPIError(http.StatusInternalServerError, "Failed to load product lots")
+	}
+	if len(lots) > 0 {
+		return newAPIError(http.StatusBadRequest, fmt.Sprintf("%q is tracked by lot; %s", product.Name, unsupported))
+	}
+	return nil
+}
+
+// consumeLots takes quantity units of a product sold from its lots, first
+// expiring first. It returns nil for products not tracked by lot.
+func consumeLots(ctx context.Context, tx repository.Store, productID int64, name string, quantity int) ([]models.SaleItemLot, error) {
+	lots, err := tx.Lots().Consume(ctx, productID, quantity, today())
+	if errors.Is(err, repository.ErrInsufficientStock) {
+		return nil, newAPIError(http.StatusBadRequest, fmt.Sprintf("Insufficient unexpired stock of %s", name))
+	}
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "Failed to update lot stock")
+	}
+	return lots, nil
+}
+
+// restockLots puts quantity returned units of a sale line back in the lots
+// of productID the line took them from, last taken first, past the units
+// earlier returns already put back. perUnit is how many units of the
+// product each unit of the line took: one, or a bundle's component count.
+// Units sold before the product had lots have no lot to go back to.
+func restockLots(ctx context.Context, tx repository.Store, line models.SaleItem, productID int64, name string, perUnit, quantity int) error {
+	if !slices.ContainsFunc(line.Lots, func(l models.SaleItemLot) bool { return l.ProductID == productID }) {
+		lots, err := tx.Lots().List(ctx, productID)
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product lots")
+		}
+		if len(lots) > 0 {
+			return newAPIError(http.StatusConflict, fmt.Sprintf("%q was sold before it was tracked by lot; its units cannot be returned to stock", name))
+		}
+		return nil
+	}
+
+	skip, left := line.ReturnedQuantity*perUnit, quantity*perUnit
+	for i := len(line.Lots) - 1; i >= 0 && left > 0; i-- {
+		lot := line.Lots[i]
+		if lot.ProductID != productID {
+			continue
+		}
+		skipped := min(skip, lot.Quantity)
+		skip -= skipped
+		n := min(lot.Quantity-skipped, left)
+		if n == 0 {
+			continue
+		}
+		if err := tx.Lots().AdjustStock(ctx, lot.LotID, n); err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to restore lot stock")
+		}
+		left -= n
+	}
+	return nil
+}
+
+// receiveLot puts the units of a purchase order receipt in the lot they
+// came in, creating it if the product has no lot with that code yet. It
+// does nothing for products not tracked by lot.
+func receiveLot(ctx context.Context, tx repository.Store, product models.Product, receipt models.ReceivePurchaseOrderItem) error {
+	lots, err := tx.Lots().List(ctx, product.ID)
+	if err != nil {
+		return newAPIError(http.StatusInternalServerError, "Failed to load product lots")
+	}
+	if len(lots) == 0 {
+		return nil
+	}
+	code := strings.TrimSpace(receipt.LotCode)
+	if code == "" {
+		return newAPIError(http.StatusBadRequest, fmt.Sprintf("%q is tracked by lot; give the lotCode of the units received", product.Name))
+	}
+
+	for _, l := range lots {
+		if strings.EqualFold(l.Code, code) {
+			if err := tx.Lots().AdjustStock(ctx, l.ID, receipt.Quantity); err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to update lot stock")
+			}
+			return nil
+		}
+	}
+
+	if receipt.ExpiresOn == nil || receipt.ExpiresOn.IsZero() {
+		return newAPIError(http.StatusBadRequest, fmt.Sprintf("%q has no lot %s; give its expiresOn to create it", product.Name, code))
+	}
+	e := *receipt.ExpiresOn
+	l := models.ProductLot{
+		ProductID: product.ID,
+		Code:      code,
+		ExpiresOn: time.Date(e.Year(), e.Month(), e.Day(), 0, 0, 0, 0, time.UTC),
+		Quantity:  receipt.Quantity,
+	}
+	if err := tx.Lots().Create(ctx, &l); err != nil {
+		return newAPIError(http.StatusInternalServerError, "Failed to create product lot")
+	}
+	return nil
+}
+
+func decodeLot(w http.ResponseWriter, r *http.Request) (models.ProductLot, bool) {
+	var l models.ProductLot
+	if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return l, false
+	}
+	if l.Code = strings.TrimSpace(l.Code); l.Code == "" {
+		respondWithError(w, http.StatusBadRequest, "Lot code is required")
+		return l, false
+	}
+	if l.ExpiresOn.IsZero() {
+		respondWithError(w, http.StatusBadRequest, "Expiry date is required")
+		return l, false
+	}
+	if l.Quantity < 0 {
+		respondWithError(w, http.StatusBadRequest, "Quantity cannot be negative")
+		return l, false
+	}
+	l.ExpiresOn = time.Date(l.ExpiresOn.Year(), l.ExpiresOn.Month(), l.ExpiresOn.Day(), 0, 0, 0, 0, time.UTC)
+	return l, true
+}
+
+func (s *server) getProductLotsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	if _, err := s.store.Products().Get(r.Context(), id); err != nil {
+		respondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	lots, err := s.store.Lots().List(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query product lots")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, lots)
+}
+
+// createProductLotHandler receives a lot into the product's stock. Stock
+// already on the product belongs to no lot, so the first lot can only be
+// added once the product's quantity is zero.
+func (s *server) createProductLotHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	l, ok := decodeLot(w, r)
+	if !ok {
+		return
+	}
+	l.ProductID = id
+	userID, _ := auth.UserID(r)
+
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		product, err := tx.Products().GetForUpdate(r.Context(), id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusNotFound, "Product not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product")
+		}
+		if err := rejectBundle(r.Context(), tx, product, "bundles have no stock of their own"); err != nil {
+			return err
+		}
+		found, err := hasVariants(r.Context(), tx, id)
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product variants")
+		}
+		if found {
+			return newAPIError(http.StatusBadRequest, fmt.Sprintf("%q has variants, which lots do not support", product.Name))
+		}
+		lots, err := tx.Lots().List(r.Context(), id)
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product lots")
+		}
+		if len(lots) == 0 && product.Quantity != 0 {
+			return newAPIError(http.StatusConflict, "Product has stock not assigned to any lot; bring its quantity to zero before adding lots")
+		}
+
+		err = tx.Lots().Create(r.Context(), &l)
+		if errors.Is(err, repository.ErrConflict) {
+			return newAPIError(http.StatusConflict, "Product already has a lot with this code")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to create product lot")
+		}
+
+		if l.Quantity != 0 {
+			if err := tx.Products().AdjustStock(r.Context(), id, l.Quantity); err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to update product stock")
+			}
+			return recordStockMovement(r.Context(), tx, id, nil, userID, models.StockMovementPurchaseReceipt, l.Quantity, "Lot "+l.Code+" received", nil)
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, l)
+}
+
+func (s *server) updateProductLotHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	lotID, err := pathID(r, "lotId")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid lot ID")
+		return
+	}
+
+	l, ok := decodeLot(w, r)
+	if !ok {
+		return
+	}
+	l.ID, l.ProductID = lotID, id
+	userID, _ := auth.UserID(r)
+
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		// Serialize lot updates per product; each shifts the product's quantity by its own delta
+		if _, err := tx.Products().GetForUpdate(r.Context(), id); err != nil {
+			return newAPIError(http.StatusNotFound, "Product not found")
+		}
+		current, err := tx.Lots().Get(r.Context(), lotID)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && current.ProductID != id) {
+			return newAPIError(http.StatusNotFound, "Lot not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product lot")
+		}
+
+		err = tx.Lots().Update(r.Context(), l)
+		if errors.Is(err, repository.ErrConflict) {
+			return newAPIError(http.StatusConflict, "Product already has a lot with this code")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to update product lot")
+		}
+
+		if delta := l.Quantity - current.Quantity; delta != 0 {
+			if err := tx.Products().AdjustStock(r.Context(), id, delta); err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to update product stock")
+			}
+			return recordStockMovement(r.Context(), tx, id, nil, userID, models.StockMovementAdjustment, delta, "Quantity changed on lot "+l.Code, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+}
+
+// deleteProductLotHandler removes a lot without stock that no sale took
+// stock from.
+func (s *server) deleteProductLotHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	lotID, err := pathID(r, "lotId")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid lot ID")
+		return
+	}
+
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		l, err := tx.Lots().Get(r.Context(), lotID)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && l.ProductID != id) {
+			return newAPIError(http.StatusNotFound, "Lot not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product lot")
+		}
+		if l.Quantity != 0 {
+			return newAPIError(http.StatusConflict, "Lot still has stock; bring its quantity to zero before deleting it")
+		}
+
+		err = tx.Lots().Delete(r.Context(), lotID)
+		if errors.Is(err, repository.ErrInUse) {
+			return newAPIError(http.StatusConflict, "Lot has sales and cannot be deleted")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to delete product lot")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getExpiringLotsHandler lists the lots with stock that expire within
+// ?days= days (expiringLotsDays by default), expired ones included.
+func (s *server) getExpiringLotsHandler(w http.ResponseWriter, r *http.Request) {
+	days := expiringLotsDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			respondWithError(w, http.StatusBadRequest, "days must be a non-negative number")
+			return
+		}
+		days = n
+	}
+
+	lots, err := s.store.Lots().Expiring(r.Context(), today().AddDate(0, 0, days))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query expiring lots")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, lots)
+}
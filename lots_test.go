@@ -0,0 +1,159 @@
+package main
+
+import (
+	"gestor-simples-ecs/internal/models"
+	"net/http"
+	"testing"
+)
+
+func TestProductLots(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+
+	yogurt := env.createProduct(adminToken, "Iogurte", "5.00", 0)
+	lotsPath := "/api/v1/products/" + itoa(yogurt.ID) + "/lots"
+
+	create := func(code string, days, quantity int) models.ProductLot {
+		t.Helper()
+		rec := env.do("POST", lotsPath, adminToken, models.ProductLot{Code: code, ExpiresOn: today().AddDate(0, 0, days), Quantity: quantity})
+		expectStatus(t, rec, http.StatusCreated)
+		return decode[models.ProductLot](t, rec)
+	}
+	later := create("L-100", 10, 3)
+	sooner := create("L-200", 3, 2)
+	expired := create("L-050", -1, 4)
+
+	expectStatus(t, env.do("POST", lotsPath, adminToken, models.ProductLot{Code: "l-100", ExpiresOn: today(), Quantity: 1}), http.StatusConflict)
+	expectStatus(t, env.do("POST", lotsPath, sellerToken, models.ProductLot{Code: "L-300", ExpiresOn: today(), Quantity: 1}), http.StatusForbidden)
+	expectStatus(t, env.do("POST", lotsPath, adminToken, models.ProductLot{Code: "L-300", Quantity: 1}), http.StatusBadRequest)
+
+	// Stock outside any lot has to go before the first lot
+	milk := env.createProduct(adminToken, "Leite", "4.50", 5)
+	expectStatus(t, env.do("POST", "/api/v1/products/"+itoa(milk.ID)+"/lots", adminToken, models.ProductLot{Code: "A1", ExpiresOn: today(), Quantity: 1}), http.StatusConflict)
+
+	// The product quantity is the sum of its lots, which list expiring first first
+	p := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(yogurt.ID), sellerToken, nil))
+	if p.Quantity != 9 || len(p.Lots) != 3 || p.Lots[0].ID != expired.ID || p.Lots[1].ID != sooner.ID {
+		t.Fatalf("product = %+v", p)
+	}
+	yogurt.Quantity = 20
+	expectStatus(t, env.do("PUT", "/api/v1/products/"+itoa(yogurt.ID), adminToken, yogurt), http.StatusBadRequest)
+	rec := env.do("POST", "/api/v1/products/"+itoa(yogurt.ID)+"/movements", adminToken, models.CreateStockMovementRequest{
+		Type: models.StockMovementAdjustment, Quantity: 1, Reason: "Entrada",
+	})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	// Sales take the unexpired lots that expire first
+	rec = env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+		Items:    []models.CreateSaleItem{{ProductID: yogurt.ID, Quantity: 4}},
+		Payments: pix(t, "20.00"),
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	salePath := "/api/v1/sales/" + itoa(decode[models.CreateSaleResponse](t, rec).SaleID)
+
+	sale := decode[models.Sale](t, env.do("GET", salePath, sellerToken, nil))
+	if lots := sale.Items[0].Lots; len(lots) != 2 ||
+		lots[0].LotID != sooner.ID || lots[0].Quantity != 2 || lots[0].Code != "L-200" ||
+		lots[1].LotID != later.ID || lots[1].Quantity != 2 {
+		t.Fatalf("sale item lots = %+v", lots)
+	}
+
+	// Expired stock is not sold
+	rec = env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+		Items:    []models.CreateSaleItem{{ProductID: yogurt.ID, Quantity: 2}},
+		Payments: pix(t, "10.00"),
+	})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	// Returns go back to the lots the units came from, last taken first
+	rec = env.do("POST", salePath+"/returns", adminToken, models.ReturnSaleItemsRequest{
+		Reason: "Embalagem danificada", Items: []models.SaleItem{{ProductID: yogurt.ID, Quantity: 3}},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	lots := decode[[]models.ProductLot](t, env.do("GET", lotsPath, sellerToken, nil))
+	if len(lots) != 3 || lots[0].Quantity != 4 || lots[1].Quantity != 1 || lots[2].Quantity != 3 {
+		t.Fatalf("lots after sale and return = %+v", lots)
+	}
+
+	// Expiring lots, expired ones included
+	expiring := func(query string) []models.ProductLot {
+		t.Helper()
+		rec := env.do("GET", "/api/v1/products/lots/expiring"+query, sellerToken, nil)
+		expectStatus(t, rec, http.StatusOK)
+		return decode[[]models.ProductLot](t, rec)
+	}
+	if soon := expiring("?days=5"); len(soon) != 2 || soon[0].ID != expired.ID || soon[0].ProductName != "Iogurte" || soon[1].ID != sooner.ID {
+		t.Fatalf("lots expiring in 5 days = %+v", soon)
+	}
+	if all := expiring(""); len(all) != 3 {
+		t.Fatalf("lots expiring in 30 days = %+v", all)
+	}
+	expectStatus(t, env.do("GET", "/api/v1/products/lots/expiring?days=-1", sellerToken, nil), http.StatusBadRequest)
+
+	summary := decode[models.AdminDashboardSummary](t, env.do("GET", "/api/v1/dashboard/summary", adminToken, nil))
+	if summary.ExpiringLots != 3 {
+		t.Fatalf("expiring lots on dashboard = %d, want 3", summary.ExpiringLots)
+	}
+
+	// Writing off the expired lot adjusts the product stock
+	expired.Quantity = 0
+	expectStatus(t, env.do("PUT", lotsPath+"/"+itoa(expired.ID), adminToken, expired), http.StatusOK)
+	if p := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(yogurt.ID), sellerToken, nil)); p.Quantity != 4 {
+		t.Fatalf("product quantity = %d, want 4", p.Quantity)
+	}
+
+	// Purchase orders placed before the first lot receive into lots too
+	cheese := env.createProduct(adminToken, "Queijo", "30.00", 0)
+	cheeseLots := "/api/v1/products/" + itoa(cheese.ID) + "/lots"
+	rec = env.do("POST", "/api/v1/suppliers", adminToken, map[string]string{"name": "Laticínios Serra"})
+	expectStatus(t, rec, http.StatusCreated)
+	rec = env.do("POST", "/api/v1/purchase-orders", adminToken, models.CreatePurchaseOrderRequest{
+		SupplierID: decode[models.Supplier](t, rec).ID,
+		Items:      []models.CreatePurchaseOrderItem{{ProductID: cheese.ID, Quantity: 6, UnitCost: mustParse(t, "20.00")}},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	orderPath := "/api/v1/purchase-orders/" + itoa(decode[models.PurchaseOrder](t, rec).ID)
+	expectStatus(t, env.do("POST", cheeseLots, adminToken, models.ProductLot{Code: "Q-1", ExpiresOn: today().AddDate(0, 0, 20)}), http.StatusCreated)
+
+	expectStatus(t, env.do("POST", orderPath+"/receive", adminToken, models.ReceivePurchaseOrderRequest{}), http.StatusBadRequest)
+	expectStatus(t, env.do("POST", orderPath+"/receive", adminToken, models.ReceivePurchaseOrderRequest{
+		Items: []models.ReceivePurchaseOrderItem{{ProductID: cheese.ID, Quantity: 2, LotCode: "Q-2"}},
+	}), http.StatusBadRequest)
+	expiresOn := today().AddDate(0, 0, 40)
+	rec = env.do("POST", orderPath+"/receive", adminToken, models.ReceivePurchaseOrderRequest{
+		Items: []models.ReceivePurchaseOrderItem{
+			{ProductID: cheese.ID, Quantity: 4, LotCode: "q-1"},
+			{ProductID: cheese.ID, Quantity: 2, LotCode: "Q-2", ExpiresOn: &expiresOn},
+		},
+	})
+	expectStatus(t, rec, http.StatusOK)
+	p = decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(cheese.ID), sellerToken, nil))
+	if p.Quantity != 6 || len(p.Lots) != 2 || p.Lots[0].Quantity != 4 || p.Lots[1].Code != "Q-2" || p.Lots[1].Quantity != 2 || !p.Lots[1].ExpiresOn.Equal(expiresOn) {
+		t.Fatalf("product after receipt = %+v", p)
+	}
+
+	rec = env.do("GET", "/api/v1/products/reconciliation", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if discrepancies := decode[[]models.StockDiscrepancy](t, rec); len(discrepancies) != 0 {
+		t.Fatalf("unexpected discrepancies: %+v", discrepancies)
+	}
+
+	// Lots with stock or sales stay
+	expectStatus(t, env.do("DELETE", lotsPath+"/"+itoa(later.ID), adminToken, nil), http.StatusConflict)
+	sooner.Quantity = 0
+	expectStatus(t, env.do("PUT", lotsPath+"/"+itoa(sooner.ID), adminToken, sooner), http.StatusOK)
+	expectStatus(t, env.do("DELETE", lotsPath+"/"+itoa(sooner.ID), adminToken, nil), http.StatusConflict)
+	expectStatus(t, env.do("DELETE", lotsPath+"/"+itoa(expired.ID), adminToken, nil), http.StatusNoContent)
+
+	// Units sold before the product had lots have no lot to go back to
+	butter := env.createProduct(adminToken, "Manteiga", "12.00", 1)
+	rec = env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+		Items:    []models.CreateSaleItem{{ProductID: butter.ID, Quantity: 1}},
+		Payments: pix(t, "12.00"),
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	butterSale := "/api/v1/sales/" + itoa(decode[models.CreateSaleResponse](t, rec).SaleID)
+	expectStatus(t, env.do("POST", "/api/v1/products/"+itoa(butter.ID)+"/lots", adminToken, models.ProductLot{Code: "M-1", ExpiresOn: today().AddDate(0, 0, 30), Quantity: 2}), http.StatusCreated)
+	expectStatus(t, env.do("POST", butterSale+"/cancel", adminToken, models.CancelSaleRequest{Reason: "Desistiu"}), http.StatusConflict)
+}
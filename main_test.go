@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"gestor-simples-ecs/internal/database/dbtest"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/internal/repository/memory"
+	"gestor-simples-ecs/internal/repository/postgres"
+	"gestor-simples-ecs/pkg/auth"
+	"gestor-simples-ecs/pkg/money"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testPassword is the password of every user created by testEnv.createUser.
+const testPassword = "secret"
+
+// testEnv is an API server running on an in-memory store, or on a Postgres
+// store of its own when DATABASE_URL is set (see dbtest).
+type testEnv struct {
+	t       *testing.T
+	store   repository.Store
+	srv     *server
+	handler http.Handler
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	t.Setenv("JWT_SECRET_KEY", "test-secret")
+	auth.Initialize()
+
+	var store repository.Store = memory.New()
+	if dbtest.Enabled() {
+		store = postgres.New(dbtest.Open(t))
+	}
+	srv := newServer(store)
+	auth.SetSessionChecker(srv.sessionIsActive)
+	auth.SetPermissionChecker(srv.roleHasPermission)
+
+	return &testEnv{t: t, store: store, srv: srv, handler: srv.routes()}
+}
+
+// createUser stores a user with testPassword, hashed cheaply to keep tests fast.
+func (e *testEnv) createUser(username, role string) models.User {
+	e.t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	user := models.User{Name: username, Username: username, PasswordHash: string(hash), Role: role}
+	if err := e.store.Users().Create(context.Background(), &user); err != nil {
+		e.t.Fatalf("creating user %s: %v", username, err)
+	}
+	user.PasswordHash = ""
+	return user
+}
+
+// token opens a session for the user and returns its access token.
+func (e *testEnv) token(user models.User) string {
+	e.t.Helper()
+	sessionID, err := e.store.Sessions().Create(context.Background(), user.ID, "hash-"+user.Username, time.Now().Add(time.Hour))
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	token, err := auth.GenerateJWT(user.ID, user.Role, sessionID)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return token
+}
+
+// createProduct adds a product through the API as an admin, so its
+// opening stock is recorded in the ledger.
+func (e *testEnv) createProduct(adminToken, name, price string, quantity int) models.Product {
+	e.t.Helper()
+	rec := e.do("POST", "/api/v1/products", adminToken, map[string]interface{}{
+		"name": name, "price": price, "quantity": quantity,
+	})
+	expectStatus(e.t, rec, http.StatusCreated)
+	return decode[models.Product](e.t, rec)
+}
+
+// do sends a request with an optional bearer token and JSON body.
+func (e *testEnv) do(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	e.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			e.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	e.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, want, rec.Body.String())
+	}
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body.String(), err)
+	}
+	return v
+}
+
+// pageOf decodes a models.Page whose data holds T values.
+func pageOf[T any](t *testing.T, rec *httptest.ResponseRecorder) ([]T, int) {
+	t.Helper()
+	var page struct {
+		Data  []T `json:"data"`
+		Total int `json:"total"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body.String(), err)
+	}
+	return page.Data, page.Total
+}
+
+func mustParse(t *testing.T, s string) money.Amount {
+	t.Helper()
+	a, err := money.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// pix pays the whole amount in a single PIX payment.
+func pix(t *testing.T, amount string) []models.Payment {
+	t.Helper()
+	return []models.Payment{{Method: models.PaymentMethodPix, Amount: mustParse(t, amount)}}
+}
+
+func itoa(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"gestor-simples-ecs/internal/database"
+	"log"
+	"os"
+	"strconv"
+)
+
+const migrateUsage = `usage: main migrate <command>
+
+commands:
+  up                 apply every pending migration
+  down [steps]       revert the latest applied migration, or the latest <steps>
+  status             list migrations and when they were applied
+  baseline <version> mark migrations up to <version> as applied without running them`
+
+// runMigrateCommand implements the `migrate` subcommand.
+func runMigrateCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println(migrateUsage)
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(database.DB)
+		for _, m := range applied {
+			log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(applied) == 0 {
+			log.Println("Database is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("Invalid number of steps: %s", args[1])
+			}
+			steps = n
+		}
+		reverted, err := database.MigrateDown(database.DB, steps)
+		for _, m := range reverted {
+			log.Printf("Reverted migration %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		if len(reverted) == 0 {
+			log.Println("No migrations to revert")
+		}
+
+	case "status":
+		statuses, err := database.MigrationStatuses(database.DB)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+
+	case "baseline":
+		if len(args) < 2 {
+			log.Fatal("baseline requires a version")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			log.Fatalf("Invalid version: %s", args[1])
+		}
+		if err := database.MigrateBaseline(database.DB, version); err != nil {
+			log.Fatalf("Baseline failed: %v", err)
+		}
+		log.Printf("Marked migrations up to %04d as applied", version)
+
+	default:
+		fmt.Println(migrateUsage)
+		os.Exit(2)
+	}
+}
+
+// autoMigrate applies pending migrations on startup when AUTO_MIGRATE is set.
+func autoMigrate() {
+	enabled, _ := strconv.ParseBool(os.Getenv("AUTO_MIGRATE"))
+	if !enabled {
+		return
+	}
+
+	applied, err := database.MigrateUp(database.DB)
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
+	if err != nil {
+		log.Fatalf("Auto-migration failed: %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/pkg/money"
+)
+
+// maxInstallments is the most installments a credit card payment may be split into.
+const maxInstallments = 12
+
+func validPaymentMethod(method string) bool {
+	for _, m := range models.PaymentMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// preparePayments validates a sale's payments, defaults credit card payments
+// to a single installment and works out the change on cash payments. It
+// returns the payments ready to store and their total. Payments may be
+// missing, for sales that come to nothing; placeSale checks the total.
+func preparePayments(payments []models.Payment) ([]models.Payment, money.Amount, error) {
+	prepared := make([]models.Payment, len(payments))
+	var total money.Amount
+	for i, p := range payments {
+		if !validPaymentMethod(p.Method) {
+			return nil, 0, fmt.Errorf("invalid payment method: %q", p.Method)
+		}
+		if p.Amount <= 0 {
+			return nil, 0, errors.New("payment amounts must be positive")
+		}
+
+		if p.Method == models.PaymentMethodCreditCard {
+			if p.Installments == 0 {
+				p.Installments = 1
+			}
+			if p.Installments < 1 || p.Installments > maxInstallments {
+				return nil, 0, fmt.Errorf("installments must be between 1 and %d", maxInstallments)
+			}
+		} else if p.Installments != 0 {
+			return nil, 0, errors.New("installments are only allowed on credit card payments")
+		}
+
+		p.Change = 0
+		if p.Method == models.PaymentMethodCash {
+			if p.Tendered == 0 {
+				p.Tendered = p.Amount
+			}
+			if p.Tendered < p.Amount {
+				return nil, 0, errors.New("cash tendered is less than the payment amount")
+			}
+			p.Change = p.Tendered - p.Amount
+		} else if p.Tendered != 0 {
+			return nil, 0, errors.New("only cash payments can have a tendered amount")
+		}
+
+		prepared[i] = p
+		total += p.Amount
+	}
+	return prepared, total, nil
+}
@@ -24,8 +24,9 @@ func Initialize() {
 
 // Claims defines the JWT claims.
 type Claims struct {
-	UserID int64  `json:"userId"`
-	Role   string `json:"role"`
+	UserID    int64  `json:"userId"`
+	Role      string `json:"role"`
+	SessionID int64  `json:"sid"`
 	jwt.StandardClaims
 }
 
@@ -41,14 +42,22 @@ func CheckPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-// GenerateJWT creates a new JWT token for a given user.
-func GenerateJWT(userID int64, role string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// GenerateJWT creates a new short-lived access token for a given user session.
+func GenerateJWT(userID int64, role string, sessionID int64) (string, error) {
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := &Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
+			Id:        tokenID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(AccessTokenTTL).Unix(),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -60,13 +69,13 @@ func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Missing authorization header", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, "Missing authorization header")
 			return
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == authHeader {
-			http.Error(w, "Invalid token format", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, "Invalid token format")
 			return
 		}
 
@@ -77,36 +86,36 @@ func AuthMiddleware(next http.Handler) http.Handler {
 
 		if err != nil {
 			if err == jwt.ErrSignatureInvalid {
-				http.Error(w, "Invalid token signature", http.StatusUnauthorized)
+				writeError(w, http.StatusUnauthorized, "Invalid token signature")
 				return
 			}
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, "Invalid token")
 			return
 		}
 
 		if !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, "Invalid token")
 			return
 		}
 
+		// Reject tokens whose session was logged out or revoked
+		if sessionChecker != nil {
+			active, err := sessionChecker(claims.SessionID)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, "Failed to verify session")
+				return
+			}
+			if !active {
+				writeError(w, http.StatusUnauthorized, "Session has been revoked")
+				return
+			}
+		}
+
 		// Pass claims to the next handler via context
 		ctx := context.WithValue(r.Context(), "user_id", claims.UserID)
 		ctx = context.WithValue(ctx, "role", claims.Role)
+		ctx = context.WithValue(ctx, "session_id", claims.SessionID)
 		
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
-
-// AdminMiddleware to protect routes that require admin role.
-func AdminMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// This middleware MUST run AFTER AuthMiddleware.
-		role, ok := r.Context().Value("role").(string)
-		if !ok || role != "admin" {
-			http.Error(w, "Admin role required", http.StatusForbidden)
-			return
-		}
-		
-		next.ServeHTTP(w, r)
-	})
-}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"log"
+	"net/http"
+)
+
+// Permissions granted to roles through the role_permissions table.
+const (
+	PermUsersRead           = "users:read"
+	PermUsersWrite          = "users:write"
+	PermRolesManage         = "roles:manage"
+	PermProductsWrite       = "products:write"
+	PermCustomersWrite      = "customers:write"
+	PermStockManage         = "stock:manage"
+	PermSalesCreate         = "sales:create"
+	PermSalesCreateOnBehalf = "sales:create_on_behalf"
+	PermSalesCancel         = "sales:cancel"
+	PermSalesViewAll        = "sales:view_all"
+	PermDiscountsApprove    = "discounts:approve"
+	PermPromotionsManage    = "promotions:manage"
+	PermReportsView         = "reports:view"
+)
+
+// PermissionChecker reports whether a role has been granted a permission.
+type PermissionChecker func(role, permission string) (bool, error)
+
+var permissionChecker PermissionChecker
+
+// SetPermissionChecker installs the function used to resolve role grants.
+// Grants are looked up on every request, so changes apply immediately.
+func SetPermissionChecker(checker PermissionChecker) {
+	permissionChecker = checker
+}
+
+// Can reports whether the authenticated user's role grants the permission.
+// Lookup failures are logged and treated as a denial.
+func Can(r *http.Request, permission string) bool {
+	role, ok := Role(r)
+	if !ok || permissionChecker == nil {
+		return false
+	}
+
+	granted, err := permissionChecker(role, permission)
+	if err != nil {
+		log.Printf("auth: checking permission %q for role %q: %v", permission, role, err)
+		return false
+	}
+	return granted
+}
+
+// RequirePermission protects routes that require the given permission.
+// This middleware MUST run AFTER AuthMiddleware.
+func RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !Can(r, permission) {
+				Forbidden(w, "Permission required: "+permission)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// UserID returns the authenticated user's ID set by AuthMiddleware.
+func UserID(r *http.Request) (int64, bool) {
+	id, ok := r.Context().Value("user_id").(int64)
+	return id, ok
+}
+
+// Role returns the authenticated user's role set by AuthMiddleware.
+func Role(r *http.Request) (string, bool) {
+	role, ok := r.Context().Value("role").(string)
+	return role, ok
+}
+
+// SessionID returns the session the access token belongs to, set by AuthMiddleware.
+func SessionID(r *http.Request) (int64, bool) {
+	id, ok := r.Context().Value("session_id").(int64)
+	return id, ok
+}
+
+// IsSelf reports whether the authenticated user is the one identified by id.
+func IsSelf(r *http.Request, id int64) bool {
+	userID, ok := UserID(r)
+	return ok && userID == id
+}
+
+// SelfOrPermissionMiddleware allows users holding the permission through, and
+// everyone else only when the route variable idVar names their own record.
+// This middleware MUST run AFTER AuthMiddleware.
+func SelfOrPermissionMiddleware(idVar, permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, err := strconv.ParseInt(mux.Vars(r)[idVar], 10, 64)
+			if err == nil && IsSelf(r, id) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !Can(r, permission) {
+				Forbidden(w, "You can only access your own record")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Forbidden writes a 403 response in the API's JSON error format.
+func Forbidden(w http.ResponseWriter, message string) {
+	writeError(w, http.StatusForbidden, message)
+}
+
+// writeError writes {"error": message}, the same shape the handlers use.
+func writeError(w http.ResponseWriter, code int, message string) {
+	response, _ := json.Marshal(map[string]string{"error": message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	// AccessTokenTTL is how long an access token (JWT) stays valid.
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is how long a session can go without being refreshed.
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// SessionChecker reports whether a session is still active, i.e. neither
+// revoked nor expired.
+type SessionChecker func(sessionID int64) (bool, error)
+
+var sessionChecker SessionChecker
+
+// SetSessionChecker installs the function AuthMiddleware uses to reject
+// access tokens whose session has been revoked.
+func SetSessionChecker(checker SessionChecker) {
+	sessionChecker = checker
+}
+
+// NewRefreshToken returns a random opaque refresh token and the hash that
+// should be stored in its place.
+func NewRefreshToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken hashes a refresh token for storage and lookup. Tokens are
+// high-entropy random values, so a fast hash is sufficient.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newTokenID returns a random identifier for the jti claim.
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
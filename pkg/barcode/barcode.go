@@ -0,0 +1,60 @@
+// Package barcode validates GTIN product barcodes: EAN-13, the most common
+// in Brazil, as well as GTIN-8 (EAN-8), GTIN-12 (UPC-A) and GTIN-14.
+package barcode
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalid is returned for values that are not a valid GTIN.
+var ErrInvalid = errors.New("invalid barcode")
+
+// Normalize strips spaces and hyphens from a barcode and checks its length
+// and GS1 check digit. It returns the digits in canonical form, so that the
+// same GTIN compares equal however it was typed: shorter codes are padded
+// with leading zeros to 13 digits, and a GTIN-14 loses its leading zero if
+// it has one. Only GTIN-14s with a packaging indicator keep 14 digits.
+func Normalize(s string) (string, error) {
+	digits := make([]byte, 0, 14)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == '-' || c == ' ':
+		default:
+			return "", ErrInvalid
+		}
+	}
+
+	switch len(digits) {
+	case 8, 12, 13, 14:
+	default:
+		return "", ErrInvalid
+	}
+	if checkDigit(digits[:len(digits)-1]) != digits[len(digits)-1]-'0' {
+		return "", ErrInvalid
+	}
+	// Leading zeros weigh nothing in the check digit
+	switch {
+	case len(digits) < 13:
+		return strings.Repeat("0", 13-len(digits)) + string(digits), nil
+	case len(digits) == 14 && digits[0] == '0':
+		return string(digits[1:]), nil
+	}
+	return string(digits), nil
+}
+
+// checkDigit computes the GS1 mod-10 check digit: digits are weighted 3 and
+// 1 alternately, starting with 3 at the rightmost one.
+func checkDigit(d []byte) byte {
+	sum := 0
+	for i := range d {
+		weight := 1
+		if (len(d)-i)%2 == 1 {
+			weight = 3
+		}
+		sum += int(d[i]-'0') * weight
+	}
+	return byte((10 - sum%10) % 10)
+}
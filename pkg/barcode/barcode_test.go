@@ -0,0 +1,30 @@
+package barcode
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"7891000100103", "7891000100103", true},
+		{"789-1000-10010-3", "7891000100103", true},
+		{"7891000100104", "", false},
+		{"4006381333931", "4006381333931", true},
+		{"96385074", "0000096385074", true},
+		{"036000291452", "0036000291452", true},
+		{"0036000291452", "0036000291452", true},
+		{"00036000291452", "0036000291452", true},
+		{"10012345678902", "10012345678902", true},
+		{"789100010010", "", false},
+		{"78910001001a3", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("Normalize(%q) = %q, %v; want %q, ok=%v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
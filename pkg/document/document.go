@@ -0,0 +1,92 @@
+// Package document validates Brazilian taxpayer IDs: CPF for individuals and
+// CNPJ for companies.
+package document
+
+import "errors"
+
+// ErrInvalid is returned for values that are neither a valid CPF nor a valid CNPJ.
+var ErrInvalid = errors.New("invalid CPF or CNPJ")
+
+// Normalize strips the usual punctuation from a CPF ("123.456.789-09") or
+// CNPJ ("12.345.678/0001-95") and checks its length and check digits. It
+// returns the bare digits.
+func Normalize(s string) (string, error) {
+	digits := make([]byte, 0, 14)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= '0' && c <= '9':
+			digits = append(digits, c)
+		case c == '.' || c == '-' || c == '/' || c == ' ':
+		default:
+			return "", ErrInvalid
+		}
+	}
+
+	switch len(digits) {
+	case 11:
+		if !validCPF(digits) {
+			return "", ErrInvalid
+		}
+	case 14:
+		if !validCNPJ(digits) {
+			return "", ErrInvalid
+		}
+	default:
+		return "", ErrInvalid
+	}
+	return string(digits), nil
+}
+
+// validCPF checks the two mod-11 check digits of an 11-digit CPF.
+func validCPF(d []byte) bool {
+	if repeated(d) {
+		return false
+	}
+	for n := 9; n <= 10; n++ {
+		sum := 0
+		for i := 0; i < n; i++ {
+			sum += int(d[i]-'0') * (n + 1 - i)
+		}
+		if checkDigit(sum) != d[n]-'0' {
+			return false
+		}
+	}
+	return true
+}
+
+// validCNPJ checks the two mod-11 check digits of a 14-digit CNPJ, whose
+// weights cycle from 9 down to 2 starting at the rightmost digit.
+func validCNPJ(d []byte) bool {
+	if repeated(d) {
+		return false
+	}
+	for n := 12; n <= 13; n++ {
+		sum := 0
+		for i := 0; i < n; i++ {
+			sum += int(d[i]-'0') * (2 + (n-1-i)%8)
+		}
+		if checkDigit(sum) != d[n]-'0' {
+			return false
+		}
+	}
+	return true
+}
+
+func checkDigit(sum int) byte {
+	r := sum % 11
+	if r < 2 {
+		return 0
+	}
+	return byte(11 - r)
+}
+
+// repeated reports whether every digit is the same; such numbers pass the
+// checksum but are never issued.
+func repeated(d []byte) bool {
+	for _, c := range d[1:] {
+		if c != d[0] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package document
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"529.982.247-25", "52998224725", true},
+		{"52998224725", "52998224725", true},
+		{"529.982.247-24", "", false},
+		{"111.111.111-11", "", false},
+		{"11.222.333/0001-81", "11222333000181", true},
+		{"11222333000181", "11222333000181", true},
+		{"11.222.333/0001-80", "", false},
+		{"00.000.000/0000-00", "", false},
+		{"1234567890", "", false},
+		{"529.982.247-2a", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("Normalize(%q) = %q, %v; want %q, ok=%v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount is an exact monetary value stored as an integer number of cents.
+//
+// It is encoded in JSON as a decimal string ("29.99") and stored in Postgres
+// as NUMERIC(12,2), so values never go through floating point.
+type Amount int64
+
+// ErrInvalidAmount is returned when a value cannot be represented exactly in cents.
+var ErrInvalidAmount = errors.New("invalid monetary amount")
+
+// FromCents builds an Amount from an integer number of cents.
+func FromCents(cents int64) Amount {
+	return Amount(cents)
+}
+
+// Cents returns the amount as an integer number of cents.
+func (a Amount) Cents() int64 {
+	return int64(a)
+}
+
+// Parse reads a decimal string such as "29.99", "-3.5" or "10".
+// Values with more than two decimal places are rejected.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, ErrInvalidAmount
+	}
+	// ParseInt would take a second sign inside either part
+	if !digits(whole) || !digits(frac) {
+		return 0, ErrInvalidAmount
+	}
+	if hasFrac {
+		// Postgres may render NUMERIC with trailing zeros beyond the scale.
+		frac = strings.TrimRight(frac, "0")
+	}
+	if len(frac) > 2 {
+		return 0, ErrInvalidAmount
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units < 0 {
+		return 0, ErrInvalidAmount
+	}
+	cents, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil || cents < 0 {
+		return 0, ErrInvalidAmount
+	}
+	if units > (math.MaxInt64-cents)/100 {
+		return 0, ErrInvalidAmount
+	}
+
+	total := units*100 + cents
+	if negative {
+		total = -total
+	}
+	return Amount(total), nil
+}
+
+// digits reports whether s holds nothing but ASCII digits.
+func digits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders the amount with exactly two decimal places.
+func (a Amount) String() string {
+	cents := int64(a)
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// Mul multiplies the amount by an integer quantity.
+func (a Amount) Mul(quantity int) Amount {
+	return a * Amount(quantity)
+}
+
+// MulRate multiplies the amount by num/den, rounding half away from zero.
+// For example, a 10% commission is a.MulRate(10, 100).
+func (a Amount) MulRate(num, den int64) Amount {
+	if den == 0 {
+		return 0
+	}
+	product := int64(a) * num
+	if (product < 0) != (den < 0) {
+		return Amount((product - den/2) / den)
+	}
+	return Amount((product + den/2) / den)
+}
+
+// Split divides the amount in proportion to weights. Shares are rounded on
+// the running total, so they add up to exactly a and, for non-negative
+// weights, none exceeds its weight when a does not exceed their sum. All
+// shares are zero if the weights add up to zero.
+func (a Amount) Split(weights []Amount) []Amount {
+	var total Amount
+	for _, w := range weights {
+		total += w
+	}
+
+	shares := make([]Amount, len(weights))
+	var running, given Amount
+	for i, w := range weights {
+		running += w
+		upTo := a.MulRate(int64(running), int64(total))
+		shares[i] = upTo - given
+		given = upTo
+	}
+	return shares
+}
+
+// MarshalJSON encodes the amount as a decimal string.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + a.String() + `"`), nil
+}
+
+// UnmarshalJSON accepts either a decimal string ("29.99") or a bare JSON
+// number (29.99). Numbers are parsed from their literal text, never as floats.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner for NUMERIC columns. NULL scans as zero so
+// amounts can be read straight from LEFT JOINs and empty aggregates.
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+		return nil
+	case []byte:
+		parsed, err := Parse(string(v))
+		if err != nil {
+			return err
+		}
+		*a = parsed
+		return nil
+	case string:
+		parsed, err := Parse(v)
+		if err != nil {
+			return err
+		}
+		*a = parsed
+		return nil
+	case int64:
+		*a = Amount(v * 100)
+		return nil
+	default:
+		return fmt.Errorf("money: cannot scan %T into Amount", src)
+	}
+}
+
+// Value implements driver.Valuer, sending the amount as a decimal string
+// that Postgres casts to NUMERIC without loss.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
@@ -0,0 +1,161 @@
+package money
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+		ok   bool
+	}{
+		{"29.99", 2999, true},
+		{"10", 1000, true},
+		{"-3.5", -350, true},
+		{"+3.50", 350, true},
+		{" 0.07 ", 7, true},
+		{".5", 50, true},
+		{"5.", 500, true},
+		{"12.3400", 1234, true},
+		{"-0.00", 0, true},
+		{"1.234", 0, false},
+		{"0.001", 0, false},
+		{"1.+5", 0, false},
+		{"1.-5", 0, false},
+		{"-+5", 0, false},
+		{"+-5", 0, false},
+		{"--5", 0, false},
+		{"1.2.3", 0, false},
+		{"1,50", 0, false},
+		{"R$ 5", 0, false},
+		{"1e3", 0, false},
+		{".", 0, false},
+		{"-", 0, false},
+		{"", 0, false},
+		{"92233720368547758.08", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("Parse(%q) = %d, %v; want %d, ok=%v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMulRate(t *testing.T) {
+	tests := []struct {
+		a        Amount
+		num, den int64
+		want     Amount
+	}{
+		{1000, 10, 100, 100},
+		{1005, 1, 2, 503},
+		{1003, 1, 2, 502},
+		{-1005, 1, 2, -503},
+		{1005, -1, 2, -503},
+		{1005, 1, -2, -503},
+		{-1005, -1, -2, -503},
+		{100, 1, 3, 33},
+		{200, 1, 3, 67},
+		{999, 0, 100, 0},
+		{999, 10, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.a.MulRate(tt.num, tt.den); got != tt.want {
+			t.Errorf("%d.MulRate(%d, %d) = %d, want %d", tt.a, tt.num, tt.den, got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		a       Amount
+		weights []Amount
+		want    []Amount
+	}{
+		{1000, []Amount{1, 1, 1}, []Amount{333, 334, 333}},
+		{100, []Amount{1, 1, 1}, []Amount{33, 34, 33}},
+		{7000, []Amount{2000, 3000}, []Amount{2800, 4200}},
+		{1, []Amount{1, 1}, []Amount{1, 0}},
+		{-1000, []Amount{1, 1, 1}, []Amount{-333, -334, -333}},
+		{500, []Amount{0, 5, 0}, []Amount{0, 500, 0}},
+		{500, []Amount{0, 0}, []Amount{0, 0}},
+		{500, nil, []Amount{}},
+	}
+	for _, tt := range tests {
+		got := tt.a.Split(tt.weights)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%d.Split(%v) = %v, want %v", tt.a, tt.weights, got, tt.want)
+		}
+		var total, sum Amount
+		for i := range got {
+			total += tt.weights[i]
+			sum += got[i]
+		}
+		if total != 0 && sum != tt.a {
+			t.Errorf("%d.Split(%v) adds up to %d", tt.a, tt.weights, sum)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want Amount
+		ok   bool
+	}{
+		{[]byte("29.99"), 2999, true},
+		{[]byte("-3.50"), -350, true},
+		{[]byte("12.340000"), 1234, true},
+		{"0.10", 10, true},
+		{int64(7), 700, true},
+		{nil, 0, true},
+		{[]byte("1.234"), 0, false},
+		{[]byte("abc"), 0, false},
+		{29.99, 0, false},
+	}
+	for _, tt := range tests {
+		a := Amount(-1)
+		err := a.Scan(tt.src)
+		if (err == nil) != tt.ok || (tt.ok && a != tt.want) {
+			t.Errorf("Scan(%#v) = %d, %v; want %d, ok=%v", tt.src, a, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+		ok   bool
+	}{
+		{`"29.99"`, 2999, true},
+		{`29.99`, 2999, true},
+		{`"-3.5"`, -350, true},
+		{`10`, 1000, true},
+		{`0.1`, 10, true},
+		{`null`, 0, true},
+		{`"1.234"`, 0, false},
+		{`1.234`, 0, false},
+		{`1e2`, 0, false},
+		{`"abc"`, 0, false},
+		{`true`, 0, false},
+	}
+	for _, tt := range tests {
+		var v struct {
+			Price Amount `json:"price"`
+		}
+		err := json.Unmarshal([]byte(`{"price":`+tt.in+`}`), &v)
+		if (err == nil) != tt.ok || (tt.ok && v.Price != tt.want) {
+			t.Errorf("Unmarshal(%s) = %d, %v; want %d, ok=%v", tt.in, v.Price, err, tt.want, tt.ok)
+		}
+	}
+
+	data, err := json.Marshal(Amount(-1205))
+	if err != nil || string(data) != `"-12.05"` {
+		t.Errorf("Marshal(-1205) = %s, %v", data, err)
+	}
+}
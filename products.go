@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/auth"
+	"gestor-simples-ecs/pkg/barcode"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// --- Product Handlers ---
+
+func (s *server) getProductsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sortParam := r.URL.Query().Get("sort")
+	if !repository.ValidProductSort(sortParam) {
+		respondWithError(w, http.StatusBadRequest, "sort must be one of name, price or quantity, optionally prefixed with -")
+		return
+	}
+
+	filter := repository.ProductFilter{
+		Search: strings.TrimSpace(r.URL.Query().Get("search")),
+		Sort:   sortParam,
+		Limit:  limit,
+		Offset: offset,
+	}
+	// A category also lists the products of its subcategories
+	if v := r.URL.Query().Get("category"); v != "" {
+		categoryID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "category must be a number")
+			return
+		}
+		filter.CategoryID = &categoryID
+	}
+
+	products, total, err := s.store.Products().List(r.Context(), filter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query products")
+		return
+	}
+	if err := s.withDetails(r.Context(), products); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query product variants, lots and components")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.Page{Data: products, Total: total, Limit: limit, Offset: offset})
+}
+
+// withDetails fills in the variants, lots and bundle components of products.
+func (s *server) withDetails(ctx context.Context, products []models.Product) error {
+	err := withChildren(ctx, products, s.store.Variants().List,
+		func(v models.ProductVariant) int64 { return v.ProductID },
+		func(p *models.Product, v models.ProductVariant) { p.Variants = append(p.Variants, v) })
+	if err != nil {
+		return err
+	}
+	err = withChildren(ctx, products, s.store.Lots().List,
+		func(l models.ProductLot) int64 { return l.ProductID },
+		func(p *models.Product, l models.ProductLot) { p.Lots = append(p.Lots, l) })
+	if err != nil {
+		return err
+	}
+	return withComponents(ctx, s.store.Bundles(), products)
+}
+
+// withChildren loads the child rows of products with list and hands each to
+// add along with the product its productID names.
+func withChildren[T any](ctx context.Context, products []models.Product, list func(context.Context, ...int64) ([]T, error), productID func(T) int64, add func(*models.Product, T)) error {
+	ids := make([]int64, len(products))
+	index := make(map[int64]int, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+		index[p.ID] = i
+	}
+	rows, err := list(ctx, ids...)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if i, ok := index[productID(row)]; ok {
+			add(&products[i], row)
+		}
+	}
+	return nil
+}
+
+// normalizeProductCodes puts the SKU in upper case and the barcode in bare
+// digits, checking the barcode's check digit.
+func normalizeProductCodes(p *models.Product) error {
+	sku, err := normalizeSKU(p.SKU)
+	if err != nil {
+		return err
+	}
+	p.SKU = sku
+	if p.Barcode = strings.TrimSpace(p.Barcode); p.Barcode != "" {
+		digits, err := barcode.Normalize(p.Barcode)
+		if err != nil {
+			return errors.New("invalid barcode: expected an EAN-13, EAN-8, UPC-A or GTIN-14 with a valid check digit")
+		}
+		p.Barcode = digits
+	}
+	return nil
+}
+
+// normalizeSKU trims the SKU and puts it in upper case, the form it is
+// stored and looked up in.
+func normalizeSKU(sku string) (string, error) {
+	sku = strings.ToUpper(strings.TrimSpace(sku))
+	if strings.ContainsAny(sku, " \t") {
+		return "", errors.New("SKU cannot contain spaces")
+	}
+	return sku, nil
+}
+
+// productByCode finds a product by its barcode or, without one, its SKU.
+// It returns barcode.ErrInvalid for malformed barcodes and
+// repository.ErrNotFound if no product has the code.
+func productByCode(ctx context.Context, products repository.ProductRepository, code, sku string) (models.Product, error) {
+	if code != "" {
+		digits, err := barcode.Normalize(code)
+		if err != nil {
+			return models.Product{}, err
+		}
+		return products.GetByBarcode(ctx, digits)
+	}
+	sku, err := normalizeSKU(sku)
+	if err != nil || sku == "" {
+		return models.Product{}, repository.ErrNotFound
+	}
+	return products.GetBySKU(ctx, sku)
+}
+
+// getProductByCodeHandler finds the product with ?barcode= or ?sku=, as
+// read by a scanner at the counter.
+func (s *server) getProductByCodeHandler(w http.ResponseWriter, r *http.Request) {
+	code, sku := r.URL.Query().Get("barcode"), r.URL.Query().Get("sku")
+	if (code == "") == (sku == "") {
+		respondWithError(w, http.StatusBadRequest, "Either barcode or sku is required")
+		return
+	}
+
+	p, err := productByCode(r.Context(), s.store.Products(), code, sku)
+	if errors.Is(err, barcode.ErrInvalid) {
+		respondWithError(w, http.StatusBadRequest, "Invalid barcode")
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to look up product")
+		return
+	}
+	products := []models.Product{p}
+	if err := s.withDetails(r.Context(), products); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query product variants, lots and components")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, products[0])
+}
+
+func (s *server) createProductHandler(w http.ResponseWriter, r *http.Request) {
+	var p models.Product
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if p.Quantity < 0 {
+		respondWithError(w, http.StatusBadRequest, "Quantity cannot be negative")
+		return
+	}
+	if p.Price < 0 {
+		respondWithError(w, http.StatusBadRequest, "Price cannot be negative")
+		return
+	}
+	if p.CostPrice < 0 {
+		respondWithError(w, http.StatusBadRequest, "Cost price cannot be negative")
+		return
+	}
+	if err := normalizeProductCodes(&p); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userID, _ := auth.UserID(r)
+
+	err := s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		err := tx.Products().Create(r.Context(), &p)
+		if errors.Is(err, repository.ErrConflict) {
+			return newAPIError(http.StatusConflict, "A product with this SKU or barcode already exists")
+		}
+		if errors.Is(err, repository.ErrInUse) {
+			return newAPIError(http.StatusBadRequest, "Category not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to create product")
+		}
+
+		if p.Quantity != 0 {
+			if err := recordStockMovement(r.Context(), tx, p.ID, nil, userID, models.StockMovementInitial, p.Quantity, "Initial stock", nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, p)
+}
+
+func (s *server) getProductHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	p, err := s.store.Products().Get(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	products := []models.Product{p}
+	if err := s.withDetails(r.Context(), products); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query product variants, lots and components")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, products[0])
+}
+
+func (s *server) updateProductHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	userID, _ := auth.UserID(r)
+
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		// Lock the row so the recorded adjustment matches the quantity we overwrite
+		current, err := tx.Products().GetForUpdate(r.Context(), id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusNotFound, "Product not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product")
+		}
+
+		// Fields left out of the body keep their current values. The cost
+		// price is not the client's to set: only receipts change it.
+		p := current
+		if current.CategoryID != nil {
+			// Decoding writes through the pointer; don't let it reach current
+			categoryID := *current.CategoryID
+			p.CategoryID = &categoryID
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return newAPIError(http.StatusBadRequest, "Invalid request payload")
+		}
+		p.ID, p.CostPrice = id, current.CostPrice
+		if p.Quantity < 0 {
+			return newAPIError(http.StatusBadRequest, "Quantity cannot be negative")
+		}
+		if p.Price < 0 {
+			return newAPIError(http.StatusBadRequest, "Price cannot be negative")
+		}
+		if err := normalizeProductCodes(&p); err != nil {
+			return newAPIError(http.StatusBadRequest, err.Error())
+		}
+		if p.Quantity != current.Quantity {
+			if err := rejectBundle(r.Context(), tx, current, "its stock is that of its components"); err != nil {
+				return err
+			}
+			if err := rejectLots(r.Context(), tx, current, "change the quantity of its lots instead"); err != nil {
+				return err
+			}
+			found, err := hasVariants(r.Context(), tx, id)
+			if err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to load product variants")
+			}
+			if found {
+				return newAPIError(http.StatusBadRequest, "The quantity of a product with variants is the sum of theirs; change the variants instead")
+			}
+		}
+
+		err = tx.Products().Update(r.Context(), p)
+		if errors.Is(err, repository.ErrConflict) {
+			return newAPIError(http.StatusConflict, "A product with this SKU or barcode already exists")
+		}
+		if errors.Is(err, repository.ErrInUse) {
+			return newAPIError(http.StatusBadRequest, "Category not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to update product")
+		}
+
+		if delta := p.Quantity - current.Quantity; delta != 0 {
+			return recordStockMovement(r.Context(), tx, id, nil, userID, models.StockMovementAdjustment, delta, "Quantity changed on product update", nil)
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+}
+
+func (s *server) deleteProductHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	err = s.store.Products().Delete(r.Context(), id)
+	if errors.Is(err, repository.ErrInUse) {
+		respondWithError(w, http.StatusConflict, "Product has sales, quotes or purchase orders or goes into a bundle and cannot be deleted")
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete product")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"gestor-simples-ecs/internal/models"
+	"net/http"
+	"testing"
+)
+
+func TestCreateProductRecordsInitialStock(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+
+	rec := env.do("POST", "/api/v1/products", sellerToken, map[string]interface{}{"name": "Caneta", "price": "2.50", "quantity": 5})
+	expectStatus(t, rec, http.StatusForbidden)
+	rec = env.do("POST", "/api/v1/products", adminToken, map[string]interface{}{"name": "Caneta", "price": "2.50", "quantity": -1})
+	expectStatus(t, rec, http.StatusBadRequest)
+	rec = env.do("POST", "/api/v1/products", adminToken, map[string]interface{}{"name": "Caneta", "price": "-2.50", "quantity": 5})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	product := env.createProduct(adminToken, "Caneta", "2.50", 5)
+	if product.Price != mustParse(t, "2.50") {
+		t.Fatalf("price = %s", product.Price)
+	}
+	rec = env.do("PUT", "/api/v1/products/"+itoa(product.ID), adminToken, map[string]interface{}{"price": "-2.50"})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	rec = env.do("GET", "/api/v1/products/"+itoa(product.ID)+"/movements", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	movements := decode[[]models.StockMovement](t, rec)
+	if len(movements) != 1 || movements[0].Type != models.StockMovementInitial || movements[0].QuantityDelta != 5 {
+		t.Fatalf("unexpected movements: %+v", movements)
+	}
+}
+
+func TestProductStockMovements(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	product := env.createProduct(adminToken, "Caneta", "2.50", 5)
+	path := "/api/v1/products/" + itoa(product.ID)
+
+	// Editing the quantity records an adjustment for the difference
+	rec := env.do("PUT", path, adminToken, map[string]interface{}{"name": "Caneta azul", "price": "3.00", "quantity": 8})
+	expectStatus(t, rec, http.StatusOK)
+
+	rec = env.do("POST", path+"/movements", adminToken, models.CreateStockMovementRequest{
+		Type: models.StockMovementPurchaseReceipt, Quantity: 12, Reason: "NF 123",
+	})
+	expectStatus(t, rec, http.StatusCreated)
+
+	rec = env.do("POST", path+"/movements", adminToken, models.CreateStockMovementRequest{
+		Type: models.StockMovementInventoryCount, Quantity: 17, Reason: "Contagem mensal",
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	if m := decode[models.StockMovement](t, rec); m.QuantityDelta != -3 {
+		t.Fatalf("inventory count delta = %d, want -3", m.QuantityDelta)
+	}
+
+	rec = env.do("POST", path+"/movements", adminToken, models.CreateStockMovementRequest{
+		Type: models.StockMovementAdjustment, Quantity: -18, Reason: "Quebra",
+	})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	rec = env.do("GET", path, adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[models.Product](t, rec); got.Quantity != 17 || got.Name != "Caneta azul" {
+		t.Fatalf("unexpected product: %+v", got)
+	}
+
+	rec = env.do("GET", path+"/movements", adminToken, nil)
+	if movements := decode[[]models.StockMovement](t, rec); len(movements) != 4 || movements[0].Type != models.StockMovementInventoryCount {
+		t.Fatalf("unexpected movements: %+v", movements)
+	}
+
+	rec = env.do("GET", "/api/v1/products/reconciliation", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if discrepancies := decode[[]models.StockDiscrepancy](t, rec); len(discrepancies) != 0 {
+		t.Fatalf("unexpected discrepancies: %+v", discrepancies)
+	}
+}
+
+func TestListProducts(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	env.createProduct(adminToken, "Caneta azul", "2.50", 5)
+	env.createProduct(adminToken, "Caderno", "15.90", 3)
+	env.createProduct(adminToken, "Caneta preta", "2.75", 0)
+
+	rec := env.do("GET", "/api/v1/products?search=caneta&sort=-price", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	products, total := pageOf[models.Product](t, rec)
+	if total != 2 || len(products) != 2 || products[0].Name != "Caneta preta" {
+		t.Fatalf("got %d of %d products: %+v", len(products), total, products)
+	}
+
+	rec = env.do("GET", "/api/v1/products?sort=name&limit=1&offset=1", adminToken, nil)
+	products, total = pageOf[models.Product](t, rec)
+	if total != 3 || len(products) != 1 || products[0].Name != "Caneta azul" {
+		t.Fatalf("got %d of %d products: %+v", len(products), total, products)
+	}
+
+	expectStatus(t, env.do("GET", "/api/v1/products?sort=id", adminToken, nil), http.StatusBadRequest)
+}
+
+func TestDeleteProduct(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	product := env.createProduct(adminToken, "Caneta", "2.50", 5)
+	path := "/api/v1/products/" + itoa(product.ID)
+
+	expectStatus(t, env.do("DELETE", path, adminToken, nil), http.StatusNoContent)
+	expectStatus(t, env.do("GET", path, adminToken, nil), http.StatusNotFound)
+	expectStatus(t, env.do("DELETE", path, adminToken, nil), http.StatusNotFound)
+}
+
+func TestProductCodes(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+
+	rec := env.do("POST", "/api/v1/products", adminToken, map[string]interface{}{
+		"name": "Café 500g", "price": "18.90", "quantity": 10, "sku": " caf-500 ", "barcode": "789-1000-10010-3",
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	coffee := decode[models.Product](t, rec)
+	if coffee.SKU != "CAF-500" || coffee.Barcode != "7891000100103" {
+		t.Fatalf("codes not normalized: sku %q, barcode %q", coffee.SKU, coffee.Barcode)
+	}
+
+	for name, body := range map[string]map[string]interface{}{
+		"bad check digit": {"name": "x", "price": "1.00", "barcode": "7891000100104"},
+		"bad length":      {"name": "x", "price": "1.00", "barcode": "789100010010"},
+		"sku with spaces": {"name": "x", "price": "1.00", "sku": "CAF 500"},
+	} {
+		if rec := env.do("POST", "/api/v1/products", adminToken, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400; body: %s", name, rec.Code, rec.Body.String())
+		}
+	}
+	for name, body := range map[string]map[string]interface{}{
+		"same sku":     {"name": "x", "price": "1.00", "sku": "Caf-500"},
+		"same barcode": {"name": "x", "price": "1.00", "barcode": "7891000100103"},
+	} {
+		if rec := env.do("POST", "/api/v1/products", adminToken, body); rec.Code != http.StatusConflict {
+			t.Errorf("%s: status = %d, want 409; body: %s", name, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec = env.do("GET", "/api/v1/products/lookup?barcode=7891000100103", sellerToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[models.Product](t, rec); got.ID != coffee.ID {
+		t.Fatalf("lookup by barcode returned product %d, want %d", got.ID, coffee.ID)
+	}
+	rec = env.do("GET", "/api/v1/products/lookup?sku=caf-500", sellerToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	expectStatus(t, env.do("GET", "/api/v1/products/lookup?barcode=4006381333931", sellerToken, nil), http.StatusNotFound)
+	expectStatus(t, env.do("GET", "/api/v1/products/lookup?barcode=4006381333930", sellerToken, nil), http.StatusBadRequest)
+	expectStatus(t, env.do("GET", "/api/v1/products/lookup", sellerToken, nil), http.StatusBadRequest)
+
+	// Updates that leave the codes out keep them
+	expectStatus(t, env.do("PUT", "/api/v1/products/"+itoa(coffee.ID), adminToken, map[string]string{"price": "18.90"}), http.StatusOK)
+	expectStatus(t, env.do("GET", "/api/v1/products/lookup?barcode=7891000100103", sellerToken, nil), http.StatusOK)
+	expectStatus(t, env.do("GET", "/api/v1/products/lookup?sku=CAF-500", sellerToken, nil), http.StatusOK)
+
+	// A UPC-A and its EAN-13 form are the same barcode
+	rec = env.do("POST", "/api/v1/products", adminToken, map[string]interface{}{"name": "Biscoito", "price": "4.50", "barcode": "036000291452"})
+	expectStatus(t, rec, http.StatusCreated)
+	biscuit := decode[models.Product](t, rec)
+	expectStatus(t, env.do("POST", "/api/v1/products", adminToken, map[string]interface{}{"name": "x", "price": "1.00", "barcode": "0036000291452"}), http.StatusConflict)
+	rec = env.do("GET", "/api/v1/products/lookup?barcode=0036000291452", sellerToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[models.Product](t, rec); got.ID != biscuit.ID || got.Barcode != "0036000291452" {
+		t.Fatalf("lookup by EAN-13 of a UPC-A returned %+v", got)
+	}
+
+	// Sale items can name their product by barcode or SKU
+	rec = env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+		Items:    []models.CreateSaleItem{{Barcode: "7891000100103", Quantity: 1}, {SKU: "caf-500", Quantity: 2}},
+		Payments: pix(t, "56.70"),
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	if got := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(coffee.ID), adminToken, nil)); got.Quantity != 7 {
+		t.Fatalf("quantity after sale = %d, want 7", got.Quantity)
+	}
+	rec = env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+		Items:    []models.CreateSaleItem{{SKU: "CHA-100", Quantity: 1}},
+		Payments: pix(t, "18.90"),
+	})
+	expectStatus(t, rec, http.StatusBadRequest)
+	rec = env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+		Items:    []models.CreateSaleItem{{ProductID: coffee.ID, SKU: "CAF-500", Quantity: 1}},
+		Payments: pix(t, "18.90"),
+	})
+	expectStatus(t, rec, http.StatusBadRequest)
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/money"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// --- Promotion Handlers ---
+
+func (s *server) getPromotionsHandler(w http.ResponseWriter, r *http.Request) {
+	promotions, err := s.store.Promotions().List(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query promotions")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, promotions)
+}
+
+// decodePromotion reads and validates a promotion payload. Promotions are
+// active unless the payload says otherwise. It writes the error response itself.
+func decodePromotion(w http.ResponseWriter, r *http.Request) (models.Promotion, bool) {
+	p := models.Promotion{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return p, false
+	}
+	if err := validatePromotion(&p); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return p, false
+	}
+	return p, true
+}
+
+// validatePromotion checks a promotion and normalizes its name, code,
+// category and happy hour.
+func validatePromotion(p *models.Promotion) error {
+	if p.Name = strings.TrimSpace(p.Name); p.Name == "" {
+		return errors.New("name is required")
+	}
+	p.Code = strings.ToUpper(strings.TrimSpace(p.Code))
+	if strings.ContainsAny(p.Code, " \t") {
+		return errors.New("code cannot contain spaces")
+	}
+
+	switch p.Type {
+	case models.PromotionTypePercentage:
+		if p.Percentage <= 0 || p.Percentage.Cents() > hundredPercent {
+			return errors.New("percentage must be greater than 0 and at most 100")
+		}
+		if p.BuyQuantity != 0 || p.FreeQuantity != 0 {
+			return errors.New("buyQuantity and freeQuantity only apply to buy_x_get_y promotions")
+		}
+	case models.PromotionTypeBuyXGetY:
+		if p.BuyQuantity < 1 || p.FreeQuantity < 1 {
+			return errors.New("buyQuantity and freeQuantity must be at least 1")
+		}
+		if p.Percentage != 0 {
+			return errors.New("percentage only applies to percentage promotions")
+		}
+	default:
+		return fmt.Errorf("invalid promotion type: %q", p.Type)
+	}
+
+	if p.ProductID != nil && p.CategoryID != nil {
+		return errors.New("a promotion can target a product or a category, not both")
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return errors.New("endsAt must be after startsAt")
+	}
+	if (p.HappyHourStart == "") != (p.HappyHourEnd == "") {
+		return errors.New("happyHourStart and happyHourEnd must be given together")
+	}
+	if p.HappyHourStart != "" {
+		start, err1 := time.Parse("15:04", p.HappyHourStart)
+		end, err2 := time.Parse("15:04", p.HappyHourEnd)
+		if err1 != nil || err2 != nil {
+			return errors.New("happy hour times must be formatted as HH:MM")
+		}
+		if start.Equal(end) {
+			return errors.New("happy hour must not start and end at the same time")
+		}
+		// Normalized so the times compare as strings
+		p.HappyHourStart, p.HappyHourEnd = start.Format("15:04"), end.Format("15:04")
+	}
+	if p.UsageLimit != nil && *p.UsageLimit < 1 {
+		return errors.New("usageLimit must be at least 1")
+	}
+	return nil
+}
+
+func (s *server) createPromotionHandler(w http.ResponseWriter, r *http.Request) {
+	p, ok := decodePromotion(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.store.Promotions().Create(r.Context(), &p)
+	if errors.Is(err, repository.ErrConflict) {
+		respondWithError(w, http.StatusConflict, "A promotion with this code already exists")
+		return
+	}
+	if errors.Is(err, repository.ErrInUse) {
+		respondWithError(w, http.StatusBadRequest, "Product or category not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create promotion")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, p)
+}
+
+func (s *server) getPromotionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid promotion ID")
+		return
+	}
+
+	p, err := s.store.Promotions().Get(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Promotion not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load promotion")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, p)
+}
+
+func (s *server) updatePromotionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid promotion ID")
+		return
+	}
+
+	p, ok := decodePromotion(w, r)
+	if !ok {
+		return
+	}
+	p.ID = id
+
+	err = s.store.Promotions().Update(r.Context(), p)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Promotion not found")
+		return
+	}
+	if errors.Is(err, repository.ErrConflict) {
+		respondWithError(w, http.StatusConflict, "A promotion with this code already exists")
+		return
+	}
+	if errors.Is(err, repository.ErrInUse) {
+		respondWithError(w, http.StatusBadRequest, "Product or category not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to update promotion")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+}
+
+func (s *server) deletePromotionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid promotion ID")
+		return
+	}
+
+	err = s.store.Promotions().Delete(r.Context(), id)
+	if errors.Is(err, repository.ErrInUse) {
+		respondWithError(w, http.StatusConflict, "Promotion was applied to sales and cannot be deleted; deactivate it instead")
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Promotion not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete promotion")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getPromotionReportHandler returns revenue and discount given per promotion,
+// optionally limited to sales between ?startDate= and ?endDate=.
+func (s *server) getPromotionReportHandler(w http.ResponseWriter, r *http.Request) {
+	from, until, err := parseDateRange(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := s.store.Promotions().Report(r.Context(), from, until)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to build promotion report")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// --- Promotion evaluation ---
+
+// runsAt reports whether t falls within the promotion's happy hour, if it
+// has one, as read on the store's clock.
+func runsAt(p models.Promotion, t time.Time) bool {
+	if p.HappyHourStart == "" {
+		return true
+	}
+	clock := t.In(storeLocation).Format("15:04")
+	if p.HappyHourStart < p.HappyHourEnd {
+		return p.HappyHourStart <= clock && clock < p.HappyHourEnd
+	}
+	// The window wraps past midnight
+	return clock >= p.HappyHourStart || clock < p.HappyHourEnd
+}
+
+// salePromotions returns the promotions a sale made at now may use: those
+// running without a code, plus the coupon matching couponCode, if given.
+// An unknown or unusable coupon is an error.
+func salePromotions(ctx context.Context, tx repository.Store, couponCode string, now time.Time) ([]models.Promotion, error) {
+	valid, err := tx.Promotions().ListValid(ctx, now)
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "Failed to load promotions")
+	}
+
+	couponCode = strings.ToUpper(strings.TrimSpace(couponCode))
+	couponFound := false
+	promotions := []models.Promotion{}
+	for _, p := range valid {
+		if !runsAt(p, now) || (p.Code != "" && p.Code != couponCode) {
+			continue
+		}
+		couponFound = couponFound || p.Code != ""
+		promotions = append(promotions, p)
+	}
+	if couponCode != "" && !couponFound {
+		return nil, newAPIError(http.StatusBadRequest, "Invalid or expired coupon")
+	}
+	return promotions, nil
+}
+
+// promotionDiscount returns how much the promotion takes off quantity units
+// of the product, or 0 if it does not match the product. categories holds
+// the product's category and its ancestors, so category promotions also
+// cover subcategories.
+func promotionDiscount(p models.Promotion, product models.Product, categories []int64, quantity int) money.Amount {
+	if p.ProductID != nil && *p.ProductID != product.ID {
+		return 0
+	}
+	if p.CategoryID != nil && !slices.Contains(categories, *p.CategoryID) {
+		return 0
+	}
+
+	if p.Type == models.PromotionTypeBuyXGetY {
+		free := quantity / (p.BuyQuantity + p.FreeQuantity) * p.FreeQuantity
+		return product.Price.Mul(free)
+	}
+	return product.Price.Mul(quantity).MulRate(p.Percentage.Cents(), hundredPercent)
+}
+
+// bestPromotion picks the promotion taking the most off a sale line. Lines
+// get at most one promotion; ties go to the oldest. It returns nil if none
+// of them gives anything.
+func bestPromotion(promotions []models.Promotion, product models.Product, categories []int64, quantity int) (*models.Promotion, money.Amount) {
+	var (
+		best     *models.Promotion
+		discount money.Amount
+	)
+	for i := range promotions {
+		if d := promotionDiscount(promotions[i], product, categories, quantity); d > discount {
+			best, discount = &promotions[i], d
+		}
+	}
+	return best, discount
+}
+
+func containsPromotion(promotions []models.Promotion, id int64) bool {
+	for _, p := range promotions {
+		if p.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// appliesCoupon reports whether any of the applied promotions is a coupon.
+func appliesCoupon(applied []models.Promotion) bool {
+	for _, p := range applied {
+		if p.Code != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"gestor-simples-ecs/internal/models"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPromotions(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+	beer := env.createProduct(adminToken, "Cerveja", "5.00", 100)
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 100)
+	notebook := env.createProduct(adminToken, "Caderno", "15.90", 50)
+	// The promotions below target Bebidas, so they must reach its subcategories
+	drinksCategory := decode[models.Category](t, env.do("POST", "/api/v1/categories", adminToken, models.Category{Name: "Bebidas"}))
+	beers := decode[models.Category](t, env.do("POST", "/api/v1/categories", adminToken, models.Category{Name: "Cervejas", ParentID: &drinksCategory.ID}))
+	beer.CategoryID = &beers.ID
+	expectStatus(t, env.do("PUT", "/api/v1/products/"+itoa(beer.ID), adminToken, beer), http.StatusOK)
+
+	create := func(p models.Promotion) models.Promotion {
+		t.Helper()
+		p.Active = true
+		rec := env.do("POST", "/api/v1/promotions", adminToken, p)
+		expectStatus(t, rec, http.StatusCreated)
+		return decode[models.Promotion](t, rec)
+	}
+	one := 1
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+	drinks := create(models.Promotion{Name: "Bebidas 10%", Type: models.PromotionTypePercentage, Percentage: mustParse(t, "10"), CategoryID: &drinksCategory.ID})
+	threeForTwo := create(models.Promotion{Name: "Leve 3 pague 2", Type: models.PromotionTypeBuyXGetY, BuyQuantity: 2, FreeQuantity: 1, ProductID: &pen.ID})
+	coupon := create(models.Promotion{Name: "Volta às aulas", Type: models.PromotionTypePercentage, Percentage: mustParse(t, "20"), Code: " volta10 ", UsageLimit: &one})
+	happyHour := create(models.Promotion{
+		Name: "Happy hour", Type: models.PromotionTypePercentage, Percentage: mustParse(t, "50"), CategoryID: &drinksCategory.ID,
+		HappyHourStart: now.Add(time.Hour).Format("15:04"), HappyHourEnd: now.Add(2 * time.Hour).Format("15:04"),
+	})
+	create(models.Promotion{Name: "Encerrada", Type: models.PromotionTypePercentage, Percentage: mustParse(t, "90"), EndsAt: &yesterday})
+	penCoupon := create(models.Promotion{Name: "Caneta 5%", Type: models.PromotionTypePercentage, Percentage: mustParse(t, "5"), Code: "CANETA5", ProductID: &pen.ID})
+	if coupon.Code != "VOLTA10" {
+		t.Fatalf("coupon code = %q, want VOLTA10", coupon.Code)
+	}
+
+	for name, p := range map[string]models.Promotion{
+		"product and category": {Name: "x", Type: models.PromotionTypePercentage, Percentage: mustParse(t, "5"), ProductID: &pen.ID, CategoryID: &drinksCategory.ID},
+		"percentage over 100":  {Name: "x", Type: models.PromotionTypePercentage, Percentage: mustParse(t, "101")},
+		"no free units":        {Name: "x", Type: models.PromotionTypeBuyXGetY, BuyQuantity: 2},
+		"half a happy hour":    {Name: "x", Type: models.PromotionTypePercentage, Percentage: mustParse(t, "5"), HappyHourStart: "18:00"},
+		"unknown type":         {Name: "x", Type: "bogus"},
+	} {
+		if rec := env.do("POST", "/api/v1/promotions", adminToken, p); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400; body: %s", name, rec.Code, rec.Body.String())
+		}
+	}
+	expectStatus(t, env.do("POST", "/api/v1/promotions", adminToken, models.Promotion{Name: "x", Type: models.PromotionTypePercentage, Percentage: mustParse(t, "5"), Code: "VOLTA10"}), http.StatusConflict)
+	expectStatus(t, env.do("POST", "/api/v1/promotions", sellerToken, models.Promotion{Name: "x", Type: models.PromotionTypePercentage, Percentage: mustParse(t, "5")}), http.StatusForbidden)
+
+	// 3 beers at 10% off and 7 pens with 2 of them free
+	rec := env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+		Items: []models.CreateSaleItem{
+			{ProductID: beer.ID, Quantity: 3},
+			{ProductID: pen.ID, Quantity: 7},
+		},
+		Payments: pix(t, "26.00"),
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	rec = env.do("GET", "/api/v1/sales/"+itoa(decode[models.CreateSaleResponse](t, rec).SaleID), sellerToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	sale := decode[models.SaleDetail](t, rec)
+	if got := sale.Items[0]; got.PromotionID == nil || *got.PromotionID != drinks.ID || got.PromotionDiscount != mustParse(t, "1.50") {
+		t.Errorf("beer line = %+v, want the drinks promotion taking 1.50", got)
+	}
+	if got := sale.Items[1]; got.PromotionID == nil || *got.PromotionID != threeForTwo.ID || got.Discount != mustParse(t, "5.00") {
+		t.Errorf("pen line = %+v, want the 3-for-2 promotion taking 5.00", got)
+	}
+	if sale.TotalPrice != mustParse(t, "26.00") {
+		t.Errorf("total = %s, want 26.00", sale.TotalPrice)
+	}
+
+	// The coupon takes 20%, beyond the seller's own limit, without approval
+	sellWithCoupon := func(code string, want int) {
+		t.Helper()
+		expectStatus(t, env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+			Items:      []models.CreateSaleItem{{ProductID: notebook.ID, Quantity: 1}},
+			CouponCode: code,
+			Payments:   pix(t, "12.72"),
+		}), want)
+	}
+	sellWithCoupon("volta10", http.StatusCreated)
+	sellWithCoupon("VOLTA10", http.StatusBadRequest) // Usage limit reached
+	sellWithCoupon("NOPE", http.StatusBadRequest)
+	sellWithCoupon("CANETA5", http.StatusBadRequest) // Does not apply to notebooks
+
+	rec = env.do("GET", "/api/v1/promotions/report", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	byID := map[int64]models.PromotionReport{}
+	for _, line := range decode[[]models.PromotionReport](t, rec) {
+		byID[line.PromotionID] = line
+	}
+	for id, want := range map[int64]models.PromotionReport{
+		drinks.ID:      {PromotionID: drinks.ID, Name: "Bebidas 10%", SalesCount: 1, UnitsSold: 3, Revenue: mustParse(t, "13.50"), DiscountGiven: mustParse(t, "1.50")},
+		threeForTwo.ID: {PromotionID: threeForTwo.ID, Name: "Leve 3 pague 2", SalesCount: 1, UnitsSold: 7, Revenue: mustParse(t, "12.50"), DiscountGiven: mustParse(t, "5.00")},
+		coupon.ID:      {PromotionID: coupon.ID, Name: "Volta às aulas", Code: "VOLTA10", SalesCount: 1, UnitsSold: 1, Revenue: mustParse(t, "12.72"), DiscountGiven: mustParse(t, "3.18")},
+		penCoupon.ID:   {PromotionID: penCoupon.ID, Name: "Caneta 5%", Code: "CANETA5"},
+	} {
+		if !reflect.DeepEqual(byID[id], want) {
+			t.Errorf("report for promotion %d = %+v, want %+v", id, byID[id], want)
+		}
+	}
+	expectStatus(t, env.do("GET", "/api/v1/promotions/report", sellerToken, nil), http.StatusForbidden)
+
+	rec = env.do("GET", "/api/v1/promotions/"+itoa(coupon.ID), adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[models.Promotion](t, rec); got.UsageCount != 1 {
+		t.Errorf("coupon usage count = %d, want 1", got.UsageCount)
+	}
+	expectStatus(t, env.do("DELETE", "/api/v1/promotions/"+itoa(coupon.ID), adminToken, nil), http.StatusConflict)
+	expectStatus(t, env.do("DELETE", "/api/v1/promotions/"+itoa(happyHour.ID), adminToken, nil), http.StatusNoContent)
+}
+
+func TestPromotionRunsAt(t *testing.T) {
+	night := models.Promotion{HappyHourStart: "22:00", HappyHourEnd: "02:00"}
+	afternoon := models.Promotion{HappyHourStart: "17:00", HappyHourEnd: "19:00"}
+	for _, tc := range []struct {
+		p     models.Promotion
+		clock string
+		want  bool
+	}{
+		{night, "23:00", true},
+		{night, "01:59", true},
+		{night, "02:00", false},
+		{night, "12:00", false},
+		{afternoon, "17:00", true},
+		{afternoon, "19:00", false},
+		{models.Promotion{}, "03:00", true},
+	} {
+		at, _ := time.ParseInLocation("15:04", tc.clock, storeLocation)
+		if got := runsAt(tc.p, at); got != tc.want {
+			t.Errorf("runsAt(%s-%s, %s) = %v, want %v", tc.p.HappyHourStart, tc.p.HappyHourEnd, tc.clock, got, tc.want)
+		}
+	}
+
+	// Happy hours run on the store's clock, three hours behind UTC in São Paulo
+	for _, tc := range []struct {
+		p    models.Promotion
+		utc  string
+		want bool
+	}{
+		{afternoon, "17:30", false},
+		{afternoon, "20:30", true},
+		{night, "01:00", true},
+		{night, "04:30", true},
+		{night, "05:00", false},
+	} {
+		at, _ := time.Parse("15:04", tc.utc)
+		at = time.Date(2026, time.March, 10, at.Hour(), at.Minute(), 0, 0, time.UTC)
+		if got := runsAt(tc.p, at); got != tc.want {
+			t.Errorf("runsAt(%s-%s, %s UTC) = %v, want %v", tc.p.HappyHourStart, tc.p.HappyHourEnd, tc.utc, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/auth"
+	"gestor-simples-ecs/pkg/money"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// --- Purchase Order Handlers ---
+
+func (s *server) getPurchaseOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	filter := repository.PurchaseOrderFilter{Status: query.Get("status"), Limit: limit, Offset: offset}
+	switch filter.Status {
+	case "", models.PurchaseOrderStatusOpen, models.PurchaseOrderStatusPartiallyReceived, models.PurchaseOrderStatusReceived, models.PurchaseOrderStatusCancelled:
+	default:
+		respondWithError(w, http.StatusBadRequest, "status must be one of open, partially_received, received or cancelled")
+		return
+	}
+	if v := query.Get("supplierId"); v != "" {
+		supplierID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "supplierId must be a number")
+			return
+		}
+		filter.SupplierID = &supplierID
+	}
+
+	orders, total, err := s.store.PurchaseOrders().List(r.Context(), filter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query purchase orders")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.Page{Data: orders, Total: total, Limit: limit, Offset: offset})
+}
+
+// getIncomingStockHandler lists what open purchase orders still have to
+// deliver, earliest expected first.
+func (s *server) getIncomingStockHandler(w http.ResponseWriter, r *http.Request) {
+	var productID *int64
+	if v := r.URL.Query().Get("productId"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "productId must be a number")
+			return
+		}
+		productID = &id
+	}
+
+	incoming, err := s.store.PurchaseOrders().Incoming(r.Context(), productID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query incoming stock")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, incoming)
+}
+
+func (s *server) createPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.CreatePurchaseOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if len(req.Items) == 0 {
+		respondWithError(w, http.StatusBadRequest, "A purchase order must have at least one item")
+		return
+	}
+
+	userID, _ := auth.UserID(r)
+	order := models.PurchaseOrder{
+		SupplierID: req.SupplierID,
+		CreatedBy:  userID,
+		ExpectedAt: req.ExpectedAt,
+		Notes:      strings.TrimSpace(req.Notes),
+	}
+	type line struct{ productID, variantID int64 }
+	seen := map[line]bool{}
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Item quantities must be positive")
+			return
+		}
+		if item.UnitCost < 0 {
+			respondWithError(w, http.StatusBadRequest, "Unit cost cannot be negative")
+			return
+		}
+		key := line{productID: item.ProductID}
+		if item.VariantID != nil {
+			key.variantID = *item.VariantID
+		}
+		if seen[key] {
+			respondWithError(w, http.StatusBadRequest, "Each product or variant can only appear once in a purchase order")
+			return
+		}
+		seen[key] = true
+		order.Items = append(order.Items, models.PurchaseOrderItem{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity, UnitCost: item.UnitCost})
+	}
+
+	err := s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		_, err := tx.Suppliers().Get(r.Context(), req.SupplierID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusBadRequest, "Supplier not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to look up supplier")
+		}
+		for _, item := range order.Items {
+			product, err := tx.Products().Get(r.Context(), item.ProductID)
+			if errors.Is(err, repository.ErrNotFound) {
+				return newAPIError(http.StatusBadRequest, "Product not found")
+			}
+			if err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to load product")
+			}
+			// Products with variants are ordered by variant
+			if _, err := productVariant(r.Context(), tx, product, item.VariantID); err != nil {
+				return err
+			}
+			if err := rejectBundle(r.Context(), tx, product, "order its components instead"); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.PurchaseOrders().Create(r.Context(), &order); err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to create purchase order")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	s.respondWithPurchaseOrder(w, r, http.StatusCreated, order.ID)
+}
+
+func (s *server) getPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid purchase order ID")
+		return
+	}
+
+	order, err := s.store.PurchaseOrders().Get(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Purchase order not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, order)
+}
+
+// respondWithPurchaseOrder reloads the order after a change and sends it.
+func (s *server) respondWithPurchaseOrder(w http.ResponseWriter, r *http.Request, code int, id int64) {
+	order, err := s.store.PurchaseOrders().Get(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load purchase order")
+		return
+	}
+	respondWithJSON(w, code, order)
+}
+
+// loadOpenPurchaseOrder locks the order inside tx and checks it still
+// expects deliveries.
+func loadOpenPurchaseOrder(r *http.Request, tx repository.Store, id int64) (models.PurchaseOrder, error) {
+	order, err := tx.PurchaseOrders().GetForUpdate(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return order, newAPIError(http.StatusNotFound, "Purchase order not found")
+	}
+	if err != nil {
+		return order, newAPIError(http.StatusInternalServerError, "Failed to load purchase order")
+	}
+	switch order.Status {
+	case models.PurchaseOrderStatusReceived:
+		return order, newAPIError(http.StatusConflict, "Purchase order was already fully received")
+	case models.PurchaseOrderStatusCancelled:
+		return order, newAPIError(http.StatusConflict, "Purchase order was cancelled")
+	}
+	return order, nil
+}
+
+// receivePurchaseOrderHandler adds delivered units to stock at the unit cost
+// of their order line. Without items in the request, everything outstanding
+// is received.
+func (s *server) receivePurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid purchase order ID")
+		return
+	}
+
+	var req models.ReceivePurchaseOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Received quantities must be positive")
+			return
+		}
+	}
+	userID, _ := auth.UserID(r)
+
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		order, err := loadOpenPurchaseOrder(r, tx, id)
+		if err != nil {
+			return err
+		}
+
+		type line struct{ productID, variantID int64 }
+		lineOf := func(productID int64, variantID *int64) line {
+			if variantID == nil {
+				return line{productID: productID}
+			}
+			return line{productID, *variantID}
+		}
+		unitCosts := map[line]money.Amount{}
+		for _, item := range order.Items {
+			unitCosts[lineOf(item.ProductID, item.VariantID)] = item.UnitCost
+		}
+		receipts := req.Items
+		if len(receipts) == 0 {
+			for _, item := range order.Items {
+				if outstanding := item.Quantity - item.ReceivedQuantity; outstanding > 0 {
+					receipts = append(receipts, models.ReceivePurchaseOrderItem{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: outstanding})
+				}
+			}
+		}
+
+		for _, receipt := range receipts {
+			what := fmt.Sprintf("product %d", receipt.ProductID)
+			if receipt.VariantID != nil {
+				what = fmt.Sprintf("variant %d of product %d", *receipt.VariantID, receipt.ProductID)
+			}
+			err := tx.PurchaseOrders().ReceiveItem(r.Context(), id, receipt.ProductID, receipt.VariantID, receipt.Quantity)
+			if errors.Is(err, repository.ErrNotFound) {
+				return newAPIError(http.StatusBadRequest, fmt.Sprintf("The purchase order has no line for %s", what))
+			}
+			if errors.Is(err, repository.ErrConflict) {
+				return newAPIError(http.StatusBadRequest, fmt.Sprintf("Receiving more units of %s than are outstanding", what))
+			}
+			if err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to record receipt")
+			}
+
+			// The product may have gained variants since it was ordered, and
+			// a line without one doesn't say which of them arrived
+			product, err := tx.Products().GetForUpdate(r.Context(), receipt.ProductID)
+			if err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to load product")
+			}
+			if receipt.VariantID == nil {
+				found, err := hasVariants(r.Context(), tx, product.ID)
+				if err != nil {
+					return newAPIError(http.StatusInternalServerError, "Failed to load product variants")
+				}
+				if found {
+					return newAPIError(http.StatusBadRequest, fmt.Sprintf("Product %q has variants since it was ordered; receive it with stock movements of its variants", product.Name))
+				}
+			} else if err := tx.Variants().AdjustStock(r.Context(), *receipt.VariantID, receipt.Quantity); err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to update variant stock")
+			}
+			if err := rejectBundle(r.Context(), tx, product, "receive its components instead"); err != nil {
+				return err
+			}
+			// Whether the product is tracked by lot is settled on arrival,
+			// since it may have gained its first lot since it was ordered
+			if err := receiveLot(r.Context(), tx, product, receipt); err != nil {
+				return err
+			}
+
+			if err := tx.Products().ReceiveStock(r.Context(), receipt.ProductID, receipt.Quantity, unitCosts[lineOf(receipt.ProductID, receipt.VariantID)]); err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to update product stock")
+			}
+			err = tx.Products().RecordMovement(r.Context(), &models.StockMovement{
+				ProductID:       receipt.ProductID,
+				VariantID:       receipt.VariantID,
+				UserID:          &userID,
+				Type:            models.StockMovementPurchaseReceipt,
+				QuantityDelta:   receipt.Quantity,
+				Reason:          fmt.Sprintf("Purchase order %d", id),
+				PurchaseOrderID: &id,
+			})
+			if err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to record stock movement")
+			}
+		}
+
+		order, err = tx.PurchaseOrders().Get(r.Context(), id)
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load purchase order")
+		}
+		status := models.PurchaseOrderStatusReceived
+		for _, item := range order.Items {
+			if item.ReceivedQuantity < item.Quantity {
+				status = models.PurchaseOrderStatusPartiallyReceived
+			}
+		}
+		if err := tx.PurchaseOrders().SetStatus(r.Context(), id, status); err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to update purchase order")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	s.respondWithPurchaseOrder(w, r, http.StatusOK, id)
+}
+
+// cancelPurchaseOrderHandler stops expecting what the order has not
+// delivered yet. Units already received stay in stock.
+func (s *server) cancelPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid purchase order ID")
+		return
+	}
+
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		if _, err := loadOpenPurchaseOrder(r, tx, id); err != nil {
+			return err
+		}
+		if err := tx.PurchaseOrders().SetStatus(r.Context(), id, models.PurchaseOrderStatusCancelled); err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to update purchase order")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	s.respondWithPurchaseOrder(w, r, http.StatusOK, id)
+}
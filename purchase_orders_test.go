@@ -0,0 +1,129 @@
+package main
+
+import (
+	"gestor-simples-ecs/internal/models"
+	"net/http"
+	"testing"
+)
+
+func TestPurchaseOrderReceiving(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 2)
+	paper := env.createProduct(adminToken, "Papel A4", "25.00", 0)
+
+	rec := env.do("POST", "/api/v1/suppliers", sellerToken, map[string]string{"name": "Distribuidora Central"})
+	expectStatus(t, rec, http.StatusForbidden)
+	rec = env.do("POST", "/api/v1/suppliers", adminToken, map[string]string{"name": "Distribuidora Central"})
+	expectStatus(t, rec, http.StatusCreated)
+	supplier := decode[models.Supplier](t, rec)
+
+	rec = env.do("POST", "/api/v1/purchase-orders", adminToken, models.CreatePurchaseOrderRequest{
+		SupplierID: supplier.ID,
+		Items: []models.CreatePurchaseOrderItem{
+			{ProductID: pen.ID, Quantity: 10, UnitCost: mustParse(t, "1.20")},
+			{ProductID: paper.ID, Quantity: 5, UnitCost: mustParse(t, "18.00")},
+		},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	order := decode[models.PurchaseOrder](t, rec)
+	if order.Status != models.PurchaseOrderStatusOpen || order.SupplierName != "Distribuidora Central" || order.TotalCost != mustParse(t, "102.00") {
+		t.Fatalf("unexpected purchase order: %+v", order)
+	}
+	path := "/api/v1/purchase-orders/" + itoa(order.ID)
+
+	rec = env.do("GET", "/api/v1/purchase-orders/incoming?productId="+itoa(pen.ID), adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if incoming := decode[[]models.IncomingStock](t, rec); len(incoming) != 1 || incoming[0].Outstanding != 10 {
+		t.Fatalf("unexpected incoming stock: %+v", incoming)
+	}
+
+	// Partial delivery
+	rec = env.do("POST", path+"/receive", adminToken, models.ReceivePurchaseOrderRequest{Items: []models.ReceivePurchaseOrderItem{{ProductID: pen.ID, Quantity: 11}}})
+	expectStatus(t, rec, http.StatusBadRequest)
+	rec = env.do("POST", path+"/receive", adminToken, models.ReceivePurchaseOrderRequest{Items: []models.ReceivePurchaseOrderItem{{ProductID: pen.ID, Quantity: 6}}})
+	expectStatus(t, rec, http.StatusOK)
+	if order = decode[models.PurchaseOrder](t, rec); order.Status != models.PurchaseOrderStatusPartiallyReceived {
+		t.Fatalf("status = %s, want partially_received", order.Status)
+	}
+	if got := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(pen.ID), adminToken, nil)); got.Quantity != 8 {
+		t.Fatalf("pen quantity = %d, want 8", got.Quantity)
+	}
+
+	// The rest of the order
+	rec = env.do("POST", path+"/receive", adminToken, models.ReceivePurchaseOrderRequest{})
+	expectStatus(t, rec, http.StatusOK)
+	if order = decode[models.PurchaseOrder](t, rec); order.Status != models.PurchaseOrderStatusReceived {
+		t.Fatalf("status = %s, want received", order.Status)
+	}
+	if got := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(paper.ID), adminToken, nil)); got.Quantity != 5 {
+		t.Fatalf("paper quantity = %d, want 5", got.Quantity)
+	}
+
+	movements := decode[[]models.StockMovement](t, env.do("GET", "/api/v1/products/"+itoa(pen.ID)+"/movements", adminToken, nil))
+	receipts := 0
+	for _, m := range movements {
+		if m.Type == models.StockMovementPurchaseReceipt && m.PurchaseOrderID != nil && *m.PurchaseOrderID == order.ID {
+			receipts++
+		}
+	}
+	if receipts != 2 {
+		t.Fatalf("purchase receipts for the order = %d, want 2: %+v", receipts, movements)
+	}
+	if discrepancies := decode[[]models.StockDiscrepancy](t, env.do("GET", "/api/v1/products/reconciliation", adminToken, nil)); len(discrepancies) != 0 {
+		t.Fatalf("unexpected discrepancies: %+v", discrepancies)
+	}
+
+	rec = env.do("POST", path+"/receive", adminToken, models.ReceivePurchaseOrderRequest{})
+	expectStatus(t, rec, http.StatusConflict)
+	rec = env.do("GET", "/api/v1/purchase-orders/incoming", adminToken, nil)
+	if incoming := decode[[]models.IncomingStock](t, rec); len(incoming) != 0 {
+		t.Fatalf("incoming stock after receiving everything: %+v", incoming)
+	}
+
+	rec = env.do("DELETE", "/api/v1/suppliers/"+itoa(supplier.ID), adminToken, nil)
+	expectStatus(t, rec, http.StatusConflict)
+}
+
+func TestCancelPurchaseOrder(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 0)
+	supplier := decode[models.Supplier](t, env.do("POST", "/api/v1/suppliers", adminToken, map[string]string{"name": "Distribuidora Central"}))
+
+	rec := env.do("POST", "/api/v1/purchase-orders", adminToken, models.CreatePurchaseOrderRequest{
+		SupplierID: supplier.ID,
+		Items:      []models.CreatePurchaseOrderItem{{ProductID: pen.ID, Quantity: 0, UnitCost: mustParse(t, "1.20")}},
+	})
+	expectStatus(t, rec, http.StatusBadRequest)
+	rec = env.do("POST", "/api/v1/purchase-orders", adminToken, models.CreatePurchaseOrderRequest{
+		SupplierID: supplier.ID + 1,
+		Items:      []models.CreatePurchaseOrderItem{{ProductID: pen.ID, Quantity: 4, UnitCost: mustParse(t, "1.20")}},
+	})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	rec = env.do("POST", "/api/v1/purchase-orders", adminToken, models.CreatePurchaseOrderRequest{
+		SupplierID: supplier.ID,
+		Items:      []models.CreatePurchaseOrderItem{{ProductID: pen.ID, Quantity: 4, UnitCost: mustParse(t, "1.20")}},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	order := decode[models.PurchaseOrder](t, rec)
+	path := "/api/v1/purchase-orders/" + itoa(order.ID)
+
+	rec = env.do("POST", path+"/cancel", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if order = decode[models.PurchaseOrder](t, rec); order.Status != models.PurchaseOrderStatusCancelled {
+		t.Fatalf("status = %s, want cancelled", order.Status)
+	}
+
+	rec = env.do("POST", path+"/receive", adminToken, models.ReceivePurchaseOrderRequest{})
+	expectStatus(t, rec, http.StatusConflict)
+	if incoming := decode[[]models.IncomingStock](t, env.do("GET", "/api/v1/purchase-orders/incoming", adminToken, nil)); len(incoming) != 0 {
+		t.Fatalf("incoming stock from a cancelled order: %+v", incoming)
+	}
+	rec = env.do("GET", "/api/v1/purchase-orders?status=cancelled", adminToken, nil)
+	if orders, total := pageOf[models.PurchaseOrder](t, rec); total != 1 || orders[0].ID != order.ID {
+		t.Fatalf("cancelled orders: %+v", orders)
+	}
+}
@@ -0,0 +1,531 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/auth"
+	"gestor-simples-ecs/pkg/money"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultQuoteValidity is how long quoted prices hold when the request does
+// not say.
+const defaultQuoteValidity = 7 * 24 * time.Hour
+
+// --- Quote Handlers ---
+
+func (s *server) getQuotesHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	filter := repository.QuoteFilter{Limit: limit, Offset: offset}
+	if v := query.Get("userId"); v != "" {
+		sellerID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "userId must be a number")
+			return
+		}
+		filter.UserID = &sellerID
+	}
+	var ok bool
+	if filter.UserID, ok = restrictToOwnSales(w, r, filter.UserID); !ok {
+		return
+	}
+	if v := query.Get("customerId"); v != "" {
+		customerID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "customerId must be a number")
+			return
+		}
+		filter.CustomerID = &customerID
+	}
+
+	quotes, total, err := s.store.Quotes().List(r.Context(), filter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query quotes")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.Page{Data: quotes, Total: total, Limit: limit, Offset: offset})
+}
+
+// createQuoteHandler prices a cart at the current product prices and item
+// discounts and locks them until the quote expires. Stock is not touched.
+func (s *server) createQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateQuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := validateSaleItems(req.Items); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := auth.UserID(r)
+	quote := models.Quote{
+		UserID:     userID,
+		CreatedBy:  userID,
+		CustomerID: req.CustomerID,
+		Notes:      strings.TrimSpace(req.Notes),
+		ValidUntil: time.Now().Add(defaultQuoteValidity),
+	}
+	if req.UserID != 0 && req.UserID != userID {
+		if !auth.Can(r, auth.PermSalesCreateOnBehalf) {
+			respondWithError(w, http.StatusForbidden, "You can only register quotes for yourself")
+			return
+		}
+		quote.UserID = req.UserID
+	}
+	if req.ValidUntil != nil {
+		if !req.ValidUntil.After(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "validUntil must be in the future")
+			return
+		}
+		quote.ValidUntil = *req.ValidUntil
+	}
+
+	err := s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		if err := checkParties(r.Context(), tx, quote.UserID, quote.CreatedBy, quote.CustomerID); err != nil {
+			return err
+		}
+		items, err := resolveSaleItems(r.Context(), tx, req.Items)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			product, err := tx.Products().Get(r.Context(), item.ProductID)
+			if errors.Is(err, repository.ErrNotFound) {
+				return newAPIError(http.StatusBadRequest, "Product not found")
+			}
+			if err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to load product")
+			}
+
+			variant, err := productVariant(r.Context(), tx, product, item.VariantID)
+			if err != nil {
+				return err
+			}
+			line := models.SaleItem{ProductID: product.ID, ProductName: product.Name, Quantity: item.Quantity, UnitPrice: product.Price}
+			if variant != nil {
+				line.VariantID, line.VariantName = &variant.ID, variant.Name
+				if variant.Price != nil {
+					line.UnitPrice = *variant.Price
+				}
+			}
+
+			line.Discount, err = discountOn(item.Discount, line.UnitPrice.Mul(item.Quantity))
+			if err != nil {
+				return newAPIError(http.StatusBadRequest, "Item discount: "+err.Error())
+			}
+			quote.Items = append(quote.Items, line)
+		}
+
+		if err := tx.Quotes().Create(r.Context(), &quote); err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to create quote")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	created, err := s.store.Quotes().Get(r.Context(), quote.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load quote")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, created)
+}
+
+// loadQuote fetches the {id} quote for the caller. Without sales:view_all,
+// only the seller credited with the quote may see it. It writes the error
+// response itself.
+func (s *server) loadQuote(w http.ResponseWriter, r *http.Request) (models.Quote, bool) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid quote ID")
+		return models.Quote{}, false
+	}
+
+	quote, err := s.store.Quotes().Get(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Quote not found")
+		return quote, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load quote")
+		return quote, false
+	}
+
+	if !auth.IsSelf(r, quote.UserID) && !auth.Can(r, auth.PermSalesViewAll) {
+		respondWithError(w, http.StatusForbidden, "You can only view your own quotes")
+		return quote, false
+	}
+	return quote, true
+}
+
+func (s *server) getQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	quote, ok := s.loadQuote(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, quote)
+}
+
+// printQuoteHandler renders the quote as an HTML page to print or hand to
+// the customer.
+func (s *server) printQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	quote, ok := s.loadQuote(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := quoteTemplate.Execute(w, quote); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to render quote")
+	}
+}
+
+// outOfStockError lists the quoted products without enough stock to convert.
+type outOfStockError struct {
+	items []models.OutOfStockItem
+}
+
+func (e *outOfStockError) Error() string {
+	return fmt.Sprintf("%d quoted products are out of stock", len(e.items))
+}
+
+// convertQuoteHandler places a sale for an open quote at its locked prices,
+// through the same checkout as POST /sales. If any product lacks stock,
+// nothing is sold and every short item is reported.
+func (s *server) convertQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	quote, ok := s.loadQuote(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.ConvertQuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if err := validateDiscount(req.Discount); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch quote.Status {
+	case models.QuoteStatusConverted:
+		respondWithError(w, http.StatusConflict, "Quote was already converted into a sale")
+		return
+	case models.QuoteStatusExpired:
+		respondWithError(w, http.StatusConflict, "Quote expired on "+quote.ValidUntil.Format("2006-01-02")+"; create a new one at current prices")
+		return
+	}
+
+	payments, paid, err := preparePayments(req.Payments)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, _ := auth.UserID(r)
+	if quote.UserID != userID && !auth.Can(r, auth.PermSalesCreateOnBehalf) {
+		respondWithError(w, http.StatusForbidden, "You can only register sales for yourself")
+		return
+	}
+	discountLimit, err := s.discountLimit(r)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load discount limit")
+		return
+	}
+
+	order := checkout{
+		request: models.CreateSaleRequest{
+			Discount:         req.Discount,
+			DiscountApproval: req.DiscountApproval,
+			CouponCode:       req.CouponCode,
+		},
+		payments:      payments,
+		paid:          paid,
+		discountLimit: discountLimit,
+	}
+	for _, item := range quote.Items {
+		line := models.CreateSaleItem{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: item.Quantity}
+		if item.Discount > 0 {
+			line.Discount = &models.Discount{Type: models.DiscountTypeAmount, Value: item.Discount}
+		}
+		order.request.Items = append(order.request.Items, line)
+		order.prices = append(order.prices, item.UnitPrice)
+	}
+
+	sale := models.Sale{UserID: quote.UserID, CreatedBy: userID, CustomerID: quote.CustomerID}
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		// Stock held for the quote is for this sale, whoever reserved it
+		held, err := quoteReservations(r, tx, quote.ID)
+		if err != nil {
+			return err
+		}
+		if err := consumeReservations(r.Context(), tx, held, order.request.Items, nil); err != nil {
+			return err
+		}
+		if err := checkQuoteStock(r, tx, quote); err != nil {
+			return err
+		}
+		if err := placeSale(r.Context(), tx, &sale, order); err != nil {
+			return err
+		}
+
+		err = tx.Quotes().MarkConverted(r.Context(), quote.ID, sale.ID)
+		if errors.Is(err, repository.ErrConflict) {
+			return newAPIError(http.StatusConflict, "Quote was already converted into a sale")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to update quote")
+		}
+		return nil
+	})
+	var shortage *outOfStockError
+	if errors.As(err, &shortage) {
+		respondWithJSON(w, http.StatusConflict, models.OutOfStockResponse{Error: "Some quoted items are out of stock", OutOfStock: shortage.items})
+		return
+	}
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	resp := models.CreateSaleResponse{SaleID: sale.ID}
+	for _, p := range payments {
+		resp.Change += p.Change
+	}
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+// reserveQuoteHandler holds the stock of an open quote the customer
+// accepted, one reservation per item, until the quote converts or the
+// reservations lapse after s.reservationTTL or with the quote.
+func (s *server) reserveQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	quote, ok := s.loadQuote(w, r)
+	if !ok {
+		return
+	}
+	if quote.Status != models.QuoteStatusOpen {
+		respondWithError(w, http.StatusConflict, "Only open quotes can reserve stock")
+		return
+	}
+	userID, _ := auth.UserID(r)
+	if quote.UserID != userID && !auth.Can(r, auth.PermSalesCreateOnBehalf) {
+		respondWithError(w, http.StatusForbidden, "You can only reserve stock for your own quotes")
+		return
+	}
+
+	expiresAt := time.Now().Add(s.reservationTTL)
+	if quote.ValidUntil.Before(expiresAt) {
+		expiresAt = quote.ValidUntil
+	}
+	reservations := []models.StockReservation{}
+	err := s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		held, err := quoteReservations(r, tx, quote.ID)
+		if err != nil {
+			return err
+		}
+		if len(held) > 0 {
+			return newAPIError(http.StatusConflict, "Quote stock is already reserved")
+		}
+		if err := checkQuoteStock(r, tx, quote); err != nil {
+			return err
+		}
+
+		for _, item := range quote.Items {
+			// Bundles have no stock of their own to hold, as with single reservations
+			if err := rejectBundle(r.Context(), tx, models.Product{ID: item.ProductID, Name: item.ProductName}, "reserve its components instead"); err != nil {
+				return err
+			}
+			res := models.StockReservation{
+				ProductID: item.ProductID,
+				VariantID: item.VariantID,
+				Quantity:  item.Quantity,
+				QuoteID:   &quote.ID,
+				UserID:    userID,
+				Notes:     fmt.Sprintf("Quote %d", quote.ID),
+				ExpiresAt: expiresAt,
+			}
+			err := tx.Reservations().Create(r.Context(), &res)
+			if errors.Is(err, repository.ErrInsufficientStock) {
+				return newAPIError(http.StatusConflict, "Not enough available stock to reserve")
+			}
+			if err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to create reservation")
+			}
+			res.ProductName, res.VariantName = item.ProductName, item.VariantName
+			res.Status = models.ReservationStatusActive
+			reservations = append(reservations, res)
+		}
+		return nil
+	})
+	var shortage *outOfStockError
+	if errors.As(err, &shortage) {
+		respondWithJSON(w, http.StatusConflict, models.OutOfStockResponse{Error: "Some quoted items are out of stock", OutOfStock: shortage.items})
+		return
+	}
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, reservations)
+}
+
+// quoteReservations returns the IDs of the reservations still holding stock
+// for the quote.
+func quoteReservations(r *http.Request, tx repository.Store, quoteID int64) ([]int64, error) {
+	reservations, _, err := tx.Reservations().List(r.Context(), repository.ReservationFilter{QuoteID: &quoteID, Status: models.ReservationStatusActive})
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "Failed to load reservations")
+	}
+	ids := make([]int64, len(reservations))
+	for i, res := range reservations {
+		ids[i] = res.ID
+	}
+	return ids, nil
+}
+
+// checkQuoteStock locks the quoted products and returns an *outOfStockError
+// listing those, or the quoted variants, with less available stock than the
+// quote asks for.
+func checkQuoteStock(r *http.Request, tx repository.Store, quote models.Quote) error {
+	requested := map[int64]int{}
+	variants := map[int64]int{}
+	var order []models.SaleItem
+	for _, item := range quote.Items {
+		if _, seen := requested[item.ProductID]; !seen {
+			order = append(order, item)
+		}
+		requested[item.ProductID] += item.Quantity
+		if item.VariantID != nil {
+			variants[*item.VariantID] += item.Quantity
+		}
+	}
+
+	shortage := &outOfStockError{}
+	for _, item := range order {
+		product, err := tx.Products().GetForUpdate(r.Context(), item.ProductID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product")
+		}
+		// Bundles have as many available as their components make
+		components, err := tx.Bundles().List(r.Context(), item.ProductID)
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load bundle components")
+		}
+		if len(components) > 0 {
+			product.Available = bundleAvailable(components)
+		}
+		if product.Available < requested[item.ProductID] {
+			shortage.items = append(shortage.items, models.OutOfStockItem{
+				ProductID:   item.ProductID,
+				ProductName: item.ProductName,
+				Requested:   requested[item.ProductID],
+				Available:   product.Available,
+			})
+		}
+	}
+
+	// With the products locked, their variants' stock holds still too
+	seen := map[int64]bool{}
+	for _, item := range quote.Items {
+		if item.VariantID == nil || seen[*item.VariantID] {
+			continue
+		}
+		seen[*item.VariantID] = true
+		available := 0
+		variant, err := tx.Variants().Get(r.Context(), *item.VariantID)
+		if err == nil {
+			available = variant.Available
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product variant")
+		}
+		if available < variants[*item.VariantID] {
+			shortage.items = append(shortage.items, models.OutOfStockItem{
+				ProductID:   item.ProductID,
+				ProductName: item.ProductName,
+				VariantID:   item.VariantID,
+				VariantName: item.VariantName,
+				Requested:   variants[*item.VariantID],
+				Available:   available,
+			})
+		}
+	}
+	if len(shortage.items) > 0 {
+		return shortage
+	}
+	return nil
+}
+
+// brl formats an amount as Brazilian reais, e.g. "R$ 1.234,56".
+func brl(a money.Amount) string {
+	cents := a.Cents()
+	sign := ""
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+	units := strconv.FormatInt(cents/100, 10)
+	for i := len(units) - 3; i > 0; i -= 3 {
+		units = units[:i] + "." + units[i:]
+	}
+	return fmt.Sprintf("%sR$ %s,%02d", sign, units, cents%100)
+}
+
+var quoteTemplate = template.Must(template.New("quote").Funcs(template.FuncMap{
+	"brl":  brl,
+	"date": func(t time.Time) string { return t.Format("02/01/2006") },
+}).Parse(`<!DOCTYPE html>
+<html lang="pt-BR">
+<head>
+<meta charset="utf-8">
+<title>Orçamento nº {{.ID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ccc; padding: .4em; text-align: left; }
+.num { text-align: right; }
+tfoot td { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Orçamento nº {{.ID}}</h1>
+<p>Emitido em {{date .CreatedAt}} por {{.SellerName}}<br>Válido até {{date .ValidUntil}}</p>
+{{if .CustomerName}}<p>Cliente: {{.CustomerName}}</p>{{end}}
+{{if eq .Status "expired"}}<p><strong>Orçamento expirado.</strong></p>{{end}}
+{{if .SaleID}}<p><strong>Convertido na venda nº {{.SaleID}}.</strong></p>{{end}}
+<table>
+<thead><tr><th>Produto</th><th class="num">Qtd.</th><th class="num">Preço unit.</th><th class="num">Desconto</th><th class="num">Total</th></tr></thead>
+<tbody>
+{{range .Items}}<tr><td>{{.ProductName}}{{with .VariantName}} ({{.}}){{end}}</td><td class="num">{{.Quantity}}</td><td class="num">{{brl .UnitPrice}}</td><td class="num">{{brl .Discount}}</td><td class="num">{{brl .Total}}</td></tr>
+{{end}}</tbody>
+<tfoot>
+<tr><td colspan="4">Subtotal</td><td class="num">{{brl .Subtotal}}</td></tr>
+<tr><td colspan="4">Descontos</td><td class="num">{{brl .DiscountTotal}}</td></tr>
+<tr><td colspan="4">Total</td><td class="num">{{brl .TotalPrice}}</td></tr>
+</tfoot>
+</table>
+{{if .Notes}}<p>{{.Notes}}</p>{{end}}
+<p>Preços garantidos até {{date .ValidUntil}}, sujeitos à disponibilidade em estoque.</p>
+</body>
+</html>
+`))
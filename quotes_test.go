@@ -0,0 +1,101 @@
+package main
+
+import (
+	"gestor-simples-ecs/internal/models"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuoteConversion(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	maria := env.createUser("maria", "vendedor")
+	sellerToken := env.token(maria)
+	joaoToken := env.token(env.createUser("joao", "vendedor"))
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 10)
+	notebook := env.createProduct(adminToken, "Caderno", "15.90", 1)
+
+	rec := env.do("POST", "/api/v1/quotes", sellerToken, models.CreateQuoteRequest{Items: []models.CreateSaleItem{
+		{ProductID: pen.ID, Quantity: 4, Discount: &models.Discount{Type: models.DiscountTypeAmount, Value: mustParse(t, "1.00")}},
+		{ProductID: notebook.ID, Quantity: 2},
+	}})
+	expectStatus(t, rec, http.StatusCreated)
+	quote := decode[models.Quote](t, rec)
+	if quote.Status != models.QuoteStatusOpen || quote.TotalPrice != mustParse(t, "40.80") {
+		t.Fatalf("unexpected quote: %+v", quote)
+	}
+
+	// Only the quoting seller (or a viewer of all sales) can see it
+	rec = env.do("GET", "/api/v1/quotes/"+itoa(quote.ID), joaoToken, nil)
+	expectStatus(t, rec, http.StatusForbidden)
+
+	rec = env.do("GET", "/api/v1/quotes/"+itoa(quote.ID)+"/print", sellerToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if body := rec.Body.String(); !strings.Contains(body, "Orçamento nº") || !strings.Contains(body, "R$ 40,80") {
+		t.Fatalf("unexpected printable quote: %s", body)
+	}
+
+	// Every short product is reported and nothing is sold
+	rec = env.do("POST", "/api/v1/quotes/"+itoa(quote.ID)+"/convert", sellerToken, models.ConvertQuoteRequest{Payments: pix(t, "40.80")})
+	expectStatus(t, rec, http.StatusConflict)
+	shortage := decode[models.OutOfStockResponse](t, rec)
+	if len(shortage.OutOfStock) != 1 || shortage.OutOfStock[0].ProductID != notebook.ID ||
+		shortage.OutOfStock[0].Requested != 2 || shortage.OutOfStock[0].Available != 1 {
+		t.Fatalf("unexpected out of stock list: %+v", shortage)
+	}
+	if got := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(pen.ID), adminToken, nil)); got.Quantity != 10 {
+		t.Fatalf("stock after failed conversion = %d, want 10", got.Quantity)
+	}
+
+	// Restock and raise the price: the quote keeps its price
+	rec = env.do("PUT", "/api/v1/products/"+itoa(notebook.ID), adminToken, map[string]interface{}{"name": "Caderno", "price": "19.90", "quantity": 5})
+	expectStatus(t, rec, http.StatusOK)
+
+	rec = env.do("POST", "/api/v1/quotes/"+itoa(quote.ID)+"/convert", joaoToken, models.ConvertQuoteRequest{Payments: pix(t, "40.80")})
+	expectStatus(t, rec, http.StatusForbidden)
+
+	rec = env.do("POST", "/api/v1/quotes/"+itoa(quote.ID)+"/convert", sellerToken, models.ConvertQuoteRequest{Payments: pix(t, "40.80")})
+	expectStatus(t, rec, http.StatusCreated)
+	saleID := decode[models.CreateSaleResponse](t, rec).SaleID
+
+	sale := decode[models.Sale](t, env.do("GET", "/api/v1/sales/"+itoa(saleID), sellerToken, nil))
+	if sale.UserID != maria.ID || sale.TotalPrice != mustParse(t, "40.80") {
+		t.Fatalf("unexpected sale: %+v", sale)
+	}
+
+	quote = decode[models.Quote](t, env.do("GET", "/api/v1/quotes/"+itoa(quote.ID), sellerToken, nil))
+	if quote.Status != models.QuoteStatusConverted || quote.SaleID == nil || *quote.SaleID != saleID {
+		t.Fatalf("unexpected converted quote: %+v", quote)
+	}
+
+	rec = env.do("POST", "/api/v1/quotes/"+itoa(quote.ID)+"/convert", sellerToken, models.ConvertQuoteRequest{Payments: pix(t, "40.80")})
+	expectStatus(t, rec, http.StatusConflict)
+}
+
+func TestQuoteExpiry(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 10)
+	items := []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 1}}
+
+	past := time.Now().Add(-time.Hour)
+	rec := env.do("POST", "/api/v1/quotes", sellerToken, models.CreateQuoteRequest{Items: items, ValidUntil: &past})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	soon := time.Now().Add(50 * time.Millisecond)
+	rec = env.do("POST", "/api/v1/quotes", sellerToken, models.CreateQuoteRequest{Items: items, ValidUntil: &soon})
+	expectStatus(t, rec, http.StatusCreated)
+	quote := decode[models.Quote](t, rec)
+
+	time.Sleep(100 * time.Millisecond)
+	quote = decode[models.Quote](t, env.do("GET", "/api/v1/quotes/"+itoa(quote.ID), sellerToken, nil))
+	if quote.Status != models.QuoteStatusExpired {
+		t.Fatalf("status = %s, want expired", quote.Status)
+	}
+
+	rec = env.do("POST", "/api/v1/quotes/"+itoa(quote.ID)+"/convert", sellerToken, models.ConvertQuoteRequest{Payments: pix(t, "2.50")})
+	expectStatus(t, rec, http.StatusConflict)
+}
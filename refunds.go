@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/auth"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// --- Refund Handlers ---
+
+// cancelSaleHandler cancels a whole sale, returning every item not yet
+// returned to stock.
+func (s *server) cancelSaleHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.CancelSaleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		respondWithError(w, http.StatusBadRequest, "A reason is required")
+		return
+	}
+
+	s.refundSale(w, r, models.RefundTypeCancellation, req.Reason, nil)
+}
+
+// returnSaleItemsHandler processes a partial return of a sale.
+func (s *server) returnSaleItemsHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.ReturnSaleItemsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		respondWithError(w, http.StatusBadRequest, "A reason is required")
+		return
+	}
+	if len(req.Items) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one item must be returned")
+		return
+	}
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Returned quantities must be positive")
+			return
+		}
+	}
+
+	s.refundSale(w, r, models.RefundTypeReturn, req.Reason, req.Items)
+}
+
+// refundSale returns the given items of the sale in the URL to stock and
+// records a refund document, all inside one transaction. A nil items slice
+// refunds everything still outstanding and marks the sale as cancelled.
+func (s *server) refundSale(w http.ResponseWriter, r *http.Request, refundType, reason string, items []models.SaleItem) {
+	saleID, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid sale ID")
+		return
+	}
+	operatorID, _ := auth.UserID(r)
+
+	refund := models.Refund{
+		SaleID: saleID,
+		UserID: operatorID,
+		Type:   refundType,
+		Reason: reason,
+		Items:  []models.SaleItem{},
+	}
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		// Lock the sale so concurrent refunds of the same sale are serialized
+		sale, err := tx.Sales().GetForUpdate(r.Context(), saleID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusNotFound, "Sale not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load sale")
+		}
+		if sale.Status == models.SaleStatusCancelled {
+			return newAPIError(http.StatusConflict, "Sale is already cancelled")
+		}
+
+		if items == nil {
+			for _, item := range sale.Items {
+				if outstanding := item.Quantity - item.ReturnedQuantity; outstanding > 0 {
+					items = append(items, models.SaleItem{ProductID: item.ProductID, VariantID: item.VariantID, Quantity: outstanding})
+				}
+			}
+		}
+
+		for _, item := range items {
+			// Mark the quantity as returned on the first sale line that still has enough of it
+			line := saleLine(sale, item)
+			returned, err := tx.Sales().ReturnItem(r.Context(), saleID, item.ProductID, item.VariantID, item.Quantity)
+			if errors.Is(err, repository.ErrReturnExceedsSold) || line < 0 {
+				return newAPIError(http.StatusBadRequest, "Returned quantity exceeds what was sold or product not in sale")
+			}
+			if err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to update sale item")
+			}
+
+			// Put the items back in stock; bundles return the components they took
+			if err := restock(r.Context(), tx, saleID, sale.Items[line], item.Quantity, operatorID, reason); err != nil {
+				return err
+			}
+			sale.Items[line].ReturnedQuantity += item.Quantity
+
+			refund.Items = append(refund.Items, returned)
+			refund.TotalAmount += returned.Total
+		}
+
+		if err := tx.Sales().CreateRefund(r.Context(), &refund); err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to create refund record")
+		}
+
+		if refundType == models.RefundTypeCancellation {
+			if err := tx.Sales().SetStatus(r.Context(), saleID, models.SaleStatusCancelled); err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to cancel sale")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, refund)
+}
+
+// saleLine returns the index of the sale line a return of item applies to,
+// the first with enough of it left as SaleRepository.ReturnItem picks it,
+// or -1 if there is none.
+func saleLine(sale models.Sale, item models.SaleItem) int {
+	return slices.IndexFunc(sale.Items, func(line models.SaleItem) bool {
+		sameVariant := (line.VariantID == nil) == (item.VariantID == nil) &&
+			(line.VariantID == nil || *line.VariantID == *item.VariantID)
+		return line.ProductID == item.ProductID && sameVariant && line.Quantity-line.ReturnedQuantity >= item.Quantity
+	})
+}
+
+// restock puts quantity units returned from a sale line back in stock: those
+// of the product and variant, or of the components a bundle took, and the
+// lots they came from, recording the movements.
+func restock(ctx context.Context, tx repository.Store, saleID int64, line models.SaleItem, quantity int, operatorID int64, reason string) error {
+	if len(line.Components) > 0 {
+		for _, c := range line.Components {
+			if err := tx.Products().AdjustStock(ctx, c.ProductID, c.Quantity*quantity); err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to restore product stock")
+			}
+			if err := restockLots(ctx, tx, line, c.ProductID, c.ProductName, c.Quantity, quantity); err != nil {
+				return err
+			}
+			if err := recordStockMovement(ctx, tx, c.ProductID, nil, operatorID, models.StockMovementReturn, c.Quantity*quantity, reason, &saleID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Bundles have no stock of their own to put the units back in
+	bundle, err := isBundle(ctx, tx, line.ProductID)
+	if err != nil {
+		return newAPIError(http.StatusInternalServerError, "Failed to load bundle components")
+	}
+	if bundle {
+		return newAPIError(http.StatusConflict, fmt.Sprintf("%q was sold before it became a bundle; its units cannot be returned to stock", line.ProductName))
+	}
+	if line.VariantID == nil {
+		// Stock outside any variant would break the sum of a product's variants
+		found, err := hasVariants(ctx, tx, line.ProductID)
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product variants")
+		}
+		if found {
+			return newAPIError(http.StatusConflict, fmt.Sprintf("%q was sold before it had variants; its units cannot be returned to stock", line.ProductName))
+		}
+	}
+
+	if err := tx.Products().AdjustStock(ctx, line.ProductID, quantity); err != nil {
+		return newAPIError(http.StatusInternalServerError, "Failed to restore product stock")
+	}
+	if line.VariantID != nil {
+		if err := tx.Variants().AdjustStock(ctx, *line.VariantID, quantity); err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to restore variant stock")
+		}
+	}
+	if err := restockLots(ctx, tx, line, line.ProductID, line.ProductName, 1, quantity); err != nil {
+		return err
+	}
+	return recordStockMovement(ctx, tx, line.ProductID, line.VariantID, operatorID, models.StockMovementReturn, quantity, reason, &saleID)
+}
+
+func (s *server) getSaleRefundsHandler(w http.ResponseWriter, r *http.Request) {
+	saleID, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid sale ID")
+		return
+	}
+
+	refunds, err := s.store.Sales().ListRefunds(r.Context(), saleID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query refunds")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, refunds)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/auth"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultReservationTTL is how long stock reservations hold unless
+// RESERVATION_TTL says otherwise.
+const defaultReservationTTL = 30 * time.Minute
+
+// --- Stock Reservation Handlers ---
+
+func (s *server) getReservationsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	filter := repository.ReservationFilter{Status: query.Get("status"), Limit: limit, Offset: offset}
+	switch filter.Status {
+	case "", models.ReservationStatusActive, models.ReservationStatusConsumed, models.ReservationStatusReleased, models.ReservationStatusExpired:
+	default:
+		respondWithError(w, http.StatusBadRequest, "status must be one of active, consumed, released or expired")
+		return
+	}
+	if v := query.Get("productId"); v != "" {
+		productID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "productId must be a number")
+			return
+		}
+		filter.ProductID = &productID
+	}
+	if v := query.Get("quoteId"); v != "" {
+		quoteID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "quoteId must be a number")
+			return
+		}
+		filter.QuoteID = &quoteID
+	}
+
+	reservations, total, err := s.store.Reservations().List(r.Context(), filter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query reservations")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.Page{Data: reservations, Total: total, Limit: limit, Offset: offset})
+}
+
+// createReservationHandler holds units of a product, or of one of its
+// variants, out of its available stock for s.reservationTTL.
+func (s *server) createReservationHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateReservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.Quantity <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Quantity must be positive")
+		return
+	}
+
+	userID, _ := auth.UserID(r)
+	res := models.StockReservation{
+		ProductID: req.ProductID,
+		Quantity:  req.Quantity,
+		UserID:    userID,
+		Notes:     strings.TrimSpace(req.Notes),
+		ExpiresAt: time.Now().Add(s.reservationTTL),
+	}
+	err := s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		product, err := tx.Products().GetForUpdate(r.Context(), req.ProductID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusBadRequest, "Product not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product")
+		}
+		if err := rejectBundle(r.Context(), tx, product, "reserve its components instead"); err != nil {
+			return err
+		}
+		variant, err := productVariant(r.Context(), tx, product, req.VariantID)
+		if err != nil {
+			return err
+		}
+		if variant != nil {
+			res.VariantID = &variant.ID
+		}
+
+		err = tx.Reservations().Create(r.Context(), &res)
+		if errors.Is(err, repository.ErrInsufficientStock) {
+			return newAPIError(http.StatusConflict, "Not enough available stock to reserve")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to create reservation")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	created, err := s.store.Reservations().Get(r.Context(), res.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load reservation")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, created)
+}
+
+func (s *server) getReservationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid reservation ID")
+		return
+	}
+
+	res, err := s.store.Reservations().Get(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Reservation not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// releaseReservationHandler gives the units back to available stock. Only
+// the user who reserved them or a stock manager may release them.
+func (s *server) releaseReservationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid reservation ID")
+		return
+	}
+
+	res, err := s.store.Reservations().Get(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Reservation not found")
+		return
+	}
+	if !auth.IsSelf(r, res.UserID) && !auth.Can(r, auth.PermStockManage) {
+		respondWithError(w, http.StatusForbidden, "You can only release your own reservations")
+		return
+	}
+
+	err = s.store.Reservations().Close(r.Context(), id, models.ReservationStatusReleased)
+	if errors.Is(err, repository.ErrConflict) {
+		respondWithError(w, http.StatusConflict, "Reservation is no longer active")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to release reservation")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// stockKey identifies what a reservation holds: a product, or one of its
+// variants.
+type stockKey struct {
+	productID, variantID int64
+}
+
+func keyOf(productID int64, variantID *int64) stockKey {
+	key := stockKey{productID: productID}
+	if variantID != nil {
+		key.variantID = *variantID
+	}
+	return key
+}
+
+// consumeReservations takes from the reservations a sale charges the units
+// it sells, so they count as available to it. Each must still be active, be
+// for a product (and variant) in the sale and, unless may is nil, pass may.
+// A reservation holding more than the sale takes of its product keeps
+// holding the rest.
+func consumeReservations(ctx context.Context, tx repository.Store, ids []int64, items []models.CreateSaleItem, may func(models.StockReservation) bool) error {
+	sold := map[stockKey]int{}
+	for _, item := range items {
+		sold[keyOf(item.ProductID, item.VariantID)] += item.Quantity
+	}
+
+	for _, id := range ids {
+		res, err := tx.Reservations().Get(ctx, id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusBadRequest, fmt.Sprintf("Reservation %d not found", id))
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load reservation")
+		}
+		if may != nil && !may(res) {
+			return newAPIError(http.StatusForbidden, fmt.Sprintf("Reservation %d belongs to another user", id))
+		}
+		key := keyOf(res.ProductID, res.VariantID)
+		if _, ok := sold[key]; !ok {
+			return newAPIError(http.StatusBadRequest, fmt.Sprintf("Reservation %d is for a product not in the sale", id))
+		}
+		quantity := min(res.Quantity, sold[key])
+		if quantity == 0 {
+			return newAPIError(http.StatusBadRequest, fmt.Sprintf("Reservation %d holds units the sale does not take; the other reservations already cover it", id))
+		}
+
+		err = tx.Reservations().Consume(ctx, id, quantity)
+		if errors.Is(err, repository.ErrConflict) {
+			return newAPIError(http.StatusConflict, fmt.Sprintf("Reservation %d is no longer active", id))
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to consume reservation")
+		}
+		sold[key] -= quantity
+	}
+	return nil
+}
+
+// expireReservations marks lapsed reservations as expired every interval
+// until ctx is done. Lapsed reservations already stop holding stock; this
+// keeps their recorded status in line.
+func (s *server) expireReservations(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := s.store.Reservations().ExpireDue(ctx)
+			if err != nil {
+				log.Printf("Expiring stock reservations: %v", err)
+				continue
+			}
+			if expired > 0 {
+				log.Printf("Expired %d stock reservations", expired)
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"gestor-simples-ecs/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReservations(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+	joaoToken := env.token(env.createUser("joao", "vendedor"))
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 10)
+	if pen.Available != 10 || pen.Reserved != 0 {
+		t.Fatalf("new product stock: %+v", pen)
+	}
+
+	rec := env.do("POST", "/api/v1/reservations", sellerToken, models.CreateReservationRequest{ProductID: pen.ID, Quantity: 11})
+	expectStatus(t, rec, http.StatusConflict)
+
+	rec = env.do("POST", "/api/v1/reservations", sellerToken, models.CreateReservationRequest{ProductID: pen.ID, Quantity: 8, Notes: "Pedido aguardando boleto"})
+	expectStatus(t, rec, http.StatusCreated)
+	res := decode[models.StockReservation](t, rec)
+	if res.Status != models.ReservationStatusActive || res.ProductName != "Caneta" {
+		t.Fatalf("unexpected reservation: %+v", res)
+	}
+
+	got := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(pen.ID), adminToken, nil))
+	if got.Quantity != 10 || got.Reserved != 8 || got.Available != 2 {
+		t.Fatalf("stock with reservation: %+v", got)
+	}
+
+	// Other sales only get what is not reserved
+	rec = env.do("POST", "/api/v1/sales", joaoToken, models.CreateSaleRequest{Items: []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 3}}, Payments: pix(t, "7.50")})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	rec = env.do("DELETE", "/api/v1/reservations/"+itoa(res.ID), joaoToken, nil)
+	expectStatus(t, rec, http.StatusForbidden)
+
+	// The sale consuming the reservation can charge its units
+	rec = env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+		Items:          []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 9}},
+		Payments:       pix(t, "22.50"),
+		ReservationIDs: []int64{res.ID},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+
+	got = decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(pen.ID), adminToken, nil))
+	if got.Quantity != 1 || got.Reserved != 0 || got.Available != 1 {
+		t.Fatalf("stock after consuming the reservation: %+v", got)
+	}
+	res = decode[models.StockReservation](t, env.do("GET", "/api/v1/reservations/"+itoa(res.ID), sellerToken, nil))
+	if res.Status != models.ReservationStatusConsumed {
+		t.Fatalf("status = %s, want consumed", res.Status)
+	}
+
+	rec = env.do("DELETE", "/api/v1/reservations/"+itoa(res.ID), sellerToken, nil)
+	expectStatus(t, rec, http.StatusConflict)
+
+	// Only the user who reserved or a stock manager can sell reserved units,
+	// and a sale taking fewer leaves the rest reserved
+	eraser := env.createProduct(adminToken, "Borracha", "1.00", 10)
+	rec = env.do("POST", "/api/v1/reservations", sellerToken, models.CreateReservationRequest{ProductID: eraser.ID, Quantity: 6})
+	expectStatus(t, rec, http.StatusCreated)
+	res = decode[models.StockReservation](t, rec)
+	sell := func(token string, quantity int) *httptest.ResponseRecorder {
+		t.Helper()
+		return env.do("POST", "/api/v1/sales", token, models.CreateSaleRequest{
+			Items:          []models.CreateSaleItem{{ProductID: eraser.ID, Quantity: quantity}},
+			Payments:       pix(t, itoa(int64(quantity))+".00"),
+			ReservationIDs: []int64{res.ID},
+		})
+	}
+	expectStatus(t, sell(joaoToken, 4), http.StatusForbidden)
+
+	expectStatus(t, sell(sellerToken, 4), http.StatusCreated)
+	res = decode[models.StockReservation](t, env.do("GET", "/api/v1/reservations/"+itoa(res.ID), sellerToken, nil))
+	if res.Status != models.ReservationStatusActive || res.Quantity != 2 {
+		t.Fatalf("reservation after selling part of it = %+v", res)
+	}
+	got = decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(eraser.ID), adminToken, nil))
+	if got.Quantity != 6 || got.Reserved != 2 || got.Available != 4 {
+		t.Fatalf("stock after selling part of a reservation: %+v", got)
+	}
+
+	expectStatus(t, sell(adminToken, 3), http.StatusCreated)
+	res = decode[models.StockReservation](t, env.do("GET", "/api/v1/reservations/"+itoa(res.ID), sellerToken, nil))
+	if res.Status != models.ReservationStatusConsumed {
+		t.Fatalf("status = %s, want consumed", res.Status)
+	}
+	got = decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(eraser.ID), adminToken, nil))
+	if got.Quantity != 3 || got.Reserved != 0 || got.Available != 3 {
+		t.Fatalf("stock after consuming the rest of a reservation: %+v", got)
+	}
+}
+
+func TestReservationExpiry(t *testing.T) {
+	env := newTestEnv(t)
+	env.srv.reservationTTL = 50 * time.Millisecond
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 10)
+
+	rec := env.do("POST", "/api/v1/reservations", sellerToken, models.CreateReservationRequest{ProductID: pen.ID, Quantity: 10})
+	expectStatus(t, rec, http.StatusCreated)
+	res := decode[models.StockReservation](t, rec)
+
+	time.Sleep(100 * time.Millisecond)
+	if got := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(pen.ID), adminToken, nil)); got.Available != 10 {
+		t.Fatalf("available after expiry = %d, want 10", got.Available)
+	}
+
+	expired, err := env.store.Reservations().ExpireDue(context.Background())
+	if err != nil || expired != 1 {
+		t.Fatalf("ExpireDue = %d, %v; want 1", expired, err)
+	}
+	rec = env.do("GET", "/api/v1/reservations?status=expired", sellerToken, nil)
+	if reservations, total := pageOf[models.StockReservation](t, rec); total != 1 || reservations[0].ID != res.ID {
+		t.Fatalf("expired reservations: %+v", reservations)
+	}
+
+	rec = env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+		Items:          []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 1}},
+		Payments:       pix(t, "2.50"),
+		ReservationIDs: []int64{res.ID},
+	})
+	expectStatus(t, rec, http.StatusConflict)
+}
+
+func TestQuoteReservation(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+	joaoToken := env.token(env.createUser("joao", "vendedor"))
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 5)
+
+	rec := env.do("POST", "/api/v1/quotes", sellerToken, models.CreateQuoteRequest{Items: []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 4}}})
+	expectStatus(t, rec, http.StatusCreated)
+	quote := decode[models.Quote](t, rec)
+
+	rec = env.do("POST", "/api/v1/quotes/"+itoa(quote.ID)+"/reserve", sellerToken, nil)
+	expectStatus(t, rec, http.StatusCreated)
+	if reservations := decode[[]models.StockReservation](t, rec); len(reservations) != 1 || *reservations[0].QuoteID != quote.ID {
+		t.Fatalf("unexpected quote reservations: %+v", reservations)
+	}
+	rec = env.do("POST", "/api/v1/quotes/"+itoa(quote.ID)+"/reserve", sellerToken, nil)
+	expectStatus(t, rec, http.StatusConflict)
+
+	// The reserved units are not for sale to anyone else
+	rec = env.do("POST", "/api/v1/sales", joaoToken, models.CreateSaleRequest{Items: []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 2}}, Payments: pix(t, "5.00")})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	rec = env.do("POST", "/api/v1/quotes/"+itoa(quote.ID)+"/convert", sellerToken, models.ConvertQuoteRequest{Payments: pix(t, "10.00")})
+	expectStatus(t, rec, http.StatusCreated)
+
+	got := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(pen.ID), adminToken, nil))
+	if got.Quantity != 1 || got.Reserved != 0 || got.Available != 1 {
+		t.Fatalf("stock after converting the quote: %+v", got)
+	}
+}
+
+func TestVariantReservations(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+	joaoToken := env.token(env.createUser("joao", "vendedor"))
+	shirt := env.createProduct(adminToken, "Camiseta", "39.90", 0)
+	variant := func(name string, quantity int) models.ProductVariant {
+		t.Helper()
+		rec := env.do("POST", "/api/v1/products/"+itoa(shirt.ID)+"/variants", adminToken, models.ProductVariant{Name: name, Quantity: quantity})
+		expectStatus(t, rec, http.StatusCreated)
+		return decode[models.ProductVariant](t, rec)
+	}
+	medium, large := variant("M", 5), variant("G", 3)
+
+	expectStatus(t, env.do("POST", "/api/v1/reservations", sellerToken, models.CreateReservationRequest{ProductID: shirt.ID, Quantity: 1}), http.StatusBadRequest)
+	expectStatus(t, env.do("POST", "/api/v1/reservations", sellerToken, models.CreateReservationRequest{ProductID: shirt.ID, VariantID: &large.ID, Quantity: 4}), http.StatusConflict)
+
+	rec := env.do("POST", "/api/v1/reservations", sellerToken, models.CreateReservationRequest{ProductID: shirt.ID, VariantID: &large.ID, Quantity: 3})
+	expectStatus(t, rec, http.StatusCreated)
+	res := decode[models.StockReservation](t, rec)
+	if res.VariantID == nil || *res.VariantID != large.ID || res.VariantName != "G" {
+		t.Fatalf("unexpected reservation: %+v", res)
+	}
+
+	// The units count against the variant as well as the product
+	got := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(shirt.ID), adminToken, nil))
+	if got.Reserved != 3 || got.Available != 5 || got.Variants[1].Reserved != 3 || got.Variants[1].Available != 0 || got.Variants[0].Available != 5 {
+		t.Fatalf("stock with a variant reservation: %+v", got)
+	}
+	sell := func(token string, variantID int64, reservations ...int64) *httptest.ResponseRecorder {
+		t.Helper()
+		return env.do("POST", "/api/v1/sales", token, models.CreateSaleRequest{
+			Items:          []models.CreateSaleItem{{ProductID: shirt.ID, VariantID: &variantID, Quantity: 1}},
+			Payments:       pix(t, "39.90"),
+			ReservationIDs: reservations,
+		})
+	}
+	expectStatus(t, sell(joaoToken, large.ID), http.StatusBadRequest)
+	expectStatus(t, sell(joaoToken, medium.ID), http.StatusCreated)
+	expectStatus(t, sell(sellerToken, medium.ID, res.ID), http.StatusBadRequest)
+
+	// Quotes for the reserved variant are short of it
+	rec = env.do("POST", "/api/v1/quotes", joaoToken, models.CreateQuoteRequest{Items: []models.CreateSaleItem{
+		{ProductID: shirt.ID, VariantID: &medium.ID, Quantity: 1},
+		{ProductID: shirt.ID, VariantID: &large.ID, Quantity: 1},
+	}})
+	expectStatus(t, rec, http.StatusCreated)
+	quotePath := "/api/v1/quotes/" + itoa(decode[models.Quote](t, rec).ID)
+	rec = env.do("GET", quotePath+"/print", joaoToken, nil)
+	if body := rec.Body.String(); !strings.Contains(body, "Camiseta (M)") || !strings.Contains(body, "Camiseta (G)") {
+		t.Fatalf("printable quote without the variants: %s", body)
+	}
+	rec = env.do("POST", quotePath+"/reserve", joaoToken, nil)
+	expectStatus(t, rec, http.StatusConflict)
+	if short := decode[models.OutOfStockResponse](t, rec).OutOfStock; len(short) != 1 || short[0].VariantName != "G" || short[0].Requested != 1 || short[0].Available != 0 {
+		t.Fatalf("out of stock = %+v", short)
+	}
+
+	expectStatus(t, sell(sellerToken, large.ID, res.ID), http.StatusCreated)
+	res = decode[models.StockReservation](t, env.do("GET", "/api/v1/reservations/"+itoa(res.ID), sellerToken, nil))
+	if res.Status != models.ReservationStatusActive || res.Quantity != 2 {
+		t.Fatalf("reservation after selling part of it = %+v", res)
+	}
+}
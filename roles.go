@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/money"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// --- Role Handlers ---
+
+// adminRole always holds every permission and cannot be edited, so there is
+// no way to lock everyone out of role management.
+const adminRole = "admin"
+
+func (s *server) getPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	permissions, err := s.store.Roles().ListPermissions(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query permissions")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, permissions)
+}
+
+func (s *server) getRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := s.store.Roles().List(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query roles")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, roles)
+}
+
+func (s *server) createRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var role models.Role
+	if err := json.NewDecoder(r.Body).Decode(&role); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	role.Name = strings.TrimSpace(role.Name)
+	if role.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "Role name is required")
+		return
+	}
+	if !validMaxDiscount(role.MaxDiscount) {
+		respondWithError(w, http.StatusBadRequest, "maxDiscount must be between 0 and 100")
+		return
+	}
+
+	err := s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		var err error
+		if role.Permissions, err = checkPermissions(r.Context(), tx.Roles(), role.Permissions); err != nil {
+			return err
+		}
+
+		err = tx.Roles().Create(r.Context(), role)
+		if errors.Is(err, repository.ErrConflict) {
+			return newAPIError(http.StatusConflict, "Role already exists")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to create role")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, role)
+}
+
+// updateRolePermissionsHandler replaces every grant of a role.
+func (s *server) updateRolePermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if name == adminRole {
+		respondWithError(w, http.StatusConflict, "The admin role cannot be changed")
+		return
+	}
+
+	var req models.UpdateRolePermissionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	var role models.Role
+	err := s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		var err error
+		role, err = tx.Roles().Get(r.Context(), name)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusNotFound, "Role not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load role")
+		}
+
+		if role.Permissions, err = checkPermissions(r.Context(), tx.Roles(), req.Permissions); err != nil {
+			return err
+		}
+		if err := tx.Roles().SetPermissions(r.Context(), name, role.Permissions); err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to grant permissions")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, role)
+}
+
+// updateRoleMaxDiscountHandler sets or, with a null maxDiscount, removes the
+// largest discount the role may give without approval.
+func (s *server) updateRoleMaxDiscountHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if name == adminRole {
+		respondWithError(w, http.StatusConflict, "The admin role cannot be changed")
+		return
+	}
+
+	var req models.UpdateRoleMaxDiscountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if !validMaxDiscount(req.MaxDiscount) {
+		respondWithError(w, http.StatusBadRequest, "maxDiscount must be between 0 and 100")
+		return
+	}
+
+	var role models.Role
+	err := s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		err := tx.Roles().SetMaxDiscount(r.Context(), name, req.MaxDiscount)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusNotFound, "Role not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to update role")
+		}
+		if role, err = tx.Roles().Get(r.Context(), name); err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load role")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, role)
+}
+
+func validMaxDiscount(maxDiscount *money.Amount) bool {
+	return maxDiscount == nil || (*maxDiscount >= 0 && maxDiscount.Cents() <= hundredPercent)
+}
+
+func (s *server) deleteRoleHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if name == adminRole {
+		respondWithError(w, http.StatusConflict, "The admin role cannot be deleted")
+		return
+	}
+
+	err := s.store.Roles().Delete(r.Context(), name)
+	if errors.Is(err, repository.ErrInUse) {
+		respondWithError(w, http.StatusConflict, "Role is still assigned to users")
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Role not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete role")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkPermissions makes sure every permission exists and returns them
+// deduplicated and sorted.
+func checkPermissions(ctx context.Context, roles repository.RoleRepository, permissions []string) ([]string, error) {
+	unique := make(map[string]bool, len(permissions))
+	granted := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		if unique[p] {
+			continue
+		}
+		unique[p] = true
+
+		exists, err := roles.PermissionExists(ctx, p)
+		if err != nil {
+			return nil, newAPIError(http.StatusInternalServerError, "Failed to grant permissions")
+		}
+		if !exists {
+			return nil, newAPIError(http.StatusBadRequest, "Unknown permission")
+		}
+		granted = append(granted, p)
+	}
+
+	sort.Strings(granted)
+	return granted, nil
+}
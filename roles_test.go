@@ -0,0 +1,71 @@
+package main
+
+import (
+	"gestor-simples-ecs/internal/models"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestCreateRole(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+
+	rec := env.do("POST", "/api/v1/roles", adminToken, models.Role{Name: "caixa", Permissions: []string{"sales:create", "bogus"}})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	rec = env.do("POST", "/api/v1/roles", adminToken, models.Role{
+		Name:        "caixa",
+		Description: "Operador de caixa",
+		Permissions: []string{"sales:cancel", "sales:create", "sales:create"},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	if got := decode[models.Role](t, rec); !reflect.DeepEqual(got.Permissions, []string{"sales:cancel", "sales:create"}) {
+		t.Fatalf("permissions = %v", got.Permissions)
+	}
+
+	rec = env.do("POST", "/api/v1/roles", adminToken, models.Role{Name: "caixa"})
+	expectStatus(t, rec, http.StatusConflict)
+
+	// The new role is usable right away
+	cashier := env.token(env.createUser("carla", "caixa"))
+	expectStatus(t, env.do("GET", "/api/v1/sales/1/refunds", cashier, nil), http.StatusOK)
+	expectStatus(t, env.do("GET", "/api/v1/users", cashier, nil), http.StatusForbidden)
+}
+
+func TestUpdateRolePermissions(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+
+	expectStatus(t, env.do("GET", "/api/v1/products/reconciliation", sellerToken, nil), http.StatusForbidden)
+
+	rec := env.do("PUT", "/api/v1/roles/vendedor/permissions", adminToken, models.UpdateRolePermissionsRequest{
+		Permissions: []string{"sales:create", "stock:manage"},
+	})
+	expectStatus(t, rec, http.StatusOK)
+	expectStatus(t, env.do("GET", "/api/v1/products/reconciliation", sellerToken, nil), http.StatusOK)
+
+	rec = env.do("PUT", "/api/v1/roles/admin/permissions", adminToken, models.UpdateRolePermissionsRequest{})
+	expectStatus(t, rec, http.StatusConflict)
+	rec = env.do("PUT", "/api/v1/roles/missing/permissions", adminToken, models.UpdateRolePermissionsRequest{})
+	expectStatus(t, rec, http.StatusNotFound)
+	expectStatus(t, env.do("GET", "/api/v1/roles", sellerToken, nil), http.StatusForbidden)
+}
+
+func TestDeleteRole(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	env.createUser("maria", "vendedor")
+
+	expectStatus(t, env.do("DELETE", "/api/v1/roles/vendedor", adminToken, nil), http.StatusConflict)
+	expectStatus(t, env.do("DELETE", "/api/v1/roles/admin", adminToken, nil), http.StatusConflict)
+	expectStatus(t, env.do("DELETE", "/api/v1/roles/gerente", adminToken, nil), http.StatusNoContent)
+	expectStatus(t, env.do("DELETE", "/api/v1/roles/gerente", adminToken, nil), http.StatusNotFound)
+
+	rec := env.do("GET", "/api/v1/roles", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if roles := decode[[]models.Role](t, rec); len(roles) != 2 {
+		t.Fatalf("got %d roles, want 2", len(roles))
+	}
+}
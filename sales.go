@@ -0,0 +1,479 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/auth"
+	"gestor-simples-ecs/pkg/barcode"
+	"gestor-simples-ecs/pkg/money"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// --- Sales Handlers ---
+
+func (s *server) getSalesHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	filter := repository.SaleFilter{Limit: limit, Offset: offset}
+	if v := query.Get("userId"); v != "" {
+		sellerID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "userId must be a number")
+			return
+		}
+		filter.UserID = &sellerID
+	}
+	var ok bool
+	if filter.UserID, ok = restrictToOwnSales(w, r, filter.UserID); !ok {
+		return
+	}
+	if v := query.Get("productId"); v != "" {
+		productID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "productId must be a number")
+			return
+		}
+		filter.ProductID = &productID
+	}
+	if v := query.Get("customerId"); v != "" {
+		customerID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "customerId must be a number")
+			return
+		}
+		filter.CustomerID = &customerID
+	}
+	if filter.From, filter.Until, err = parseDateRange(r); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sales, total, err := s.store.Sales().List(r.Context(), filter)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query sales")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.Page{Data: sales, Total: total, Limit: limit, Offset: offset})
+}
+
+// restrictToOwnSales limits a listing of sales or quotes to the caller's own
+// unless they hold sales:view_all, and returns the seller to filter by.
+// Asking for another seller's records is answered with 403 and false.
+func restrictToOwnSales(w http.ResponseWriter, r *http.Request, sellerID *int64) (*int64, bool) {
+	if auth.Can(r, auth.PermSalesViewAll) {
+		return sellerID, true
+	}
+
+	userID, _ := auth.UserID(r)
+	if sellerID != nil && *sellerID != userID {
+		respondWithError(w, http.StatusForbidden, "You can only view your own sales")
+		return nil, false
+	}
+	return &userID, true
+}
+
+// getSaleHandler returns one sale with its items, seller and refunds.
+// Without sales:view_all, only the seller credited with the sale may see it.
+func (s *server) getSaleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid sale ID")
+		return
+	}
+
+	sale, err := s.store.Sales().Get(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Sale not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load sale")
+		return
+	}
+
+	if !auth.IsSelf(r, sale.UserID) && !auth.Can(r, auth.PermSalesViewAll) {
+		respondWithError(w, http.StatusForbidden, "You can only view your own sales")
+		return
+	}
+
+	detail := models.SaleDetail{Sale: sale}
+	if creator, err := s.store.Users().Get(r.Context(), sale.CreatedBy); err == nil {
+		detail.CreatedByName = creator.Name
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load sale")
+		return
+	}
+
+	detail.Payments, err = s.store.Sales().ListPayments(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query payments")
+		return
+	}
+
+	detail.Refunds, err = s.store.Sales().ListRefunds(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query refunds")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, detail)
+}
+
+func (s *server) createSaleHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateSaleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if err := validateSaleItems(req.Items); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateDiscount(req.Discount); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	payments, paid, err := preparePayments(req.Payments)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// The authenticated user always keys the sale. Sellers can only sell for
+	// themselves; managers and admins may book a sale on behalf of another seller.
+	userID, _ := auth.UserID(r)
+	sale := models.Sale{UserID: userID, CreatedBy: userID, CustomerID: req.CustomerID}
+	if req.UserID != 0 && req.UserID != userID {
+		if !auth.Can(r, auth.PermSalesCreateOnBehalf) {
+			respondWithError(w, http.StatusForbidden, "You can only register sales for yourself")
+			return
+		}
+		sale.UserID = req.UserID
+	}
+
+	// Permission checks go through the store, so resolve the limit and who
+	// may sell reserved units before the transaction
+	discountLimit, err := s.discountLimit(r)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to load discount limit")
+		return
+	}
+	manager := auth.Can(r, auth.PermStockManage)
+
+	order := checkout{
+		request:       req,
+		payments:      payments,
+		paid:          paid,
+		discountLimit: discountLimit,
+		mayReserve: func(res models.StockReservation) bool {
+			return manager || auth.IsSelf(r, res.UserID)
+		},
+	}
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		return placeSale(r.Context(), tx, &sale, order)
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	resp := models.CreateSaleResponse{SaleID: sale.ID}
+	for _, p := range payments {
+		resp.Change += p.Change
+	}
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+// validateSaleItems checks the items of a sale or quote request.
+func validateSaleItems(items []models.CreateSaleItem) error {
+	if len(items) == 0 {
+		return errors.New("a sale must have at least one item")
+	}
+	for _, item := range items {
+		named := 0
+		for _, set := range []bool{item.ProductID != 0, item.Barcode != "", item.SKU != ""} {
+			if set {
+				named++
+			}
+		}
+		if named != 1 {
+			return errors.New("each item needs exactly one of productId, barcode or sku")
+		}
+		if item.Quantity <= 0 {
+			return errors.New("item quantities must be positive")
+		}
+		if err := validateDiscount(item.Discount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveSaleItems returns a copy of items with the product of every item
+// given by barcode or SKU looked up and set in ProductID.
+func resolveSaleItems(ctx context.Context, tx repository.Store, items []models.CreateSaleItem) ([]models.CreateSaleItem, error) {
+	resolved := append([]models.CreateSaleItem{}, items...)
+	for i, item := range resolved {
+		if item.ProductID != 0 {
+			continue
+		}
+		product, err := productByCode(ctx, tx.Products(), item.Barcode, item.SKU)
+		if errors.Is(err, barcode.ErrInvalid) {
+			return nil, newAPIError(http.StatusBadRequest, fmt.Sprintf("Invalid barcode %q", item.Barcode))
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			if item.Barcode != "" {
+				return nil, newAPIError(http.StatusBadRequest, fmt.Sprintf("No product with barcode %s", item.Barcode))
+			}
+			return nil, newAPIError(http.StatusBadRequest, fmt.Sprintf("No product with SKU %s", item.SKU))
+		}
+		if err != nil {
+			return nil, newAPIError(http.StatusInternalServerError, "Failed to look up product")
+		}
+		resolved[i].ProductID, resolved[i].Barcode, resolved[i].SKU = product.ID, "", ""
+	}
+	return resolved, nil
+}
+
+// checkParties checks that the seller credited with a sale or quote keyed by
+// createdBy, and its customer if any, exist.
+func checkParties(ctx context.Context, tx repository.Store, sellerID, createdBy int64, customerID *int64) error {
+	if sellerID != createdBy {
+		_, err := tx.Users().Get(ctx, sellerID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusBadRequest, "Seller not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to look up seller")
+		}
+	}
+	if customerID != nil {
+		_, err := tx.Customers().Get(ctx, *customerID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusBadRequest, "Customer not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to look up customer")
+		}
+	}
+	return nil
+}
+
+// checkout is a validated sale request ready to be placed.
+type checkout struct {
+	request       models.CreateSaleRequest
+	prices        []money.Amount // Unit price per item locked by a quote; nil charges current prices
+	payments      []models.Payment
+	paid          money.Amount
+	discountLimit *money.Amount // Limit of the user keying the sale
+	// mayReserve reports whether the user keying the sale may charge a
+	// reservation the request names
+	mayReserve func(models.StockReservation) bool
+}
+
+// placeSale registers the sale inside tx: it consumes the reservations it
+// names, charges the products' available stock, applies promotions and
+// discounts, stores the sale with its items and payments and counts the
+// promotions used. sale carries the seller, the user keying it and the
+// customer; its ID is set on success.
+func placeSale(ctx context.Context, tx repository.Store, sale *models.Sale, order checkout) error {
+	req := order.request
+	if err := checkParties(ctx, tx, sale.UserID, sale.CreatedBy, sale.CustomerID); err != nil {
+		return err
+	}
+	items, err := resolveSaleItems(ctx, tx, req.Items)
+	if err != nil {
+		return err
+	}
+	req.Items = items
+	if err := consumeReservations(ctx, tx, req.ReservationIDs, req.Items, order.mayReserve); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	promotions, err := salePromotions(ctx, tx, req.CouponCode, now)
+	if err != nil {
+		return err
+	}
+
+	// Decrease product quantity, and that of the variant or lots sold if any,
+	// or the components of bundles, reading back the name, price charged and
+	// cost so later product changes don't rewrite this sale. Each line gets
+	// the best promotion running for it, and its own discount applies to
+	// what the promotion leaves.
+	lines := make([]models.SaleItem, len(req.Items))
+	values := make([]money.Amount, len(req.Items))
+	var subtotal, promoted money.Amount
+	var applied []models.Promotion
+	for i, item := range req.Items {
+		components, err := tx.Bundles().List(ctx, item.ProductID)
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load bundle components")
+		}
+		var (
+			product  models.Product
+			variant  *models.ProductVariant
+			consumed []models.SaleItemComponent
+			lots     []models.SaleItemLot
+		)
+		if len(components) > 0 {
+			if product, consumed, err = chargeBundle(ctx, tx, item, components); err != nil {
+				return err
+			}
+			for _, c := range consumed {
+				taken, err := consumeLots(ctx, tx, c.ProductID, c.ProductName, c.Quantity*item.Quantity)
+				if err != nil {
+					return err
+				}
+				lots = append(lots, taken...)
+			}
+		} else {
+			product, err = tx.Products().DecrementStock(ctx, item.ProductID, item.Quantity)
+			if errors.Is(err, repository.ErrInsufficientStock) {
+				return newAPIError(http.StatusBadRequest, "Insufficient available stock or product not found")
+			}
+			if err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to update product stock")
+			}
+			if variant, err = productVariant(ctx, tx, product, item.VariantID); err != nil {
+				return err
+			}
+			if lots, err = consumeLots(ctx, tx, product.ID, product.Name, item.Quantity); err != nil {
+				return err
+			}
+		}
+		if variant != nil {
+			if _, err := tx.Variants().DecrementStock(ctx, variant.ID, item.Quantity); errors.Is(err, repository.ErrInsufficientStock) {
+				return newAPIError(http.StatusBadRequest, fmt.Sprintf("Insufficient stock of %s %s", product.Name, variant.Name))
+			} else if err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to update variant stock")
+			}
+			if variant.Price != nil {
+				product.Price = *variant.Price
+			}
+		}
+		if order.prices != nil {
+			product.Price = order.prices[i]
+		}
+
+		var categories []int64
+		if product.CategoryID != nil {
+			if categories, err = tx.Categories().Ancestors(ctx, *product.CategoryID); err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to load product category")
+			}
+		}
+
+		gross := product.Price.Mul(item.Quantity)
+		promotion, promotionDiscount := bestPromotion(promotions, product, categories, item.Quantity)
+		discount, err := discountOn(item.Discount, gross-promotionDiscount)
+		if err != nil {
+			return newAPIError(http.StatusBadRequest, "Item discount: "+err.Error())
+		}
+		lines[i] = models.SaleItem{
+			ProductID:         item.ProductID,
+			ProductName:       product.Name,
+			CategoryID:        product.CategoryID,
+			Quantity:          item.Quantity,
+			UnitPrice:         product.Price,
+			UnitCost:          product.CostPrice,
+			Discount:          promotionDiscount + discount,
+			PromotionDiscount: promotionDiscount,
+			Components:        consumed,
+			Lots:              lots,
+		}
+		if variant != nil {
+			lines[i].VariantID, lines[i].VariantName = &variant.ID, variant.Name
+		}
+		if promotion != nil {
+			id := promotion.ID
+			lines[i].PromotionID = &id
+			if !containsPromotion(applied, id) {
+				applied = append(applied, *promotion)
+			}
+		}
+		values[i] = gross - promotionDiscount - discount
+		subtotal += gross
+		promoted += promotionDiscount
+	}
+	if req.CouponCode != "" && !appliesCoupon(applied) {
+		return newAPIError(http.StatusBadRequest, "Coupon does not apply to any item in the sale")
+	}
+
+	// The sale-level discount applies to what is left after item discounts
+	// and is spread over the lines, so returns refund what was paid.
+	var net money.Amount
+	for _, v := range values {
+		net += v
+	}
+	saleDiscount, err := discountOn(req.Discount, net)
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, "Sale discount: "+err.Error())
+	}
+	sale.SaleDiscount = saleDiscount
+	for i, share := range saleDiscount.Split(values) {
+		lines[i].Discount += share
+		if len(lines[i].Components) > 0 {
+			attributeRevenue(&lines[i])
+		}
+	}
+	total := net - saleDiscount
+
+	// Promotions are set up by admins, so only manual discounts count against the limit
+	if sale.DiscountApprovedBy, err = approveDiscount(ctx, tx, order.discountLimit, subtotal, subtotal-total-promoted, req.DiscountApproval); err != nil {
+		return err
+	}
+
+	// Create the sale record
+	if err := tx.Sales().Create(ctx, sale); err != nil {
+		return newAPIError(http.StatusInternalServerError, "Failed to create sale record")
+	}
+	for _, line := range lines {
+		if len(line.Components) == 0 {
+			if err := recordStockMovement(ctx, tx, line.ProductID, line.VariantID, sale.CreatedBy, models.StockMovementSale, -line.Quantity, "Sale", &sale.ID); err != nil {
+				return err
+			}
+		}
+		for _, c := range line.Components {
+			if err := recordStockMovement(ctx, tx, c.ProductID, nil, sale.CreatedBy, models.StockMovementSale, -c.Quantity*line.Quantity, "Sale of bundle "+line.ProductName, &sale.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Sales().AddItem(ctx, sale.ID, line); err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to record sale item")
+		}
+	}
+	for _, p := range applied {
+		err := tx.Promotions().Use(ctx, p.ID)
+		if errors.Is(err, repository.ErrConflict) {
+			return newAPIError(http.StatusConflict, fmt.Sprintf("Promotion %q has reached its usage limit", p.Name))
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to record promotion use")
+		}
+	}
+
+	// Prices are only known once the products are charged
+	if order.paid != total {
+		return newAPIError(http.StatusBadRequest, fmt.Sprintf("Payments add up to %s but the sale total is %s", order.paid, total))
+	}
+	for _, p := range order.payments {
+		if err := tx.Sales().AddPayment(ctx, sale.ID, p); err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to record payment")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"gestor-simples-ecs/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCreateSale(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	maria := env.createUser("maria", "vendedor")
+	sellerToken := env.token(maria)
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 10)
+	notebook := env.createProduct(adminToken, "Caderno", "15.90", 1)
+
+	// A failing line rolls back the whole sale
+	rec := env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{Items: []models.CreateSaleItem{
+		{ProductID: pen.ID, Quantity: 2},
+		{ProductID: notebook.ID, Quantity: 2},
+	}, Payments: pix(t, "36.80")})
+	expectStatus(t, rec, http.StatusBadRequest)
+	if got := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(pen.ID), adminToken, nil)); got.Quantity != 10 {
+		t.Fatalf("stock after failed sale = %d, want 10", got.Quantity)
+	}
+
+	rec = env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{Items: []models.CreateSaleItem{
+		{ProductID: pen.ID, Quantity: 2},
+		{ProductID: notebook.ID, Quantity: 1},
+	}, Payments: pix(t, "20.90")})
+	expectStatus(t, rec, http.StatusCreated)
+
+	// Later price changes do not rewrite the sale
+	rec = env.do("PUT", "/api/v1/products/"+itoa(pen.ID), adminToken, map[string]interface{}{"name": "Caneta", "price": "9.99", "quantity": 8})
+	expectStatus(t, rec, http.StatusOK)
+
+	rec = env.do("GET", "/api/v1/sales", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	sales, total := pageOf[models.Sale](t, rec)
+	if total != 1 {
+		t.Fatalf("got %d sales, want 1", total)
+	}
+	sale := sales[0]
+	if sale.UserID != maria.ID || sale.CreatedBy != maria.ID || sale.Status != models.SaleStatusCompleted {
+		t.Fatalf("unexpected sale: %+v", sale)
+	}
+	if sale.TotalPrice != mustParse(t, "20.90") {
+		t.Fatalf("total = %s, want 20.90", sale.TotalPrice)
+	}
+
+	rec = env.do("GET", "/api/v1/products/reconciliation", adminToken, nil)
+	if discrepancies := decode[[]models.StockDiscrepancy](t, rec); len(discrepancies) != 0 {
+		t.Fatalf("unexpected discrepancies: %+v", discrepancies)
+	}
+}
+
+func TestCreateSaleOnBehalf(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	maria := env.createUser("maria", "vendedor")
+	joao := env.createUser("joao", "vendedor")
+	manager := env.createUser("ana", "gerente")
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 10)
+	items := []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 1}}
+	payments := pix(t, "2.50")
+
+	rec := env.do("POST", "/api/v1/sales", env.token(maria), models.CreateSaleRequest{UserID: joao.ID, Items: items, Payments: payments})
+	expectStatus(t, rec, http.StatusForbidden)
+
+	managerToken := env.token(manager)
+	rec = env.do("POST", "/api/v1/sales", managerToken, models.CreateSaleRequest{UserID: 999, Items: items, Payments: payments})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	rec = env.do("POST", "/api/v1/sales", managerToken, models.CreateSaleRequest{UserID: joao.ID, Items: items, Payments: payments})
+	expectStatus(t, rec, http.StatusCreated)
+
+	rec = env.do("GET", "/api/v1/sales?userId="+itoa(joao.ID), adminToken, nil)
+	sales, total := pageOf[models.Sale](t, rec)
+	if total != 1 || sales[0].CreatedBy != manager.ID {
+		t.Fatalf("unexpected sales: %+v", sales)
+	}
+}
+
+func TestListSalesFilters(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 10)
+	notebook := env.createProduct(adminToken, "Caderno", "15.90", 10)
+
+	for _, p := range []models.Product{pen, notebook, pen} {
+		rec := env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+			Items:    []models.CreateSaleItem{{ProductID: p.ID, Quantity: 1}},
+			Payments: pix(t, p.Price.String()),
+		})
+		expectStatus(t, rec, http.StatusCreated)
+	}
+
+	rec := env.do("GET", "/api/v1/sales?productId="+itoa(pen.ID)+"&limit=1", adminToken, nil)
+	sales, total := pageOf[models.Sale](t, rec)
+	if total != 2 || len(sales) != 1 || sales[0].ID != 3 {
+		t.Fatalf("got %d of %d sales: %+v", len(sales), total, sales)
+	}
+
+	rec = env.do("GET", "/api/v1/sales?endDate=2000-01-01", adminToken, nil)
+	if _, total := pageOf[models.Sale](t, rec); total != 0 {
+		t.Fatalf("got %d sales before 2000", total)
+	}
+
+	// Dates are days on the store's clock
+	day := time.Now().In(storeLocation).Format("2006-01-02")
+	rec = env.do("GET", "/api/v1/sales?startDate="+day+"&endDate="+day, adminToken, nil)
+	if _, total := pageOf[models.Sale](t, rec); total != 3 {
+		t.Fatalf("got %d sales on %s", total, day)
+	}
+	from, until, err := parseDateRange(httptest.NewRequest("GET", "/?startDate=2024-03-01&endDate=2024-03-31", nil))
+	if err != nil || !from.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, storeLocation)) || !until.Equal(time.Date(2024, 4, 1, 0, 0, 0, 0, storeLocation)) {
+		t.Fatalf("parseDateRange = %v, %v, %v", from, until, err)
+	}
+
+	expectStatus(t, env.do("GET", "/api/v1/sales?startDate=yesterday", adminToken, nil), http.StatusBadRequest)
+}
+
+func TestCancelAndReturnSale(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 10)
+	notebook := env.createProduct(adminToken, "Caderno", "15.90", 10)
+
+	rec := env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{Items: []models.CreateSaleItem{
+		{ProductID: pen.ID, Quantity: 4},
+		{ProductID: notebook.ID, Quantity: 1},
+	}, Payments: pix(t, "25.90")})
+	expectStatus(t, rec, http.StatusCreated)
+	salePath := "/api/v1/sales/" + itoa(decode[models.CreateSaleResponse](t, rec).SaleID)
+
+	expectStatus(t, env.do("POST", salePath+"/cancel", sellerToken, models.CancelSaleRequest{Reason: "Desistiu"}), http.StatusForbidden)
+
+	rec = env.do("POST", salePath+"/returns", adminToken, models.ReturnSaleItemsRequest{
+		Reason: "Defeito", Items: []models.SaleItem{{ProductID: pen.ID, Quantity: 5}},
+	})
+	expectStatus(t, rec, http.StatusBadRequest)
+
+	rec = env.do("POST", salePath+"/returns", adminToken, models.ReturnSaleItemsRequest{
+		Reason: "Defeito", Items: []models.SaleItem{{ProductID: pen.ID, Quantity: 1}},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	if refund := decode[models.Refund](t, rec); refund.TotalAmount != mustParse(t, "2.50") {
+		t.Fatalf("return total = %s, want 2.50", refund.TotalAmount)
+	}
+
+	rec = env.do("POST", salePath+"/cancel", adminToken, models.CancelSaleRequest{Reason: "Desistiu"})
+	expectStatus(t, rec, http.StatusCreated)
+	refund := decode[models.Refund](t, rec)
+	if refund.TotalAmount != mustParse(t, "23.40") || len(refund.Items) != 2 {
+		t.Fatalf("unexpected cancellation: %+v", refund)
+	}
+
+	expectStatus(t, env.do("POST", salePath+"/cancel", adminToken, models.CancelSaleRequest{Reason: "De novo"}), http.StatusConflict)
+	expectStatus(t, env.do("POST", "/api/v1/sales/999/cancel", adminToken, models.CancelSaleRequest{Reason: "x"}), http.StatusNotFound)
+
+	rec = env.do("GET", salePath+"/refunds", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if refunds := decode[[]models.Refund](t, rec); len(refunds) != 2 {
+		t.Fatalf("got %d refunds, want 2", len(refunds))
+	}
+
+	for _, p := range []models.Product{pen, notebook} {
+		if got := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(p.ID), adminToken, nil)); got.Quantity != 10 {
+			t.Fatalf("%s stock = %d, want 10", p.Name, got.Quantity)
+		}
+	}
+	rec = env.do("GET", "/api/v1/products/reconciliation", adminToken, nil)
+	if discrepancies := decode[[]models.StockDiscrepancy](t, rec); len(discrepancies) != 0 {
+		t.Fatalf("unexpected discrepancies: %+v", discrepancies)
+	}
+}
+
+func TestGetSale(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	maria := env.createUser("maria", "vendedor")
+	joao := env.createUser("joao", "vendedor")
+	manager := env.createUser("ana", "gerente")
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 10)
+
+	rec := env.do("POST", "/api/v1/sales", env.token(manager), models.CreateSaleRequest{
+		UserID: maria.ID, Items: []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 3}}, Payments: pix(t, "7.50"),
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	salePath := "/api/v1/sales/" + itoa(decode[models.CreateSaleResponse](t, rec).SaleID)
+	rec = env.do("POST", salePath+"/returns", adminToken, models.ReturnSaleItemsRequest{
+		Reason: "Defeito", Items: []models.SaleItem{{ProductID: pen.ID, Quantity: 1}},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+
+	rec = env.do("GET", salePath, env.token(maria), nil)
+	expectStatus(t, rec, http.StatusOK)
+	sale := decode[models.SaleDetail](t, rec)
+	if sale.SellerName != "maria" || sale.CreatedByName != "ana" || sale.Status != models.SaleStatusCompleted {
+		t.Fatalf("unexpected sale: %+v", sale)
+	}
+	if len(sale.Items) != 1 || sale.Items[0].UnitPrice != mustParse(t, "2.50") || sale.Items[0].ReturnedQuantity != 1 {
+		t.Fatalf("unexpected items: %+v", sale.Items)
+	}
+	if sale.TotalPrice != mustParse(t, "7.50") || sale.RefundedTotal != mustParse(t, "2.50") || len(sale.Refunds) != 1 {
+		t.Fatalf("unexpected totals: %+v", sale)
+	}
+
+	joaoToken := env.token(joao)
+	expectStatus(t, env.do("GET", salePath, joaoToken, nil), http.StatusForbidden)
+	expectStatus(t, env.do("GET", salePath, adminToken, nil), http.StatusOK)
+	expectStatus(t, env.do("GET", "/api/v1/sales/999", adminToken, nil), http.StatusNotFound)
+
+	// Sellers only list their own sales
+	rec = env.do("GET", "/api/v1/sales", joaoToken, nil)
+	if _, total := pageOf[models.Sale](t, rec); total != 0 {
+		t.Fatalf("joao sees %d sales, want 0", total)
+	}
+	expectStatus(t, env.do("GET", "/api/v1/sales?userId="+itoa(maria.ID), joaoToken, nil), http.StatusForbidden)
+}
+
+func TestSalePayments(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 10)
+	items := []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 4}} // 10.00
+
+	for name, payments := range map[string][]models.Payment{
+		"none":                  nil,
+		"short":                 pix(t, "9.99"),
+		"unknown method":        {{Method: "cheque", Amount: mustParse(t, "10")}},
+		"installments on pix":   {{Method: models.PaymentMethodPix, Amount: mustParse(t, "10"), Installments: 2}},
+		"too many installments": {{Method: models.PaymentMethodCreditCard, Amount: mustParse(t, "10"), Installments: 13}},
+		"cash short":            {{Method: models.PaymentMethodCash, Amount: mustParse(t, "10"), Tendered: mustParse(t, "5")}},
+		"tendered on card":      {{Method: models.PaymentMethodDebitCard, Amount: mustParse(t, "10"), Tendered: mustParse(t, "20")}},
+	} {
+		rec := env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{Items: items, Payments: payments})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400; body: %s", name, rec.Code, rec.Body.String())
+		}
+	}
+	// Rejected payments leave the stock untouched
+	if got := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(pen.ID), adminToken, nil)); got.Quantity != 10 {
+		t.Fatalf("stock = %d, want 10", got.Quantity)
+	}
+
+	rec := env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{Items: items, Payments: []models.Payment{
+		{Method: models.PaymentMethodCash, Amount: mustParse(t, "3.50"), Tendered: mustParse(t, "5")},
+		{Method: models.PaymentMethodCreditCard, Amount: mustParse(t, "6.50")},
+	}})
+	expectStatus(t, rec, http.StatusCreated)
+	created := decode[models.CreateSaleResponse](t, rec)
+	if created.Change != mustParse(t, "1.50") {
+		t.Fatalf("change = %s, want 1.50", created.Change)
+	}
+
+	rec = env.do("GET", "/api/v1/sales/"+itoa(created.SaleID), sellerToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	sale := decode[models.SaleDetail](t, rec)
+	want := []models.Payment{
+		{Method: models.PaymentMethodCash, Amount: mustParse(t, "3.50"), Tendered: mustParse(t, "5"), Change: mustParse(t, "1.50")},
+		{Method: models.PaymentMethodCreditCard, Amount: mustParse(t, "6.50"), Installments: 1},
+	}
+	if !reflect.DeepEqual(sale.Payments, want) {
+		t.Fatalf("payments = %+v, want %+v", sale.Payments, want)
+	}
+
+	// Sales that come to nothing need no payment
+	rec = env.do("POST", "/api/v1/sales", adminToken, models.CreateSaleRequest{Items: []models.CreateSaleItem{{
+		ProductID: pen.ID, Quantity: 1, Discount: &models.Discount{Type: models.DiscountTypePercentage, Value: mustParse(t, "100")},
+	}}})
+	expectStatus(t, rec, http.StatusCreated)
+	rec = env.do("GET", "/api/v1/sales/"+itoa(decode[models.CreateSaleResponse](t, rec).SaleID), adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if free := decode[models.SaleDetail](t, rec); free.TotalPrice != 0 || len(free.Payments) != 0 {
+		t.Fatalf("free sale = %+v", free)
+	}
+}
+
+func TestSaleDiscounts(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	maria := env.createUser("maria", "vendedor")
+	mariaToken := env.token(maria)
+	env.createUser("ana", "gerente")
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 10)
+	notebook := env.createProduct(adminToken, "Caderno", "15.90", 10)
+
+	// Subtotal 25.90; 10% off the pens is 1.00
+	items := []models.CreateSaleItem{
+		{ProductID: pen.ID, Quantity: 4, Discount: &models.Discount{Type: models.DiscountTypePercentage, Value: mustParse(t, "10")}},
+		{ProductID: notebook.ID, Quantity: 1},
+	}
+	sale := func(discount string, approval *models.DiscountApproval, total string) models.CreateSaleRequest {
+		return models.CreateSaleRequest{
+			Items:            items,
+			Discount:         &models.Discount{Type: models.DiscountTypeAmount, Value: mustParse(t, discount)},
+			DiscountApproval: approval,
+			Payments:         pix(t, total),
+		}
+	}
+
+	invalid := []models.CreateSaleRequest{
+		{Items: []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 1, Discount: &models.Discount{Type: models.DiscountTypePercentage, Value: mustParse(t, "150")}}}, Payments: pix(t, "1")},
+		{Items: []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 1, Discount: &models.Discount{Type: models.DiscountTypeAmount, Value: mustParse(t, "3")}}}, Payments: pix(t, "1")},
+		{Items: []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 1, Discount: &models.Discount{Type: "coupon", Value: mustParse(t, "1")}}}, Payments: pix(t, "1")},
+		sale("25", nil, "0.01"),
+	}
+	for i, req := range invalid {
+		if rec := env.do("POST", "/api/v1/sales", mariaToken, req); rec.Code != http.StatusBadRequest {
+			t.Errorf("invalid request %d: status = %d, want 400; body: %s", i, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Sellers may give up to 5% of the subtotal (1.29) on their own
+	expectStatus(t, env.do("POST", "/api/v1/sales", mariaToken, sale("0.30", nil, "24.60")), http.StatusForbidden)
+	expectStatus(t, env.do("POST", "/api/v1/sales", mariaToken, sale("0.30", &models.DiscountApproval{Username: "admin", Password: "wrong"}, "24.60")), http.StatusForbidden)
+	expectStatus(t, env.do("POST", "/api/v1/sales", mariaToken, sale("0.30", &models.DiscountApproval{Username: "ana", Password: testPassword}, "24.60")), http.StatusForbidden)
+
+	rec := env.do("POST", "/api/v1/sales", mariaToken, sale("0.30", &models.DiscountApproval{Username: "admin", Password: testPassword}, "24.60"))
+	expectStatus(t, rec, http.StatusCreated)
+	approved := decode[models.CreateSaleResponse](t, rec).SaleID
+	if got := decode[models.SaleDetail](t, env.do("GET", "/api/v1/sales/"+itoa(approved), adminToken, nil)); got.DiscountApprovedBy == nil {
+		t.Fatalf("approved sale has no approver: %+v", got)
+	}
+
+	rec = env.do("POST", "/api/v1/sales", mariaToken, sale("0.20", nil, "24.70"))
+	expectStatus(t, rec, http.StatusCreated)
+	salePath := "/api/v1/sales/" + itoa(decode[models.CreateSaleResponse](t, rec).SaleID)
+
+	// The 0.20 sale discount is spread over the lines after their own discounts (9.00 and 15.90)
+	got := decode[models.SaleDetail](t, env.do("GET", salePath, mariaToken, nil))
+	if got.Subtotal != mustParse(t, "25.90") || got.SaleDiscount != mustParse(t, "0.20") || got.DiscountTotal != mustParse(t, "1.20") || got.TotalPrice != mustParse(t, "24.70") {
+		t.Fatalf("unexpected totals: %+v", got)
+	}
+	if got.Items[0].Discount != mustParse(t, "1.07") || got.Items[1].Discount != mustParse(t, "0.13") || got.DiscountApprovedBy != nil {
+		t.Fatalf("unexpected items: %+v", got.Items)
+	}
+
+	// Returns refund the discounted value, the last one taking the rounding
+	for _, c := range []struct {
+		quantity int
+		want     string
+	}{{1, "2.23"}, {3, "6.70"}} {
+		rec = env.do("POST", salePath+"/returns", adminToken, models.ReturnSaleItemsRequest{
+			Reason: "Defeito", Items: []models.SaleItem{{ProductID: pen.ID, Quantity: c.quantity}},
+		})
+		expectStatus(t, rec, http.StatusCreated)
+		if refund := decode[models.Refund](t, rec); refund.TotalAmount != mustParse(t, c.want) {
+			t.Fatalf("returning %d pens refunded %s, want %s", c.quantity, refund.TotalAmount, c.want)
+		}
+	}
+
+	// Dashboards count what was paid: 24.60 + 24.70 - 8.93
+	rec = env.do("GET", "/api/v1/dashboard/summary", mariaToken, nil)
+	if summary := decode[models.VendedorDashboardSummary](t, rec); summary.MyTotalSalesMonth != mustParse(t, "40.37") || summary.Commissions != mustParse(t, "4.04") {
+		t.Fatalf("seller summary = %+v", summary)
+	}
+}
+
+func TestRoleMaxDiscount(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+	pen := env.createProduct(adminToken, "Caneta", "2.50", 10)
+	req := models.CreateSaleRequest{
+		Items:    []models.CreateSaleItem{{ProductID: pen.ID, Quantity: 4}},
+		Discount: &models.Discount{Type: models.DiscountTypePercentage, Value: mustParse(t, "15")},
+		Payments: pix(t, "8.50"),
+	}
+	expectStatus(t, env.do("POST", "/api/v1/sales", sellerToken, req), http.StatusForbidden)
+
+	limit := mustParse(t, "150")
+	expectStatus(t, env.do("PUT", "/api/v1/roles/vendedor/max-discount", adminToken, models.UpdateRoleMaxDiscountRequest{MaxDiscount: &limit}), http.StatusBadRequest)
+	expectStatus(t, env.do("PUT", "/api/v1/roles/admin/max-discount", adminToken, models.UpdateRoleMaxDiscountRequest{}), http.StatusConflict)
+	expectStatus(t, env.do("PUT", "/api/v1/roles/nobody/max-discount", adminToken, models.UpdateRoleMaxDiscountRequest{}), http.StatusNotFound)
+	expectStatus(t, env.do("PUT", "/api/v1/roles/vendedor/max-discount", sellerToken, models.UpdateRoleMaxDiscountRequest{}), http.StatusForbidden)
+
+	limit = mustParse(t, "20")
+	rec := env.do("PUT", "/api/v1/roles/vendedor/max-discount", adminToken, models.UpdateRoleMaxDiscountRequest{MaxDiscount: &limit})
+	expectStatus(t, rec, http.StatusOK)
+	if role := decode[models.Role](t, rec); role.MaxDiscount == nil || *role.MaxDiscount != limit {
+		t.Fatalf("unexpected role: %+v", role)
+	}
+	expectStatus(t, env.do("POST", "/api/v1/sales", sellerToken, req), http.StatusCreated)
+}
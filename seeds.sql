@@ -11,18 +11,37 @@ INSERT INTO Users (name, username, password_hash, role) VALUES
 ('Maria Santos', 'maria.santos', '$2a$10$Q7.Y.aD5.d6t.9sR/6U6OOE/5.1J2A1QY6.Rz.F3B8c3j2a7C5y.O', 'vendedor'); -- password123
 
 -- Produtos
-INSERT INTO Products (name, description, quantity, price) VALUES
-('Produto A', 'Descrição detalhada do Produto A.', 150, 29.99),
-('Produto B', 'Descrição detalhada do Produto B.', 45, 199.90),
-('Produto C', 'Novo produto adicionado.', 200, 50.00);
+INSERT INTO Products (name, description, category, quantity, price) VALUES
+('Produto A', 'Descrição detalhada do Produto A.', 'Bebidas', 150, 29.99),
+('Produto B', 'Descrição detalhada do Produto B.', '', 45, 199.90),
+('Produto C', 'Novo produto adicionado.', 'Papelaria', 200, 50.00);
+
+-- Promoções: happy hour de bebidas e um cupom de uso limitado
+INSERT INTO Promotions (name, type, code, percentage, category, happy_hour_start, happy_hour_end, usage_limit) VALUES
+('Happy hour de bebidas', 'percentage', NULL, 15, 'Bebidas', '17:00', '19:00', NULL),
+('Volta às aulas', 'percentage', 'VOLTA10', 10, 'Papelaria', NULL, NULL, 100);
 
 -- Vendas
 -- Uma venda feita por João Silva (user_id = 2)
-INSERT INTO Sales (user_id, date) VALUES
-(2, '2025-11-20 14:30:00');
+INSERT INTO Sales (user_id, created_by, date) VALUES
+(2, 2, '2025-11-20 14:30:00');
 
 -- Itens de Venda para a venda acima
 -- 2 unidades do Produto A e 1 unidade do Produto B
-INSERT INTO Sales_Items (sale_id, product_id, quantity) VALUES
-(1, 1, 2),
-(1, 2, 1);
+INSERT INTO Sales_Items (sale_id, product_id, product_name, quantity, unit_price) VALUES
+(1, 1, 'Produto A', 2, 29.99),
+(1, 2, 'Produto B', 1, 199.90);
+
+-- Pagamentos da venda acima (total 259.88): parte em dinheiro, com troco, e parte no cartão de crédito em 3x
+INSERT INTO Sale_Payments (sale_id, method, amount, installments, tendered, change) VALUES
+(1, 'cash', 59.88, 0, 60.00, 0.12),
+(1, 'credit_card', 200.00, 3, 0, 0);
+
+-- Movimentações de estoque
+-- Saldo inicial de cada produto (quantidade atual + itens já vendidos) e a baixa da venda acima
+INSERT INTO Stock_Movements (product_id, user_id, type, quantity_delta, reason, sale_id, date) VALUES
+(1, 1, 'initial', 152, 'Initial stock', NULL, '2025-11-01 09:00:00'),
+(2, 1, 'initial', 46, 'Initial stock', NULL, '2025-11-01 09:00:00'),
+(3, 1, 'initial', 200, 'Initial stock', NULL, '2025-11-01 09:00:00'),
+(1, 2, 'sale', -2, 'Sale', 1, '2025-11-20 14:30:00'),
+(2, 2, 'sale', -1, 'Sale', 1, '2025-11-20 14:30:00');
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/auth"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// server holds the dependencies shared by every handler.
+type server struct {
+	store          repository.Store
+	reservationTTL time.Duration // How long stock reservations hold
+}
+
+func newServer(store repository.Store) *server {
+	return &server{store: store, reservationTTL: defaultReservationTTL}
+}
+
+// routes builds the API router.
+func (s *server) routes() http.Handler {
+	r := mux.NewRouter()
+	api := r.PathPrefix("/api/v1").Subrouter()
+
+	// Authentication routes
+	api.HandleFunc("/auth/login", s.loginHandler).Methods("POST")
+	api.HandleFunc("/auth/register", s.registerHandler).Methods("POST")
+	api.HandleFunc("/auth/refresh", s.refreshHandler).Methods("POST")
+	api.Handle("/auth/logout", auth.AuthMiddleware(http.HandlerFunc(s.logoutHandler))).Methods("POST")
+
+	// User routes
+	userRouter := api.PathPrefix("/users").Subrouter()
+	userRouter.Use(auth.AuthMiddleware) // Protect all user routes
+	userRouter.HandleFunc("", requirePermission(auth.PermUsersRead, s.getUsersHandler)).Methods("GET")
+	userRouter.HandleFunc("", requirePermission(auth.PermUsersWrite, s.createUserHandler)).Methods("POST")
+	userRouter.HandleFunc("/{id}", selfOrPermission(auth.PermUsersRead, s.getUserHandler)).Methods("GET")
+	userRouter.HandleFunc("/{id}", selfOrPermission(auth.PermUsersWrite, s.updateUserHandler)).Methods("PUT")
+	userRouter.HandleFunc("/{id}", requirePermission(auth.PermUsersWrite, s.deleteUserHandler)).Methods("DELETE")
+	userRouter.HandleFunc("/{id}/sessions", requirePermission(auth.PermUsersWrite, s.revokeUserSessionsHandler)).Methods("DELETE")
+
+	// Role and permission routes
+	roleRouter := api.PathPrefix("/roles").Subrouter()
+	roleRouter.Use(auth.AuthMiddleware)
+	roleRouter.Use(auth.RequirePermission(auth.PermRolesManage))
+	roleRouter.HandleFunc("", s.getRolesHandler).Methods("GET")
+	roleRouter.HandleFunc("", s.createRoleHandler).Methods("POST")
+	roleRouter.HandleFunc("/{name}/permissions", s.updateRolePermissionsHandler).Methods("PUT")
+	roleRouter.HandleFunc("/{name}/max-discount", s.updateRoleMaxDiscountHandler).Methods("PUT")
+	roleRouter.HandleFunc("/{name}", s.deleteRoleHandler).Methods("DELETE")
+	api.Handle("/permissions", auth.AuthMiddleware(auth.RequirePermission(auth.PermRolesManage)(http.HandlerFunc(s.getPermissionsHandler)))).Methods("GET")
+
+	// Product routes
+	productRouter := api.PathPrefix("/products").Subrouter()
+	productRouter.Use(auth.AuthMiddleware)
+	productRouter.HandleFunc("", s.getProductsHandler).Methods("GET")
+	productRouter.HandleFunc("", requirePermission(auth.PermProductsWrite, s.createProductHandler)).Methods("POST")
+	productRouter.HandleFunc("/lookup", s.getProductByCodeHandler).Methods("GET")
+	productRouter.HandleFunc("/lots/expiring", s.getExpiringLotsHandler).Methods("GET")
+	productRouter.HandleFunc("/reconciliation", requirePermission(auth.PermStockManage, s.getStockReconciliationHandler)).Methods("GET")
+	productRouter.HandleFunc("/{id}", s.getProductHandler).Methods("GET")
+	productRouter.HandleFunc("/{id}", requirePermission(auth.PermProductsWrite, s.updateProductHandler)).Methods("PUT")
+	productRouter.HandleFunc("/{id}", requirePermission(auth.PermProductsWrite, s.deleteProductHandler)).Methods("DELETE")
+	productRouter.HandleFunc("/{id}/movements", requirePermission(auth.PermStockManage, s.getProductMovementsHandler)).Methods("GET")
+	productRouter.HandleFunc("/{id}/movements", requirePermission(auth.PermStockManage, s.createProductMovementHandler)).Methods("POST")
+	productRouter.HandleFunc("/{id}/variants", s.getProductVariantsHandler).Methods("GET")
+	productRouter.HandleFunc("/{id}/variants", requirePermission(auth.PermProductsWrite, s.createProductVariantHandler)).Methods("POST")
+	productRouter.HandleFunc("/{id}/variants/{variantId}", requirePermission(auth.PermProductsWrite, s.updateProductVariantHandler)).Methods("PUT")
+	productRouter.HandleFunc("/{id}/variants/{variantId}", requirePermission(auth.PermProductsWrite, s.deleteProductVariantHandler)).Methods("DELETE")
+	productRouter.HandleFunc("/{id}/lots", s.getProductLotsHandler).Methods("GET")
+	productRouter.HandleFunc("/{id}/lots", requirePermission(auth.PermStockManage, s.createProductLotHandler)).Methods("POST")
+	productRouter.HandleFunc("/{id}/lots/{lotId}", requirePermission(auth.PermStockManage, s.updateProductLotHandler)).Methods("PUT")
+	productRouter.HandleFunc("/{id}/lots/{lotId}", requirePermission(auth.PermStockManage, s.deleteProductLotHandler)).Methods("DELETE")
+	productRouter.HandleFunc("/{id}/components", requirePermission(auth.PermProductsWrite, s.setProductComponentsHandler)).Methods("PUT")
+
+	// Category routes
+	categoryRouter := api.PathPrefix("/categories").Subrouter()
+	categoryRouter.Use(auth.AuthMiddleware)
+	categoryRouter.HandleFunc("", s.getCategoriesHandler).Methods("GET")
+	categoryRouter.HandleFunc("", requirePermission(auth.PermProductsWrite, s.createCategoryHandler)).Methods("POST")
+	categoryRouter.HandleFunc("/{id}", s.getCategoryHandler).Methods("GET")
+	categoryRouter.HandleFunc("/{id}", requirePermission(auth.PermProductsWrite, s.updateCategoryHandler)).Methods("PUT")
+	categoryRouter.HandleFunc("/{id}", requirePermission(auth.PermProductsWrite, s.deleteCategoryHandler)).Methods("DELETE")
+
+	// Supplier routes
+	supplierRouter := api.PathPrefix("/suppliers").Subrouter()
+	supplierRouter.Use(auth.AuthMiddleware)
+	supplierRouter.Use(auth.RequirePermission(auth.PermStockManage))
+	supplierRouter.HandleFunc("", s.getSuppliersHandler).Methods("GET")
+	supplierRouter.HandleFunc("", s.createSupplierHandler).Methods("POST")
+	supplierRouter.HandleFunc("/{id}", s.getSupplierHandler).Methods("GET")
+	supplierRouter.HandleFunc("/{id}", s.updateSupplierHandler).Methods("PUT")
+	supplierRouter.HandleFunc("/{id}", s.deleteSupplierHandler).Methods("DELETE")
+
+	// Purchase order routes
+	purchaseOrderRouter := api.PathPrefix("/purchase-orders").Subrouter()
+	purchaseOrderRouter.Use(auth.AuthMiddleware)
+	purchaseOrderRouter.Use(auth.RequirePermission(auth.PermStockManage))
+	purchaseOrderRouter.HandleFunc("", s.getPurchaseOrdersHandler).Methods("GET")
+	purchaseOrderRouter.HandleFunc("", s.createPurchaseOrderHandler).Methods("POST")
+	purchaseOrderRouter.HandleFunc("/incoming", s.getIncomingStockHandler).Methods("GET")
+	purchaseOrderRouter.HandleFunc("/{id}", s.getPurchaseOrderHandler).Methods("GET")
+	purchaseOrderRouter.HandleFunc("/{id}/receive", s.receivePurchaseOrderHandler).Methods("POST")
+	purchaseOrderRouter.HandleFunc("/{id}/cancel", s.cancelPurchaseOrderHandler).Methods("POST")
+
+	// Customer routes
+	customerRouter := api.PathPrefix("/customers").Subrouter()
+	customerRouter.Use(auth.AuthMiddleware)
+	customerRouter.HandleFunc("", s.getCustomersHandler).Methods("GET")
+	customerRouter.HandleFunc("", requirePermission(auth.PermCustomersWrite, s.createCustomerHandler)).Methods("POST")
+	customerRouter.HandleFunc("/{id}", s.getCustomerHandler).Methods("GET")
+	customerRouter.HandleFunc("/{id}", requirePermission(auth.PermCustomersWrite, s.updateCustomerHandler)).Methods("PUT")
+	customerRouter.HandleFunc("/{id}", requirePermission(auth.PermCustomersWrite, s.deleteCustomerHandler)).Methods("DELETE")
+	customerRouter.HandleFunc("/{id}/sales", s.getCustomerSalesHandler).Methods("GET")
+	customerRouter.HandleFunc("/{id}/metrics", requirePermission(auth.PermSalesViewAll, s.getCustomerMetricsHandler)).Methods("GET")
+
+	// Sales routes
+	salesRouter := api.PathPrefix("/sales").Subrouter()
+	salesRouter.Use(auth.AuthMiddleware)
+	salesRouter.HandleFunc("", s.getSalesHandler).Methods("GET")
+	salesRouter.HandleFunc("", requirePermission(auth.PermSalesCreate, s.createSaleHandler)).Methods("POST")
+	salesRouter.HandleFunc("/{id}", s.getSaleHandler).Methods("GET")
+	salesRouter.HandleFunc("/{id}/cancel", requirePermission(auth.PermSalesCancel, s.cancelSaleHandler)).Methods("POST")
+	salesRouter.HandleFunc("/{id}/returns", requirePermission(auth.PermSalesCancel, s.returnSaleItemsHandler)).Methods("POST")
+	salesRouter.HandleFunc("/{id}/refunds", requirePermission(auth.PermSalesCancel, s.getSaleRefundsHandler)).Methods("GET")
+
+	// Quote routes
+	quoteRouter := api.PathPrefix("/quotes").Subrouter()
+	quoteRouter.Use(auth.AuthMiddleware)
+	quoteRouter.HandleFunc("", s.getQuotesHandler).Methods("GET")
+	quoteRouter.HandleFunc("", requirePermission(auth.PermSalesCreate, s.createQuoteHandler)).Methods("POST")
+	quoteRouter.HandleFunc("/{id}", s.getQuoteHandler).Methods("GET")
+	quoteRouter.HandleFunc("/{id}/print", s.printQuoteHandler).Methods("GET")
+	quoteRouter.HandleFunc("/{id}/convert", requirePermission(auth.PermSalesCreate, s.convertQuoteHandler)).Methods("POST")
+	quoteRouter.HandleFunc("/{id}/reserve", requirePermission(auth.PermSalesCreate, s.reserveQuoteHandler)).Methods("POST")
+
+	// Stock reservation routes
+	reservationRouter := api.PathPrefix("/reservations").Subrouter()
+	reservationRouter.Use(auth.AuthMiddleware)
+	reservationRouter.Use(auth.RequirePermission(auth.PermSalesCreate))
+	reservationRouter.HandleFunc("", s.getReservationsHandler).Methods("GET")
+	reservationRouter.HandleFunc("", s.createReservationHandler).Methods("POST")
+	reservationRouter.HandleFunc("/{id}", s.getReservationHandler).Methods("GET")
+	reservationRouter.HandleFunc("/{id}", s.releaseReservationHandler).Methods("DELETE")
+
+	// Promotion routes
+	promotionRouter := api.PathPrefix("/promotions").Subrouter()
+	promotionRouter.Use(auth.AuthMiddleware)
+	promotionRouter.HandleFunc("", requirePermission(auth.PermPromotionsManage, s.getPromotionsHandler)).Methods("GET")
+	promotionRouter.HandleFunc("", requirePermission(auth.PermPromotionsManage, s.createPromotionHandler)).Methods("POST")
+	promotionRouter.HandleFunc("/report", requirePermission(auth.PermReportsView, s.getPromotionReportHandler)).Methods("GET")
+	promotionRouter.HandleFunc("/{id}", requirePermission(auth.PermPromotionsManage, s.getPromotionHandler)).Methods("GET")
+	promotionRouter.HandleFunc("/{id}", requirePermission(auth.PermPromotionsManage, s.updatePromotionHandler)).Methods("PUT")
+	promotionRouter.HandleFunc("/{id}", requirePermission(auth.PermPromotionsManage, s.deletePromotionHandler)).Methods("DELETE")
+
+	// Dashboard routes
+	dashboardRouter := api.PathPrefix("/dashboard").Subrouter()
+	dashboardRouter.Use(auth.AuthMiddleware)
+	dashboardRouter.HandleFunc("/summary", s.getDashboardSummaryHandler).Methods("GET")
+	dashboardRouter.HandleFunc("/margins/products", requirePermission(auth.PermReportsView, s.getProductMarginsHandler)).Methods("GET")
+	dashboardRouter.HandleFunc("/margins/sellers", requirePermission(auth.PermReportsView, s.getSellerMarginsHandler)).Methods("GET")
+	dashboardRouter.HandleFunc("/categories", requirePermission(auth.PermReportsView, s.getCategoryRevenueHandler)).Methods("GET")
+	dashboardRouter.HandleFunc("/bundles", requirePermission(auth.PermReportsView, s.getBundleRevenueHandler)).Methods("GET")
+
+	return r
+}
+
+// sessionIsActive is the auth.SessionChecker backed by the session repository.
+func (s *server) sessionIsActive(sessionID int64) (bool, error) {
+	return s.store.Sessions().IsActive(context.Background(), sessionID)
+}
+
+// roleHasPermission is the auth.PermissionChecker backed by the role repository.
+func (s *server) roleHasPermission(role, permission string) (bool, error) {
+	return s.store.Roles().HasPermission(context.Background(), role, permission)
+}
+
+// --- Helper Functions ---
+
+func respondWithError(w http.ResponseWriter, code int, message string) {
+	respondWithJSON(w, code, map[string]string{"error": message})
+}
+
+func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	response, _ := json.Marshal(payload)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
+
+// apiError carries the response a failed transaction should produce.
+type apiError struct {
+	code    int
+	message string
+}
+
+func (e *apiError) Error() string { return e.message }
+
+func newAPIError(code int, message string) error {
+	return &apiError{code: code, message: message}
+}
+
+// respondWithTxError writes the response for an error returned by
+// repository.Store.WithTx. Errors that are not apiErrors come from starting
+// or committing the transaction.
+func respondWithTxError(w http.ResponseWriter, err error) {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		respondWithError(w, apiErr.code, apiErr.message)
+		return
+	}
+	respondWithError(w, http.StatusInternalServerError, "Failed to commit transaction")
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// parsePagination reads the ?limit= and ?offset= query parameters.
+func parsePagination(r *http.Request) (limit, offset int, err error) {
+	limit = defaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
+
+// parseDateRange reads the ?startDate= and ?endDate= query parameters
+// (YYYY-MM-DD, both inclusive, days on the store's clock) as a [from, until)
+// range. Missing bounds are nil.
+func parseDateRange(r *http.Request) (from, until *time.Time, err error) {
+	if v := r.URL.Query().Get("startDate"); v != "" {
+		start, err := time.ParseInLocation("2006-01-02", v, storeLocation)
+		if err != nil {
+			return nil, nil, errors.New("startDate must be formatted as YYYY-MM-DD")
+		}
+		from = &start
+	}
+	if v := r.URL.Query().Get("endDate"); v != "" {
+		end, err := time.ParseInLocation("2006-01-02", v, storeLocation)
+		if err != nil {
+			return nil, nil, errors.New("endDate must be formatted as YYYY-MM-DD")
+		}
+		// endDate is inclusive, so include the whole day
+		next := end.AddDate(0, 0, 1)
+		until = &next
+	}
+	return from, until, nil
+}
+
+// pathID parses the numeric route variable name.
+func pathID(r *http.Request, name string) (int64, error) {
+	return strconv.ParseInt(mux.Vars(r)[name], 10, 64)
+}
+
+// requirePermission is a convenience function to chain auth.RequirePermission.
+func requirePermission(permission string, h http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.RequirePermission(permission)(h).ServeHTTP(w, r)
+	})
+}
+
+// selfOrPermission restricts a /users/{id} route to the user themselves and
+// to users holding the permission.
+func selfOrPermission(permission string, h http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.SelfOrPermissionMiddleware("id", permission)(h).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/auth"
+	"net/http"
+	"strings"
+)
+
+// --- Stock Movement Handlers ---
+
+// recordStockMovement appends an entry to the stock ledger. It must run in the
+// same transaction that changes the product quantity so the two never diverge.
+// variantID names the variant whose stock changed, if the product has any.
+func recordStockMovement(ctx context.Context, tx repository.Store, productID int64, variantID *int64, userID int64, movementType string, delta int, reason string, saleID *int64) error {
+	err := tx.Products().RecordMovement(ctx, &models.StockMovement{
+		ProductID:     productID,
+		VariantID:     variantID,
+		UserID:        &userID,
+		Type:          movementType,
+		QuantityDelta: delta,
+		Reason:        reason,
+		SaleID:        saleID,
+	})
+	if err != nil {
+		return newAPIError(http.StatusInternalServerError, "Failed to record stock movement")
+	}
+	return nil
+}
+
+func (s *server) getProductMovementsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	if _, err := s.store.Products().Get(r.Context(), id); err != nil {
+		respondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	movements, err := s.store.Products().ListMovements(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query stock movements")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, movements)
+}
+
+// createProductMovementHandler records a manual stock change: an adjustment
+// or purchase receipt by delta, or an inventory count by absolute quantity.
+// Receipts with a unit cost also update the product's cost price. Products
+// with variants move the stock of the variant the request names.
+func (s *server) createProductMovementHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	var req models.CreateStockMovementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		respondWithError(w, http.StatusBadRequest, "A reason is required")
+		return
+	}
+	switch req.Type {
+	case models.StockMovementAdjustment:
+		if req.Quantity == 0 {
+			respondWithError(w, http.StatusBadRequest, "Adjustment quantity cannot be zero")
+			return
+		}
+	case models.StockMovementPurchaseReceipt:
+		if req.Quantity <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Received quantity must be positive")
+			return
+		}
+	case models.StockMovementInventoryCount:
+		if req.Quantity < 0 {
+			respondWithError(w, http.StatusBadRequest, "Counted quantity cannot be negative")
+			return
+		}
+	default:
+		respondWithError(w, http.StatusBadRequest, "Movement type must be adjustment, purchase_receipt or inventory_count")
+		return
+	}
+	if req.UnitCost != nil && (req.Type != models.StockMovementPurchaseReceipt || *req.UnitCost < 0) {
+		respondWithError(w, http.StatusBadRequest, "A unit cost can only be given for purchase receipts and cannot be negative")
+		return
+	}
+	userID, _ := auth.UserID(r)
+
+	movement := models.StockMovement{
+		ProductID: id,
+		VariantID: req.VariantID,
+		UserID:    &userID,
+		Type:      req.Type,
+		Reason:    req.Reason,
+	}
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		product, err := tx.Products().GetForUpdate(r.Context(), id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusNotFound, "Product not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product")
+		}
+
+		if err := rejectBundle(r.Context(), tx, product, "move the stock of its components instead"); err != nil {
+			return err
+		}
+		if err := rejectLots(r.Context(), tx, product, "change the quantity of its lots instead"); err != nil {
+			return err
+		}
+		variant, err := productVariant(r.Context(), tx, product, req.VariantID)
+		if err != nil {
+			return err
+		}
+		// The count and the floor at zero apply to the variant's own stock
+		stock := product.Quantity
+		if variant != nil {
+			stock = variant.Quantity
+		}
+
+		movement.QuantityDelta = req.Quantity
+		if req.Type == models.StockMovementInventoryCount {
+			movement.QuantityDelta = req.Quantity - stock
+		}
+		if stock+movement.QuantityDelta < 0 {
+			return newAPIError(http.StatusBadRequest, "Stock cannot become negative")
+		}
+		if variant != nil {
+			if err := tx.Variants().AdjustStock(r.Context(), variant.ID, movement.QuantityDelta); err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to update variant stock")
+			}
+		}
+
+		if req.UnitCost != nil {
+			err = tx.Products().ReceiveStock(r.Context(), id, movement.QuantityDelta, *req.UnitCost)
+		} else {
+			err = tx.Products().AdjustStock(r.Context(), id, movement.QuantityDelta)
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to update product stock")
+		}
+		if err := tx.Products().RecordMovement(r.Context(), &movement); err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to record stock movement")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, movement)
+}
+
+// getStockReconciliationHandler lists products and variants whose quantity
+// differs from the sum of their stock ledger. An empty list means the ledger
+// is consistent.
+func (s *server) getStockReconciliationHandler(w http.ResponseWriter, r *http.Request) {
+	discrepancies, err := s.store.Products().Reconcile(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to reconcile stock")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, discrepancies)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/document"
+	"net/http"
+	"net/mail"
+	"strings"
+)
+
+// --- Supplier Handlers ---
+
+func (s *server) getSuppliersHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	suppliers, total, err := s.store.Suppliers().List(r.Context(), repository.SupplierFilter{
+		Search: strings.TrimSpace(r.URL.Query().Get("search")),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query suppliers")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.Page{Data: suppliers, Total: total, Limit: limit, Offset: offset})
+}
+
+// decodeSupplier reads and validates a supplier payload, normalizing the
+// document to bare digits. It writes the error response itself.
+func decodeSupplier(w http.ResponseWriter, r *http.Request) (models.Supplier, bool) {
+	var sup models.Supplier
+	if err := json.NewDecoder(r.Body).Decode(&sup); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return sup, false
+	}
+
+	sup.Name = strings.TrimSpace(sup.Name)
+	if sup.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "Supplier name is required")
+		return sup, false
+	}
+	if sup.Document = strings.TrimSpace(sup.Document); sup.Document != "" {
+		digits, err := document.Normalize(sup.Document)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid CNPJ or CPF")
+			return sup, false
+		}
+		sup.Document = digits
+	}
+	if sup.Email = strings.TrimSpace(sup.Email); sup.Email != "" {
+		if _, err := mail.ParseAddress(sup.Email); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid email address")
+			return sup, false
+		}
+	}
+	sup.Phone = strings.TrimSpace(sup.Phone)
+	return sup, true
+}
+
+func (s *server) createSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	sup, ok := decodeSupplier(w, r)
+	if !ok {
+		return
+	}
+
+	err := s.store.Suppliers().Create(r.Context(), &sup)
+	if errors.Is(err, repository.ErrConflict) {
+		respondWithError(w, http.StatusConflict, "A supplier with this document already exists")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create supplier")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, sup)
+}
+
+func (s *server) getSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid supplier ID")
+		return
+	}
+
+	sup, err := s.store.Suppliers().Get(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Supplier not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, sup)
+}
+
+func (s *server) updateSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid supplier ID")
+		return
+	}
+
+	sup, ok := decodeSupplier(w, r)
+	if !ok {
+		return
+	}
+	sup.ID = id
+
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		current, err := tx.Suppliers().Get(r.Context(), id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusNotFound, "Supplier not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load supplier")
+		}
+		sup.CreatedAt = current.CreatedAt
+
+		err = tx.Suppliers().Update(r.Context(), sup)
+		if errors.Is(err, repository.ErrConflict) {
+			return newAPIError(http.StatusConflict, "A supplier with this document already exists")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to update supplier")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, sup)
+}
+
+func (s *server) deleteSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid supplier ID")
+		return
+	}
+
+	err = s.store.Suppliers().Delete(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "Supplier not found")
+		return
+	}
+	if errors.Is(err, repository.ErrInUse) {
+		respondWithError(w, http.StatusConflict, "Supplier has purchase orders and cannot be deleted")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete supplier")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/auth"
+	"net/http"
+)
+
+// --- User Handlers ---
+
+func (s *server) createUserHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	_, err := s.store.Roles().Get(r.Context(), req.Role)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusBadRequest, "Unknown role")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to look up role")
+		return
+	}
+
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to hash password")
+		return
+	}
+
+	user := models.User{
+		Name:         req.Name,
+		Username:     req.Username,
+		PasswordHash: hashedPassword,
+		Role:         req.Role,
+	}
+	err = s.store.Users().Create(r.Context(), &user)
+	if errors.Is(err, repository.ErrConflict) {
+		respondWithError(w, http.StatusConflict, "Username is already taken")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, user)
+}
+
+func (s *server) getUsersHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	users, total, err := s.store.Users().List(r.Context(), repository.UserFilter{
+		Role:   r.URL.Query().Get("role"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query users")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, models.Page{Data: users, Total: total, Limit: limit, Offset: offset})
+}
+
+func (s *server) getUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	user, err := s.store.Users().Get(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+func (s *server) updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var req models.UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	user, err := s.store.Users().Get(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	// Users editing their own record may not change their login name or role
+	if !auth.Can(r, auth.PermUsersWrite) {
+		if req.Username != nil && *req.Username != user.Username {
+			respondWithError(w, http.StatusForbidden, "Only admins can change the username")
+			return
+		}
+		if req.Role != nil && *req.Role != user.Role {
+			respondWithError(w, http.StatusForbidden, "Only admins can change the role")
+			return
+		}
+	}
+
+	roleChanged := req.Role != nil && *req.Role != user.Role
+	if roleChanged {
+		_, err := s.store.Roles().Get(r.Context(), *req.Role)
+		if errors.Is(err, repository.ErrNotFound) {
+			respondWithError(w, http.StatusBadRequest, "Unknown role")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Failed to look up role")
+			return
+		}
+	}
+
+	// Fields left out of the request keep their current values
+	if req.Name != nil {
+		user.Name = *req.Name
+	}
+	if req.Username != nil {
+		user.Username = *req.Username
+	}
+	if req.Role != nil {
+		user.Role = *req.Role
+	}
+
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		err := tx.Users().Update(r.Context(), user)
+		if errors.Is(err, repository.ErrConflict) {
+			return newAPIError(http.StatusConflict, "Username is already taken")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to update user")
+		}
+
+		// Tokens carry the role, so a role change forces the user to log in again
+		if roleChanged {
+			if _, err := tx.Sessions().RevokeAllForUser(r.Context(), user.ID); err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to revoke user sessions")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+func (s *server) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	// Cut off access first, so it is gone even if the delete below fails
+	// (e.g. because the user still has sales referencing them).
+	if _, err := s.store.Sessions().RevokeAllForUser(r.Context(), id); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke user sessions")
+		return
+	}
+
+	err = s.store.Users().Delete(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "User not found or already deleted")
+		return
+	}
+	if errors.Is(err, repository.ErrInUse) {
+		respondWithError(w, http.StatusConflict, "User has sales, quotes or purchase orders and cannot be deleted")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to delete user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) revokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	revoked, err := s.store.Sessions().RevokeAllForUser(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to revoke user sessions")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]int64{"revokedSessions": revoked})
+}
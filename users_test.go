@@ -0,0 +1,87 @@
+package main
+
+import (
+	"gestor-simples-ecs/internal/models"
+	"net/http"
+	"testing"
+)
+
+func TestUserOwnership(t *testing.T) {
+	env := newTestEnv(t)
+	maria := env.createUser("maria", "vendedor")
+	joao := env.createUser("joao", "vendedor")
+	token := env.token(maria)
+
+	expectStatus(t, env.do("GET", "/api/v1/users/"+itoa(maria.ID), token, nil), http.StatusOK)
+	expectStatus(t, env.do("GET", "/api/v1/users/"+itoa(joao.ID), token, nil), http.StatusForbidden)
+	expectStatus(t, env.do("GET", "/api/v1/users", token, nil), http.StatusForbidden)
+
+	name := "Maria Silva"
+	rec := env.do("PUT", "/api/v1/users/"+itoa(maria.ID), token, models.UpdateUserRequest{Name: &name})
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[models.User](t, rec); got.Name != name || got.Role != "vendedor" {
+		t.Fatalf("unexpected user after update: %+v", got)
+	}
+
+	role := "admin"
+	rec = env.do("PUT", "/api/v1/users/"+itoa(maria.ID), token, models.UpdateUserRequest{Role: &role})
+	expectStatus(t, rec, http.StatusForbidden)
+}
+
+func TestRoleChangeRevokesSessions(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.createUser("admin", "admin")
+	maria := env.createUser("maria", "vendedor")
+	adminToken := env.token(admin)
+	mariaToken := env.token(maria)
+
+	role := "gerente"
+	rec := env.do("PUT", "/api/v1/users/"+itoa(maria.ID), adminToken, models.UpdateUserRequest{Role: &role})
+	expectStatus(t, rec, http.StatusOK)
+
+	expectStatus(t, env.do("GET", "/api/v1/users/"+itoa(maria.ID), mariaToken, nil), http.StatusUnauthorized)
+
+	unknown := "caixa"
+	rec = env.do("PUT", "/api/v1/users/"+itoa(maria.ID), adminToken, models.UpdateUserRequest{Role: &unknown})
+	expectStatus(t, rec, http.StatusBadRequest)
+}
+
+func TestListUsersFiltersByRole(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.createUser("admin", "admin")
+	env.createUser("maria", "vendedor")
+	env.createUser("joao", "vendedor")
+	env.createUser("ana", "gerente")
+
+	rec := env.do("GET", "/api/v1/users?role=vendedor&limit=1", env.token(admin), nil)
+	expectStatus(t, rec, http.StatusOK)
+	users, total := pageOf[models.User](t, rec)
+	if total != 2 || len(users) != 1 || users[0].Username != "maria" {
+		t.Fatalf("got %d of %d users: %+v", len(users), total, users)
+	}
+
+	expectStatus(t, env.do("GET", "/api/v1/users?limit=0", env.token(admin), nil), http.StatusBadRequest)
+}
+
+func TestDeleteUser(t *testing.T) {
+	env := newTestEnv(t)
+	admin := env.createUser("admin", "admin")
+	maria := env.createUser("maria", "vendedor")
+	adminToken := env.token(admin)
+	mariaToken := env.token(maria)
+	product := env.createProduct(adminToken, "Caneta", "2.50", 10)
+
+	rec := env.do("POST", "/api/v1/sales", mariaToken, models.CreateSaleRequest{
+		Items:    []models.CreateSaleItem{{ProductID: product.ID, Quantity: 1}},
+		Payments: pix(t, "2.50"),
+	})
+	expectStatus(t, rec, http.StatusCreated)
+
+	// Sellers with sales cannot be deleted, but lose access anyway
+	expectStatus(t, env.do("DELETE", "/api/v1/users/"+itoa(maria.ID), adminToken, nil), http.StatusConflict)
+	expectStatus(t, env.do("GET", "/api/v1/users/"+itoa(maria.ID), mariaToken, nil), http.StatusUnauthorized)
+
+	joao := env.createUser("joao", "vendedor")
+	expectStatus(t, env.do("DELETE", "/api/v1/users/"+itoa(joao.ID), adminToken, nil), http.StatusNoContent)
+	expectStatus(t, env.do("DELETE", "/api/v1/users/"+itoa(joao.ID), adminToken, nil), http.StatusNotFound)
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gestor-simples-ecs/internal/models"
+	"gestor-simples-ecs/internal/repository"
+	"gestor-simples-ecs/pkg/auth"
+	"net/http"
+	"strings"
+)
+
+// --- Product Variant Handlers ---
+//
+// A product with variants keeps its own quantity as the sum of theirs: every
+// change to a variant's stock changes the product by the same amount in the
+// same transaction, so reservations and reports keep working per product.
+
+// hasVariants reports whether the product is sold by variant.
+func hasVariants(ctx context.Context, tx repository.Store, productID int64) (bool, error) {
+	variants, err := tx.Variants().List(ctx, productID)
+	return len(variants) > 0, err
+}
+
+// productVariant loads the variant a sale, quote or stock movement names for
+// product. Products with variants need one and products without don't take
+// one; it returns nil for the latter.
+func productVariant(ctx context.Context, tx repository.Store, product models.Product, variantID *int64) (*models.ProductVariant, error) {
+	if variantID == nil {
+		found, err := hasVariants(ctx, tx, product.ID)
+		if err != nil {
+			return nil, newAPIError(http.StatusInternalServerError, "Failed to load product variants")
+		}
+		if found {
+			return nil, newAPIError(http.StatusBadRequest, fmt.Sprintf("Product %q has variants; choose one with variantId", product.Name))
+		}
+		return nil, nil
+	}
+
+	v, err := tx.Variants().Get(ctx, *variantID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && v.ProductID != product.ID) {
+		return nil, newAPIError(http.StatusBadRequest, fmt.Sprintf("Variant %d not found for product %q", *variantID, product.Name))
+	}
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, "Failed to load product variant")
+	}
+	return &v, nil
+}
+
+func decodeVariant(w http.ResponseWriter, r *http.Request) (models.ProductVariant, bool) {
+	var v models.ProductVariant
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return v, false
+	}
+	if v.Name = strings.TrimSpace(v.Name); v.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "Variant name is required")
+		return v, false
+	}
+	if v.Price != nil && *v.Price < 0 {
+		respondWithError(w, http.StatusBadRequest, "Price cannot be negative")
+		return v, false
+	}
+	if v.Quantity < 0 {
+		respondWithError(w, http.StatusBadRequest, "Quantity cannot be negative")
+		return v, false
+	}
+	return v, true
+}
+
+func (s *server) getProductVariantsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	if _, err := s.store.Products().Get(r.Context(), id); err != nil {
+		respondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	variants, err := s.store.Variants().List(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to query product variants")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, variants)
+}
+
+// createProductVariantHandler adds a variant and its initial stock to the
+// product. Stock already on the product belongs to no variant, so the first
+// variant can only be added once the product's quantity is zero.
+func (s *server) createProductVariantHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+
+	v, ok := decodeVariant(w, r)
+	if !ok {
+		return
+	}
+	v.ProductID = id
+	userID, _ := auth.UserID(r)
+
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		product, err := tx.Products().GetForUpdate(r.Context(), id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return newAPIError(http.StatusNotFound, "Product not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product")
+		}
+		if err := rejectBundle(r.Context(), tx, product, "bundles cannot have variants"); err != nil {
+			return err
+		}
+		if err := rejectLots(r.Context(), tx, product, "products with lots cannot have variants"); err != nil {
+			return err
+		}
+		bundles, err := tx.Bundles().ContainedIn(r.Context(), id)
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load bundles")
+		}
+		if len(bundles) > 0 {
+			return newAPIError(http.StatusConflict, "Product goes into a bundle; bundle components cannot have variants")
+		}
+		found, err := hasVariants(r.Context(), tx, id)
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product variants")
+		}
+		if !found && product.Quantity != 0 {
+			return newAPIError(http.StatusConflict, "Product has stock not assigned to any variant; bring its quantity to zero before adding variants")
+		}
+
+		err = tx.Variants().Create(r.Context(), &v)
+		if errors.Is(err, repository.ErrConflict) {
+			return newAPIError(http.StatusConflict, "Product already has a variant with this name")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to create product variant")
+		}
+
+		if v.Quantity != 0 {
+			if err := tx.Products().AdjustStock(r.Context(), id, v.Quantity); err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to update product stock")
+			}
+			if err := recordStockMovement(r.Context(), tx, id, &v.ID, userID, models.StockMovementInitial, v.Quantity, "Initial stock", nil); err != nil {
+				return err
+			}
+		}
+
+		// Respond with the variant as listed, available stock included
+		if v, err = tx.Variants().Get(r.Context(), v.ID); err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product variant")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, v)
+}
+
+func (s *server) updateProductVariantHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	variantID, err := pathID(r, "variantId")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid variant ID")
+		return
+	}
+
+	v, ok := decodeVariant(w, r)
+	if !ok {
+		return
+	}
+	v.ID, v.ProductID = variantID, id
+	userID, _ := auth.UserID(r)
+
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		// Lock the product so concurrent updates of its variants move its quantity one at a time
+		if _, err := tx.Products().GetForUpdate(r.Context(), id); err != nil {
+			return newAPIError(http.StatusNotFound, "Product not found")
+		}
+		current, err := tx.Variants().Get(r.Context(), variantID)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && current.ProductID != id) {
+			return newAPIError(http.StatusNotFound, "Variant not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product variant")
+		}
+
+		err = tx.Variants().Update(r.Context(), v)
+		if errors.Is(err, repository.ErrConflict) {
+			return newAPIError(http.StatusConflict, "Product already has a variant with this name")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to update product variant")
+		}
+
+		if delta := v.Quantity - current.Quantity; delta != 0 {
+			if err := tx.Products().AdjustStock(r.Context(), id, delta); err != nil {
+				return newAPIError(http.StatusInternalServerError, "Failed to update product stock")
+			}
+			return recordStockMovement(r.Context(), tx, id, &variantID, userID, models.StockMovementAdjustment, delta, "Quantity changed on variant update", nil)
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+}
+
+// deleteProductVariantHandler removes a variant without stock that was
+// never sold or quoted.
+func (s *server) deleteProductVariantHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid product ID")
+		return
+	}
+	variantID, err := pathID(r, "variantId")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid variant ID")
+		return
+	}
+
+	err = s.store.WithTx(r.Context(), func(tx repository.Store) error {
+		v, err := tx.Variants().Get(r.Context(), variantID)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && v.ProductID != id) {
+			return newAPIError(http.StatusNotFound, "Variant not found")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to load product variant")
+		}
+		if v.Quantity != 0 {
+			return newAPIError(http.StatusConflict, "Variant still has stock; bring its quantity to zero before deleting it")
+		}
+
+		err = tx.Variants().Delete(r.Context(), variantID)
+		if errors.Is(err, repository.ErrInUse) {
+			return newAPIError(http.StatusConflict, "Variant has sales, quotes or refunds and cannot be deleted")
+		}
+		if err != nil {
+			return newAPIError(http.StatusInternalServerError, "Failed to delete product variant")
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithTxError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"gestor-simples-ecs/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProductVariants(t *testing.T) {
+	env := newTestEnv(t)
+	adminToken := env.token(env.createUser("admin", "admin"))
+	sellerToken := env.token(env.createUser("maria", "vendedor"))
+
+	shirt := env.createProduct(adminToken, "Camiseta", "39.90", 0)
+	variantsPath := "/api/v1/products/" + itoa(shirt.ID) + "/variants"
+	large := mustParse(t, "44.90")
+
+	create := func(v models.ProductVariant) models.ProductVariant {
+		t.Helper()
+		rec := env.do("POST", variantsPath, adminToken, v)
+		expectStatus(t, rec, http.StatusCreated)
+		return decode[models.ProductVariant](t, rec)
+	}
+	medium := create(models.ProductVariant{Name: "M / Azul", Attributes: map[string]string{"size": "M", "color": "Azul"}, Quantity: 5})
+	big := create(models.ProductVariant{Name: "GG / Azul", Price: &large, Quantity: 2})
+	if medium.Quantity != 5 || medium.Available != 5 {
+		t.Fatalf("expected new variant with 5 available, got %+v", medium)
+	}
+
+	expectStatus(t, env.do("POST", variantsPath, adminToken, models.ProductVariant{Name: "m / azul"}), http.StatusConflict)
+	expectStatus(t, env.do("POST", variantsPath, sellerToken, models.ProductVariant{Name: "P / Azul"}), http.StatusForbidden)
+
+	// Purchase orders placed before the product had variants are not received into it
+	hat := env.createProduct(adminToken, "Boné", "29.90", 0)
+	supplier := decode[models.Supplier](t, env.do("POST", "/api/v1/suppliers", adminToken, map[string]string{"name": "Confecções Sul"}))
+	rec := env.do("POST", "/api/v1/purchase-orders", adminToken, models.CreatePurchaseOrderRequest{
+		SupplierID: supplier.ID,
+		Items:      []models.CreatePurchaseOrderItem{{ProductID: hat.ID, Quantity: 5, UnitCost: mustParse(t, "12.00")}},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	orderPath := "/api/v1/purchase-orders/" + itoa(decode[models.PurchaseOrder](t, rec).ID)
+	expectStatus(t, env.do("POST", "/api/v1/products/"+itoa(hat.ID)+"/variants", adminToken, models.ProductVariant{Name: "Azul"}), http.StatusCreated)
+	expectStatus(t, env.do("POST", orderPath+"/receive", adminToken, models.ReceivePurchaseOrderRequest{}), http.StatusBadRequest)
+	if p := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(hat.ID), sellerToken, nil)); p.Quantity != 0 {
+		t.Fatalf("product quantity after refused receipt = %d, want 0", p.Quantity)
+	}
+
+	// Products with variants are ordered and received by variant
+	sock := env.createProduct(adminToken, "Meia", "12.90", 0)
+	sockPath := "/api/v1/products/" + itoa(sock.ID) + "/variants"
+	white := decode[models.ProductVariant](t, env.do("POST", sockPath, adminToken, models.ProductVariant{Name: "Branca", Quantity: 1}))
+	black := decode[models.ProductVariant](t, env.do("POST", sockPath, adminToken, models.ProductVariant{Name: "Preta", Quantity: 2}))
+	order := func(items ...models.CreatePurchaseOrderItem) *httptest.ResponseRecorder {
+		return env.do("POST", "/api/v1/purchase-orders", adminToken, models.CreatePurchaseOrderRequest{SupplierID: supplier.ID, Items: items})
+	}
+	expectStatus(t, order(models.CreatePurchaseOrderItem{ProductID: sock.ID, Quantity: 4, UnitCost: mustParse(t, "5.00")}), http.StatusBadRequest)
+	expectStatus(t, order(models.CreatePurchaseOrderItem{ProductID: sock.ID, VariantID: &medium.ID, Quantity: 4, UnitCost: mustParse(t, "5.00")}), http.StatusBadRequest)
+	rec = order(
+		models.CreatePurchaseOrderItem{ProductID: sock.ID, VariantID: &white.ID, Quantity: 4, UnitCost: mustParse(t, "5.00")},
+		models.CreatePurchaseOrderItem{ProductID: sock.ID, VariantID: &black.ID, Quantity: 2, UnitCost: mustParse(t, "6.00")},
+	)
+	expectStatus(t, rec, http.StatusCreated)
+	sockOrder := decode[models.PurchaseOrder](t, rec)
+	if item := sockOrder.Items[1]; item.VariantID == nil || *item.VariantID != black.ID || item.VariantName != "Preta" {
+		t.Fatalf("unexpected purchase order item: %+v", item)
+	}
+	orderPath = "/api/v1/purchase-orders/" + itoa(sockOrder.ID)
+	expectStatus(t, env.do("POST", orderPath+"/receive", adminToken, models.ReceivePurchaseOrderRequest{
+		Items: []models.ReceivePurchaseOrderItem{{ProductID: sock.ID, Quantity: 1}},
+	}), http.StatusBadRequest)
+	expectStatus(t, env.do("POST", orderPath+"/receive", adminToken, models.ReceivePurchaseOrderRequest{
+		Items: []models.ReceivePurchaseOrderItem{{ProductID: sock.ID, VariantID: &black.ID, Quantity: 1}},
+	}), http.StatusOK)
+	if got := decode[[]models.ProductVariant](t, env.do("GET", sockPath, sellerToken, nil)); got[0].Quantity != 1 || got[1].Quantity != 3 {
+		t.Fatalf("variant stock after receipt = %+v", got)
+	}
+	if p := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(sock.ID), sellerToken, nil)); p.Quantity != 4 {
+		t.Fatalf("product quantity after receipt = %d, want 4", p.Quantity)
+	}
+	incoming := decode[[]models.IncomingStock](t, env.do("GET", "/api/v1/purchase-orders/incoming?productId="+itoa(sock.ID), adminToken, nil))
+	if len(incoming) != 2 || incoming[0].VariantName != "Branca" || incoming[0].Outstanding != 4 || incoming[1].Outstanding != 1 {
+		t.Fatalf("unexpected incoming stock: %+v", incoming)
+	}
+	expectStatus(t, env.do("DELETE", sockPath+"/"+itoa(white.ID), adminToken, nil), http.StatusConflict)
+	if discrepancies := decode[[]models.StockDiscrepancy](t, env.do("GET", "/api/v1/products/reconciliation", adminToken, nil)); len(discrepancies) != 0 {
+		t.Fatalf("unexpected discrepancies: %+v", discrepancies)
+	}
+
+	// Stock outside any variant has to go before the first variant
+	mug := env.createProduct(adminToken, "Caneca", "25.00", 3)
+	expectStatus(t, env.do("POST", "/api/v1/products/"+itoa(mug.ID)+"/variants", adminToken, models.ProductVariant{Name: "Branca"}), http.StatusConflict)
+
+	// Units sold before the product had variants can't be returned to it
+	keyring := env.createProduct(adminToken, "Chaveiro", "9.90", 1)
+	rec = env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+		Items:    []models.CreateSaleItem{{ProductID: keyring.ID, Quantity: 1}},
+		Payments: pix(t, "9.90"),
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	keyringSale := "/api/v1/sales/" + itoa(decode[models.CreateSaleResponse](t, rec).SaleID)
+	expectStatus(t, env.do("POST", "/api/v1/products/"+itoa(keyring.ID)+"/variants", adminToken, models.ProductVariant{Name: "Prata"}), http.StatusCreated)
+	expectStatus(t, env.do("POST", keyringSale+"/cancel", adminToken, models.CancelSaleRequest{Reason: "Desistiu"}), http.StatusConflict)
+
+	// The product quantity is the sum of its variants
+	products, _ := pageOf[models.Product](t, env.do("GET", "/api/v1/products?search=Camiseta", sellerToken, nil))
+	if len(products) != 1 || products[0].Quantity != 7 || len(products[0].Variants) != 2 || products[0].Variants[0].Attributes["size"] != "M" {
+		t.Fatalf("products = %+v", products)
+	}
+	shirt.Quantity = 10
+	expectStatus(t, env.do("PUT", "/api/v1/products/"+itoa(shirt.ID), adminToken, shirt), http.StatusBadRequest)
+
+	// Sales need a variant, charge its price and take its stock
+	sale := func(variantID *int64, quantity int, total string) int {
+		t.Helper()
+		rec := env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+			Items:    []models.CreateSaleItem{{ProductID: shirt.ID, VariantID: variantID, Quantity: quantity}},
+			Payments: pix(t, total),
+		})
+		return rec.Code
+	}
+	if code := sale(nil, 1, "39.90"); code != http.StatusBadRequest {
+		t.Fatalf("sale without variant = %d, want 400", code)
+	}
+	if code := sale(&big.ID, 3, "134.70"); code != http.StatusBadRequest {
+		t.Fatalf("sale over variant stock = %d, want 400", code)
+	}
+	rec = env.do("POST", "/api/v1/sales", sellerToken, models.CreateSaleRequest{
+		Items:    []models.CreateSaleItem{{ProductID: shirt.ID, VariantID: &big.ID, Quantity: 2}, {ProductID: shirt.ID, VariantID: &medium.ID, Quantity: 1}},
+		Payments: pix(t, "129.70"),
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	salePath := "/api/v1/sales/" + itoa(decode[models.CreateSaleResponse](t, rec).SaleID)
+
+	saleDetail := decode[models.Sale](t, env.do("GET", salePath, sellerToken, nil))
+	if item := saleDetail.Items[0]; item.VariantID == nil || *item.VariantID != big.ID || item.VariantName != "GG / Azul" || item.UnitPrice != large {
+		t.Fatalf("sale item = %+v", item)
+	}
+
+	// Returns put the units back in the variant they were sold from
+	rec = env.do("POST", salePath+"/returns", adminToken, models.ReturnSaleItemsRequest{
+		Reason: "Trocou", Items: []models.SaleItem{{ProductID: shirt.ID, VariantID: &medium.ID, Quantity: 2}},
+	})
+	expectStatus(t, rec, http.StatusBadRequest)
+	rec = env.do("POST", salePath+"/returns", adminToken, models.ReturnSaleItemsRequest{
+		Reason: "Trocou", Items: []models.SaleItem{{ProductID: shirt.ID, VariantID: &big.ID, Quantity: 1}},
+	})
+	expectStatus(t, rec, http.StatusCreated)
+
+	variants := decode[[]models.ProductVariant](t, env.do("GET", variantsPath, sellerToken, nil))
+	if len(variants) != 2 || variants[0].Quantity != 4 || variants[1].Quantity != 1 {
+		t.Fatalf("variants after sale and return = %+v", variants)
+	}
+	if p := decode[models.Product](t, env.do("GET", "/api/v1/products/"+itoa(shirt.ID), sellerToken, nil)); p.Quantity != 5 {
+		t.Fatalf("product quantity = %d, want 5", p.Quantity)
+	}
+
+	// Inventory counts apply to the variant named
+	rec = env.do("POST", "/api/v1/products/"+itoa(shirt.ID)+"/movements", adminToken, models.CreateStockMovementRequest{
+		Type: models.StockMovementInventoryCount, Quantity: 6, Reason: "Balanço",
+	})
+	expectStatus(t, rec, http.StatusBadRequest)
+	rec = env.do("POST", "/api/v1/products/"+itoa(shirt.ID)+"/movements", adminToken, models.CreateStockMovementRequest{
+		Type: models.StockMovementInventoryCount, VariantID: &medium.ID, Quantity: 6, Reason: "Balanço",
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	if m := decode[models.StockMovement](t, rec); m.QuantityDelta != 2 {
+		t.Fatalf("count delta = %d, want 2", m.QuantityDelta)
+	}
+
+	rec = env.do("GET", "/api/v1/products/reconciliation", adminToken, nil)
+	expectStatus(t, rec, http.StatusOK)
+	if discrepancies := decode[[]models.StockDiscrepancy](t, rec); len(discrepancies) != 0 {
+		t.Fatalf("unexpected discrepancies: %+v", discrepancies)
+	}
+
+	// Variants with stock or sales stay
+	expectStatus(t, env.do("DELETE", variantsPath+"/"+itoa(big.ID), adminToken, nil), http.StatusConflict)
+	big.Quantity = 0
+	expectStatus(t, env.do("PUT", variantsPath+"/"+itoa(big.ID), adminToken, big), http.StatusOK)
+	expectStatus(t, env.do("DELETE", variantsPath+"/"+itoa(big.ID), adminToken, nil), http.StatusConflict)
+	unsold := create(models.ProductVariant{Name: "P / Azul"})
+	expectStatus(t, env.do("DELETE", variantsPath+"/"+itoa(unsold.ID), adminToken, nil), http.StatusNoContent)
+}